@@ -0,0 +1,133 @@
+package customer_service
+
+import "time"
+
+// maxAuditEntries 审计日志保留的最大条数，超出后丢弃最早的记录
+const maxAuditEntries = 1024
+
+// 审计操作类型
+const (
+	AuditAdminClose      = "admin_close"
+	AuditAdminTransfer   = "admin_transfer"
+	AuditUserDisconnect  = "user_disconnect"
+	AuditStaffDisconnect = "staff_disconnect"
+)
+
+// AuditEntry 管理员操作和断线的审计记录
+type AuditEntry struct {
+	Action    string    `json:"action"`
+	ActorID   string    `json:"actor_id"`
+	SessionID string    `json:"session_id"`
+	TargetID  string    `json:"target_id,omitempty"` // 转接的目标客服
+	Reason    string    `json:"reason,omitempty"`
+	CreateAt  time.Time `json:"create_at"`
+}
+
+// TransferRecord 会话转接记录
+type TransferRecord struct {
+	FromStaffID string    `json:"from_staff_id"`
+	ToStaffID   string    `json:"to_staff_id"`
+	ByID        string    `json:"by_id,omitempty"` // 发起转接的管理员，客服自行转接时为空
+	Reason      string    `json:"reason,omitempty"`
+	CreateAt    time.Time `json:"create_at"`
+}
+
+// AdminCloseSession 管理员强制关闭会话，不要求管理员是会话参与者，操作记入审计日志
+func (cs *CustomerService) AdminCloseSession(sessionID, adminID, reason string) error {
+	if adminID == "" {
+		return ErrInvalidOperation
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if session.Status == SessionStatusClosed {
+		return ErrSessionClosed
+	}
+
+	event := SessionEvent{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		StaffID:   session.StaffID,
+		Reason:    ReasonClosedByAdmin,
+	}
+	if err := cs.closeSessionLocked(session, ReasonClosedByAdmin); err != nil {
+		return err
+	}
+	cs.recordAuditLocked(AuditEntry{
+		Action:    AuditAdminClose,
+		ActorID:   adminID,
+		SessionID: sessionID,
+		Reason:    reason,
+	})
+	cs.emit(EventSessionClosed, event)
+	return nil
+}
+
+// AdminTransfer 管理员强制转接会话，不要求管理员是会话参与者，操作记入审计日志和会话的转接记录
+func (cs *CustomerService) AdminTransfer(sessionID, toStaffID, adminID, reason string) error {
+	if adminID == "" {
+		return ErrInvalidOperation
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if session.Status == SessionStatusClosed {
+		return ErrSessionClosed
+	}
+
+	if err := cs.transferLocked(session, toStaffID, adminID, reason); err != nil {
+		return err
+	}
+	cs.recordAuditLocked(AuditEntry{
+		Action:    AuditAdminTransfer,
+		ActorID:   adminID,
+		SessionID: sessionID,
+		TargetID:  toStaffID,
+		Reason:    reason,
+	})
+	return nil
+}
+
+// recordAuditLocked 追加审计记录，调用方需持有cs.mu
+func (cs *CustomerService) recordAuditLocked(entry AuditEntry) {
+	entry.CreateAt = cs.now()
+	if len(cs.audit) >= maxAuditEntries {
+		copy(cs.audit, cs.audit[1:])
+		cs.audit = cs.audit[:len(cs.audit)-1]
+	}
+	cs.audit = append(cs.audit, entry)
+}
+
+// AuditLog 获取审计日志副本，按时间顺序排列
+func (cs *CustomerService) AuditLog() []AuditEntry {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	entries := make([]AuditEntry, len(cs.audit))
+	copy(entries, cs.audit)
+	return entries
+}
+
+// TransferHistory 获取会话转接记录副本
+func (cs *CustomerService) TransferHistory(sessionID string) ([]TransferRecord, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	records := make([]TransferRecord, len(session.Transfers))
+	copy(records, session.Transfers)
+	return records, nil
+}
@@ -0,0 +1,76 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_AdminCloseSession(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 非参与者不能通过普通接口关闭会话
+	assert.ErrorIs(t, cs.CloseSession(session.ID, "admin1"), ErrInvalidOperation)
+
+	assert.ErrorIs(t, cs.AdminCloseSession(session.ID, "", "abuse"), ErrInvalidOperation)
+	assert.NoError(t, cs.AdminCloseSession(session.ID, "admin1", "abuse"))
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.ErrorIs(t, cs.AdminCloseSession(session.ID, "admin1", "abuse"), ErrSessionClosed)
+	assert.ErrorIs(t, cs.AdminCloseSession("missing", "admin1", "abuse"), ErrSessionNotFound)
+
+	assert.Equal(t, EventSessionClosed, <-types)
+	event := <-events
+	assert.Equal(t, ReasonClosedByAdmin, event.Reason)
+	assert.Equal(t, "staff1", event.StaffID)
+
+	audit := cs.AuditLog()
+	if assert.Len(t, audit, 1) {
+		assert.Equal(t, AuditAdminClose, audit[0].Action)
+		assert.Equal(t, "admin1", audit[0].ActorID)
+		assert.Equal(t, session.ID, audit[0].SessionID)
+		assert.Equal(t, "abuse", audit[0].Reason)
+		assert.False(t, audit[0].CreateAt.IsZero())
+	}
+}
+
+func TestCustomerService_AdminTransfer(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	_, err := cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, cs.AdminTransfer(session.ID, "missing", "admin1", "rebalance"), ErrStaffNotFound)
+	assert.NoError(t, cs.AdminTransfer(session.ID, "staff2", "admin1", "rebalance"))
+	assert.Equal(t, "staff2", session.StaffID)
+	assert.Contains(t, cs.GetStaff("staff2").Sessions, session.ID)
+	assert.NotContains(t, cs.GetStaff("staff1").Sessions, session.ID)
+
+	// 客服自行转接也记入转接记录，但不进审计日志
+	assert.NoError(t, cs.TransferSession(session.ID, "staff1"))
+
+	history, err := cs.TransferHistory(session.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "staff1", history[0].FromStaffID)
+		assert.Equal(t, "staff2", history[0].ToStaffID)
+		assert.Equal(t, "admin1", history[0].ByID)
+		assert.Equal(t, "rebalance", history[0].Reason)
+		assert.Equal(t, "staff2", history[1].FromStaffID)
+		assert.Empty(t, history[1].ByID)
+	}
+
+	audit := cs.AuditLog()
+	if assert.Len(t, audit, 1) {
+		assert.Equal(t, AuditAdminTransfer, audit[0].Action)
+		assert.Equal(t, "staff2", audit[0].TargetID)
+	}
+
+	assert.NoError(t, cs.AdminCloseSession(session.ID, "admin1", ""))
+	assert.ErrorIs(t, cs.AdminTransfer(session.ID, "staff2", "admin1", ""), ErrSessionClosed)
+}
@@ -0,0 +1,115 @@
+package customer_service
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultHandleTime 组内还没有结束的会话时假定的单个会话处理时长
+const defaultHandleTime = 5 * time.Minute
+
+// handleTimeSmoothing 会话处理时长滑动平均的权重分母，每个新样本占1/handleTimeSmoothing
+const handleTimeSmoothing = 8
+
+// WithMaxQueueWait 设置可接受的预计排队时长，新用户排队时预计等待超过d则返回ErrWaitTooLong，
+// 如实告知等待过久而不是让用户无限期等待，已在排队的用户不受影响。小于等于0时不限
+func WithMaxQueueWait(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.maxQueueWait = d
+	}
+}
+
+// EstimatedWait 估算新用户现在加入组内队列需要等待的时长。组内没有可接待的客服时返回ok为false，表示无法估计
+func (cs *CustomerService) EstimatedWait(groupID string) (wait time.Duration, ok bool, err error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return 0, false, ErrGroupNotFound
+	}
+	wait, ok = cs.estimatedWaitLocked(group)
+	return wait, ok, nil
+}
+
+// estimatedWaitLocked 按排队人数、空闲名额和平均处理时长估算新用户的等待时长：
+// 空闲名额足以接入时为0，否则为超出空闲名额的人数乘以平均处理时长再按总名额分摊。
+// 有客服不限会话数时总能立即接入；没有可接待的客服时ok为false，调用方需持有cs.mu
+func (cs *CustomerService) estimatedWaitLocked(group *CSGroup) (time.Duration, bool) {
+	slots, free := 0, 0
+	for _, staff := range group.Members {
+		if staff.Status != UserStatusOnline || staff.unresponsive {
+			continue
+		}
+		if staff.MaxSessions <= 0 {
+			return 0, true
+		}
+		slots += staff.MaxSessions
+		if used := len(staff.Sessions) + cs.pendingOffersLocked(staff.ID); used < staff.MaxSessions {
+			free += staff.MaxSessions - used
+		}
+	}
+
+	// 已收到邀请的排队用户占用的名额已从空闲名额中扣除，不再计入前面的人数
+	ahead := 0
+	for _, userID := range cs.queues[group.ID] {
+		if _, offered := cs.userOffers[userID]; !offered {
+			ahead++
+		}
+	}
+	backlog := ahead + 1 - free
+	if backlog <= 0 {
+		return 0, true
+	}
+	if slots == 0 {
+		return 0, false
+	}
+	handle := group.handleTime
+	if handle == 0 {
+		handle = defaultHandleTime
+	}
+	return time.Duration(backlog) * handle / time.Duration(slots), true
+}
+
+// pendingOffersLocked 统计发给客服的待处理邀请数，调用方需持有cs.mu
+func (cs *CustomerService) pendingOffersLocked(staffID string) int {
+	pending := 0
+	for _, offer := range cs.offers {
+		if offer.StaffID == staffID {
+			pending++
+		}
+	}
+	return pending
+}
+
+// admitLocked 预计等待超过可接受时长时拒绝新用户排队，没有可接待的客服时同样拒绝，调用方需持有cs.mu
+func (cs *CustomerService) admitLocked(group *CSGroup) error {
+	if cs.maxQueueWait <= 0 {
+		return nil
+	}
+	wait, ok := cs.estimatedWaitLocked(group)
+	if !ok {
+		return ErrWaitTooLong
+	}
+	if wait > cs.maxQueueWait {
+		return NewServiceError(CodeWaitTooLong, fmt.Sprintf("estimated wait %s too long, please try again later", wait.Round(time.Second)))
+	}
+	return nil
+}
+
+// recordHandleTimeLocked 会话结束时将其时长计入所属组的平均处理时长，未分配过客服的会话不计，调用方需持有cs.mu
+func (cs *CustomerService) recordHandleTimeLocked(session *Session) {
+	group, exists := cs.groups[session.GroupID]
+	if !exists || session.StaffID == "" {
+		return
+	}
+	sample := session.UpdateAt.Sub(session.CreateAt)
+	if sample <= 0 {
+		return
+	}
+	if group.handleTime == 0 {
+		group.handleTime = sample
+		return
+	}
+	group.handleTime += (sample - group.handleTime) / handleTimeSmoothing
+}
@@ -0,0 +1,88 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_MaxQueueWait(t *testing.T) {
+	cs := NewCustomerService(WithMaxQueueWait(12 * time.Minute))
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	if _, err := cs.ConnectStaff("staff1", "TestStaff", "group1", nil); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, cs.SetStaffCapacity("staff1", 1))
+	cs.SetAutoAccept("staff1", true)
+	for _, id := range []string{"user1", "user2", "user3", "user4"} {
+		cs.ConnectUser(id, "TestUser", nil)
+	}
+
+	// 空闲名额可以立即接入
+	wait, ok, err := cs.EstimatedWait("group1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Zero(t, wait)
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+	assert.NotEmpty(t, cs.GetUser("user1").SessionID)
+
+	// 名额已满后每多一人排队，预计等待增加一个默认处理时长
+	assert.NoError(t, cs.EnqueueUser("user2", "group1"))
+	assert.NoError(t, cs.EnqueueUser("user3", "group1"))
+	wait, _, _ = cs.EstimatedWait("group1")
+	assert.Equal(t, 3*defaultHandleTime, wait)
+
+	err = cs.EnqueueUser("user4", "group1")
+	assert.ErrorIs(t, err, ErrWaitTooLong)
+	assert.Contains(t, err.Error(), "15m0s")
+	_, queued := cs.waiting["user4"]
+	assert.False(t, queued)
+
+	// 增加名额后预计等待缩短，重新允许排队
+	assert.NoError(t, cs.SetStaffCapacity("staff1", 3))
+	assert.NoError(t, cs.EnqueueUser("user4", "group1"))
+}
+
+func TestCustomerService_MaxQueueWaitNoStaff(t *testing.T) {
+	cs := NewCustomerService(WithMaxQueueWait(time.Minute))
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+
+	// 没有可接待的客服时无法估计，视为等待过久
+	_, ok, err := cs.EstimatedWait("group1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.ErrorIs(t, cs.EnqueueUser("user1", "group1"), ErrWaitTooLong)
+
+	_, _, err = cs.EstimatedWait("missing")
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+}
+
+func TestCustomerService_HandleTimeAverage(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	assert.NoError(t, cs.SetStaffCapacity("staff1", 1))
+	wait, _, _ := cs.EstimatedWait("group1")
+	assert.Equal(t, defaultHandleTime, wait)
+
+	// 首个结束的会话时长作为平均处理时长的初值
+	clock.Advance(time.Minute)
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+	assert.Equal(t, time.Minute, cs.groups["group1"].handleTime)
+
+	// 后续样本按滑动平均计入
+	session = createTestSession(t, cs, "user2", "staff1")
+	clock.Advance(9 * time.Minute)
+	wait, _, _ = cs.EstimatedWait("group1")
+	assert.Equal(t, time.Minute, wait)
+	assert.NoError(t, cs.CloseSession(session.ID, "user2"))
+	assert.Equal(t, 2*time.Minute, cs.groups["group1"].handleTime)
+}
@@ -0,0 +1,111 @@
+package customer_service
+
+import (
+	"log"
+	"strings"
+)
+
+// Attachment 消息附件，文件本身由客户端上传到存储服务，消息只携带元信息和下载地址
+type Attachment struct {
+	Name     string `json:"name"`
+	MIMEType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	URL      string `json:"url"`
+
+	ThumbnailURL string `json:"thumbnail_url,omitempty"` // 图片预览地址，由ThumbnailGenerator生成
+}
+
+// AttachmentScanner 附件检查，在附件消息保存和转发前调用，返回错误时拒绝该附件。
+// 可用于接入杀毒扫描或MIME类型白名单
+type AttachmentScanner interface {
+	Scan(att Attachment) error
+}
+
+// passThroughScanner 默认的附件检查，放行全部附件
+type passThroughScanner struct{}
+
+func (passThroughScanner) Scan(Attachment) error { return nil }
+
+// WithAttachmentScanner 设置附件检查，为空时放行全部附件
+func WithAttachmentScanner(scanner AttachmentScanner) Option {
+	return func(cs *CustomerService) {
+		if scanner == nil {
+			scanner = passThroughScanner{}
+		}
+		cs.scanner = scanner
+	}
+}
+
+// ThumbnailGenerator 为图片附件生成缩略图并返回预览地址，可用于接入图片处理服务
+type ThumbnailGenerator interface {
+	Thumbnail(att Attachment) (string, error)
+}
+
+// WithThumbnailGenerator 设置图片附件的缩略图生成，为空时不生成
+func WithThumbnailGenerator(gen ThumbnailGenerator) Option {
+	return func(cs *CustomerService) {
+		cs.thumbnails = gen
+	}
+}
+
+// thumbnail 为图片附件生成预览地址，未设置生成器或不是图片时返回空。
+// 生成失败只记录日志，不影响消息发送
+func (cs *CustomerService) thumbnail(att Attachment) string {
+	if cs.thumbnails == nil || !isImage(att) {
+		return ""
+	}
+	url, err := cs.thumbnails.Thumbnail(att)
+	if err != nil {
+		log.Printf("Error generating thumbnail for %s: %v", att.URL, err)
+		return ""
+	}
+	return url
+}
+
+// isImage 判断附件是否为图片
+func isImage(att Attachment) bool {
+	return strings.HasPrefix(att.MIMEType, "image/")
+}
+
+// SendAttachment 在会话中发送附件消息，附件未通过检查时返回ErrAttachmentRejected，错误描述为拒绝原因。
+// 图片类型的附件作为图片消息发送并附带缩略图地址，其余作为文件消息，消息内容为附件名称。
+// 缩略图地址只由服务端生成，客户端传入的值被忽略
+func (cs *CustomerService) SendAttachment(sessionID, fromID string, att Attachment) (*Message, error) {
+	if att.URL == "" {
+		return nil, ErrEmptyContent
+	}
+	// 扫描可能较慢，不在持锁期间进行
+	if err := cs.scanner.Scan(att); err != nil {
+		return nil, NewServiceError(CodeAttachmentRejected, "attachment rejected: "+err.Error())
+	}
+	// 缩略图生成同样不在持锁期间进行
+	att.ThumbnailURL = cs.thumbnail(att)
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	msgType := MessageTypeFile
+	if isImage(att) {
+		msgType = MessageTypeImage
+	}
+	name := att.Name
+	if strings.TrimSpace(name) == "" {
+		name = att.URL
+	}
+
+	msg, err := cs.newMessageTo(session, fromID, defaultRecipient(session, fromID), name, msgType)
+	if err != nil {
+		return nil, err
+	}
+	msg.Attachment = &att
+	cs.appendMessageLocked(session, msg)
+	session.UpdateAt = cs.now()
+	cs.persistMessages(msg)
+	cs.evictMessagesLocked(session)
+	cs.publishMessageLocked(msg)
+	return msg, nil
+}
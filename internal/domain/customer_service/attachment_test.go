@@ -0,0 +1,99 @@
+package customer_service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mimeWhitelist 只放行白名单内MIME类型的附件检查
+type mimeWhitelist map[string]bool
+
+func (w mimeWhitelist) Scan(att Attachment) error {
+	if !w[att.MIMEType] {
+		return errors.New("mime type " + att.MIMEType + " not allowed")
+	}
+	return nil
+}
+
+func TestCustomerService_SendAttachment(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 默认放行全部附件，图片作为图片消息发送
+	msg, err := cs.SendAttachment(session.ID, "user1", Attachment{Name: "a.png", MIMEType: "image/png", Size: 10, URL: "https://cdn/a.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, MessageTypeImage, msg.Type)
+	assert.Equal(t, "a.png", msg.Content)
+	assert.Equal(t, "staff1", msg.ToID)
+	assert.Equal(t, "https://cdn/a.png", msg.Attachment.URL)
+
+	msg, err = cs.SendAttachment(session.ID, "staff1", Attachment{MIMEType: "application/pdf", URL: "https://cdn/b.pdf"})
+	assert.NoError(t, err)
+	assert.Equal(t, MessageTypeFile, msg.Type)
+	assert.Equal(t, "https://cdn/b.pdf", msg.Content)
+
+	_, err = cs.SendAttachment(session.ID, "user1", Attachment{Name: "empty"})
+	assert.ErrorIs(t, err, ErrEmptyContent)
+	_, err = cs.SendAttachment("missing", "user1", Attachment{URL: "https://cdn/c"})
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestCustomerService_AttachmentScanner(t *testing.T) {
+	cs := NewCustomerService(WithAttachmentScanner(mimeWhitelist{"image/png": true}))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	_, err := cs.SendAttachment(session.ID, "user1", Attachment{Name: "a.png", MIMEType: "image/png", URL: "https://cdn/a.png"})
+	assert.NoError(t, err)
+
+	// 不在白名单内的附件被拒绝，不保存为消息
+	_, err = cs.SendAttachment(session.ID, "user1", Attachment{Name: "run.exe", MIMEType: "application/x-msdownload", URL: "https://cdn/run.exe"})
+	assert.ErrorIs(t, err, ErrAttachmentRejected)
+	assert.Contains(t, err.Error(), "application/x-msdownload")
+	assert.Len(t, session.Messages, 1)
+}
+
+// thumbnailFunc 以函数实现的缩略图生成
+type thumbnailFunc func(att Attachment) (string, error)
+
+func (f thumbnailFunc) Thumbnail(att Attachment) (string, error) { return f(att) }
+
+func TestCustomerService_AttachmentThumbnail(t *testing.T) {
+	var calls int
+	gen := thumbnailFunc(func(att Attachment) (string, error) {
+		calls++
+		return att.URL + "?w=128", nil
+	})
+	cs := NewCustomerService(WithThumbnailGenerator(gen))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	msg, err := cs.SendAttachment(session.ID, "user1", Attachment{Name: "a.png", MIMEType: "image/png", URL: "https://cdn/a.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cdn/a.png?w=128", msg.Attachment.ThumbnailURL)
+
+	// 非图片不生成缩略图，客户端传入的缩略图地址被忽略
+	msg, err = cs.SendAttachment(session.ID, "user1", Attachment{MIMEType: "application/pdf", URL: "https://cdn/b.pdf", ThumbnailURL: "https://evil/b.png"})
+	assert.NoError(t, err)
+	assert.Empty(t, msg.Attachment.ThumbnailURL)
+	assert.Equal(t, 1, calls)
+}
+
+func TestCustomerService_AttachmentThumbnailError(t *testing.T) {
+	gen := thumbnailFunc(func(Attachment) (string, error) {
+		return "", errors.New("image service unavailable")
+	})
+	cs := NewCustomerService(WithThumbnailGenerator(gen))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 生成失败不影响消息发送
+	msg, err := cs.SendAttachment(session.ID, "user1", Attachment{Name: "a.png", MIMEType: "image/png", URL: "https://cdn/a.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, MessageTypeImage, msg.Type)
+	assert.Empty(t, msg.Attachment.ThumbnailURL)
+	assert.Equal(t, msg, session.LastMessage)
+}
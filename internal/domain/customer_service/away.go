@@ -0,0 +1,71 @@
+package customer_service
+
+import (
+	"sort"
+	"time"
+)
+
+// WithAutoTransferOnAway 客服设为离开时仍有进行中的会话，等待d后将会话转给组内其他在线客服，
+// 无人可接时重新排队；0表示不自动转接
+func WithAutoTransferOnAway(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.autoTransferOnAway = d
+	}
+}
+
+// startAwayTimerLocked 客服进入离开状态后开始计时，宽限期内恢复在线则取消转接，调用方需持有cs.mu
+func (cs *CustomerService) startAwayTimerLocked(staff *CSStaff) {
+	stopAwayTimerLocked(staff)
+	if cs.autoTransferOnAway <= 0 || len(staff.Sessions) == 0 {
+		return
+	}
+
+	staffID := staff.ID
+	staff.awayTimer = cs.afterFunc(cs.autoTransferOnAway, func() {
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		// 客服可能已断开并重新连接，只处理仍处于离开状态的同一客服
+		if current, exists := cs.staffs[staffID]; exists && current == staff && staff.Status == UserStatusAway {
+			staff.awayTimer = nil
+			cs.transferAwaySessionsLocked(staff)
+		}
+	})
+}
+
+// stopAwayTimerLocked 取消离开状态的转接计时，调用方需持有cs.mu
+func stopAwayTimerLocked(staff *CSStaff) {
+	if staff.awayTimer != nil {
+		staff.awayTimer.Stop()
+		staff.awayTimer = nil
+	}
+}
+
+// transferAwaySessionsLocked 将离开客服的进行中会话转给组内其他客服，无人可接时重新排队，调用方需持有cs.mu
+func (cs *CustomerService) transferAwaySessionsLocked(staff *CSStaff) {
+	sessionIDs := make([]string, 0, len(staff.Sessions))
+	for id, session := range staff.Sessions {
+		if session.Status == SessionStatusActive {
+			sessionIDs = append(sessionIDs, id)
+		}
+	}
+	sort.Strings(sessionIDs)
+
+	for _, id := range sessionIDs {
+		session := staff.Sessions[id]
+		event := SessionEvent{
+			SessionID:   session.ID,
+			UserID:      session.UserID,
+			StaffID:     session.StaffID,
+			PrevStaffID: staff.ID,
+			Reason:      ReasonStaffAway,
+		}
+		if target := cs.pickStaffLocked(session.GroupID, session.Subject, map[string]bool{staff.ID: true}); target != nil {
+			if cs.transferLocked(session, target.ID, "", ReasonStaffAway) == nil {
+				event.StaffID = target.ID
+				cs.emit(EventSessionTransferred, event)
+			}
+		} else if cs.requeueSessionLocked(session, ReasonStaffAway) == nil {
+			cs.emit(EventSessionRequeued, event)
+		}
+	}
+}
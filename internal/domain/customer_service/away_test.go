@@ -0,0 +1,97 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_AutoTransferOnAway(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithAutoTransferOnAway(time.Minute))
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	_, err := cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusAway))
+	clock.Advance(time.Minute)
+
+	// 宽限期结束后会话转给同组在线的同事
+	select {
+	case eventType := <-types:
+		assert.Equal(t, EventSessionTransferred, eventType)
+	case <-time.After(time.Second):
+		t.Fatal("session was not transferred")
+	}
+	event := <-events
+	assert.Equal(t, session.ID, event.SessionID)
+	assert.Equal(t, "staff1", event.PrevStaffID)
+	assert.Equal(t, "staff2", event.StaffID)
+	assert.Equal(t, ReasonStaffAway, event.Reason)
+
+	cs.mu.RLock()
+	assert.Equal(t, "staff2", session.StaffID)
+	assert.NotContains(t, cs.staffs["staff1"].Sessions, session.ID)
+	assert.Contains(t, cs.staffs["staff2"].Sessions, session.ID)
+	cs.mu.RUnlock()
+
+	history, err := cs.TransferHistory(session.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, ReasonStaffAway, history[0].Reason)
+	}
+}
+
+func TestCustomerService_AutoTransferOnAwayRequeue(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithAutoTransferOnAway(time.Minute))
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusAway))
+	clock.Advance(time.Minute)
+
+	// 组内没有其他客服时重新排队
+	select {
+	case eventType := <-types:
+		assert.Equal(t, EventSessionRequeued, eventType)
+	case <-time.After(time.Second):
+		t.Fatal("session was not requeued")
+	}
+	assert.Equal(t, ReasonStaffAway, (<-events).Reason)
+
+	cs.mu.RLock()
+	assert.Equal(t, SessionStatusWaiting, session.Status)
+	assert.Contains(t, cs.waiting, "user1")
+	cs.mu.RUnlock()
+}
+
+func TestCustomerService_AutoTransferOnAwayCancelled(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithAutoTransferOnAway(time.Minute))
+	defer cs.Shutdown()
+	types, _, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	_, err := cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	assert.NoError(t, err)
+
+	// 宽限期内恢复在线则保留会话
+	assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusAway))
+	assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusOnline))
+	clock.Advance(2 * time.Minute)
+
+	select {
+	case eventType := <-types:
+		t.Fatalf("unexpected event %s", eventType)
+	default:
+	}
+	cs.mu.RLock()
+	assert.Equal(t, "staff1", session.StaffID)
+	cs.mu.RUnlock()
+}
@@ -0,0 +1,88 @@
+package customer_service
+
+import (
+	"sort"
+	"strings"
+)
+
+// CannedResponse 快捷回复，客服可以按Key、分类或内容查找后直接发送
+type CannedResponse struct {
+	Key      string `json:"key"`
+	Category string `json:"category"`
+	Text     string `json:"text"`
+}
+
+// AddCanned 为客服组添加快捷回复，Key已存在时覆盖原有内容
+func (cs *CustomerService) AddCanned(groupID string, resp CannedResponse) error {
+	if strings.TrimSpace(resp.Key) == "" || strings.TrimSpace(resp.Text) == "" {
+		return ErrInvalidOperation
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	if group.canned == nil {
+		group.canned = make(map[string]CannedResponse)
+	}
+	group.canned[resp.Key] = resp
+	return nil
+}
+
+// RemoveCanned 删除客服组的快捷回复
+func (cs *CustomerService) RemoveCanned(groupID, key string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	if _, ok := group.canned[key]; !ok {
+		return ErrCannedNotFound
+	}
+	delete(group.canned, key)
+	return nil
+}
+
+// ListCanned 返回客服组的全部快捷回复，按分类和Key排序，组不存在时返回空
+func (cs *CustomerService) ListCanned(groupID string) []CannedResponse {
+	return cs.SearchCanned(groupID, "")
+}
+
+// SearchCanned 返回Key、分类或内容包含query（忽略大小写）的快捷回复，排序同ListCanned，
+// query为空时返回全部
+func (cs *CustomerService) SearchCanned(groupID, query string) []CannedResponse {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return nil
+	}
+
+	needle := strings.ToLower(strings.TrimSpace(query))
+	var results []CannedResponse
+	for _, resp := range group.canned {
+		if needle == "" || matchCanned(resp, needle) {
+			results = append(results, resp)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Category != results[j].Category {
+			return results[i].Category < results[j].Category
+		}
+		return results[i].Key < results[j].Key
+	})
+	return results
+}
+
+// matchCanned 判断快捷回复的Key、分类或内容是否包含needle，needle需为小写
+func matchCanned(resp CannedResponse, needle string) bool {
+	return strings.Contains(strings.ToLower(resp.Key), needle) ||
+		strings.Contains(strings.ToLower(resp.Category), needle) ||
+		strings.Contains(strings.ToLower(resp.Text), needle)
+}
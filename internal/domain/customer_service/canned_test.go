@@ -0,0 +1,49 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cannedKeys 提取快捷回复的Key，便于断言结果集
+func cannedKeys(responses []CannedResponse) []string {
+	keys := make([]string, len(responses))
+	for i, resp := range responses {
+		keys[i] = resp.Key
+	}
+	return keys
+}
+
+func TestCustomerService_SearchCanned(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	cs.CreateGroup("group1", "TestGroup")
+	cs.CreateGroup("group2", "OtherGroup")
+
+	assert.NoError(t, cs.AddCanned("group1", CannedResponse{Key: "greet", Category: "general", Text: "Hello, how can I help?"}))
+	assert.NoError(t, cs.AddCanned("group1", CannedResponse{Key: "bye", Category: "general", Text: "Thanks for contacting us"}))
+	assert.NoError(t, cs.AddCanned("group1", CannedResponse{Key: "refund-status", Category: "billing", Text: "Your refund is on its way"}))
+	assert.NoError(t, cs.AddCanned("group1", CannedResponse{Key: "invoice", Category: "billing", Text: "I have resent the invoice"}))
+	assert.NoError(t, cs.AddCanned("group2", CannedResponse{Key: "refund-policy", Category: "billing", Text: "Refunds take 5 days"}))
+
+	// 按Key、分类和内容匹配，忽略大小写，结果按分类和Key排序且不包含其他组
+	assert.Equal(t, []string{"refund-status"}, cannedKeys(cs.SearchCanned("group1", "REFUND")))
+	assert.Equal(t, []string{"invoice", "refund-status"}, cannedKeys(cs.SearchCanned("group1", "billing")))
+	assert.Equal(t, []string{"bye", "greet"}, cannedKeys(cs.SearchCanned("group1", "general")))
+	assert.Equal(t, []string{"greet"}, cannedKeys(cs.SearchCanned("group1", "help")))
+	assert.Empty(t, cs.SearchCanned("group1", "shipping"))
+	assert.Equal(t, []string{"invoice", "refund-status", "bye", "greet"}, cannedKeys(cs.ListCanned("group1")))
+
+	// 覆盖和删除
+	assert.NoError(t, cs.AddCanned("group1", CannedResponse{Key: "greet", Category: "general", Text: "Hi there"}))
+	assert.Empty(t, cs.SearchCanned("group1", "help"))
+	assert.NoError(t, cs.RemoveCanned("group1", "invoice"))
+	assert.Equal(t, []string{"refund-status"}, cannedKeys(cs.SearchCanned("group1", "billing")))
+
+	// 错误情况
+	assert.Equal(t, ErrCannedNotFound, cs.RemoveCanned("group1", "invoice"))
+	assert.Equal(t, ErrGroupNotFound, cs.AddCanned("nonexistent", CannedResponse{Key: "k", Text: "t"}))
+	assert.Equal(t, ErrInvalidOperation, cs.AddCanned("group1", CannedResponse{Key: "empty"}))
+	assert.Nil(t, cs.SearchCanned("nonexistent", "refund"))
+}
@@ -0,0 +1,39 @@
+package customer_service
+
+import "sort"
+
+// SetMaxSessions 设置系统同时存在的未关闭会话数上限，小于等于0表示不限，运行时可随时调整
+func (cs *CustomerService) SetMaxSessions(n int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.maxSessions = n
+	// 上限放宽后继续分配排队用户
+	cs.dispatchAllLocked()
+}
+
+// atSystemCapacityLocked 判断未关闭的会话数是否已达系统上限，调用方需持有cs.mu
+func (cs *CustomerService) atSystemCapacityLocked() bool {
+	if cs.maxSessions <= 0 {
+		return false
+	}
+	open := 0
+	for _, session := range cs.sessions {
+		if session.Status != SessionStatusClosed {
+			open++
+		}
+	}
+	return open >= cs.maxSessions
+}
+
+// dispatchAllLocked 按组ID顺序为所有组的排队用户发起分配，调用方需持有cs.mu
+func (cs *CustomerService) dispatchAllLocked() {
+	groupIDs := make([]string, 0, len(cs.queues))
+	for groupID := range cs.queues {
+		groupIDs = append(groupIDs, groupID)
+	}
+	sort.Strings(groupIDs)
+	for _, groupID := range groupIDs {
+		cs.dispatchGroupLocked(groupID)
+	}
+}
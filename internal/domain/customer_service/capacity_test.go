@@ -0,0 +1,85 @@
+package customer_service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_MaxSessions(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	offers := recordOffers(cs)
+	cs.SetMaxSessions(2)
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectStaff("staff1", "TestStaff1", "group1", nil)
+	for _, id := range []string{"user1", "user2", "user3", "user4"} {
+		cs.ConnectUser(id, "TestUser", nil)
+	}
+
+	// 达到上限后拒绝新会话
+	session1, err := cs.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	_, err = cs.CreateSession("user2", "staff1")
+	assert.NoError(t, err)
+	_, err = cs.CreateSession("user3", "staff1")
+	assert.Equal(t, ErrSystemAtCapacity, err)
+	assert.Equal(t, ErrSystemAtCapacity, cs.EnqueueUser("user3", "group1"))
+
+	// 关闭会话后释放名额
+	assert.NoError(t, cs.CloseSession(session1.ID, "staff1"))
+	_, err = cs.CreateSession("user3", "staff1")
+	assert.NoError(t, err)
+
+	// 运行时放宽上限后排队用户继续分配
+	cs.SetMaxSessions(3)
+	assert.NoError(t, cs.EnqueueUser("user4", "group1"))
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "user4", offer.UserID)
+	cs.SetMaxSessions(2)
+	_, err = cs.AcceptOffer("staff1", offer.ID)
+	assert.Equal(t, ErrSystemAtCapacity, err)
+	cs.SetMaxSessions(0)
+	_, err = cs.AcceptOffer("staff1", offer.ID)
+	assert.NoError(t, err)
+}
+
+func TestCustomerService_CreateSessionConcurrentCapacity(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectStaff("staff1", "TestStaff", "group1", nil)
+	cs.SetStaffCapacity("staff1", 1)
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		cs.ConnectUser(fmt.Sprintf("user%d", i), "TestUser", nil)
+	}
+
+	// 并发创建只有一个成功，其余因客服已满额失败
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = cs.CreateSession(fmt.Sprintf("user%d", i), "staff1")
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.Equal(t, ErrStaffAtCapacity, err)
+	}
+	assert.Equal(t, 1, succeeded)
+	assert.Len(t, cs.GetStaff("staff1").Sessions, 1)
+}
@@ -0,0 +1,31 @@
+package customer_service
+
+// ClaimNext 客服主动领取所在组中有效优先级最高的排队用户并创建会话，
+// 队列为空时返回ErrNoWaitingUsers。领取在一次加锁内完成，多个客服同时领取不会分到同一用户
+func (cs *CustomerService) ClaimNext(staffID string) (*Session, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return nil, ErrStaffNotFound
+	}
+	if staff.Status != UserStatusOnline || cs.atCapacityLocked(staff) {
+		return nil, ErrStaffUnavailable
+	}
+	if err := cs.checkGroupLocked(cs.groups[staff.GroupID]); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range cs.dispatchOrderLocked(staff.GroupID) {
+		user, exists := cs.users[entry.UserID]
+		if !exists {
+			continue
+		}
+		if entry.SessionID == "" && cs.atSystemCapacityLocked() {
+			return nil, ErrSystemAtCapacity
+		}
+		return cs.assignQueuedLocked(user, staff), nil
+	}
+	return nil, ErrNoWaitingUsers
+}
@@ -0,0 +1,83 @@
+package customer_service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ClaimNext(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	_, err := cs.ClaimNext("staff1")
+	assert.ErrorIs(t, err, ErrStaffNotFound)
+	cs.ConnectStaff("staff1", "TestStaff", "group1", nil)
+
+	_, err = cs.ClaimNext("staff1")
+	assert.ErrorIs(t, err, ErrNoWaitingUsers)
+
+	// 优先级高的用户先被领取
+	cs.ConnectUser("low", "LowUser", nil)
+	cs.ConnectUser("high", "HighUser", nil)
+	assert.NoError(t, cs.EnqueueUser("low", "group1"))
+	assert.NoError(t, cs.EnqueueUserWithPriority("high", "group1", 1))
+
+	session, err := cs.ClaimNext("staff1")
+	assert.NoError(t, err)
+	assert.Equal(t, "high", session.UserID)
+	assert.Equal(t, "staff1", session.StaffID)
+	assert.Equal(t, SessionStatusActive, session.Status)
+	assert.Equal(t, []string{"low"}, cs.QueuedUsers("group1"))
+
+	// 满额的客服不能继续领取
+	assert.NoError(t, cs.SetStaffCapacity("staff1", 1))
+	_, err = cs.ClaimNext("staff1")
+	assert.ErrorIs(t, err, ErrStaffUnavailable)
+}
+
+func TestCustomerService_ClaimNextConcurrent(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	const users = 50
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectStaff("staff1", "TestStaff1", "group1", nil)
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	for i := 0; i < users; i++ {
+		id := fmt.Sprintf("user%d", i)
+		cs.ConnectUser(id, "TestUser", nil)
+		assert.NoError(t, cs.EnqueueUser(id, "group1"))
+	}
+
+	var mu sync.Mutex
+	claimed := make(map[string]string)
+	var wg sync.WaitGroup
+	for _, staffID := range []string{"staff1", "staff2"} {
+		wg.Add(1)
+		go func(staffID string) {
+			defer wg.Done()
+			for {
+				session, err := cs.ClaimNext(staffID)
+				if err != nil {
+					assert.ErrorIs(t, err, ErrNoWaitingUsers)
+					return
+				}
+				mu.Lock()
+				if prev, exists := claimed[session.UserID]; exists {
+					t.Errorf("user %s claimed by both %s and %s", session.UserID, prev, staffID)
+				}
+				claimed[session.UserID] = staffID
+				mu.Unlock()
+			}
+		}(staffID)
+	}
+	wg.Wait()
+
+	assert.Len(t, claimed, users)
+	assert.Empty(t, cs.QueuedUsers("group1"))
+	assert.Equal(t, users, len(cs.GetStaff("staff1").Sessions)+len(cs.GetStaff("staff2").Sessions))
+}
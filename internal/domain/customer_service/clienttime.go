@@ -0,0 +1,25 @@
+package customer_service
+
+import "time"
+
+const (
+	defaultClientMaxAge  = 24 * time.Hour  // 默认允许离线编辑的消息声明的最早发送时间
+	defaultClientMaxSkew = 5 * time.Minute // 默认允许的客户端时钟超前量
+)
+
+// WithClientTimestampWindow 设置客户端声明的发送时间的可信范围：最多早于服务端时间maxAge，
+// 最多晚于服务端时间maxSkew，超出范围的时间被丢弃
+func WithClientTimestampWindow(maxAge, maxSkew time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.clientMaxAge = maxAge
+		cs.clientMaxSkew = maxSkew
+	}
+}
+
+// trustedClientTime 客户端时间在可信范围内时原样返回，否则返回零值
+func (cs *CustomerService) trustedClientTime(sentAt, now time.Time) time.Time {
+	if sentAt.IsZero() || sentAt.Before(now.Add(-cs.clientMaxAge)) || sentAt.After(now.Add(cs.clientMaxSkew)) {
+		return time.Time{}
+	}
+	return sentAt
+}
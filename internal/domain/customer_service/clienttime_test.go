@@ -0,0 +1,51 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ClientSentAt(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	first, _, err := cs.SendMessageOnce(session.ID, "user1", "c1", "first", MessageTypeText)
+	assert.NoError(t, err)
+
+	// 客户端时间远在未来：消息照常接收，但不记录该时间，序号和服务端时间仍在前一条之后
+	future := time.Now().Add(365 * 24 * time.Hour)
+	second, _, err := cs.SendMessageOnceAt(session.ID, "user1", "c2", "second", MessageTypeText, future)
+	assert.NoError(t, err)
+	assert.True(t, second.ClientSentAt.IsZero())
+	assert.Greater(t, second.Seq, first.Seq)
+	assert.False(t, second.CreateAt.Before(first.CreateAt))
+
+	// 可信范围内的客户端时间原样保留，但不影响排序
+	sentAt := time.Now().Add(-time.Minute)
+	third, _, err := cs.SendMessageOnceAt(session.ID, "staff1", "c3", "third", MessageTypeText, sentAt)
+	assert.NoError(t, err)
+	assert.True(t, sentAt.Equal(third.ClientSentAt))
+	assert.Greater(t, third.Seq, second.Seq)
+
+	msgs, err := cs.GetMessages(session.ID, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, []string{msgs[0].Content, msgs[1].Content, msgs[2].Content})
+
+	// 过旧的客户端时间同样被丢弃
+	stale, _, err := cs.SendMessageOnceAt(session.ID, "user1", "c4", "stale", MessageTypeText, time.Now().Add(-48*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, stale.ClientSentAt.IsZero())
+}
+
+func TestCustomerService_ClientTimestampWindow(t *testing.T) {
+	cs := NewCustomerService(WithClientTimestampWindow(time.Hour, time.Second))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	msg, _, err := cs.SendMessageOnceAt(session.ID, "user1", "c1", "hello", MessageTypeText, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, msg.ClientSentAt.IsZero())
+}
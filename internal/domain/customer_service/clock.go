@@ -0,0 +1,47 @@
+package customer_service
+
+import "time"
+
+// Clock 服务读取当前时间和创建计时器的来源，测试中可以替换为手动推进的时钟。
+// 邀请超时、整理状态、SLA等计时器都由该时钟创建
+type Clock interface {
+	Now() time.Time
+	// AfterFunc 在d之后于独立协程中调用f，返回的计时器可以取消调用
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer 由Clock创建的计时器
+type Timer interface {
+	// Stop 取消尚未触发的调用，计时器已触发或已停止时返回false
+	Stop() bool
+}
+
+// realClock 系统时钟
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// WithClock 设置时间来源，默认使用系统时钟
+func WithClock(clock Clock) Option {
+	return func(cs *CustomerService) {
+		if clock != nil {
+			cs.clock = clock
+		}
+	}
+}
+
+// now 返回服务时钟的当前时间
+func (cs *CustomerService) now() time.Time {
+	return cs.clock.Now()
+}
+
+// afterFunc 由服务时钟创建计时器
+func (cs *CustomerService) afterFunc(d time.Duration, f func()) Timer {
+	return cs.clock.AfterFunc(d, f)
+}
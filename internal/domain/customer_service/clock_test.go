@@ -0,0 +1,143 @@
+package customer_service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock 手动推进的时钟，测试中不需要真实等待
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer fakeClock创建的计时器，时钟推进到at时触发
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+	done  bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	stopped := !t.done
+	t.done = true
+	return stopped
+}
+
+// Advance 将时钟向前推进d，按时间顺序在调用方协程中触发到期的计时器，
+// 触发时时钟停在计时器的到期时间，回调中新建的计时器到期时同样触发
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		next := c.nextTimerLocked(target)
+		if next == nil {
+			break
+		}
+		next.done = true
+		c.now = next.at
+		c.mu.Unlock()
+		next.f()
+		c.mu.Lock()
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// nextTimerLocked 返回不晚于target且最早到期的计时器，并移除已结束的计时器
+func (c *fakeClock) nextTimerLocked(target time.Time) *fakeTimer {
+	var next *fakeTimer
+	active := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.done {
+			continue
+		}
+		active = append(active, timer)
+		if !timer.at.After(target) && (next == nil || timer.at.Before(next.at)) {
+			next = timer
+		}
+	}
+	c.timers = active
+	return next
+}
+
+func TestCustomerService_FakeClockSilenceClose(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(
+		WithClock(clock),
+		WithUserSilencePolicy(time.Minute, SilenceActionClose),
+		WithReapInterval(time.Hour),
+	)
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	active := createTestSession(t, cs, "user2", "staff1")
+	assert.Equal(t, clock.Now(), session.CreateAt)
+
+	// 用户2在30秒后发言，沉默计时按服务时钟重置
+	clock.Advance(30 * time.Second)
+	msg, err := cs.SendMessage(active.ID, "user2", "still here", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, clock.Now(), msg.CreateAt)
+
+	clock.Advance(31 * time.Second)
+	cs.reap(clock.Now())
+
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.Equal(t, SessionStatusActive, active.Status)
+
+	clock.Advance(time.Minute)
+	cs.reap(clock.Now())
+	assert.Equal(t, SessionStatusClosed, active.Status)
+}
+
+func TestWithClock_NilKeepsDefault(t *testing.T) {
+	cs := NewCustomerService(WithClock(nil))
+	defer cs.Shutdown()
+
+	assert.Equal(t, realClock{}, cs.clock)
+}
+
+func TestFakeClock_AfterFunc(t *testing.T) {
+	clock := newFakeClock()
+	var fired []string
+	clock.AfterFunc(2*time.Minute, func() { fired = append(fired, "second") })
+	clock.AfterFunc(time.Minute, func() {
+		fired = append(fired, "first")
+		// 回调中新建的计时器在同一次推进内到期时同样触发
+		clock.AfterFunc(30*time.Second, func() { fired = append(fired, "nested") })
+	})
+	stopped := clock.AfterFunc(90*time.Second, func() { fired = append(fired, "stopped") })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	clock.Advance(59 * time.Second)
+	assert.Empty(t, fired)
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, []string{"first", "nested", "second"}, fired)
+}
@@ -0,0 +1,47 @@
+package customer_service
+
+// WithMaxConnectionsPerIdentity 设置同一用户或客服身份同时保持的连接数上限，小于等于0表示不限
+func WithMaxConnectionsPerIdentity(n int) Option {
+	return func(cs *CustomerService) {
+		cs.maxConnsPerIdentity = n
+	}
+}
+
+// connKey 连接计数的键，用户和客服的ID空间相互独立
+func connKey(role PresenceRole, id string) string {
+	return string(role) + ":" + id
+}
+
+// AcquireConnection 为身份登记一个新连接，已达上限时返回ErrTooManyConnections。
+// 登记成功的连接断开后需调用ReleaseConnection
+func (cs *CustomerService) AcquireConnection(role PresenceRole, id string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	key := connKey(role, id)
+	if cs.maxConnsPerIdentity > 0 && cs.connCounts[key] >= cs.maxConnsPerIdentity {
+		return ErrTooManyConnections
+	}
+	cs.connCounts[key]++
+	return nil
+}
+
+// ReleaseConnection 注销身份的一个连接
+func (cs *CustomerService) ReleaseConnection(role PresenceRole, id string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	key := connKey(role, id)
+	if cs.connCounts[key] <= 1 {
+		delete(cs.connCounts, key)
+		return
+	}
+	cs.connCounts[key]--
+}
+
+// ConnectionCount 获取身份当前登记的连接数
+func (cs *CustomerService) ConnectionCount(role PresenceRole, id string) int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.connCounts[connKey(role, id)]
+}
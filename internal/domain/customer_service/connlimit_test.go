@@ -0,0 +1,39 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_MaxConnectionsPerIdentity(t *testing.T) {
+	cs := NewCustomerService(WithMaxConnectionsPerIdentity(2))
+	defer cs.Shutdown()
+
+	assert.NoError(t, cs.AcquireConnection(PresenceRoleUser, "user1"))
+	assert.NoError(t, cs.AcquireConnection(PresenceRoleUser, "user1"))
+	assert.ErrorIs(t, cs.AcquireConnection(PresenceRoleUser, "user1"), ErrTooManyConnections)
+	assert.Equal(t, 2, cs.ConnectionCount(PresenceRoleUser, "user1"))
+
+	// 不同身份、同ID的客服分别计数
+	assert.NoError(t, cs.AcquireConnection(PresenceRoleUser, "user2"))
+	assert.NoError(t, cs.AcquireConnection(PresenceRoleStaff, "user1"))
+
+	// 释放后可以重新连接
+	cs.ReleaseConnection(PresenceRoleUser, "user1")
+	assert.NoError(t, cs.AcquireConnection(PresenceRoleUser, "user1"))
+
+	cs.ReleaseConnection(PresenceRoleUser, "user2")
+	cs.ReleaseConnection(PresenceRoleUser, "user2")
+	assert.Equal(t, 0, cs.ConnectionCount(PresenceRoleUser, "user2"))
+}
+
+func TestCustomerService_UnlimitedConnections(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, cs.AcquireConnection(PresenceRoleStaff, "staff1"))
+	}
+	assert.Equal(t, 10, cs.ConnectionCount(PresenceRoleStaff, "staff1"))
+}
@@ -0,0 +1,19 @@
+package customer_service
+
+import "unicode/utf8"
+
+// WithMaxContentLength 设置文本消息内容的最大字符数，超出时返回ErrContentTooLong，小于等于0时不限制。
+// 与网关的帧大小限制相互独立，按字符而不是字节计算
+func WithMaxContentLength(n int) Option {
+	return func(cs *CustomerService) {
+		cs.maxContentLength = n
+	}
+}
+
+// checkContentLength 检查文本消息内容是否超出最大字符数
+func (cs *CustomerService) checkContentLength(content string) error {
+	if cs.maxContentLength > 0 && utf8.RuneCountInString(content) > cs.maxContentLength {
+		return ErrContentTooLong
+	}
+	return nil
+}
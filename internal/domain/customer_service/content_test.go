@@ -0,0 +1,36 @@
+package customer_service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_MaxContentLength(t *testing.T) {
+	cs := NewCustomerService(WithMaxContentLength(5))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 按字符计算，5个汉字不超限
+	msg, err := cs.SendMessage(session.ID, "user1", "你好你好你", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, "你好你好你", msg.Content)
+
+	_, err = cs.SendMessage(session.ID, "user1", "hello!", MessageTypeText)
+	assert.Equal(t, ErrContentTooLong, err)
+	_, errs := cs.SendMessages(session.ID, "staff1", []string{"ok", strings.Repeat("x", 6)})
+	assert.NoError(t, errs[0])
+	assert.Equal(t, ErrContentTooLong, errs[1])
+	assert.Len(t, session.Messages, 2)
+}
+
+func TestCustomerService_MaxContentLengthUnlimited(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	_, err := cs.SendMessage(session.ID, "user1", strings.Repeat("x", 100000), MessageTypeText)
+	assert.NoError(t, err)
+}
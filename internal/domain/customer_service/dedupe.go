@@ -0,0 +1,101 @@
+package customer_service
+
+import (
+	"container/list"
+	"time"
+)
+
+// defaultDedupeSize 每个会话默认记录的最近客户端消息ID数量
+const defaultDedupeSize = 256
+
+// WithDedupeSize 设置每个会话记录的最近客户端消息ID数量，超出后淘汰最久未使用的记录
+func WithDedupeSize(n int) Option {
+	return func(cs *CustomerService) {
+		cs.dedupeSize = n
+	}
+}
+
+// dedupeCache 容量固定的LRU缓存，记录客户端消息ID对应的已创建消息
+type dedupeCache struct {
+	capacity int
+	order    *list.List // 最近使用的在前
+	items    map[string]*list.Element
+}
+
+// dedupeEntry LRU中的一条记录
+type dedupeEntry struct {
+	key string
+	msg *Message
+}
+
+func newDedupeCache(capacity int) *dedupeCache {
+	return &dedupeCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get 查找记录并标记为最近使用
+func (c *dedupeCache) get(key string) (*Message, bool) {
+	elem, exists := c.items[key]
+	if !exists {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*dedupeEntry).msg, true
+}
+
+// add 添加记录，超出容量时淘汰最久未使用的记录
+func (c *dedupeCache) add(key string, msg *Message) {
+	c.items[key] = c.order.PushFront(&dedupeEntry{key: key, msg: msg})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*dedupeEntry).key)
+	}
+}
+
+// len 当前记录数
+func (c *dedupeCache) len() int {
+	return c.order.Len()
+}
+
+// SendMessageOnce 发送带客户端消息ID的消息，同一发送者在会话内重复提交相同clientMsgID时
+// 不再追加，直接返回首次创建的消息，第二个返回值表示是否为重复提交。clientMsgID为空时等同于SendMessage
+func (cs *CustomerService) SendMessageOnce(sessionID, fromID, clientMsgID, content string, msgType MessageType) (*Message, bool, error) {
+	return cs.SendMessageOnceAt(sessionID, fromID, clientMsgID, content, msgType, time.Time{})
+}
+
+// SendMessageOnceAt 同SendMessageOnce，并记录客户端声明的发送时间clientSentAt。该时间只用于展示，
+// 超出允许偏差时被丢弃，消息的序号和排序始终以服务端时间为准
+func (cs *CustomerService) SendMessageOnceAt(sessionID, fromID, clientMsgID, content string, msgType MessageType, clientSentAt time.Time) (*Message, bool, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, false, ErrSessionNotFound
+	}
+	toID := defaultRecipient(session, fromID)
+	if clientMsgID == "" || cs.dedupeSize <= 0 {
+		msg, err := cs.sendAtLocked(session, fromID, toID, clientMsgID, content, msgType, clientSentAt)
+		return msg, false, err
+	}
+
+	// 按发送者区分，避免不同参与者生成的ID相互冲突
+	key := fromID + "\x00" + clientMsgID
+	if session.dedupe == nil {
+		session.dedupe = newDedupeCache(cs.dedupeSize)
+	}
+	if msg, seen := session.dedupe.get(key); seen {
+		return msg, true, nil
+	}
+
+	msg, err := cs.sendAtLocked(session, fromID, toID, clientMsgID, content, msgType, clientSentAt)
+	if err != nil {
+		return nil, false, err
+	}
+	session.dedupe.add(key, msg)
+	return msg, false, nil
+}
@@ -0,0 +1,49 @@
+package customer_service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_SendMessageOnce(t *testing.T) {
+	cs := NewCustomerService(WithDedupeSize(4))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 相同client_msg_id重复提交只保存一条
+	first, dup, err := cs.SendMessageOnce(session.ID, "user1", "c1", "Hello", MessageTypeText)
+	assert.NoError(t, err)
+	assert.False(t, dup)
+	assert.Equal(t, "c1", first.ClientMsgID)
+	retry, dup, err := cs.SendMessageOnce(session.ID, "user1", "c1", "Hello", MessageTypeText)
+	assert.NoError(t, err)
+	assert.True(t, dup)
+	assert.Same(t, first, retry)
+	assert.Len(t, session.Messages, 1)
+
+	// 不同发送者使用相同ID互不影响
+	_, dup, err = cs.SendMessageOnce(session.ID, "staff1", "c1", "Hi", MessageTypeText)
+	assert.NoError(t, err)
+	assert.False(t, dup)
+	assert.Len(t, session.Messages, 2)
+
+	// 失败的发送不记录ID，修正后可以用同一ID重试
+	_, _, err = cs.SendMessageOnce(session.ID, "user1", "c2", " ", MessageTypeText)
+	assert.Equal(t, ErrEmptyContent, err)
+	_, dup, err = cs.SendMessageOnce(session.ID, "user1", "c2", "Fixed", MessageTypeText)
+	assert.NoError(t, err)
+	assert.False(t, dup)
+
+	// 记录数量受容量限制，最早的ID被淘汰后会重新发送
+	for i := 0; i < 10; i++ {
+		cs.SendMessageOnce(session.ID, "user1", fmt.Sprintf("n%d", i), "More", MessageTypeText)
+	}
+	assert.Equal(t, 4, session.dedupe.len())
+	count := len(session.Messages)
+	_, dup, _ = cs.SendMessageOnce(session.ID, "user1", "c1", "Hello", MessageTypeText)
+	assert.False(t, dup)
+	assert.Len(t, session.Messages, count+1)
+}
@@ -0,0 +1,22 @@
+package customer_service
+
+// DisconnectReason 用户或客服断开连接的原因
+type DisconnectReason string
+
+const (
+	DisconnectClientClose DisconnectReason = "client_close" // 客户端正常关闭连接
+	DisconnectError       DisconnectReason = "error"        // 网络或读写错误
+	DisconnectIdle        DisconnectReason = "idle"         // 长时间无活动被回收
+	DisconnectKicked      DisconnectReason = "kicked"       // 被系统或管理员移出
+	DisconnectLogout      DisconnectReason = "logout"       // 主动退出登录
+)
+
+// requeuesSessions 意外断开时客服的会话重新排队由其他客服接手，主动断开时直接关闭
+func (r DisconnectReason) requeuesSessions() bool {
+	return r == DisconnectError || r == DisconnectIdle
+}
+
+// skipsGrace 主动退出或被移出的用户不保留重连宽限期
+func (r DisconnectReason) skipsGrace() bool {
+	return r == DisconnectLogout || r == DisconnectKicked
+}
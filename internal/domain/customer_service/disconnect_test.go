@@ -0,0 +1,75 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_DisconnectStaffReason(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	dropped := createTestSession(t, cs, "user1", "staff1")
+	loggedOut := createTestSession(t, cs, "user2", "staff2")
+	types, events, _ := recordSessionEvents(cs)
+
+	// 网络错误断开，会话重新排队等待其他客服
+	cs.DisconnectStaff("staff1", DisconnectError)
+	assert.Equal(t, SessionStatusWaiting, dropped.Status)
+	assert.Empty(t, dropped.StaffID)
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+
+	// 主动退出，会话直接关闭
+	cs.DisconnectStaff("staff2", DisconnectLogout)
+	assert.Equal(t, SessionStatusClosed, loggedOut.Status)
+	assert.NotContains(t, cs.QueuedUsers("group1"), "user2")
+
+	select {
+	case eventType := <-types:
+		event := <-events
+		assert.Equal(t, EventSessionRequeued, eventType)
+		assert.Equal(t, dropped.ID, event.SessionID)
+		assert.Equal(t, "staff1", event.StaffID)
+		assert.Equal(t, ReasonStaffOffline, event.Reason)
+	case <-time.After(time.Second):
+		t.Fatal("session was not requeued")
+	}
+
+	audit := cs.AuditLog()
+	if assert.Len(t, audit, 2) {
+		assert.Equal(t, AuditEntry{Action: AuditStaffDisconnect, ActorID: "staff1", Reason: "error", CreateAt: audit[0].CreateAt}, audit[0])
+		assert.Equal(t, string(DisconnectLogout), audit[1].Reason)
+	}
+}
+
+func TestCustomerService_DisconnectUserLogoutSkipsGrace(t *testing.T) {
+	cs := NewCustomerService(WithReconnectGrace(time.Minute))
+	defer cs.Shutdown()
+
+	kept := createTestSession(t, cs, "user1", "staff1")
+	closed := createTestSession(t, cs, "user2", "staff1")
+	sub, cancel := cs.SubscribePresence()
+	defer cancel()
+
+	// 网络错误保留宽限期，主动退出立即关闭会话
+	cs.DisconnectUser("user1", DisconnectError)
+	assert.Equal(t, SessionStatusActive, kept.Status)
+	assert.NotNil(t, cs.GetUser("user1"))
+
+	cs.DisconnectUser("user2", DisconnectLogout)
+	assert.Equal(t, SessionStatusClosed, closed.Status)
+	assert.Nil(t, cs.GetUser("user2"))
+
+	// 宽限期内主动退出同样立即结束
+	cs.DisconnectUser("user1", DisconnectLogout)
+	assert.Equal(t, SessionStatusClosed, kept.Status)
+	assert.Nil(t, cs.GetUser("user1"))
+
+	event := <-sub
+	assert.Equal(t, DisconnectError, event.Reason)
+	event = <-sub
+	assert.Equal(t, DisconnectLogout, event.Reason)
+	assert.Equal(t, PresenceOffline, event.State)
+}
@@ -0,0 +1,85 @@
+package customer_service
+
+import (
+	"context"
+	"time"
+)
+
+// drainPollInterval 排空期间检查组内会话是否全部结束的间隔
+const drainPollInterval = 20 * time.Millisecond
+
+// DrainGroup 排空客服组以便滚动发布：组内不再分配新会话，新的排队请求返回ErrGroupDraining，
+// 已排队的用户继续等待，已向组内客服发出的邀请撤销，进行中的会话照常进行直到关闭。
+// 组内没有进行中的会话时返回nil，ctx结束时返回ctx.Err()。排空状态一直保持到调用ResumeGroup，
+// 多实例部署时调用方可以在排空期间经消息总线将会话转给其他实例
+func (cs *CustomerService) DrainGroup(groupID string, ctx context.Context) error {
+	cs.mu.Lock()
+	group, exists := cs.groups[groupID]
+	if !exists {
+		cs.mu.Unlock()
+		return ErrGroupNotFound
+	}
+	group.draining = true
+	for _, offer := range cs.offers {
+		if offer.GroupID == groupID {
+			cs.removeOfferLocked(offer)
+		}
+	}
+	cs.mu.Unlock()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for cs.groupSessionCount(groupID) > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// ResumeGroup 结束排空，组内恢复分配并立即为排队用户发起分配
+func (cs *CustomerService) ResumeGroup(groupID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	group.draining = false
+	cs.dispatchGroupLocked(groupID)
+	return nil
+}
+
+// groupSessionCount 统计组内客服进行中的会话数，组已删除时视为0
+func (cs *CustomerService) groupSessionCount(groupID string) int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return 0
+	}
+	count := 0
+	for _, staff := range group.Members {
+		count += len(staff.Sessions)
+	}
+	return count
+}
+
+// checkGroupLocked 检查组能否接入新会话，正在排空时返回ErrGroupDraining，
+// 已达会话总数上限时返回ErrGroupAtCapacity，调用方需持有cs.mu
+func (cs *CustomerService) checkGroupLocked(group *CSGroup) error {
+	if group == nil {
+		return nil
+	}
+	if group.draining {
+		return ErrGroupDraining
+	}
+	if cs.atGroupCapacityLocked(group) {
+		return ErrGroupAtCapacity
+	}
+	return nil
+}
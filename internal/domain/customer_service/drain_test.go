@@ -0,0 +1,73 @@
+package customer_service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_DrainGroup(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session1 := createTestSession(t, cs, "user1", "staff1")
+	session2 := createTestSession(t, cs, "user2", "staff1")
+	cs.SetAutoAccept("staff1", true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- cs.DrainGroup("group1", ctx) }()
+
+	// 排空开始后不再分配新会话
+	cs.ConnectUser("user3", "TestUser", nil)
+	assert.Eventually(t, func() bool {
+		_, err := cs.CreateSession("user3", "staff1")
+		return err == ErrGroupDraining
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, ErrGroupDraining, cs.EnqueueUser("user3", "group1"))
+	assert.Empty(t, cs.GetUser("user3").SessionID)
+
+	// 进行中的会话照常进行，全部关闭后排空完成
+	_, err := cs.SendMessage(session1.ID, "user1", "still here", MessageTypeText)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.CloseSession(session1.ID, "staff1"))
+	select {
+	case err := <-drained:
+		t.Fatalf("drain finished early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.NoError(t, cs.CloseSession(session2.ID, "user2"))
+	select {
+	case err := <-drained:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("drain did not finish")
+	}
+
+	// 恢复后重新接入
+	assert.NoError(t, cs.ResumeGroup("group1"))
+	assert.NoError(t, cs.EnqueueUser("user3", "group1"))
+	assert.NotEmpty(t, cs.GetUser("user3").SessionID)
+}
+
+func TestCustomerService_DrainGroupCancelled(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	cs.SetAutoAccept("staff2", true)
+	cs.ConnectUser("user2", "TestUser", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, cs.DrainGroup("group1", ctx))
+
+	// 超时后仍保持排空，空闲客服也不会接入
+	_, err := cs.ClaimNext("staff2")
+	assert.Equal(t, ErrGroupDraining, err)
+	assert.Equal(t, ErrGroupNotFound, cs.DrainGroup("missing", context.Background()))
+}
@@ -0,0 +1,69 @@
+package customer_service
+
+// Encryptor 消息内容的静态加密，写入存储前加密，从存储加载后解密
+type Encryptor interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// identityEncryptor 默认实现，原样存储
+type identityEncryptor struct{}
+
+func (identityEncryptor) Encrypt(plaintext []byte) ([]byte, error)  { return plaintext, nil }
+func (identityEncryptor) Decrypt(ciphertext []byte) ([]byte, error) { return ciphertext, nil }
+
+// WithEncryptor 设置消息内容写入存储时使用的加密器。内存中的会话、搜索和导出始终使用明文，
+// 从存储加载的消息自动解密
+func WithEncryptor(enc Encryptor) Option {
+	return func(cs *CustomerService) {
+		cs.encryptor = enc
+	}
+}
+
+// encryptedStore 在存储外层加解密消息内容，写入的是副本，不影响内存中的消息
+type encryptedStore struct {
+	SessionStore
+	enc Encryptor
+}
+
+// AppendMessage 加密消息内容后写入底层存储
+func (s *encryptedStore) AppendMessage(msgs ...*Message) error {
+	encrypted := make([]*Message, len(msgs))
+	for i, msg := range msgs {
+		content, err := s.enc.Encrypt([]byte(msg.Content))
+		if err != nil {
+			return err
+		}
+		m := *msg
+		m.Content = string(content)
+		encrypted[i] = &m
+	}
+	return s.SessionStore.AppendMessage(encrypted...)
+}
+
+// LoadMessages 从底层存储加载并解密消息内容
+func (s *encryptedStore) LoadMessages(sessionID string) ([]*Message, error) {
+	stored, err := s.SessionStore.LoadMessages(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*Message, len(stored))
+	for i, msg := range stored {
+		content, err := s.enc.Decrypt([]byte(msg.Content))
+		if err != nil {
+			return nil, err
+		}
+		m := *msg
+		m.Content = string(content)
+		msgs[i] = &m
+	}
+	return msgs, nil
+}
+
+// Ping 底层存储支持连通性检查时透传，否则视为正常
+func (s *encryptedStore) Ping() error {
+	if pinger, ok := s.SessionStore.(StorePinger); ok {
+		return pinger.Ping()
+	}
+	return nil
+}
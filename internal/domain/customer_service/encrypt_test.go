@@ -0,0 +1,75 @@
+package customer_service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// aesEncryptor 测试用的AES-GCM加密器，密文前缀为随机nonce
+type aesEncryptor struct {
+	aead cipher.AEAD
+}
+
+func newAESEncryptor(t *testing.T) *aesEncryptor {
+	block, err := aes.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	assert.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	return &aesEncryptor{aead: aead}
+}
+
+func (e *aesEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return e.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (e *aesEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	size := e.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, errors.New("ciphertext too short")
+	}
+	return e.aead.Open(nil, ciphertext[:size], ciphertext[size:], nil)
+}
+
+func TestCustomerService_EncryptAtRest(t *testing.T) {
+	store := NewMemoryStore()
+	enc := newAESEncryptor(t)
+	old := NewCustomerService(WithStore(store), WithEncryptor(enc))
+	session := createTestSession(t, old, "user1", "staff1")
+	old.SendMessage(session.ID, "user1", "my card ends in 4242", MessageTypeText)
+	old.SendMessage(session.ID, "staff1", "thanks", MessageTypeText)
+
+	// 存储中只有密文，内存中的消息和搜索使用明文
+	stored, err := store.LoadMessages(session.ID)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 2)
+	assert.NotContains(t, stored[0].Content, "4242")
+	assert.Equal(t, "my card ends in 4242", session.Messages[0].Content)
+	results, err := old.SearchMessages(session.ID, "4242", 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	snapshots := old.Snapshot()
+	old.Shutdown()
+
+	// 从存储恢复时自动解密
+	for i := range snapshots {
+		snapshots[i].Messages = nil
+	}
+	cs := NewCustomerService(WithStore(store), WithEncryptor(enc))
+	defer cs.Shutdown()
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, cs.RestoreFrom(snapshots))
+	restored := cs.GetSession(session.ID)
+	assert.Len(t, restored.Messages, 2)
+	assert.Equal(t, "my card ends in 4242", restored.Messages[0].Content)
+	assert.Equal(t, "thanks", restored.LastMessage.Content)
+}
@@ -0,0 +1,98 @@
+package customer_service
+
+// 错误码
+const (
+	CodeUserNotFound       = "user_not_found"
+	CodeStaffNotFound      = "staff_not_found"
+	CodeSessionNotFound    = "session_not_found"
+	CodeGroupNotFound      = "group_not_found"
+	CodeInvalidOperation   = "invalid_operation"
+	CodeEmptyContent       = "empty_content"
+	CodeUserAlreadyQueued  = "user_already_queued"
+	CodeOfferNotFound      = "offer_not_found"
+	CodeStaffUnavailable   = "staff_unavailable"
+	CodeSessionClosed      = "session_closed"
+	CodeOutOfHours         = "out_of_hours"
+	CodeInvalidTicket      = "invalid_ticket"
+	CodeMessageNotFound    = "message_not_found"
+	CodeNotParticipant     = "not_participant"
+	CodeSystemAtCapacity   = "system_at_capacity"
+	CodeInvalidTransition  = "invalid_transition"
+	CodeNoActiveSession    = "no_active_session"
+	CodeInvalidIdentity    = "invalid_identity"
+	CodeGroupNotEmpty      = "group_not_empty"
+	CodeAttachmentRejected = "attachment_rejected"
+	CodeTooManyConnections = "too_many_connections"
+	CodeNoWaitingUsers     = "no_waiting_users"
+	CodeCannedNotFound     = "canned_not_found"
+	CodeUserNotReady       = "user_not_ready"
+	CodeReopenExpired      = "reopen_window_expired"
+	CodeSystemBusy         = "system_busy"
+	CodeStaffAtCapacity    = "staff_at_capacity"
+	CodeContentTooLong     = "content_too_long"
+	CodeInvalidResumeToken = "invalid_resume_token"
+	CodeGroupAtCapacity    = "group_at_capacity"
+	CodeGroupDraining      = "group_draining"
+	CodePermissionDenied   = "permission_denied"
+	CodeWaitTooLong        = "wait_too_long"
+	// CodePreSessionBufferFull 会话建立前缓存的消息已达上限，客户端应等待会话建立后再发送
+	CodePreSessionBufferFull = "pre_session_buffer_full"
+)
+
+var (
+	ErrUserNotFound         = NewServiceError(CodeUserNotFound, "user not found")
+	ErrStaffNotFound        = NewServiceError(CodeStaffNotFound, "staff not found")
+	ErrSessionNotFound      = NewServiceError(CodeSessionNotFound, "session not found")
+	ErrGroupNotFound        = NewServiceError(CodeGroupNotFound, "group not found")
+	ErrInvalidOperation     = NewServiceError(CodeInvalidOperation, "invalid operation")
+	ErrEmptyContent         = NewServiceError(CodeEmptyContent, "empty message content")
+	ErrUserAlreadyQueued    = NewServiceError(CodeUserAlreadyQueued, "user already queued")
+	ErrOfferNotFound        = NewServiceError(CodeOfferNotFound, "offer not found")
+	ErrStaffUnavailable     = NewServiceError(CodeStaffUnavailable, "staff unavailable")
+	ErrSessionClosed        = NewServiceError(CodeSessionClosed, "session closed")
+	ErrOutOfHours           = NewServiceError(CodeOutOfHours, "out of business hours")
+	ErrInvalidTicket        = NewServiceError(CodeInvalidTicket, "ticket contact required")
+	ErrMessageNotFound      = NewServiceError(CodeMessageNotFound, "message not found")
+	ErrNotParticipant       = NewServiceError(CodeNotParticipant, "recipient is not a session participant")
+	ErrSystemAtCapacity     = NewServiceError(CodeSystemAtCapacity, "system at session capacity")
+	ErrInvalidTransition    = NewServiceError(CodeInvalidTransition, "invalid session status transition")
+	ErrNoActiveSession      = NewServiceError(CodeNoActiveSession, "no active session")
+	ErrInvalidIdentity      = NewServiceError(CodeInvalidIdentity, "invalid id or name")
+	ErrGroupNotEmpty        = NewServiceError(CodeGroupNotEmpty, "group still has staff members")
+	ErrAttachmentRejected   = NewServiceError(CodeAttachmentRejected, "attachment rejected")
+	ErrTooManyConnections   = NewServiceError(CodeTooManyConnections, "too many connections for this identity")
+	ErrNoWaitingUsers       = NewServiceError(CodeNoWaitingUsers, "no waiting users in queue")
+	ErrCannedNotFound       = NewServiceError(CodeCannedNotFound, "canned response not found")
+	ErrUserNotReady         = NewServiceError(CodeUserNotReady, "user has not completed the pre-chat form")
+	ErrReopenWindowExpired  = NewServiceError(CodeReopenExpired, "session closed too long ago to reopen")
+	ErrSystemBusy           = NewServiceError(CodeSystemBusy, "system busy, please leave a message")
+	ErrStaffAtCapacity      = NewServiceError(CodeStaffAtCapacity, "staff at session capacity")
+	ErrContentTooLong       = NewServiceError(CodeContentTooLong, "message content too long")
+	ErrInvalidResumeToken   = NewServiceError(CodeInvalidResumeToken, "invalid or expired resume token")
+	ErrGroupAtCapacity      = NewServiceError(CodeGroupAtCapacity, "group at session capacity")
+	ErrGroupDraining        = NewServiceError(CodeGroupDraining, "group is draining and not accepting new sessions")
+	ErrPermissionDenied     = NewServiceError(CodePermissionDenied, "participant role does not permit this operation")
+	ErrWaitTooLong          = NewServiceError(CodeWaitTooLong, "estimated wait too long, please try again later")
+	ErrPreSessionBufferFull = NewServiceError(CodePreSessionBufferFull, "no active session and pre-session buffer is full")
+)
+
+// ServiceError 带错误码的业务错误，调用方可以按Code映射为HTTP或WebSocket状态
+type ServiceError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewServiceError 创建业务错误
+func NewServiceError(code, message string) *ServiceError {
+	return &ServiceError{Code: code, Message: message}
+}
+
+func (e *ServiceError) Error() string {
+	return e.Message
+}
+
+// Is 错误码相同即视为同一错误，使errors.Is可以匹配携带不同描述的同类错误
+func (e *ServiceError) Is(target error) bool {
+	t, ok := target.(*ServiceError)
+	return ok && t.Code == e.Code
+}
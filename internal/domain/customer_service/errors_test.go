@@ -0,0 +1,37 @@
+package customer_service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceError_Is(t *testing.T) {
+	cs := NewCustomerService()
+
+	// 服务方法返回的错误仍可用errors.Is匹配
+	_, err := cs.CreateSession("nonexistent", "staff1")
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+	assert.False(t, errors.Is(err, ErrStaffNotFound))
+
+	_, err = cs.OfferSession("nonexistent", "user1")
+	assert.True(t, errors.Is(err, ErrStaffNotFound))
+
+	// 包装后仍可匹配
+	wrapped := fmt.Errorf("connect: %w", ErrGroupNotFound)
+	assert.True(t, errors.Is(wrapped, ErrGroupNotFound))
+
+	// 错误码相同而描述不同的错误视为同一错误
+	assert.True(t, errors.Is(NewServiceError(CodeSessionNotFound, "session abc not found"), ErrSessionNotFound))
+
+	// 可以取出错误码
+	var serviceErr *ServiceError
+	assert.True(t, errors.As(wrapped, &serviceErr))
+	assert.Equal(t, CodeGroupNotFound, serviceErr.Code)
+	assert.Equal(t, "group not found", serviceErr.Error())
+
+	// 普通错误不匹配
+	assert.False(t, errors.Is(errors.New("user not found"), ErrUserNotFound))
+}
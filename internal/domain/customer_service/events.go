@@ -0,0 +1,137 @@
+package customer_service
+
+import "sync"
+
+// 事件类型
+const (
+	EventSessionOffer       = "session_offer"
+	EventSessionAssigned    = "session_assigned"
+	EventSessionRequeued    = "session_requeued"
+	EventSessionTransferred = "session_transferred"
+	EventSessionClosed      = "session_closed"
+	EventSystemMessage      = "system_message"
+	EventMessageRecalled    = "message_recalled"
+	EventStaffUpdated       = "staff_updated"
+	EventQueuePosition      = "queue_position"
+	EventQueueCancelled     = "queue_cancelled"
+	EventSLAWarning         = "sla_warning"
+	EventSLABreach          = "sla_breach"
+	EventStaffGroupChanged  = "staff_group_changed"
+	EventSessionStatus      = "session_status"
+	EventAssignmentInvite   = "assignment_invite"
+)
+
+// 会话事件原因
+const (
+	ReasonUserSilence   = "user_silence"
+	ReasonClosedByUser  = "closed_by_user"
+	ReasonClosedByStaff = "closed_by_staff"
+	ReasonMerged        = "merged"
+	ReasonOrphaned      = "orphaned"
+	ReasonClosedByAdmin = "closed_by_admin"
+	ReasonStaffAway     = "staff_away"
+	ReasonUserOffline   = "user_offline"
+	ReasonGroupDeleted  = "group_deleted"
+	ReasonReopened      = "reopened"
+	ReasonStaffOffline  = "staff_offline"
+)
+
+// SessionEvent 会话重新排队、关闭等状态变更事件
+type SessionEvent struct {
+	SessionID   string `json:"session_id"`
+	UserID      string `json:"user_id"`
+	StaffID     string `json:"staff_id"`
+	PrevStaffID string `json:"prev_staff_id,omitempty"` // 转接前的客服，仅转接事件携带
+	Reason      string `json:"reason"`
+}
+
+// EventHook 系统事件回调，在独立协程中按事件发生的顺序调用
+type EventHook func(eventType string, payload interface{})
+
+// hookEvent 待分发的事件
+type hookEvent struct {
+	eventType string
+	payload   interface{}
+}
+
+// eventDispatcher 事件分发器，发布方只入队不阻塞，回调中可以安全地再次调用CustomerService
+type eventDispatcher struct {
+	hook   EventHook
+	queue  []hookEvent
+	signal chan struct{}
+	done   chan struct{}
+	mu     sync.Mutex
+}
+
+func newEventDispatcher(hook EventHook) *eventDispatcher {
+	d := &eventDispatcher{
+		hook:   hook,
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// push 事件入队
+func (d *eventDispatcher) push(event hookEvent) {
+	d.mu.Lock()
+	d.queue = append(d.queue, event)
+	d.mu.Unlock()
+
+	select {
+	case d.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run 依次分发队列中的事件
+func (d *eventDispatcher) run() {
+	for {
+		select {
+		case <-d.signal:
+		case <-d.done:
+			return
+		}
+
+		for {
+			d.mu.Lock()
+			if len(d.queue) == 0 {
+				d.mu.Unlock()
+				break
+			}
+			event := d.queue[0]
+			d.queue = d.queue[1:]
+			d.mu.Unlock()
+
+			d.hook(event.eventType, event.payload)
+		}
+	}
+}
+
+// stop 停止分发
+func (d *eventDispatcher) stop() {
+	close(d.done)
+}
+
+// SetEventHook 设置事件回调，重复设置时替换原回调
+func (cs *CustomerService) SetEventHook(hook EventHook) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.events != nil {
+		cs.events.stop()
+		cs.events = nil
+	}
+	if hook != nil {
+		cs.events = newEventDispatcher(hook)
+	}
+}
+
+// emit 发布事件，调用方需持有cs.mu
+func (cs *CustomerService) emit(eventType string, payload interface{}) {
+	if cs.events == nil {
+		return
+	}
+	cs.events.push(hookEvent{eventType: eventType, payload: payload})
+}
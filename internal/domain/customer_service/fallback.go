@@ -0,0 +1,27 @@
+package customer_service
+
+// WithAutoCreateGroups 开启后客服连接到不存在的组时自动创建该组，组名与组ID相同
+func WithAutoCreateGroups(on bool) Option {
+	return func(cs *CustomerService) {
+		cs.autoCreateGroups = on
+	}
+}
+
+// WithDefaultGroup 设置默认组，用户请求排入的组不存在时改为排入默认组，默认组也不存在时仍返回ErrGroupNotFound
+func WithDefaultGroup(groupID string) Option {
+	return func(cs *CustomerService) {
+		cs.defaultGroup = groupID
+	}
+}
+
+// resolveGroupLocked 查找用户请求的客服组，不存在时回退到默认组，调用方需持有cs.mu
+func (cs *CustomerService) resolveGroupLocked(groupID string) (*CSGroup, bool) {
+	if group, exists := cs.groups[groupID]; exists {
+		return group, true
+	}
+	if cs.defaultGroup == "" {
+		return nil, false
+	}
+	group, exists := cs.groups[cs.defaultGroup]
+	return group, exists
+}
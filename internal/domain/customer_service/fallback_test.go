@@ -0,0 +1,61 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_DefaultGroupFallback(t *testing.T) {
+	cs := NewCustomerService(WithDefaultGroup("general"))
+	defer cs.Shutdown()
+
+	user, _ := cs.ConnectUser("user1", "TestUser", nil)
+	// 默认组尚未创建时仍返回错误
+	assert.ErrorIs(t, cs.EnqueueUser("user1", "vip"), ErrGroupNotFound)
+
+	cs.CreateGroup("general", "General")
+	assert.NoError(t, cs.EnqueueUser("user1", "vip"))
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("general"))
+	assert.Empty(t, cs.QueuedUsers("vip"))
+	assert.Equal(t, "general", user.GroupID)
+
+	// 请求的组存在时不回退
+	cs.CreateGroup("vip", "VIP")
+	cs.ConnectUser("user2", "TestUser2", nil)
+	assert.NoError(t, cs.EnqueueUser("user2", "vip"))
+	assert.Equal(t, []string{"user2"}, cs.QueuedUsers("vip"))
+}
+
+func TestCustomerService_NoDefaultGroup(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.ErrorIs(t, cs.EnqueueUser("user1", "vip"), ErrGroupNotFound)
+
+	_, err := cs.ConnectStaff("staff1", "TestStaff", "vip", nil)
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+}
+
+func TestCustomerService_AutoCreateGroups(t *testing.T) {
+	cs := NewCustomerService(WithAutoCreateGroups(true))
+	defer cs.Shutdown()
+
+	staff, err := cs.ConnectStaff("staff1", "TestStaff", "vip", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "vip", staff.GroupID)
+
+	cs.mu.RLock()
+	group := cs.groups["vip"]
+	cs.mu.RUnlock()
+	if assert.NotNil(t, group) {
+		assert.Equal(t, "vip", group.Name)
+		assert.Contains(t, group.Members, "staff1")
+	}
+
+	// 自动创建的组可以正常排队分配
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, cs.EnqueueUser("user1", "vip"))
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("vip"))
+}
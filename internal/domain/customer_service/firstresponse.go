@@ -0,0 +1,18 @@
+package customer_service
+
+// recordFirstResponse 记录用户首条消息时间，以及此后客服的首次回复时间和用时。
+// 用户发言前客服的问候不算作回复，转接后由新客服回复同样计入，调用方需持有cs.mu
+func recordFirstResponse(session *Session, msg *Message) {
+	switch {
+	case msg.Type == MessageTypeSystem:
+	case msg.FromID == session.UserID:
+		if session.firstUserMessageAt.IsZero() {
+			session.firstUserMessageAt = msg.CreateAt
+		}
+	case msg.FromID == session.StaffID:
+		if !session.firstUserMessageAt.IsZero() && session.FirstResponseAt.IsZero() {
+			session.FirstResponseAt = msg.CreateAt
+			session.FirstResponseDuration = msg.CreateAt.Sub(session.firstUserMessageAt)
+		}
+	}
+}
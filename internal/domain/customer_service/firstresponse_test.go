@@ -0,0 +1,53 @@
+package customer_service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_FirstResponseTime(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+
+	start := clock.Now()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 用户发言前的问候不计入
+	cs.SendMessage(session.ID, "staff1", "welcome", MessageTypeText)
+	assert.True(t, session.FirstResponseAt.IsZero())
+
+	asked, err := cs.SendMessage(session.ID, "user1", "hello", MessageTypeText)
+	assert.NoError(t, err)
+	clock.Advance(50 * time.Second)
+	cs.SendMessage(session.ID, "user1", "anyone there?", MessageTypeText)
+	replied, err := cs.SendMessage(session.ID, "staff1", "hi, how can I help", MessageTypeText)
+	assert.NoError(t, err)
+
+	assert.Equal(t, replied.CreateAt, session.FirstResponseAt)
+	assert.Equal(t, replied.CreateAt.Sub(asked.CreateAt), session.FirstResponseDuration)
+	assert.Equal(t, 50*time.Second, session.FirstResponseDuration)
+
+	// 之后的回复不改变首次回复时间
+	cs.SendMessage(session.ID, "staff1", "still here", MessageTypeText)
+	assert.Equal(t, replied.CreateAt, session.FirstResponseAt)
+
+	summaries, err := cs.StaffSessions("staff1")
+	assert.NoError(t, err)
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, session.FirstResponseDuration, summaries[0].FirstResponseDuration)
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, cs.ExportReport(start, time.Time{}, &buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "first_response_seconds", records[0][9])
+		assert.Equal(t, "50.000", records[1][9])
+	}
+}
@@ -0,0 +1,63 @@
+package customer_service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 10 * time.Second
+	flushRetryInterval     = 50 * time.Millisecond
+)
+
+// WithShutdownTimeout 设置Shutdown等待缓冲消息写入存储的时限，小于等于0时使用默认值
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		if d > 0 {
+			cs.shutdownTimeout = d
+		}
+	}
+}
+
+// Flush 将批量写入缓冲中的消息立即写入存储，写入失败时按间隔重试直到成功或ctx结束，
+// ctx结束时返回最后一次写入错误或ctx.Err()。未开启批量写入时消息已同步写入，直接返回
+func (cs *CustomerService) Flush(ctx context.Context) error {
+	cs.mu.RLock()
+	writer := cs.writer
+	cs.mu.RUnlock()
+
+	if writer == nil {
+		return nil
+	}
+	return writer.flushContext(ctx)
+}
+
+// flushContext 写入缓冲中的消息直到成功或ctx结束。存储调用本身无法取消，
+// ctx结束时进行中的写入在后台继续，flushMu保证与之后的写入不会乱序
+func (w *storeWriter) flushContext(ctx context.Context) error {
+	var lastErr error
+	for {
+		result := make(chan error, 1)
+		go func() { result <- w.flush() }()
+
+		select {
+		case lastErr = <-result:
+		case <-ctx.Done():
+			if lastErr != nil {
+				return lastErr
+			}
+			return ctx.Err()
+		}
+		if lastErr == nil {
+			return nil
+		}
+		log.Printf("Error flushing messages to store, retrying: %v", lastErr)
+
+		select {
+		case <-time.After(flushRetryInterval):
+		case <-ctx.Done():
+			return lastErr
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package customer_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStore 前failures次写入失败，之后正常写入，failures为负数时一直失败
+type failingStore struct {
+	*MemoryStore
+	failures int
+	mu       sync.Mutex
+}
+
+func (s *failingStore) AppendMessage(msgs ...*Message) error {
+	s.mu.Lock()
+	if s.failures != 0 {
+		s.failures--
+		s.mu.Unlock()
+		return errors.New("store unavailable")
+	}
+	s.mu.Unlock()
+	return s.MemoryStore.AppendMessage(msgs...)
+}
+
+func TestCustomerService_Flush(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store), WithStoreBatch(100, time.Hour))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	for i := 0; i < 10; i++ {
+		_, err := cs.SendMessage(session.ID, "user1", fmt.Sprintf("msg-%d", i), MessageTypeText)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 0, storedCount(t, store, session.ID))
+
+	assert.NoError(t, cs.Flush(context.Background()))
+	stored, _ := store.LoadMessages(session.ID)
+	if assert.Len(t, stored, 10) {
+		for i, msg := range stored {
+			assert.Equal(t, fmt.Sprintf("msg-%d", i), msg.Content)
+		}
+	}
+}
+
+func TestCustomerService_FlushRetriesFailedWrites(t *testing.T) {
+	store := &failingStore{MemoryStore: NewMemoryStore(), failures: 2}
+	cs := NewCustomerService(WithStore(store), WithStoreBatch(100, time.Hour))
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	cs.SendMessages(session.ID, "user1", []string{"one", "two", "three"})
+	assert.NoError(t, cs.ShutdownContext(context.Background()))
+	assert.Equal(t, 3, storedCount(t, store, session.ID))
+}
+
+func TestCustomerService_ShutdownDeadline(t *testing.T) {
+	store := &failingStore{MemoryStore: NewMemoryStore(), failures: -1}
+	cs := NewCustomerService(WithStore(store), WithStoreBatch(100, time.Hour), WithShutdownTimeout(100*time.Millisecond))
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(session.ID, "user1", "lost", MessageTypeText)
+
+	// 存储一直不可用时在时限后返回写入错误，不会无限等待
+	start := time.Now()
+	err := cs.Shutdown()
+	assert.EqualError(t, err, "store unavailable")
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,92 @@
+package customer_service
+
+import (
+	"sort"
+	"time"
+)
+
+// SessionSummary 客服会话列表中的一项，Focused标记客服当前聚焦的会话，其余为后台会话
+type SessionSummary struct {
+	SessionID   string        `json:"session_id"`
+	UserID      string        `json:"user_id"`
+	GroupID     string        `json:"group_id"`
+	Status      SessionStatus `json:"status"`
+	UpdateAt    time.Time     `json:"update_at"`
+	LastMessage string        `json:"last_message,omitempty"` // 最后一条未撤回消息的内容
+	Focused     bool          `json:"focused"`
+
+	FirstResponseDuration time.Duration `json:"first_response_duration,omitempty"` // 客服首次回复用时，尚未回复时为0
+}
+
+// SetFocus 客服将自己负责的一个会话设为聚焦，其余会话转为后台，客户端据此决定通知方式。
+// sessionID为空时取消聚焦，会话不属于该客服时返回ErrInvalidOperation
+func (cs *CustomerService) SetFocus(staffID, sessionID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return ErrStaffNotFound
+	}
+	if sessionID != "" {
+		session, exists := cs.sessions[sessionID]
+		if !exists {
+			return ErrSessionNotFound
+		}
+		if session.Status == SessionStatusClosed {
+			return ErrSessionClosed
+		}
+		if _, owned := staff.Sessions[sessionID]; !owned || session.StaffID != staffID {
+			return ErrInvalidOperation
+		}
+	}
+	staff.focusSessionID = sessionID
+	return nil
+}
+
+// StaffSessions 返回客服当前负责的会话摘要，聚焦的会话排在最前，其余按最近更新时间倒序
+func (cs *CustomerService) StaffSessions(staffID string) ([]SessionSummary, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return nil, ErrStaffNotFound
+	}
+	focus := focusedSessionLocked(staff)
+	summaries := make([]SessionSummary, 0, len(staff.Sessions))
+	for _, session := range staff.Sessions {
+		summary := SessionSummary{
+			SessionID: session.ID,
+			UserID:    session.UserID,
+			GroupID:   session.GroupID,
+			Status:    session.Status,
+			UpdateAt:  session.UpdateAt,
+			Focused:   session.ID == focus,
+
+			FirstResponseDuration: session.FirstResponseDuration,
+		}
+		if last := session.LastMessage; last != nil && !last.Recalled {
+			summary.LastMessage = last.Content
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Focused != summaries[j].Focused {
+			return summaries[i].Focused
+		}
+		if !summaries[i].UpdateAt.Equal(summaries[j].UpdateAt) {
+			return summaries[i].UpdateAt.After(summaries[j].UpdateAt)
+		}
+		return summaries[i].SessionID < summaries[j].SessionID
+	})
+	return summaries, nil
+}
+
+// focusedSessionLocked 返回客服仍在负责的聚焦会话ID，会话已转接或关闭后视为未聚焦，调用方需持有cs.mu
+func focusedSessionLocked(staff *CSStaff) string {
+	if session, owned := staff.Sessions[staff.focusSessionID]; owned && session.StaffID == staff.ID && session.Status != SessionStatusClosed {
+		return staff.focusSessionID
+	}
+	return ""
+}
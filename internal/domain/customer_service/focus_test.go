@@ -0,0 +1,55 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_SetFocus(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	s1 := createTestSession(t, cs, "user1", "staff1")
+	s2 := createTestSession(t, cs, "user2", "staff1")
+	other := createTestSession(t, cs, "user3", "staff2")
+
+	// 聚焦的会话排在最前，其余为后台会话
+	assert.NoError(t, cs.SetFocus("staff1", s2.ID))
+	summaries, err := cs.StaffSessions("staff1")
+	assert.NoError(t, err)
+	if assert.Len(t, summaries, 2) {
+		assert.Equal(t, s2.ID, summaries[0].SessionID)
+		assert.True(t, summaries[0].Focused)
+		assert.Equal(t, s1.ID, summaries[1].SessionID)
+		assert.False(t, summaries[1].Focused)
+	}
+	view, _ := cs.GetStaffView("staff1")
+	assert.Equal(t, s2.ID, view.FocusSessionID)
+
+	// 切换聚焦
+	assert.NoError(t, cs.SetFocus("staff1", s1.ID))
+	summaries, _ = cs.StaffSessions("staff1")
+	assert.Equal(t, s1.ID, summaries[0].SessionID)
+	assert.True(t, summaries[0].Focused)
+	assert.False(t, summaries[1].Focused)
+
+	// 不能聚焦其他客服的会话
+	assert.Equal(t, ErrInvalidOperation, cs.SetFocus("staff1", other.ID))
+	assert.Equal(t, ErrSessionNotFound, cs.SetFocus("staff1", "nonexistent"))
+	assert.Equal(t, ErrStaffNotFound, cs.SetFocus("nonexistent", s1.ID))
+
+	// 聚焦的会话关闭后不再视为聚焦
+	cs.CloseSession(s1.ID, "user1")
+	view, _ = cs.GetStaffView("staff1")
+	assert.Empty(t, view.FocusSessionID)
+	assert.Equal(t, ErrSessionClosed, cs.SetFocus("staff1", s1.ID))
+
+	// 空ID取消聚焦
+	assert.NoError(t, cs.SetFocus("staff1", s2.ID))
+	assert.NoError(t, cs.SetFocus("staff1", ""))
+	summaries, _ = cs.StaffSessions("staff1")
+	for _, summary := range summaries {
+		assert.False(t, summary.Focused)
+	}
+}
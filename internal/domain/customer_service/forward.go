@@ -0,0 +1,62 @@
+package customer_service
+
+// ForwardOrigin 转发消息的来源
+type ForwardOrigin struct {
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	FromID    string `json:"from_id"` // 原消息的发送者
+}
+
+// ForwardMessage 将会话中的一条消息复制到另一个会话，新消息由byStaffID发出并标记来源。
+// 转发者需同时是来源会话和目标会话的参与者，系统消息和已撤回的消息不能转发
+func (cs *CustomerService) ForwardMessage(sourceSessionID, messageID, targetSessionID, byStaffID string) (*Message, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	source, exists := cs.sessions[sourceSessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	target, exists := cs.sessions[targetSessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	if _, exists := cs.staffs[byStaffID]; !exists {
+		return nil, ErrStaffNotFound
+	}
+	if !source.isParticipant(byStaffID) || !target.isParticipant(byStaffID) {
+		return nil, ErrNotParticipant
+	}
+	if target.Status == SessionStatusClosed {
+		return nil, ErrSessionClosed
+	}
+
+	original := findMessage(source, messageID)
+	if original == nil {
+		return nil, ErrMessageNotFound
+	}
+	if original.Type == MessageTypeSystem || original.Recalled {
+		return nil, ErrInvalidOperation
+	}
+
+	msg, err := cs.newMessageTo(target, byStaffID, defaultRecipient(target, byStaffID), original.Content, original.Type)
+	if err != nil {
+		return nil, err
+	}
+	if original.Attachment != nil {
+		att := *original.Attachment
+		msg.Attachment = &att
+	}
+	msg.Headers = cloneHeaders(original.Headers)
+	msg.ForwardedFrom = &ForwardOrigin{
+		SessionID: source.ID,
+		MessageID: original.ID,
+		FromID:    original.FromID,
+	}
+	cs.appendMessageLocked(target, msg)
+	target.UpdateAt = cs.now()
+	cs.persistMessages(msg)
+	cs.evictMessagesLocked(target)
+	cs.publishMessageLocked(msg)
+	return msg, nil
+}
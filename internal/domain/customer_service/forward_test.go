@@ -0,0 +1,47 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ForwardMessage(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	source := createTestSession(t, cs, "user1", "staff1")
+	target := createTestSession(t, cs, "user2", "staff1")
+	other := createTestSession(t, cs, "user3", "staff2")
+
+	original, err := cs.SendMessage(source.ID, "user1", "订单号是A1001", MessageTypeText)
+	assert.NoError(t, err)
+
+	forwarded, err := cs.ForwardMessage(source.ID, original.ID, target.ID, "staff1")
+	assert.NoError(t, err)
+	assert.Equal(t, target.ID, forwarded.SessionID)
+	assert.Equal(t, "staff1", forwarded.FromID)
+	assert.Equal(t, "user2", forwarded.ToID)
+	assert.Equal(t, original.Content, forwarded.Content)
+	assert.Equal(t, &ForwardOrigin{SessionID: source.ID, MessageID: original.ID, FromID: "user1"}, forwarded.ForwardedFrom)
+	assert.Equal(t, forwarded, target.LastMessage)
+	assert.Nil(t, original.ForwardedFrom)
+
+	// 转发者需参与来源和目标会话
+	_, err = cs.ForwardMessage(source.ID, original.ID, other.ID, "staff1")
+	assert.ErrorIs(t, err, ErrNotParticipant)
+	_, err = cs.ForwardMessage(source.ID, original.ID, target.ID, "staff2")
+	assert.ErrorIs(t, err, ErrNotParticipant)
+	_, err = cs.ForwardMessage(source.ID, "missing", target.ID, "staff1")
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+
+	// 已撤回的消息不能转发
+	_, err = cs.RecallMessage(source.ID, original.ID, "user1")
+	assert.NoError(t, err)
+	_, err = cs.ForwardMessage(source.ID, original.ID, target.ID, "staff1")
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+
+	assert.NoError(t, cs.CloseSession(target.ID, "staff1"))
+	_, err = cs.ForwardMessage(source.ID, original.ID, target.ID, "staff1")
+	assert.ErrorIs(t, err, ErrSessionClosed)
+}
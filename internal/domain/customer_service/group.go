@@ -0,0 +1,155 @@
+package customer_service
+
+import (
+	"sort"
+
+	"github.com/gorilla/websocket"
+)
+
+// DeleteGroup 删除客服组，组内仍有客服时返回ErrGroupNotEmpty。
+// 组内排队的用户移出队列，重新排队中的会话随之关闭
+func (cs *CustomerService) DeleteGroup(groupID string) error {
+	return cs.deleteGroup(groupID, false)
+}
+
+// ForceDeleteGroup 强制删除客服组，其余处理同DeleteGroup。组内在线的客服按以下规则处理：
+// 配置了默认组（WithDefaultGroup）且默认组存在时，客服连同进行中的会话移入默认组；
+// 否则关闭客服的会话并断开客服。两种情况都发出EventStaffGroupChanged事件
+func (cs *CustomerService) ForceDeleteGroup(groupID string) error {
+	return cs.deleteGroup(groupID, true)
+}
+
+func (cs *CustomerService) deleteGroup(groupID string, force bool) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	if len(group.Members) > 0 && !force {
+		return ErrGroupNotEmpty
+	}
+
+	// 先清空队列，避免关闭会话释放名额时再向组内客服发起分配
+	queued := append([]string(nil), cs.queues[groupID]...)
+	for _, userID := range queued {
+		cs.cancelQueuedLocked(userID)
+	}
+	delete(cs.queues, groupID)
+
+	fallback, hasFallback := cs.groups[cs.defaultGroup]
+	hasFallback = hasFallback && fallback != group
+	staffIDs := make([]string, 0, len(group.Members))
+	for id := range group.Members {
+		staffIDs = append(staffIDs, id)
+	}
+	sort.Strings(staffIDs)
+	for _, id := range staffIDs {
+		staff := group.Members[id]
+		if hasFallback {
+			cs.moveStaffLocked(staff, fallback)
+			continue
+		}
+		sessionIDs := make([]string, 0, len(staff.Sessions))
+		for sessionID := range staff.Sessions {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+		sort.Strings(sessionIDs)
+		for _, sessionID := range sessionIDs {
+			cs.closeGroupSessionLocked(cs.sessions[sessionID])
+		}
+		cs.removeGroupStaffLocked(staff)
+	}
+
+	delete(cs.groups, groupID)
+	if hasFallback && len(staffIDs) > 0 {
+		cs.dispatchGroupLocked(fallback.ID)
+	}
+	return nil
+}
+
+// StaffGroupChange 客服所属组被删除时的处理结果，NewGroupID为空表示客服已被断开
+type StaffGroupChange struct {
+	StaffID    string `json:"staff_id"`
+	OldGroupID string `json:"old_group_id"`
+	NewGroupID string `json:"new_group_id,omitempty"`
+	Reason     string `json:"reason"`
+
+	// Conn 被断开客服的连接，交由事件接收方发送关闭原因后关闭；未设置事件回调时由服务直接关闭
+	Conn *websocket.Conn `json:"-"`
+}
+
+// moveStaffLocked 将客服连同进行中的会话移入新组，调用方需持有cs.mu
+func (cs *CustomerService) moveStaffLocked(staff *CSStaff, to *CSGroup) {
+	oldGroupID := staff.GroupID
+	if group, exists := cs.groups[oldGroupID]; exists {
+		delete(group.Members, staff.ID)
+	}
+	to.Members[staff.ID] = staff
+	staff.GroupID = to.ID
+	for _, session := range staff.Sessions {
+		session.GroupID = to.ID
+	}
+
+	cs.emit(EventStaffGroupChanged, StaffGroupChange{
+		StaffID:    staff.ID,
+		OldGroupID: oldGroupID,
+		NewGroupID: to.ID,
+		Reason:     ReasonGroupDeleted,
+	})
+	cs.emitStaffUpdatedLocked(staff)
+}
+
+// removeGroupStaffLocked 因客服组删除断开客服，连接交给事件接收方以便告知断开原因，调用方需持有cs.mu
+func (cs *CustomerService) removeGroupStaffLocked(staff *CSStaff) {
+	conn := staff.Conn
+	if cs.events != nil {
+		staff.Conn = nil
+	}
+	change := StaffGroupChange{
+		StaffID:    staff.ID,
+		OldGroupID: staff.GroupID,
+		Reason:     ReasonGroupDeleted,
+		Conn:       conn,
+	}
+	cs.disconnectStaffLocked(staff, DisconnectKicked)
+	cs.emit(EventStaffGroupChanged, change)
+}
+
+// cancelQueuedLocked 因客服组删除将用户移出队列：重新排队的会话直接关闭，新用户收到排队取消事件，调用方需持有cs.mu
+func (cs *CustomerService) cancelQueuedLocked(userID string) {
+	entry, exists := cs.waiting[userID]
+	if !exists {
+		return
+	}
+	if offerID, offered := cs.userOffers[userID]; offered {
+		cs.removeOfferLocked(cs.offers[offerID])
+	}
+	cs.dequeueLocked(userID)
+
+	if session, exists := cs.sessions[entry.SessionID]; exists {
+		cs.closeGroupSessionLocked(session)
+		return
+	}
+	cs.emit(EventQueueCancelled, SessionEvent{
+		UserID: userID,
+		Reason: ReasonGroupDeleted,
+	})
+}
+
+// closeGroupSessionLocked 因客服组删除关闭会话并发出关闭事件，调用方需持有cs.mu
+func (cs *CustomerService) closeGroupSessionLocked(session *Session) {
+	if session == nil {
+		return
+	}
+	event := SessionEvent{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		StaffID:   session.StaffID,
+		Reason:    ReasonGroupDeleted,
+	}
+	if cs.closeSessionLocked(session, ReasonGroupDeleted) == nil {
+		cs.emit(EventSessionClosed, event)
+	}
+}
@@ -0,0 +1,146 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_DeleteEmptyGroup(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+
+	// 没有客服的组可以直接删除，排队用户收到取消事件
+	assert.NoError(t, cs.DeleteGroup("group1"))
+	assert.Empty(t, cs.QueuedUsers("group1"))
+	assert.ErrorIs(t, cs.DeleteGroup("group1"), ErrGroupNotFound)
+	assert.ErrorIs(t, cs.EnqueueUser("user1", "group1"), ErrGroupNotFound)
+
+	assert.Equal(t, EventQueueCancelled, <-types)
+	event := <-events
+	assert.Equal(t, "user1", event.UserID)
+	assert.Equal(t, ReasonGroupDeleted, event.Reason)
+}
+
+func TestCustomerService_DeleteGroupNotEmpty(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	assert.ErrorIs(t, cs.DeleteGroup("group1"), ErrGroupNotEmpty)
+	assert.NotNil(t, cs.GetStaff("staff1"))
+	assert.Equal(t, SessionStatusActive, session.Status)
+}
+
+func TestCustomerService_ForceDeleteGroup(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectUser("user2", "TestUser2", nil)
+	assert.NoError(t, cs.EnqueueUser("user2", "group1"))
+
+	assert.NoError(t, cs.ForceDeleteGroup("group1"))
+	assert.Nil(t, cs.GetStaff("staff1"))
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.Empty(t, cs.GetUser("user1").SessionID)
+	assert.Empty(t, cs.QueuedUsers("group1"))
+	assert.ErrorIs(t, cs.DeleteGroup("group1"), ErrGroupNotFound)
+
+	// 排队用户收到取消事件，进行中的会话以组删除为由关闭
+	closed := map[string]SessionEvent{}
+	for len(closed) < 2 {
+		eventType := <-types
+		event := <-events
+		if eventType == EventQueueCancelled || eventType == EventSessionClosed {
+			closed[eventType] = event
+		}
+	}
+	assert.Equal(t, "user2", closed[EventQueueCancelled].UserID)
+	assert.Equal(t, session.ID, closed[EventSessionClosed].SessionID)
+	assert.Equal(t, ReasonGroupDeleted, closed[EventSessionClosed].Reason)
+}
+
+// recordStaffGroupChanges 通过事件回调收集客服所属组变更
+func recordStaffGroupChanges(cs *CustomerService) <-chan StaffGroupChange {
+	changes := make(chan StaffGroupChange, 16)
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		if eventType == EventStaffGroupChanged {
+			changes <- payload.(StaffGroupChange)
+		}
+	})
+	return changes
+}
+
+func TestCustomerService_ForceDeleteGroupMovesStaffToDefault(t *testing.T) {
+	cs := NewCustomerService(WithDefaultGroup("default"))
+	defer cs.Shutdown()
+	changes := recordStaffGroupChanges(cs)
+
+	cs.CreateGroup("default", "DefaultGroup")
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectUser("user2", "TestUser2", nil)
+	assert.NoError(t, cs.EnqueueUser("user2", "default"))
+
+	// 客服连同进行中的会话移入默认组，并开始接待默认组的排队用户
+	assert.NoError(t, cs.ForceDeleteGroup("group1"))
+	staff := cs.GetStaff("staff1")
+	assert.NotNil(t, staff)
+	assert.Equal(t, "default", staff.GroupID)
+	assert.Equal(t, SessionStatusActive, session.Status)
+	assert.Equal(t, "default", session.GroupID)
+	assert.Equal(t, session.ID, cs.GetUser("user1").SessionID)
+	cs.mu.RLock()
+	offerID := cs.userOffers["user2"]
+	cs.mu.RUnlock()
+	assert.Equal(t, "staff1", cs.GetOffer(offerID).StaffID)
+
+	change := <-changes
+	assert.Equal(t, StaffGroupChange{StaffID: "staff1", OldGroupID: "group1", NewGroupID: "default", Reason: ReasonGroupDeleted}, change)
+}
+
+func TestCustomerService_ForceDeleteGroupDisconnectsStaff(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	changes := recordStaffGroupChanges(cs)
+
+	createTestSession(t, cs, "user1", "staff1")
+	assert.NoError(t, cs.ForceDeleteGroup("group1"))
+	assert.Nil(t, cs.GetStaff("staff1"))
+
+	change := <-changes
+	assert.Equal(t, "staff1", change.StaffID)
+	assert.Equal(t, "group1", change.OldGroupID)
+	assert.Empty(t, change.NewGroupID)
+	assert.Equal(t, ReasonGroupDeleted, change.Reason)
+}
+
+func TestCustomerService_RoutingWithMissingGroup(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	staff, _ := cs.ConnectStaff("staff1", "TestStaff", "group1", nil)
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+
+	// 客服指向不存在的组时，各分配入口都不会panic
+	cs.mu.Lock()
+	staff.GroupID = "ghost"
+	cs.mu.Unlock()
+	assert.NotPanics(t, func() {
+		_, err := cs.ClaimNext("staff1")
+		assert.ErrorIs(t, err, ErrNoWaitingUsers)
+		assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusAway))
+		assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusOnline))
+		assert.NoError(t, cs.SetStaffCapacity("staff1", 2))
+		cs.DisconnectStaff("staff1", DisconnectClientClose)
+	})
+}
@@ -0,0 +1,38 @@
+package customer_service
+
+// SetMaxGroupSessions 设置组内客服同时处理的会话总数上限，与单个客服的上限同时生效，
+// 小于等于0表示不限。达到上限后组内不再分配新会话，排队用户继续等待
+func (cs *CustomerService) SetMaxGroupSessions(groupID string, n int) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	if n < 0 {
+		n = 0
+	}
+	group.MaxGroupSessions = n
+	// 上限放宽后继续分配排队用户
+	cs.dispatchGroupLocked(groupID)
+	return nil
+}
+
+// atGroupCapacityLocked 判断组内客服的会话数与待处理邀请数之和是否已达组上限，
+// 与atCapacityLocked一致地计入邀请，接受邀请不会使组超出上限，调用方需持有cs.mu
+func (cs *CustomerService) atGroupCapacityLocked(group *CSGroup) bool {
+	if group == nil || group.MaxGroupSessions <= 0 {
+		return false
+	}
+	total := 0
+	for _, staff := range group.Members {
+		total += len(staff.Sessions)
+	}
+	for _, offer := range cs.offers {
+		if _, member := group.Members[offer.StaffID]; member {
+			total++
+		}
+	}
+	return total >= group.MaxGroupSessions
+}
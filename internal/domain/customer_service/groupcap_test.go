@@ -0,0 +1,58 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_MaxGroupSessions(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session1 := createTestSession(t, cs, "user1", "staff1")
+	createTestSession(t, cs, "user2", "staff2")
+	cs.SetAutoAccept("staff1", true)
+	assert.NoError(t, cs.SetMaxGroupSessions("group1", 2))
+	assert.Equal(t, ErrGroupNotFound, cs.SetMaxGroupSessions("missing", 2))
+
+	// 单个客服未满，但组已达上限
+	cs.ConnectUser("user3", "TestUser", nil)
+	_, err := cs.CreateSession("user3", "staff1")
+	assert.Equal(t, ErrGroupAtCapacity, err)
+	assert.NoError(t, cs.EnqueueUser("user3", "group1"))
+	assert.Equal(t, []string{"user3"}, cs.QueuedUsers("group1"))
+	_, err = cs.ClaimNext("staff2")
+	assert.Equal(t, ErrGroupAtCapacity, err)
+
+	// 关闭会话释放名额后排队用户继续分配
+	assert.NoError(t, cs.CloseSession(session1.ID, "staff1"))
+	assert.Empty(t, cs.QueuedUsers("group1"))
+	user := cs.GetUser("user3")
+	if assert.NotNil(t, user) {
+		assert.NotEmpty(t, user.SessionID)
+	}
+
+	// 其他组不受影响
+	cs.CreateGroup("group2", "Group2")
+	cs.ConnectStaff("staff3", "TestStaff", "group2", nil)
+	cs.ConnectUser("user4", "TestUser", nil)
+	_, err = cs.CreateSession("user4", "staff3")
+	assert.NoError(t, err)
+}
+
+func TestCustomerService_MaxGroupSessionsRaised(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	createTestSession(t, cs, "user1", "staff1")
+	cs.SetAutoAccept("staff1", true)
+	assert.NoError(t, cs.SetMaxGroupSessions("group1", 1))
+	cs.ConnectUser("user2", "TestUser", nil)
+	assert.NoError(t, cs.EnqueueUser("user2", "group1"))
+	assert.Equal(t, []string{"user2"}, cs.QueuedUsers("group1"))
+
+	// 放宽上限后立即分配
+	assert.NoError(t, cs.SetMaxGroupSessions("group1", 0))
+	assert.Empty(t, cs.QueuedUsers("group1"))
+}
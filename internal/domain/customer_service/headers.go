@@ -0,0 +1,50 @@
+package customer_service
+
+// MessageFilter 消息写入会话前的处理，可以通过SetHeader为消息附加意图分类、情绪评分等机器可读信息。
+// 在cs.mu内按注册顺序调用，不能再调用CustomerService的方法
+type MessageFilter interface {
+	Filter(msg *Message)
+}
+
+// MessageFilterFunc 将普通函数适配为MessageFilter
+type MessageFilterFunc func(msg *Message)
+
+func (f MessageFilterFunc) Filter(msg *Message) { f(msg) }
+
+// WithMessageFilter 追加消息过滤器，多次调用时按顺序全部生效
+func WithMessageFilter(filters ...MessageFilter) Option {
+	return func(cs *CustomerService) {
+		for _, filter := range filters {
+			if filter != nil {
+				cs.filters = append(cs.filters, filter)
+			}
+		}
+	}
+}
+
+// SetHeader 设置消息的附加信息，首次设置时创建Headers
+func (m *Message) SetHeader(key, value string) {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+	m.Headers[key] = value
+}
+
+// filterMessageLocked 依次调用消息过滤器，调用方需持有cs.mu
+func (cs *CustomerService) filterMessageLocked(msg *Message) {
+	for _, filter := range cs.filters {
+		filter.Filter(msg)
+	}
+}
+
+// cloneHeaders 复制消息附加信息，为空时返回nil
+func cloneHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	cloned := make(map[string]string, len(headers))
+	for key, value := range headers {
+		cloned[key] = value
+	}
+	return cloned
+}
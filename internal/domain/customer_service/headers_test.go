@@ -0,0 +1,71 @@
+package customer_service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sentimentFilter 按关键词给用户消息打上情绪标记
+var sentimentFilter = MessageFilterFunc(func(msg *Message) {
+	if strings.Contains(msg.Content, "angry") {
+		msg.SetHeader("sentiment", "negative")
+	}
+})
+
+func TestCustomerService_MessageHeaders(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store), WithMessageFilter(sentimentFilter))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	msg, err := cs.SendMessage(session.ID, "user1", "I am angry", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"sentiment": "negative"}, msg.Headers)
+	plain, _ := cs.SendMessage(session.ID, "staff1", "Sorry to hear that", MessageTypeText)
+	assert.Nil(t, plain.Headers)
+
+	// 写入存储后读回
+	stored, _ := store.LoadMessages(session.ID)
+	assert.Equal(t, "negative", stored[0].Headers["sentiment"])
+
+	// 为空时不出现在JSON中
+	data, _ := json.Marshal(plain)
+	assert.NotContains(t, string(data), "headers")
+
+	// 导出快照经JSON往返后恢复，附加信息保留
+	data, err = json.Marshal(cs.Snapshot())
+	assert.NoError(t, err)
+	var snapshots []SessionSnapshot
+	assert.NoError(t, json.Unmarshal(data, &snapshots))
+	restored := NewCustomerService()
+	defer restored.Shutdown()
+	restored.CreateGroup("group1", "TestGroup")
+	restored.ConnectUser("user1", "TestUser", nil)
+	restored.ConnectStaff("staff1", "TestStaff", "group1", nil)
+	assert.NoError(t, restored.RestoreFrom(snapshots))
+	messages := restored.GetSession(session.ID).Messages
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, map[string]string{"sentiment": "negative"}, messages[0].Headers)
+		assert.Nil(t, messages[1].Headers)
+	}
+}
+
+func TestCustomerService_ForwardKeepsHeaders(t *testing.T) {
+	cs := NewCustomerService(WithMessageFilter(sentimentFilter))
+	defer cs.Shutdown()
+
+	source := createTestSession(t, cs, "user1", "staff1")
+	target := createTestSession(t, cs, "user2", "staff1")
+	msg, _ := cs.SendMessage(source.ID, "user1", "angry again", MessageTypeText)
+
+	forwarded, err := cs.ForwardMessage(source.ID, msg.ID, target.ID, "staff1")
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Headers, forwarded.Headers)
+
+	// 转发的消息有独立的副本
+	forwarded.SetHeader("intent", "escalate")
+	assert.NotContains(t, msg.Headers, "intent")
+}
@@ -0,0 +1,55 @@
+package customer_service
+
+import "time"
+
+// reaperStallFactor 巡检超过该倍数的间隔仍未完成时视为协程已卡住
+const reaperStallFactor = 3
+
+// StorePinger 可选的存储连通性检查，存储实现该接口时健康检查会调用Ping
+type StorePinger interface {
+	Ping() error
+}
+
+// HealthStatus 服务健康状态，巡检协程和存储均正常时Healthy为true
+type HealthStatus struct {
+	Healthy      bool   `json:"healthy"`
+	ReaperAlive  bool   `json:"reaper_alive"` // 未开启巡检时为true
+	StoreOK      bool   `json:"store_ok"`     // 未配置存储或存储不支持Ping时为true
+	StoreError   string `json:"store_error,omitempty"`
+	OpenSessions int    `json:"open_sessions"`
+	MaxSessions  int    `json:"max_sessions"` // 0表示不限
+	QueuedUsers  int    `json:"queued_users"`
+	AtCapacity   bool   `json:"at_capacity"` // 未关闭的会话数已达系统上限
+}
+
+// Health 检查服务健康状态，存储的Ping在锁外调用
+func (cs *CustomerService) Health() HealthStatus {
+	cs.mu.RLock()
+	status := HealthStatus{
+		ReaperAlive: true,
+		StoreOK:     true,
+		MaxSessions: cs.maxSessions,
+		QueuedUsers: len(cs.waiting),
+		AtCapacity:  cs.atSystemCapacityLocked(),
+	}
+	for _, session := range cs.sessions {
+		if session.Status != SessionStatusClosed {
+			status.OpenSessions++
+		}
+	}
+	reaper, interval, store := cs.reaper, cs.reapInterval, cs.store
+	cs.mu.RUnlock()
+
+	if reaper != nil {
+		lastBeat := time.Unix(0, reaper.beat.Load())
+		status.ReaperAlive = time.Since(lastBeat) <= reaperStallFactor*interval
+	}
+	if pinger, ok := store.(StorePinger); ok {
+		if err := pinger.Ping(); err != nil {
+			status.StoreOK = false
+			status.StoreError = err.Error()
+		}
+	}
+	status.Healthy = status.ReaperAlive && status.StoreOK
+	return status
+}
@@ -0,0 +1,62 @@
+package customer_service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pingStore 可以模拟连通性故障的存储
+type pingStore struct {
+	*MemoryStore
+	err error
+}
+
+func (s *pingStore) Ping() error {
+	return s.err
+}
+
+func TestCustomerService_Health(t *testing.T) {
+	store := &pingStore{MemoryStore: NewMemoryStore()}
+	cs := NewCustomerService(
+		WithStore(store),
+		WithUserSilencePolicy(time.Minute, SilenceActionClose),
+		WithReapInterval(10*time.Millisecond),
+	)
+	defer cs.Shutdown()
+
+	createTestSession(t, cs, "user1", "staff1")
+	cs.SetMaxSessions(1)
+
+	health := cs.Health()
+	assert.True(t, health.Healthy)
+	assert.True(t, health.ReaperAlive)
+	assert.True(t, health.StoreOK)
+	assert.Equal(t, 1, health.OpenSessions)
+	assert.Equal(t, 1, health.MaxSessions)
+	assert.True(t, health.AtCapacity)
+
+	// 存储不可达时不健康
+	store.err = errors.New("connection refused")
+	health = cs.Health()
+	assert.False(t, health.Healthy)
+	assert.False(t, health.StoreOK)
+	assert.Equal(t, "connection refused", health.StoreError)
+}
+
+func TestCustomerService_HealthReaperStalled(t *testing.T) {
+	cs := NewCustomerService(
+		WithUserSilencePolicy(time.Minute, SilenceActionClose),
+		WithReapInterval(time.Hour),
+	)
+	defer cs.Shutdown()
+	assert.True(t, cs.Health().ReaperAlive)
+
+	// 超过3个巡检间隔未完成巡检视为卡住
+	cs.reaper.beat.Store(time.Now().Add(-4 * time.Hour).UnixNano())
+	health := cs.Health()
+	assert.False(t, health.ReaperAlive)
+	assert.False(t, health.Healthy)
+}
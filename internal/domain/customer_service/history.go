@@ -0,0 +1,88 @@
+package customer_service
+
+import "math"
+
+// WithMaxInMemoryMessages 设置每个会话在内存中保留的消息条数上限，超出时淘汰最早的消息，0表示不限
+// 配置了存储时被淘汰的消息仍可通过GetMessages从存储分页读取，否则直接丢弃
+func WithMaxInMemoryMessages(n int) Option {
+	return func(cs *CustomerService) {
+		cs.maxInMemoryMessages = n
+	}
+}
+
+// evictMessagesLocked 淘汰超出上限的最早消息，调用方需持有cs.mu
+// 消息在发送时已交给存储，这里只释放内存中的引用，LastMessage不受影响
+func (cs *CustomerService) evictMessagesLocked(session *Session) {
+	excess := len(session.Messages) - cs.maxInMemoryMessages
+	if cs.maxInMemoryMessages <= 0 || excess <= 0 {
+		return
+	}
+	// 置空被淘汰的位置，底层数组扩容时不再持有旧消息
+	for i := 0; i < excess; i++ {
+		session.Messages[i] = nil
+	}
+	session.Messages = session.Messages[excess:]
+	session.msgCount -= excess
+	cs.totalMessages.Add(-int64(excess))
+}
+
+// GetMessages 按序号倒序分页读取会话消息，返回序号小于beforeSeq的最近limit条，按时间正序排列
+// beforeSeq不大于0时从最新一条开始；内存中不足时从存储读取已淘汰的更早消息
+func (cs *CustomerService) GetMessages(sessionID string, beforeSeq int64, limit int) ([]*Message, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidOperation
+	}
+	if beforeSeq <= 0 {
+		beforeSeq = math.MaxInt64
+	}
+
+	cs.mu.RLock()
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		cs.mu.RUnlock()
+		return nil, ErrSessionNotFound
+	}
+	inMemory := make([]*Message, len(session.Messages))
+	copy(inMemory, session.Messages)
+	store, writer := cs.store, cs.writer
+	cs.mu.RUnlock()
+
+	page := pageMessages(inMemory, beforeSeq, limit)
+	if len(page) == limit || store == nil {
+		return page, nil
+	}
+
+	// 内存中最早的消息之前可能还有已淘汰的消息
+	if len(inMemory) > 0 && inMemory[0].Seq < beforeSeq {
+		beforeSeq = inMemory[0].Seq
+	}
+	if beforeSeq <= 1 {
+		return page, nil
+	}
+	if writer != nil {
+		if err := writer.flush(); err != nil {
+			return nil, err
+		}
+	}
+	stored, err := store.LoadMessages(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	older := pageMessages(stored, beforeSeq, limit-len(page))
+	return append(older, page...), nil
+}
+
+// pageMessages 从按序号递增排列的消息中取序号小于beforeSeq的最后limit条
+func pageMessages(messages []*Message, beforeSeq int64, limit int) []*Message {
+	end := len(messages)
+	for end > 0 && messages[end-1].Seq >= beforeSeq {
+		end--
+	}
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+	page := make([]*Message, end-start)
+	copy(page, messages[start:end])
+	return page
+}
@@ -0,0 +1,87 @@
+package customer_service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func messageContents(msgs []*Message) []string {
+	contents := make([]string, len(msgs))
+	for i, msg := range msgs {
+		contents[i] = msg.Content
+	}
+	return contents
+}
+
+func TestCustomerService_MaxInMemoryMessages(t *testing.T) {
+	cs := NewCustomerService(WithStore(NewMemoryStore()), WithMaxInMemoryMessages(3))
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	for i := 1; i <= 8; i++ {
+		_, err := cs.SendMessage(session.ID, "user1", fmt.Sprintf("msg%d", i), MessageTypeText)
+		assert.NoError(t, err)
+	}
+
+	// 内存中只保留最近3条
+	assert.Equal(t, []string{"msg6", "msg7", "msg8"}, messageContents(session.Messages))
+	last, err := cs.LastMessage(session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "msg8", last.Content)
+
+	// 最新一页直接来自内存
+	page, err := cs.GetMessages(session.ID, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"msg7", "msg8"}, messageContents(page))
+
+	// 跨越内存边界时从存储补齐更早的消息
+	page, err = cs.GetMessages(session.ID, page[0].Seq, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"msg3", "msg4", "msg5", "msg6"}, messageContents(page))
+
+	page, err = cs.GetMessages(session.ID, page[0].Seq, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"msg1", "msg2"}, messageContents(page))
+
+	page, err = cs.GetMessages(session.ID, page[0].Seq, 4)
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+
+	_, err = cs.GetMessages(session.ID, 0, 0)
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+	_, err = cs.GetMessages("missing", 0, 1)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestCustomerService_MaxInMemoryMessagesWithoutStore(t *testing.T) {
+	cs := NewCustomerService(WithMaxInMemoryMessages(2))
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	for i := 1; i <= 5; i++ {
+		_, err := cs.SendMessage(session.ID, "staff1", fmt.Sprintf("msg%d", i), MessageTypeText)
+		assert.NoError(t, err)
+	}
+
+	// 没有存储时淘汰的消息直接丢弃
+	page, err := cs.GetMessages(session.ID, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"msg4", "msg5"}, messageContents(page))
+}
+
+func TestCustomerService_MaxInMemoryMessagesBatchStore(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store), WithStoreBatch(100, 0), WithMaxInMemoryMessages(2))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	for i := 1; i <= 4; i++ {
+		_, err := cs.SendMessage(session.ID, "user1", fmt.Sprintf("msg%d", i), MessageTypeText)
+		assert.NoError(t, err)
+	}
+
+	// 批量写入尚未刷出的消息在分页前先写入存储
+	page, err := cs.GetMessages(session.ID, 0, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"msg1", "msg2", "msg3", "msg4"}, messageContents(page))
+}
@@ -0,0 +1,93 @@
+package customer_service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// BatchError 批量操作中部分条目失败，Errors按条目ID记录失败原因
+type BatchError struct {
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%s: %v", id, e.Errors[id])
+	}
+	return fmt.Sprintf("%d failed: %s", len(ids), strings.Join(parts, "; "))
+}
+
+// Unwrap 支持用errors.Is判断是否包含某种失败
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// GetMessagesBatch 一次读取多个会话各自最近的limitEach条消息，按时间正序排列，用于客服批量接手会话。
+// 内存中的消息在一次加锁内读取，不足时再从存储补齐已淘汰的消息。
+// 不存在或读取失败的会话不出现在结果中，失败原因通过*BatchError返回，其余会话的结果照常返回
+func (cs *CustomerService) GetMessagesBatch(sessionIDs []string, limitEach int) (map[string][]*Message, error) {
+	if limitEach <= 0 {
+		return nil, ErrInvalidOperation
+	}
+
+	result := make(map[string][]*Message, len(sessionIDs))
+	failed := make(map[string]error)
+	// 内存中的消息不足一页的会话，记录内存中最早消息的序号，需要从存储补齐更早的消息
+	incomplete := make(map[string]int64)
+
+	cs.mu.RLock()
+	for _, id := range sessionIDs {
+		session, exists := cs.sessions[id]
+		if !exists {
+			failed[id] = ErrSessionNotFound
+			continue
+		}
+		page := pageMessages(session.Messages, math.MaxInt64, limitEach)
+		result[id] = page
+		if len(page) < limitEach && session.msgSeq > int64(len(page)) {
+			oldest := session.msgSeq + 1
+			if len(page) > 0 {
+				oldest = page[0].Seq
+			}
+			incomplete[id] = oldest
+		}
+	}
+	store, writer := cs.store, cs.writer
+	cs.mu.RUnlock()
+
+	if store != nil && len(incomplete) > 0 {
+		if writer != nil {
+			if err := writer.flush(); err != nil {
+				return nil, err
+			}
+		}
+		for id, beforeSeq := range incomplete {
+			stored, err := store.LoadMessages(id)
+			if err != nil {
+				delete(result, id)
+				failed[id] = err
+				continue
+			}
+			older := pageMessages(stored, beforeSeq, limitEach-len(result[id]))
+			result[id] = append(older, result[id]...)
+		}
+	}
+
+	if len(failed) > 0 {
+		return result, &BatchError{Errors: failed}
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package customer_service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_GetMessagesBatch(t *testing.T) {
+	cs := NewCustomerService(WithStore(NewMemoryStore()), WithMaxInMemoryMessages(2))
+	defer cs.Shutdown()
+
+	first := createTestSession(t, cs, "user1", "staff1")
+	second := createTestSession(t, cs, "user2", "staff1")
+	empty := createTestSession(t, cs, "user3", "staff1")
+	for i := 1; i <= 5; i++ {
+		_, err := cs.SendMessage(first.ID, "user1", fmt.Sprintf("a%d", i), MessageTypeText)
+		assert.NoError(t, err)
+	}
+	_, err := cs.SendMessage(second.ID, "user2", "b1", MessageTypeText)
+	assert.NoError(t, err)
+
+	batch, err := cs.GetMessagesBatch([]string{first.ID, second.ID, empty.ID, "missing"}, 3)
+
+	// 不存在的会话不出现在结果中，原因记录在错误里
+	var batchErr *BatchError
+	assert.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, map[string]error{"missing": ErrSessionNotFound}, batchErr.Errors)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	assert.NotContains(t, batch, "missing")
+
+	// 内存中只保留2条，第3条从存储补齐
+	assert.Equal(t, []string{"a3", "a4", "a5"}, messageContents(batch[first.ID]))
+	assert.Equal(t, []string{"b1"}, messageContents(batch[second.ID]))
+	assert.Contains(t, batch, empty.ID)
+	assert.Empty(t, batch[empty.ID])
+
+	batch, err = cs.GetMessagesBatch([]string{first.ID}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a5"}, messageContents(batch[first.ID]))
+
+	_, err = cs.GetMessagesBatch([]string{first.ID}, 0)
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+}
@@ -0,0 +1,103 @@
+package customer_service
+
+import "time"
+
+// defaultOutOfHoursReply 非营业时间的默认自动回复
+const defaultOutOfHoursReply = "当前不在服务时间，您可以留言，我们会在上班后尽快联系您"
+
+// TimeRange 一天内的营业时间段，以距零点的时长表示，End小于Start时表示跨越午夜
+type TimeRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// contains 判断距零点的时长是否落在时间段内
+func (r TimeRange) contains(offset time.Duration) bool {
+	if r.Start <= r.End {
+		return offset >= r.Start && offset < r.End
+	}
+	return offset >= r.Start || offset < r.End
+}
+
+// BusinessHours 客服组营业时间
+type BusinessHours struct {
+	Days      []time.Weekday // 营业日，为空表示每天
+	Ranges    []TimeRange    // 营业时间段，为空表示全天
+	Location  *time.Location // 时区，为空时使用UTC
+	AutoReply string         // 非营业时间的自动回复，为空时使用默认回复
+}
+
+// isOpen 判断给定时刻是否在营业时间内
+func (h *BusinessHours) isOpen(at time.Time) bool {
+	loc := h.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := at.In(loc)
+
+	if len(h.Days) > 0 {
+		open := false
+		for _, day := range h.Days {
+			if day == local.Weekday() {
+				open = true
+				break
+			}
+		}
+		if !open {
+			return false
+		}
+	}
+
+	if len(h.Ranges) == 0 {
+		return true
+	}
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	offset := local.Sub(midnight)
+	for _, r := range h.Ranges {
+		if r.contains(offset) {
+			return true
+		}
+	}
+	return false
+}
+
+// autoReply 非营业时间的自动回复
+func (h *BusinessHours) autoReply() string {
+	if h.AutoReply != "" {
+		return h.AutoReply
+	}
+	return defaultOutOfHoursReply
+}
+
+// SetBusinessHours 设置客服组营业时间，hours为空时恢复全天服务
+func (cs *CustomerService) SetBusinessHours(groupID string, hours *BusinessHours) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	group.BusinessHours = hours
+	return nil
+}
+
+// IsOpen 判断客服组在给定时刻是否营业，不存在的组视为不营业
+func (cs *CustomerService) IsOpen(groupID string, at time.Time) bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return false
+	}
+	return group.BusinessHours == nil || group.BusinessHours.isOpen(at)
+}
+
+// checkOpenLocked 非营业时间返回携带自动回复内容的ErrOutOfHours，调用方需持有cs.mu
+func (cs *CustomerService) checkOpenLocked(group *CSGroup, at time.Time) error {
+	if group.BusinessHours == nil || group.BusinessHours.isOpen(at) {
+		return nil
+	}
+	return NewServiceError(CodeOutOfHours, group.BusinessHours.autoReply())
+}
@@ -0,0 +1,64 @@
+package customer_service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_IsOpen(t *testing.T) {
+	cs := NewCustomerService()
+	cs.CreateGroup("group1", "TestGroup")
+	shanghai := time.FixedZone("CST", 8*3600)
+
+	// 未设置营业时间时全天服务
+	assert.True(t, cs.IsOpen("group1", time.Now()))
+	assert.False(t, cs.IsOpen("nonexistent", time.Now()))
+
+	assert.NoError(t, cs.SetBusinessHours("group1", &BusinessHours{
+		Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Ranges:   []TimeRange{{Start: 9 * time.Hour, End: 17 * time.Hour}},
+		Location: shanghai,
+	}))
+	assert.Equal(t, ErrGroupNotFound, cs.SetBusinessHours("nonexistent", nil))
+
+	// 2024-06-03 为周一
+	assert.True(t, cs.IsOpen("group1", time.Date(2024, 6, 3, 10, 0, 0, 0, shanghai)))
+	assert.False(t, cs.IsOpen("group1", time.Date(2024, 6, 3, 20, 0, 0, 0, shanghai)))
+	assert.False(t, cs.IsOpen("group1", time.Date(2024, 6, 3, 17, 0, 0, 0, shanghai)))
+	// 按组的时区判断：UTC 02:00 即上海 10:00
+	assert.True(t, cs.IsOpen("group1", time.Date(2024, 6, 3, 2, 0, 0, 0, time.UTC)))
+	// 周末不营业
+	assert.False(t, cs.IsOpen("group1", time.Date(2024, 6, 8, 10, 0, 0, 0, shanghai)))
+
+	// 跨越午夜的时间段
+	cs.SetBusinessHours("group1", &BusinessHours{Ranges: []TimeRange{{Start: 22 * time.Hour, End: 6 * time.Hour}}})
+	assert.True(t, cs.IsOpen("group1", time.Date(2024, 6, 3, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, cs.IsOpen("group1", time.Date(2024, 6, 4, 5, 0, 0, 0, time.UTC)))
+	assert.False(t, cs.IsOpen("group1", time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestCustomerService_EnqueueOutOfHours(t *testing.T) {
+	cs := NewCustomerService()
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+
+	// 今天不在营业日内
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Weekday()
+	cs.SetBusinessHours("group1", &BusinessHours{
+		Days:      []time.Weekday{tomorrow},
+		AutoReply: "明天再来",
+	})
+
+	err := cs.EnqueueUser("user1", "group1")
+	assert.True(t, errors.Is(err, ErrOutOfHours))
+	assert.Equal(t, "明天再来", err.Error())
+	assert.Empty(t, cs.QueuedUsers("group1"))
+
+	// 营业时间内可以排队
+	cs.SetBusinessHours("group1", nil)
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+}
@@ -0,0 +1,49 @@
+package customer_service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+)
+
+// IDGenerator 会话ID生成器，生成的ID不应包含用户或客服ID等内部标识
+type IDGenerator interface {
+	// NewSessionID 生成一个新的会话ID，需保证唯一
+	NewSessionID() string
+}
+
+// WithIDGenerator 设置会话ID生成器，默认生成随机ID
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(cs *CustomerService) {
+		cs.idGen = gen
+	}
+}
+
+// RandomIDGenerator 生成128位随机十六进制ID
+type RandomIDGenerator struct{}
+
+// NewSessionID 生成随机会话ID
+func (RandomIDGenerator) NewSessionID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("customer_service: read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// CounterIDGenerator 生成“前缀+递增序号”形式的ID，可以在多个服务实例间共享
+type CounterIDGenerator struct {
+	prefix string
+	n      int64
+}
+
+// NewCounterIDGenerator 创建前缀加序号的ID生成器
+func NewCounterIDGenerator(prefix string) *CounterIDGenerator {
+	return &CounterIDGenerator{prefix: prefix}
+}
+
+// NewSessionID 生成下一个序号ID
+func (g *CounterIDGenerator) NewSessionID() string {
+	return g.prefix + strconv.FormatInt(atomic.AddInt64(&g.n, 1), 10)
+}
@@ -0,0 +1,35 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_SessionIDScheme(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	// 默认随机ID不包含参与者ID，同一秒内重复创建也不会冲突
+	first := createTestSession(t, cs, "user1", "staff1")
+	assert.NoError(t, cs.CloseSession(first.ID, "user1"))
+	second, err := cs.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	for _, session := range []*Session{first, second} {
+		assert.Len(t, session.ID, 32)
+		assert.NotContains(t, session.ID, "user1")
+		assert.NotContains(t, session.ID, "staff1")
+	}
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Same(t, second, cs.GetSession(second.ID))
+
+	// 自定义前缀加序号
+	cs2 := NewCustomerService(WithIDGenerator(NewCounterIDGenerator("cs-")))
+	defer cs2.Shutdown()
+	session := createTestSession(t, cs2, "user1", "staff1")
+	assert.Equal(t, "cs-1", session.ID)
+	msg, err := cs2.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, "cs-1_1", msg.ID)
+	assert.Same(t, session, cs2.GetSession("cs-1"))
+}
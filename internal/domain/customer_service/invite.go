@@ -0,0 +1,54 @@
+package customer_service
+
+// InviteStaffToUser 主管提醒指定客服接待指定的排队用户，向客服发出assignment_invite邀请，
+// 客服通过AcceptOffer或DeclineOffer处理，不会自动分配。接受后创建会话并将用户移出队列；
+// 拒绝或超时后邀请撤销，用户继续按正常方式排队分配。用户已有其他客服的待处理邀请时改为邀请该客服
+func (cs *CustomerService) InviteStaffToUser(staffID, userID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return ErrStaffNotFound
+	}
+	if _, exists := cs.users[userID]; !exists {
+		return ErrUserNotFound
+	}
+	entry, queued := cs.waiting[userID]
+	if !queued {
+		return ErrInvalidOperation
+	}
+	if staff.Status != UserStatusOnline || staff.unresponsive {
+		return ErrStaffUnavailable
+	}
+	if cs.groups[staff.GroupID].draining {
+		return ErrGroupDraining
+	}
+
+	offer, offered := cs.offers[cs.userOffers[userID]]
+	if offered && offer.StaffID == staffID {
+		// 已向该客服发出邀请，只改为指定邀请并重新计时
+		offer.invite = true
+		cs.assignOfferLocked(offer, staff)
+		return nil
+	}
+	if cs.atCapacityLocked(staff) {
+		return ErrStaffAtCapacity
+	}
+	if offered {
+		cs.removeOfferLocked(offer)
+	}
+
+	offer = cs.newOfferLocked(userID, entry.GroupID)
+	offer.invite = true
+	cs.assignOfferLocked(offer, staff)
+	return nil
+}
+
+// withdrawInviteLocked 撤销被拒绝或超时的指定邀请，用户仍在排队时重新按正常方式分配，调用方需持有cs.mu
+func (cs *CustomerService) withdrawInviteLocked(offer *Offer) {
+	cs.removeOfferLocked(offer)
+	if entry, queued := cs.waiting[offer.UserID]; queued {
+		cs.dispatchLocked(entry, map[string]bool{offer.StaffID: true})
+	}
+}
@@ -0,0 +1,92 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordInvites 记录发给客服的指定邀请和普通邀请
+func recordInvites(cs *CustomerService) (invites, offers <-chan Offer) {
+	inviteCh := make(chan Offer, 16)
+	offerCh := make(chan Offer, 16)
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		switch eventType {
+		case EventAssignmentInvite:
+			inviteCh <- payload.(Offer)
+		case EventSessionOffer:
+			offerCh <- payload.(Offer)
+		}
+	})
+	return inviteCh, offerCh
+}
+
+func TestCustomerService_InviteStaffToUser(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	invites, offers := recordInvites(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+	cs.ConnectStaff("staff1", "TestStaff1", "group1", nil)
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	cs.SetAutoAccept("staff2", true)
+
+	// 客服上线时排队用户先邀请给staff1
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "staff1", offer.StaffID)
+
+	assert.Equal(t, ErrStaffNotFound, cs.InviteStaffToUser("missing", "user1"))
+	assert.Equal(t, ErrUserNotFound, cs.InviteStaffToUser("staff1", "missing"))
+
+	// 指定邀请不会自动分配给自动接入的客服
+	assert.NoError(t, cs.InviteStaffToUser("staff2", "user1"))
+	invite := receiveOffer(t, invites)
+	assert.Equal(t, "staff2", invite.StaffID)
+	assert.Equal(t, "user1", invite.UserID)
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+	assert.Empty(t, cs.GetUser("user1").SessionID)
+	assert.Nil(t, cs.GetOffer(offer.ID))
+
+	session, err := cs.AcceptOffer("staff2", invite.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "staff2", session.StaffID)
+	assert.Empty(t, cs.QueuedUsers("group1"))
+	assert.Nil(t, cs.GetOffer(invite.ID))
+
+	// 不在排队中的用户不能邀请
+	assert.Equal(t, ErrInvalidOperation, cs.InviteStaffToUser("staff1", "user1"))
+	select {
+	case offer := <-offers:
+		t.Fatalf("unexpected offer: %+v", offer)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCustomerService_InviteStaffDeclined(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	invites, offers := recordInvites(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectStaff("staff1", "TestStaff1", "group1", nil)
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "staff1", offer.StaffID)
+
+	// 用户已有邀请时改为邀请指定客服
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	assert.NoError(t, cs.InviteStaffToUser("staff2", "user1"))
+	invite := receiveOffer(t, invites)
+	assert.Equal(t, "staff2", invite.StaffID)
+	assert.Nil(t, cs.GetOffer(offer.ID))
+
+	// 拒绝后不转给其他客服的指定邀请，用户按正常方式重新分配
+	assert.NoError(t, cs.DeclineOffer("staff2", invite.ID))
+	offer = receiveOffer(t, offers)
+	assert.Equal(t, "staff1", offer.StaffID)
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+}
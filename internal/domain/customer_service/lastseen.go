@@ -0,0 +1,145 @@
+package customer_service
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultAwayAfter 默认连接无活动多久后视为离开
+const defaultAwayAfter = 5 * time.Minute
+
+// PresenceState 根据连接活动推断的在线状态，与客服手动设置的状态相互独立
+type PresenceState string
+
+const (
+	PresenceOnline  PresenceState = "online"  // 最近有入站消息或pong
+	PresenceAway    PresenceState = "away"    // 连接仍在但超过awayAfter没有活动
+	PresenceOffline PresenceState = "offline" // 未连接或处于断线重连宽限期
+)
+
+// PresenceView 用户或客服的推断在线状态，供主管查看
+type PresenceView struct {
+	ID         string        `json:"id"`
+	Role       PresenceRole  `json:"role"`
+	State      PresenceState `json:"state"`
+	LastSeenAt time.Time     `json:"last_seen_at"`
+}
+
+// WithAwayAfter 设置连接超过d没有入站消息或pong时推断为离开，小于等于0时只区分在线和离线
+func WithAwayAfter(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.awayAfter = d
+	}
+}
+
+// Touch 记录收到用户或客服的入站消息，刷新最近活跃时间
+func (cs *CustomerService) Touch(role PresenceRole, id string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	now := cs.now()
+	switch role {
+	case PresenceRoleUser:
+		if user, exists := cs.users[id]; exists {
+			user.lastSeenAt = now
+		}
+	case PresenceRoleStaff:
+		if staff, exists := cs.staffs[id]; exists {
+			staff.lastSeenAt = now
+			cs.recoverStaffLocked(staff)
+		}
+	}
+}
+
+// LastSeen 返回用户或客服最近一次活跃的时间，先按用户ID查找，ID不存在时返回false
+func (cs *CustomerService) LastSeen(id string) (time.Time, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if user, exists := cs.users[id]; exists {
+		return user.lastSeenAt, true
+	}
+	if staff, exists := cs.staffs[id]; exists {
+		return staff.lastSeenAt, true
+	}
+	return time.Time{}, false
+}
+
+// Presence 返回用户或客服当前的推断在线状态，ID不存在时为offline
+func (cs *CustomerService) Presence(id string) PresenceState {
+	return cs.presenceAt(id, cs.now())
+}
+
+// presenceAt 按指定时间推断在线状态
+func (cs *CustomerService) presenceAt(id string, now time.Time) PresenceState {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if user, exists := cs.users[id]; exists {
+		return cs.userPresenceLocked(user, now)
+	}
+	if staff, exists := cs.staffs[id]; exists {
+		return cs.staffPresenceLocked(staff, now)
+	}
+	return PresenceOffline
+}
+
+// ListPresence 返回全部用户和客服的推断在线状态，按角色、ID排序
+func (cs *CustomerService) ListPresence() []PresenceView {
+	return cs.listPresenceAt(cs.now())
+}
+
+// listPresenceAt 按指定时间推断全部用户和客服的在线状态
+func (cs *CustomerService) listPresenceAt(now time.Time) []PresenceView {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	views := make([]PresenceView, 0, len(cs.users)+len(cs.staffs))
+	for _, user := range cs.users {
+		views = append(views, PresenceView{
+			ID:         user.ID,
+			Role:       PresenceRoleUser,
+			State:      cs.userPresenceLocked(user, now),
+			LastSeenAt: user.lastSeenAt,
+		})
+	}
+	for _, staff := range cs.staffs {
+		views = append(views, PresenceView{
+			ID:         staff.ID,
+			Role:       PresenceRoleStaff,
+			State:      cs.staffPresenceLocked(staff, now),
+			LastSeenAt: staff.lastSeenAt,
+		})
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Role != views[j].Role {
+			return views[i].Role < views[j].Role
+		}
+		return views[i].ID < views[j].ID
+	})
+	return views
+}
+
+// userPresenceLocked 推断用户的在线状态，断线重连宽限期内视为离线，调用方需持有cs.mu
+func (cs *CustomerService) userPresenceLocked(user *User, now time.Time) PresenceState {
+	if user.graceTimer != nil {
+		return PresenceOffline
+	}
+	return cs.derivePresence(user.lastSeenAt, now)
+}
+
+// staffPresenceLocked 推断客服的在线状态，漏回pong的客服视为离开，调用方需持有cs.mu
+func (cs *CustomerService) staffPresenceLocked(staff *CSStaff, now time.Time) PresenceState {
+	if staff.unresponsive {
+		return PresenceAway
+	}
+	return cs.derivePresence(staff.lastSeenAt, now)
+}
+
+// derivePresence 根据最近活跃时间推断已连接的用户或客服是在线还是离开
+func (cs *CustomerService) derivePresence(lastSeenAt, now time.Time) PresenceState {
+	if cs.awayAfter > 0 && now.Sub(lastSeenAt) >= cs.awayAfter {
+		return PresenceAway
+	}
+	return PresenceOnline
+}
@@ -0,0 +1,67 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_LastSeen(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+	cs.ConnectStaff("staff1", "TestStaff", "group1", nil)
+
+	connectedAt, ok := cs.LastSeen("user1")
+	assert.True(t, ok)
+	assert.False(t, connectedAt.IsZero())
+
+	clock.Advance(time.Second)
+	cs.Touch(PresenceRoleUser, "user1")
+	seen, _ := cs.LastSeen("user1")
+	assert.True(t, seen.After(connectedAt))
+
+	// pong同样刷新活跃时间
+	staffSeen, ok := cs.LastSeen("staff1")
+	assert.True(t, ok)
+	clock.Advance(time.Second)
+	cs.RecordRTT(PresenceRoleStaff, "staff1", 10*time.Millisecond)
+	seen, _ = cs.LastSeen("staff1")
+	assert.True(t, seen.After(staffSeen))
+
+	_, ok = cs.LastSeen("nonexistent")
+	assert.False(t, ok)
+}
+
+func TestCustomerService_DerivedPresence(t *testing.T) {
+	cs := NewCustomerService(WithAwayAfter(time.Minute))
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+	cs.ConnectStaff("staff1", "TestStaff", "group1", nil)
+	assert.Equal(t, PresenceOnline, cs.Presence("user1"))
+
+	// 用户一直没有活动，超过时限后推断为离开，显式状态不受影响
+	later := time.Now().Add(2 * time.Minute)
+	assert.Equal(t, PresenceAway, cs.presenceAt("user1", later))
+	assert.Equal(t, UserStatusOnline, cs.GetUser("user1").Status)
+
+	// 客服在此期间有活动，仍为在线
+	cs.staffs["staff1"].lastSeenAt = later.Add(-10 * time.Second)
+	assert.Equal(t, []PresenceView{
+		{ID: "staff1", Role: PresenceRoleStaff, State: PresenceOnline, LastSeenAt: later.Add(-10 * time.Second)},
+		{ID: "user1", Role: PresenceRoleUser, State: PresenceAway, LastSeenAt: cs.users["user1"].lastSeenAt},
+	}, cs.listPresenceAt(later))
+
+	// 再次活动后恢复在线
+	cs.users["user1"].lastSeenAt = later
+	assert.Equal(t, PresenceOnline, cs.presenceAt("user1", later))
+
+	cs.DisconnectUser("user1", DisconnectClientClose)
+	assert.Equal(t, PresenceOffline, cs.Presence("user1"))
+}
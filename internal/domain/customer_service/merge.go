@@ -0,0 +1,190 @@
+package customer_service
+
+import (
+	"log"
+	"sort"
+)
+
+// MessageRewriter 可选的存储改写能力，存储实现该接口时合并会话会按新的序号重写合并后的历史，
+// 否则存储中的历史保持合并前的状态
+type MessageRewriter interface {
+	// ReplaceMessages 以msgs替换会话已存储的全部消息，msgs为空时删除会话的消息
+	ReplaceMessages(sessionID string, msgs []*Message) error
+}
+
+// ReplaceMessages 替换会话的全部消息，保存副本
+func (s *MemoryStore) ReplaceMessages(sessionID string, msgs []*Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(msgs) == 0 {
+		delete(s.messages, sessionID)
+		return nil
+	}
+	stored := make([]*Message, len(msgs))
+	for i, msg := range msgs {
+		stored[i] = snapshotMessage(msg)
+	}
+	s.messages[sessionID] = stored
+	return nil
+}
+
+// ReplaceMessages 底层存储支持改写时加密后透传，否则忽略
+func (s *encryptedStore) ReplaceMessages(sessionID string, msgs []*Message) error {
+	rewriter, ok := s.SessionStore.(MessageRewriter)
+	if !ok {
+		return nil
+	}
+	encrypted := make([]*Message, len(msgs))
+	for i, msg := range msgs {
+		content, err := s.enc.Encrypt([]byte(msg.Content))
+		if err != nil {
+			return err
+		}
+		m := *msg
+		m.Content = string(content)
+		encrypted[i] = &m
+	}
+	return rewriter.ReplaceMessages(sessionID, encrypted)
+}
+
+// MergeSessions 将同一用户的两个会话合并
+// 次会话的消息按时间顺序并入主会话并保留原发送者和时间，随后关闭次会话，用户指向主会话。
+// 合并后的消息按时间重新编号并按主会话和新序号重新生成ID，内存中已淘汰的消息从存储补齐后一并编号。
+// 编号作用在消息副本上，已交给调用方的消息保持不变。存储支持改写时先重写存储，重写失败时不做合并
+func (cs *CustomerService) MergeSessions(primaryID, secondaryID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	primary, exists := cs.sessions[primaryID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	secondary, exists := cs.sessions[secondaryID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if primaryID == secondaryID || primary.UserID != secondary.UserID {
+		return ErrInvalidOperation
+	}
+	if primary.Status == SessionStatusClosed {
+		return ErrSessionClosed
+	}
+
+	// 缓冲中的消息先写入存储，避免重写后再追加旧序号的消息
+	if cs.store != nil && cs.writer != nil {
+		if err := cs.writer.flush(); err != nil {
+			return err
+		}
+	}
+	primaryHistory, err := cs.fullHistoryLocked(primary)
+	if err != nil {
+		return err
+	}
+	secondaryHistory, err := cs.fullHistoryLocked(secondary)
+	if err != nil {
+		return err
+	}
+
+	sorted := make([]*Message, 0, len(primaryHistory)+len(secondaryHistory))
+	sorted = append(sorted, primaryHistory...)
+	sorted = append(sorted, secondaryHistory...)
+	moved := make(map[*Message]bool, len(secondaryHistory))
+	for _, msg := range secondaryHistory {
+		moved[msg] = true
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreateAt.Before(sorted[j].CreateAt)
+	})
+	merged := make([]*Message, len(sorted))
+	for i, msg := range sorted {
+		merged[i] = retargetMessage(msg, primary, secondary, int64(i+1), moved[msg])
+	}
+
+	if rewriter, ok := cs.store.(MessageRewriter); ok {
+		if err := rewriter.ReplaceMessages(primary.ID, merged); err != nil {
+			return err
+		}
+		if err := rewriter.ReplaceMessages(secondary.ID, nil); err != nil {
+			log.Printf("Error removing stored messages of merged session %s: %v", secondary.ID, err)
+		}
+	}
+
+	// 内存中的消息数以合并结果为准，其中包括从存储补齐的消息，超出上限的部分随后淘汰
+	cs.totalMessages.Add(int64(len(merged) - primary.msgCount - secondary.msgCount))
+	primary.Messages = merged
+	if len(merged) > 0 {
+		primary.LastMessage = merged[len(merged)-1]
+	}
+	primary.msgSeq = int64(len(merged))
+	primary.msgCount = len(merged)
+	cs.evictMessagesLocked(primary)
+	if secondary.userActiveAt.After(primary.userActiveAt) {
+		primary.userActiveAt = secondary.userActiveAt
+	}
+	primary.UpdateAt = cs.now()
+
+	// 关闭次会话，其消息已归入主会话
+	event := SessionEvent{
+		SessionID: secondary.ID,
+		UserID:    secondary.UserID,
+		StaffID:   secondary.StaffID,
+		Reason:    ReasonMerged,
+	}
+	if secondary.Status != SessionStatusClosed {
+		cs.transitionLocked(secondary, SessionStatusClosed, ReasonMerged)
+	}
+	secondary.Messages = nil
+	secondary.LastMessage = nil
+	secondary.msgCount = 0
+	secondary.UpdateAt = primary.UpdateAt
+	cs.releaseSessionLocked(secondary)
+	if user, exists := cs.users[primary.UserID]; exists {
+		user.SessionID = primary.ID
+		// 离线或在重连宽限期内的用户保持离线，重连时再恢复会话状态
+		if user.Status != UserStatusOffline {
+			user.Status = UserStatusInSession
+		}
+	}
+	cs.emit(EventSessionClosed, event)
+
+	return nil
+}
+
+// fullHistoryLocked 获取会话的全部消息，内存中已淘汰的消息从存储补齐，补齐的是存储中消息的副本。
+// 调用方需持有cs.mu，且已写入批量缓冲中的消息
+func (cs *CustomerService) fullHistoryLocked(session *Session) ([]*Message, error) {
+	history := make([]*Message, len(session.Messages))
+	copy(history, session.Messages)
+
+	firstSeq := session.msgSeq + 1
+	if len(history) > 0 {
+		firstSeq = history[0].Seq
+	}
+	if cs.store == nil || firstSeq <= 1 {
+		return history, nil
+	}
+
+	stored, err := cs.store.LoadMessages(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	older := pageMessages(stored, firstSeq, len(stored))
+	for i, msg := range older {
+		older[i] = snapshotMessage(msg)
+	}
+	return append(older, history...), nil
+}
+
+// retargetMessage 返回消息在合并后的副本：归入主会话并使用新序号和对应的ID，
+// 来自次会话且由用户发出的消息改由主会话的客服接收。原消息不做修改
+func retargetMessage(msg *Message, primary, secondary *Session, seq int64, moved bool) *Message {
+	m := snapshotMessage(msg)
+	m.SessionID = primary.ID
+	m.Seq = seq
+	m.ID = messageID(primary.ID, seq)
+	if moved && m.FromID == secondary.UserID {
+		m.ToID = primary.StaffID
+	}
+	return m
+}
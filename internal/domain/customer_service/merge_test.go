@@ -0,0 +1,135 @@
+package customer_service
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_MergeSessions(t *testing.T) {
+	cs := NewCustomerService()
+	primary := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	secondary, _ := cs.CreateSession("user1", "staff2")
+
+	// 两个会话的消息交替产生
+	a1, _ := cs.SendMessage(primary.ID, "user1", "a1", MessageTypeText)
+	b1, _ := cs.SendMessage(secondary.ID, "user1", "b1", MessageTypeText)
+	a2, _ := cs.SendMessage(primary.ID, "staff1", "a2", MessageTypeText)
+	b2, _ := cs.SendMessage(secondary.ID, "staff2", "b2", MessageTypeText)
+	originals := []*Message{a1, b1, a2, b2}
+	before := make([]Message, len(originals))
+	for i, msg := range originals {
+		before[i] = *msg
+	}
+
+	assert.NoError(t, cs.MergeSessions(primary.ID, secondary.ID))
+
+	// 按时间交错合并，保留原发送者、内容和时间，ID按主会话和新序号生成
+	if assert.Len(t, primary.Messages, 4) {
+		for i, msg := range primary.Messages {
+			assert.NotSame(t, originals[i], msg)
+			assert.Equal(t, primary.ID, msg.SessionID)
+			assert.Equal(t, int64(i+1), msg.Seq)
+			assert.Equal(t, primary.ID+"_"+strconv.Itoa(i+1), msg.ID)
+			assert.Equal(t, before[i].FromID, msg.FromID)
+			assert.Equal(t, before[i].Content, msg.Content)
+			assert.Equal(t, before[i].CreateAt, msg.CreateAt)
+		}
+		assert.Equal(t, "staff1", primary.Messages[1].ToID)
+		assert.Same(t, primary.Messages[3], primary.LastMessage)
+	}
+
+	// 已交给调用方的消息不受合并影响
+	for i, msg := range originals {
+		assert.Equal(t, before[i], *msg)
+	}
+
+	// 次会话关闭，用户指向主会话
+	assert.Equal(t, SessionStatusClosed, secondary.Status)
+	assert.Empty(t, secondary.Messages)
+	assert.NotContains(t, cs.GetStaff("staff2").Sessions, secondary.ID)
+	assert.Equal(t, primary.ID, cs.GetUser("user1").SessionID)
+
+	// 合并后继续发送，序号连续
+	next, err := cs.SendMessage(primary.ID, "user1", "after merge", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), next.Seq)
+}
+
+func TestCustomerService_MergeSessionsErrors(t *testing.T) {
+	cs := NewCustomerService()
+	session1 := createTestSession(t, cs, "user1", "staff1")
+	session2 := createTestSession(t, cs, "user2", "staff1")
+
+	// 不同用户的会话不能合并
+	assert.Equal(t, ErrInvalidOperation, cs.MergeSessions(session1.ID, session2.ID))
+	assert.Equal(t, ErrInvalidOperation, cs.MergeSessions(session1.ID, session1.ID))
+	assert.Equal(t, ErrSessionNotFound, cs.MergeSessions("nonexistent", session1.ID))
+	assert.Equal(t, ErrSessionNotFound, cs.MergeSessions(session1.ID, "nonexistent"))
+
+	// 主会话已关闭
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	other, _ := cs.CreateSession("user1", "staff2")
+	cs.CloseSession(session1.ID, "staff1")
+	assert.Equal(t, ErrSessionClosed, cs.MergeSessions(session1.ID, other.ID))
+}
+
+func TestCustomerService_MergeSessionsRewritesStore(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store), WithStoreBatch(100, time.Hour), WithMaxInMemoryMessages(2))
+	defer cs.Shutdown()
+	primary := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	secondary, _ := cs.CreateSession("user1", "staff2")
+
+	contents := []string{"a1", "b1", "a2", "b2", "a3", "b3"}
+	for i, content := range contents {
+		sessionID := primary.ID
+		if i%2 == 1 {
+			sessionID = secondary.ID
+		}
+		_, err := cs.SendMessage(sessionID, "user1", content, MessageTypeText)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, cs.MergeSessions(primary.ID, secondary.ID))
+
+	// 内存中只保留最近2条，已淘汰的消息从存储按新序号分页读取，不缺不重
+	page, err := cs.GetMessages(primary.ID, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, contents, messageContents(page))
+	for i, msg := range page {
+		assert.Equal(t, int64(i+1), msg.Seq)
+		assert.Equal(t, primary.ID, msg.SessionID)
+	}
+	count, _ := cs.MessageCount(primary.ID)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 2, cs.Stats().TotalMessages)
+
+	stored, _ := store.LoadMessages(secondary.ID)
+	assert.Empty(t, stored)
+}
+
+func TestCustomerService_MergeSessionsOfflineUser(t *testing.T) {
+	cs := NewCustomerService(WithReconnectGrace(time.Minute))
+	defer cs.Shutdown()
+	primary := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	secondary, _ := cs.CreateSession("user1", "staff2")
+
+	// 宽限期内合并不改变离线状态，重连后恢复到主会话
+	token, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+	cs.DisconnectUser("user1", DisconnectError)
+	assert.NoError(t, cs.MergeSessions(primary.ID, secondary.ID))
+	user := cs.GetUser("user1")
+	assert.Equal(t, UserStatusOffline, user.Status)
+	assert.Equal(t, primary.ID, user.SessionID)
+
+	_, err = cs.ResumeUser(token, "user1", "TestUser", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, UserStatusInSession, cs.GetUser("user1").Status)
+}
@@ -14,48 +14,127 @@ const (
 	UserStatusOffline UserStatus = iota
 	UserStatusOnline
 	UserStatusInSession
+	UserStatusWrapUp // 会话结束后的整理状态，期间不分配新会话
+	UserStatusAway   // 客服暂时离开，保留现有会话但不分配新会话
 )
 
 // User 表示连接到系统的用户
 type User struct {
-	ID        string
-	Name      string
-	Status    UserStatus
-	Conn      *websocket.Conn
-	CreateAt  time.Time
-	SessionID string
-	mu        sync.RWMutex
+	ID         string
+	Name       string
+	Status     UserStatus
+	Conn       *websocket.Conn
+	CreateAt   time.Time
+	SessionID  string
+	Channel    string            // 接入渠道
+	GroupID    string            // 最近一次请求的客服组
+	RTT        time.Duration     // 连接往返时延的滑动平均
+	pending    []string          // 会话建立前缓存的消息内容
+	graceTimer Timer             // 断线重连宽限期计时，为空表示不在宽限期内
+	preChat    map[string]string // 会话前表单填写的字段，新建会话时写入会话自定义字段
+	ready      bool              // 是否已提交会话前表单
+	lastSeenAt time.Time         // 最近一次收到入站消息或pong的时间
+	mu         sync.RWMutex
 }
 
+// 接入渠道
+const (
+	ChannelWeb    = "web"
+	ChannelMobile = "mobile"
+	ChannelEmail  = "email"
+)
+
 // CSGroup 客服组
 type CSGroup struct {
-	ID      string
-	Name    string
-	Members map[string]*CSStaff
-	mu      sync.RWMutex
+	ID            string
+	Name          string
+	Members       map[string]*CSStaff
+	BusinessHours *BusinessHours            // 营业时间，为空表示全天服务
+	ShareDrafts   bool                      // 为true时客服可以看到用户正在输入的草稿
+	SLA           *SLAConfig                // 服务等级目标，为空表示不考核
+	canned        map[string]CannedResponse // 快捷回复，按Key索引，首次添加时创建，由cs.mu保护
+	handleTime    time.Duration             // 已结束会话时长的滑动平均，用于估算排队等待，由cs.mu保护
+	mu            sync.RWMutex
+
+	MaxGroupSessions int  // 组内客服同时处理的会话总数上限，0表示不限，通过SetMaxGroupSessions修改
+	draining         bool // 正在排空，不再分配新会话，由cs.mu保护
 }
 
 // CSStaff 客服人员
 type CSStaff struct {
-	ID       string
-	Name     string
-	GroupID  string
-	Status   UserStatus
-	Conn     *websocket.Conn
-	Sessions map[string]*Session // 当前处理的会话列表
-	mu       sync.RWMutex
+	ID          string
+	Name        string
+	GroupID     string
+	Status      UserStatus
+	Conn        *websocket.Conn
+	Sessions    map[string]*Session // 当前处理的会话列表
+	MaxSessions int                 // 同时处理的会话上限，0表示不限
+	AutoAccept  bool                // 为true时排队用户直接分配，否则发起邀请
+	Skills      []string            // 技能标签
+	Weight      float64             // 加权分配时的权重，小于等于0时按1计算
+	RTT         time.Duration       // 连接往返时延的滑动平均
+	wrapUpTimer Timer               // 整理状态结束计时
+	awayTimer   Timer               // 离开状态自动转接计时
+	lastSeenAt  time.Time           // 最近一次收到入站消息或pong的时间
+	mu          sync.RWMutex
+
+	focusSessionID string // 客服聚焦的会话，会话不再由该客服负责时失效
+	unresponsive   bool   // 漏回pong后推断为离开，不再分配新会话，收到pong或入站消息后恢复
+
+	NotificationPrefs map[string]bool // 各类辅助通知的开关，按事件类型索引，未设置的类型默认开启，由cs.mu保护
+}
+
+// Supervisor 主管，可以加入会话旁听或发言
+type Supervisor struct {
+	ID   string
+	Conn *websocket.Conn
 }
 
 // Session 会话
 type Session struct {
-	ID        string
-	UserID    string
-	StaffID   string
-	Status    SessionStatus
-	CreateAt  time.Time
-	UpdateAt  time.Time
-	Messages  []*Message
-	mu        sync.RWMutex
+	ID           string
+	UserID       string
+	StaffID      string
+	GroupID      string // 会话所属客服组
+	Channel      string // 会话来源渠道
+	Subject      string // 用户发起会话时声明的咨询主题
+	Status       SessionStatus
+	CreateAt     time.Time
+	UpdateAt     time.Time
+	Messages     []*Message
+	LastMessage  *Message          // 最后一条消息缓存，避免每次索引Messages
+	Supervisors  map[string]bool   // 加入会话的主管
+	joinedSeq    map[string]int64  // 主管加入时会话的消息序号，此前的消息已随记录发给主管
+	Orphaned     bool              // 恢复后客服已不在线，等待重新分配
+	Transfers    []TransferRecord  // 转接记录，按时间顺序
+	Rating       int               // 用户对会话的评分，1到5，0表示未评价
+	Variables    map[string]string // 集成方附加的自定义字段，通过SetVariable等方法在会话锁内读写
+	dedupe       *dedupeCache      // 最近的客户端消息ID，首次使用时创建
+	msgSeq       int64             // 会话内消息序号
+	msgCount     int               // 内存中保留的消息条数，撤回不影响，淘汰时减少
+	unread       map[string]int    // 各参与者的未读消息数，首次计数时创建
+	userActiveAt time.Time         // 用户最近一次发言时间
+	sla          *slaTracker       // 服务等级考核状态，所属组未配置时为空
+	mu           sync.RWMutex
+
+	FirstResponseAt       time.Time     // 客服首次回复用户的时间，尚未回复时为零值
+	FirstResponseDuration time.Duration // 用户首条消息到客服首次回复的时长
+	firstUserMessageAt    time.Time     // 用户首条消息的时间
+	notes                 []Note        // 客服内部备注，不属于会话消息，由cs.mu保护
+
+	roles map[string]Role // 单独指定了角色的参与者，其余参与者按身份取默认角色
+}
+
+// appendMessage 追加消息并更新最后一条消息缓存和消息计数，调用方需持有cs.mu
+func (s *Session) appendMessage(msg *Message) {
+	s.Messages = append(s.Messages, msg)
+	s.LastMessage = msg
+	s.msgCount++
+}
+
+// isParticipant 判断id是否为会话参与者（用户、客服或已加入的主管）
+func (s *Session) isParticipant(id string) bool {
+	return id != "" && (id == s.UserID || id == s.StaffID || s.Supervisors[id])
 }
 
 // SessionStatus 会话状态
@@ -67,22 +146,85 @@ const (
 	SessionStatusClosed
 )
 
+// String 返回会话状态的名称，用于报表等对外输出
+func (s SessionStatus) String() string {
+	switch s {
+	case SessionStatusWaiting:
+		return "waiting"
+	case SessionStatusActive:
+		return "active"
+	case SessionStatusClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// sessionTransitions 允许的会话状态变更：等待中的会话被接入或放弃，进行中的会话可以重新排队或关闭，
+// 关闭的会话只能由ReopenSession重新打开，回到等待状态
+var sessionTransitions = map[SessionStatus][]SessionStatus{
+	SessionStatusWaiting: {SessionStatusActive, SessionStatusClosed},
+	SessionStatusActive:  {SessionStatusWaiting, SessionStatusClosed},
+	SessionStatusClosed:  {SessionStatusWaiting},
+}
+
+// transitionTo 按状态变更表切换会话状态，不允许的变更返回ErrInvalidTransition，调用方需持有cs.mu
+func (s *Session) transitionTo(status SessionStatus) error {
+	for _, next := range sessionTransitions[s.Status] {
+		if next == status {
+			s.Status = status
+			return nil
+		}
+	}
+	return ErrInvalidTransition
+}
+
 // Message 消息
 type Message struct {
-	ID        string
-	SessionID string
-	FromID    string
-	ToID      string
-	Content   string
-	Type      MessageType
-	CreateAt  time.Time
+	ID          string
+	Seq         int64 // 会话内递增序号
+	SessionID   string
+	FromID      string
+	ToID        string
+	Content     string
+	Type        MessageType
+	CreateAt    time.Time
+	Recalled    bool        // 是否已撤回
+	ClientMsgID string      // 客户端生成的消息ID，用于重试去重
+	Attachment  *Attachment // 附件，仅图片和文件消息携带
+
+	ForwardedFrom *ForwardOrigin // 转发来源，仅转发的消息携带
+	ClientSentAt  time.Time      // 客户端声明的发送时间，仅供展示，排序和序号以服务端的CreateAt为准
+	Undelivered   bool           // 网关多次重发后仍有接收者未确认收到
+
+	Headers map[string]string // 过滤器附加的机器可读信息，不展示给用户
 }
 
+// SystemSenderID 系统消息的保留发送者ID
+const SystemSenderID = "system"
+
 // MessageType 消息类型
 type MessageType int
 
 const (
 	MessageTypeText MessageType = iota
 	MessageTypeImage
-	MessageTypeSystem
-)
\ No newline at end of file
+	MessageTypeSystem // 系统消息，发送者固定为SystemSenderID，ToID为空表示会话双方可见
+	MessageTypeFile   // 文件消息，附件不是图片时使用
+)
+
+// String 返回消息类型的名称，用于对外传输
+func (t MessageType) String() string {
+	switch t {
+	case MessageTypeText:
+		return "text"
+	case MessageTypeImage:
+		return "image"
+	case MessageTypeSystem:
+		return "system"
+	case MessageTypeFile:
+		return "file"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,64 @@
+package customer_service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Note 客服在会话上添加的内部备注，只对客服和主管可见，随会话转接交给新客服，不会发给用户
+type Note struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	AuthorID  string    `json:"author_id"`
+	Content   string    `json:"content"`
+	CreateAt  time.Time `json:"create_at"`
+}
+
+// AddNote 会话当前的客服或已加入的主管添加内部备注，用户添加时返回ErrInvalidOperation
+func (cs *CustomerService) AddNote(sessionID, authorID, content string) (*Note, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrEmptyContent
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	if !canSeeNotes(session, authorID) {
+		return nil, ErrInvalidOperation
+	}
+	note := Note{
+		ID:        session.ID + "_note_" + strconv.Itoa(len(session.notes)+1),
+		SessionID: session.ID,
+		AuthorID:  authorID,
+		Content:   content,
+		CreateAt:  cs.now(),
+	}
+	session.notes = append(session.notes, note)
+	return &note, nil
+}
+
+// Notes 按添加顺序返回会话内部备注的副本，只有会话当前的客服和已加入的主管可以查看，
+// 其他人（包括用户和转出的原客服）返回ErrInvalidOperation
+func (cs *CustomerService) Notes(sessionID, viewerID string) ([]Note, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	if !canSeeNotes(session, viewerID) {
+		return nil, ErrInvalidOperation
+	}
+	return append([]Note(nil), session.notes...), nil
+}
+
+// canSeeNotes 判断id能否查看和添加会话备注，调用方需持有cs.mu
+func canSeeNotes(session *Session, id string) bool {
+	return id != "" && id != session.UserID && (id == session.StaffID || session.Supervisors[id])
+}
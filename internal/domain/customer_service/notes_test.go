@@ -0,0 +1,40 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_NotesFollowTransfer(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+
+	note, err := cs.AddNote(session.ID, "staff1", "VIP customer, refund pending")
+	assert.NoError(t, err)
+	assert.Equal(t, "staff1", note.AuthorID)
+	_, err = cs.AddNote(session.ID, "user1", "let me see")
+	assert.Equal(t, ErrInvalidOperation, err)
+	_, err = cs.AddNote(session.ID, "staff1", "  ")
+	assert.Equal(t, ErrEmptyContent, err)
+
+	// 备注不是会话消息
+	assert.Empty(t, session.Messages)
+
+	assert.NoError(t, cs.TransferSession(session.ID, "staff2"))
+	notes, err := cs.Notes(session.ID, "staff2")
+	assert.NoError(t, err)
+	assert.Equal(t, []Note{*note}, notes)
+
+	// 用户和转出的原客服不能查看
+	_, err = cs.Notes(session.ID, "user1")
+	assert.Equal(t, ErrInvalidOperation, err)
+	_, err = cs.Notes(session.ID, "staff1")
+	assert.Equal(t, ErrInvalidOperation, err)
+	for _, msg := range session.Messages {
+		assert.NotContains(t, msg.Content, "refund pending")
+	}
+}
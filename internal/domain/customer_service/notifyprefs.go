@@ -0,0 +1,44 @@
+package customer_service
+
+// requiredNotifications 客服不能关闭的通知：消息本身、会话邀请和会话分配等决定客服能否正常接待的事件
+var requiredNotifications = map[string]bool{
+	"message":              true,
+	"session_created":      true,
+	EventSessionOffer:      true,
+	EventAssignmentInvite:  true,
+	EventStaffGroupChanged: true,
+}
+
+// SetNotificationPref 客服开启或关闭某类辅助通知，如SLA提醒、转接通知。
+// 消息投递和决定接待的通知不能关闭，关闭时返回ErrInvalidOperation
+func (cs *CustomerService) SetNotificationPref(staffID, eventType string, on bool) error {
+	if eventType == "" || (!on && requiredNotifications[eventType]) {
+		return ErrInvalidOperation
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return ErrStaffNotFound
+	}
+	if staff.NotificationPrefs == nil {
+		staff.NotificationPrefs = make(map[string]bool)
+	}
+	staff.NotificationPrefs[eventType] = on
+	return nil
+}
+
+// NotificationEnabled 判断是否应向客服转发某类通知，未设置或客服不存在时为true
+func (cs *CustomerService) NotificationEnabled(staffID, eventType string) bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return true
+	}
+	on, set := staff.NotificationPrefs[eventType]
+	return !set || on
+}
@@ -0,0 +1,34 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_NotificationPrefs(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	if _, err := cs.ConnectStaff("staff1", "TestStaff", "group1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认全部开启
+	assert.True(t, cs.NotificationEnabled("staff1", EventSLAWarning))
+
+	assert.NoError(t, cs.SetNotificationPref("staff1", EventSLAWarning, false))
+	assert.False(t, cs.NotificationEnabled("staff1", EventSLAWarning))
+	assert.True(t, cs.NotificationEnabled("staff1", EventSLABreach))
+	assert.NoError(t, cs.SetNotificationPref("staff1", EventSLAWarning, true))
+	assert.True(t, cs.NotificationEnabled("staff1", EventSLAWarning))
+
+	// 消息和会话邀请不能关闭
+	assert.ErrorIs(t, cs.SetNotificationPref("staff1", "message", false), ErrInvalidOperation)
+	assert.ErrorIs(t, cs.SetNotificationPref("staff1", EventSessionOffer, false), ErrInvalidOperation)
+	assert.NoError(t, cs.SetNotificationPref("staff1", EventSessionOffer, true))
+	assert.ErrorIs(t, cs.SetNotificationPref("staff1", "", false), ErrInvalidOperation)
+	assert.ErrorIs(t, cs.SetNotificationPref("missing", EventSLAWarning, false), ErrStaffNotFound)
+	assert.True(t, cs.NotificationEnabled("missing", EventSLAWarning))
+}
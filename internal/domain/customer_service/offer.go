@@ -0,0 +1,212 @@
+package customer_service
+
+import (
+	"strconv"
+	"time"
+)
+
+// defaultOfferTimeout 客服接受会话邀请的默认时限
+const defaultOfferTimeout = 30 * time.Second
+
+// Offer 向客服发出的会话邀请，客服拒绝或超时后转给组内下一位客服
+type Offer struct {
+	ID       string    `json:"id"`
+	UserID   string    `json:"user_id"`
+	StaffID  string    `json:"staff_id"`
+	GroupID  string    `json:"group_id"`
+	CreateAt time.Time `json:"create_at"`
+	ExpireAt time.Time `json:"expire_at"`
+
+	declined map[string]bool // 已拒绝或超时的客服
+	timer    Timer
+	invite   bool // 主管指定客服的邀请，拒绝或超时后不转给其他客服
+}
+
+// WithOfferTimeout 设置客服接受会话邀请的时限
+func WithOfferTimeout(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.offerTimeout = d
+	}
+}
+
+// OfferSession 向客服发出与用户建立会话的邀请
+func (cs *CustomerService) OfferSession(staffID, userID string) (string, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return "", ErrStaffNotFound
+	}
+	if staff.Status != UserStatusOnline {
+		return "", ErrStaffUnavailable
+	}
+	user, exists := cs.users[userID]
+	if !exists {
+		return "", ErrUserNotFound
+	}
+	if user.SessionID != "" {
+		return "", ErrInvalidOperation
+	}
+	if _, offered := cs.userOffers[userID]; offered {
+		return "", ErrInvalidOperation
+	}
+
+	groupID := staff.GroupID
+	if entry, queued := cs.waiting[userID]; queued {
+		groupID = entry.GroupID
+	}
+	return cs.offerLocked(staff, userID, groupID).ID, nil
+}
+
+// AcceptOffer 客服接受邀请并创建会话
+func (cs *CustomerService) AcceptOffer(staffID, offerID string) (*Session, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	offer, exists := cs.offers[offerID]
+	if !exists || offer.StaffID != staffID {
+		return nil, ErrOfferNotFound
+	}
+
+	user, exists := cs.users[offer.UserID]
+	if !exists {
+		cs.removeOfferLocked(offer)
+		return nil, ErrUserNotFound
+	}
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return nil, ErrStaffNotFound
+	}
+
+	if entry, queued := cs.waiting[user.ID]; (!queued || entry.SessionID == "") && cs.atSystemCapacityLocked() {
+		return nil, ErrSystemAtCapacity
+	}
+	return cs.assignQueuedLocked(user, staff), nil
+}
+
+// SetAutoAccept 设置客服是否自动接入排队用户，关闭时改为逐个发起邀请
+func (cs *CustomerService) SetAutoAccept(staffID string, on bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if staff, exists := cs.staffs[staffID]; exists {
+		staff.AutoAccept = on
+	}
+}
+
+// DeclineOffer 客服拒绝邀请，邀请转给组内下一位客服
+func (cs *CustomerService) DeclineOffer(staffID, offerID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	offer, exists := cs.offers[offerID]
+	if !exists || offer.StaffID != staffID {
+		return ErrOfferNotFound
+	}
+	cs.reofferLocked(offer)
+	return nil
+}
+
+// GetOffer 获取邀请信息
+func (cs *CustomerService) GetOffer(offerID string) *Offer {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if offer, exists := cs.offers[offerID]; exists {
+		snapshot := *offer
+		return &snapshot
+	}
+	return nil
+}
+
+// offerLocked 创建邀请并通知客服，调用方需持有cs.mu
+func (cs *CustomerService) offerLocked(staff *CSStaff, userID, groupID string) *Offer {
+	offer := cs.newOfferLocked(userID, groupID)
+	cs.assignOfferLocked(offer, staff)
+	return offer
+}
+
+// newOfferLocked 创建尚未交给客服的邀请，调用方需持有cs.mu
+func (cs *CustomerService) newOfferLocked(userID, groupID string) *Offer {
+	cs.seq++
+	offer := &Offer{
+		ID:       "offer_" + strconv.FormatInt(cs.seq, 10),
+		UserID:   userID,
+		GroupID:  groupID,
+		CreateAt: cs.now(),
+		declined: make(map[string]bool),
+	}
+	cs.offers[offer.ID] = offer
+	cs.userOffers[userID] = offer.ID
+	return offer
+}
+
+// assignOfferLocked 将邀请交给指定客服并重新计时，调用方需持有cs.mu
+func (cs *CustomerService) assignOfferLocked(offer *Offer, staff *CSStaff) {
+	offer.StaffID = staff.ID
+	offer.ExpireAt = cs.now().Add(cs.offerTimeout)
+	if offer.timer != nil {
+		offer.timer.Stop()
+	}
+
+	offerID, staffID := offer.ID, staff.ID
+	offer.timer = cs.afterFunc(cs.offerTimeout, func() {
+		cs.expireOffer(offerID, staffID)
+	})
+
+	if offer.invite {
+		cs.emit(EventAssignmentInvite, *offer)
+		return
+	}
+	cs.emit(EventSessionOffer, *offer)
+}
+
+// reofferLocked 将邀请转给下一位客服，没有可用客服时撤销邀请，用户继续排队，调用方需持有cs.mu
+func (cs *CustomerService) reofferLocked(offer *Offer) {
+	offer.declined[offer.StaffID] = true
+	if _, exists := cs.users[offer.UserID]; !exists {
+		cs.removeOfferLocked(offer)
+		return
+	}
+	if offer.invite {
+		cs.withdrawInviteLocked(offer)
+		return
+	}
+
+	var subject string
+	if entry, queued := cs.waiting[offer.UserID]; queued {
+		subject = entry.Subject
+	}
+	next := cs.pickStaffLocked(offer.GroupID, subject, offer.declined)
+	if next == nil {
+		cs.removeOfferLocked(offer)
+		return
+	}
+	if next.AutoAccept {
+		cs.removeOfferLocked(offer)
+		cs.autoAssignLocked(cs.users[offer.UserID], next)
+		return
+	}
+	cs.assignOfferLocked(offer, next)
+}
+
+// expireOffer 邀请超时处理
+func (cs *CustomerService) expireOffer(offerID, staffID string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	offer, exists := cs.offers[offerID]
+	if !exists || offer.StaffID != staffID {
+		return
+	}
+	cs.reofferLocked(offer)
+}
+
+// removeOfferLocked 删除邀请，调用方需持有cs.mu
+func (cs *CustomerService) removeOfferLocked(offer *Offer) {
+	if offer.timer != nil {
+		offer.timer.Stop()
+	}
+	delete(cs.offers, offer.ID)
+	delete(cs.userOffers, offer.UserID)
+}
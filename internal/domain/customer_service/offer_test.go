@@ -0,0 +1,131 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordOffers 通过事件回调收集会话邀请
+func recordOffers(cs *CustomerService) <-chan Offer {
+	offers := make(chan Offer, 16)
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		if eventType == EventSessionOffer {
+			offers <- payload.(Offer)
+		}
+	})
+	return offers
+}
+
+func receiveOffer(t *testing.T, offers <-chan Offer) Offer {
+	select {
+	case offer := <-offers:
+		return offer
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for session offer")
+	}
+	return Offer{}
+}
+
+func TestCustomerService_OfferDeclineThenAccept(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	offers := recordOffers(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectStaff("staff1", "TestStaff1", "group1", nil)
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	user, _ := cs.ConnectUser("user1", "TestUser", nil)
+
+	// 排队后邀请发给第一位客服，而不是直接分配
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+	assert.Equal(t, ErrUserAlreadyQueued, cs.EnqueueUser("user1", "group1"))
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "staff1", offer.StaffID)
+	assert.Equal(t, "user1", offer.UserID)
+	assert.Empty(t, user.SessionID)
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+
+	// 第一位客服拒绝后转给第二位客服
+	assert.NoError(t, cs.DeclineOffer("staff1", offer.ID))
+	reoffer := receiveOffer(t, offers)
+	assert.Equal(t, offer.ID, reoffer.ID)
+	assert.Equal(t, "staff2", reoffer.StaffID)
+
+	// 已拒绝的客服不能再接受
+	_, err := cs.AcceptOffer("staff1", offer.ID)
+	assert.Equal(t, ErrOfferNotFound, err)
+
+	// 第二位客服接受后创建会话并移出队列
+	session, err := cs.AcceptOffer("staff2", offer.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "staff2", session.StaffID)
+	assert.Equal(t, session.ID, user.SessionID)
+	assert.Empty(t, cs.QueuedUsers("group1"))
+	assert.Nil(t, cs.GetOffer(offer.ID))
+}
+
+func TestCustomerService_OfferTimeout(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithOfferTimeout(time.Minute))
+	defer cs.Shutdown()
+	offers := recordOffers(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectStaff("staff1", "TestStaff1", "group1", nil)
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	cs.ConnectUser("user1", "TestUser", nil)
+
+	offerID, err := cs.OfferSession("staff1", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, "staff1", receiveOffer(t, offers).StaffID)
+
+	// 第一位客服超时后转给第二位，第二位也超时后撤销邀请
+	clock.Advance(time.Minute)
+	assert.Equal(t, "staff2", receiveOffer(t, offers).StaffID)
+	assert.NotNil(t, cs.GetOffer(offerID))
+	clock.Advance(time.Minute)
+	assert.Nil(t, cs.GetOffer(offerID))
+
+	// 错误情况
+	_, err = cs.OfferSession("nonexistent", "user1")
+	assert.Equal(t, ErrStaffNotFound, err)
+	_, err = cs.OfferSession("staff1", "nonexistent")
+	assert.Equal(t, ErrUserNotFound, err)
+	assert.Equal(t, ErrOfferNotFound, cs.DeclineOffer("staff1", "nonexistent"))
+}
+
+func TestCustomerService_AutoAccept(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	types, events, offers := recordSessionEvents(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	auto, _ := cs.ConnectStaff("auto1", "AutoStaff", "group1", nil)
+	cs.ConnectStaff("manual1", "ManualStaff", "group1", nil)
+	cs.SetAutoAccept("auto1", true)
+	auto.MaxSessions = 1
+	user1, _ := cs.ConnectUser("user1", "TestUser1", nil)
+	user2, _ := cs.ConnectUser("user2", "TestUser2", nil)
+
+	// 自动接入的客服直接分配会话，不发邀请
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+	assert.Equal(t, EventSessionAssigned, <-types)
+	event := <-events
+	assert.Equal(t, "auto1", event.StaffID)
+	assert.Equal(t, user1.SessionID, event.SessionID)
+	assert.Len(t, auto.Sessions, 1)
+	assert.Empty(t, cs.QueuedUsers("group1"))
+
+	// 自动接入的客服已满额，手动接入的客服收到邀请
+	assert.NoError(t, cs.EnqueueUser("user2", "group1"))
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "manual1", offer.StaffID)
+	assert.Empty(t, user2.SessionID)
+
+	session, err := cs.AcceptOffer("manual1", offer.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, session.ID, user2.SessionID)
+	assert.Len(t, auto.Sessions, 1)
+}
@@ -0,0 +1,103 @@
+package customer_service
+
+// OfflineStore 可选的离线消息存储能力，配置的存储实现该接口时离线消息随存储持久化，
+// 服务重启后接收方重新连接仍可收到
+type OfflineStore interface {
+	// SaveOffline 为接收方追加一条离线消息，超过limit条时丢弃最早的消息
+	SaveOffline(recipientID string, msg *Message, limit int) error
+	// TakeOffline 按保存顺序取出并删除接收方的全部离线消息
+	TakeOffline(recipientID string) ([]*Message, error)
+}
+
+// WithOfflineQueue 开启离线消息队列，接收方未连接时消息暂存，重新连接后补发，每个接收方最多保留limit条，
+// 超出时丢弃最早的消息。配置的存储实现OfflineStore时持久化，否则只保存在内存中。小于等于0时不开启
+func WithOfflineQueue(limit int) Option {
+	return func(cs *CustomerService) {
+		cs.offlineLimit = limit
+	}
+}
+
+// newOfflineStore 选择离线消息的存储：配置的存储支持时使用该存储，否则使用独立的内存存储
+func newOfflineStore(store SessionStore) OfflineStore {
+	if offline, ok := store.(OfflineStore); ok {
+		return offline
+	}
+	return NewMemoryStore()
+}
+
+// QueueOfflineMessage 保存发给未连接接收方的消息，未开启离线队列时忽略。存储在锁外访问
+func (cs *CustomerService) QueueOfflineMessage(recipientID string, msg *Message) error {
+	if cs.offline == nil || msg == nil {
+		return nil
+	}
+	return cs.offline.SaveOffline(recipientID, msg, cs.offlineLimit)
+}
+
+// TakeOfflineMessages 取出并删除接收方的离线消息，按保存顺序返回，未开启离线队列时为空
+func (cs *CustomerService) TakeOfflineMessages(recipientID string) ([]*Message, error) {
+	if cs.offline == nil {
+		return nil, nil
+	}
+	return cs.offline.TakeOffline(recipientID)
+}
+
+// SaveOffline 保存离线消息的副本
+func (s *MemoryStore) SaveOffline(recipientID string, msg *Message, limit int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := *msg
+	m.Headers = cloneHeaders(msg.Headers)
+	queue := append(s.offline[recipientID], &m)
+	if limit > 0 && len(queue) > limit {
+		queue = queue[len(queue)-limit:]
+	}
+	s.offline[recipientID] = queue
+	return nil
+}
+
+// TakeOffline 取出并删除离线消息
+func (s *MemoryStore) TakeOffline(recipientID string) ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := s.offline[recipientID]
+	delete(s.offline, recipientID)
+	return msgs, nil
+}
+
+// encryptedOfflineStore 在离线消息存储外层加解密消息内容
+type encryptedOfflineStore struct {
+	OfflineStore
+	enc Encryptor
+}
+
+// SaveOffline 加密消息内容后写入底层存储
+func (s *encryptedOfflineStore) SaveOffline(recipientID string, msg *Message, limit int) error {
+	content, err := s.enc.Encrypt([]byte(msg.Content))
+	if err != nil {
+		return err
+	}
+	m := *msg
+	m.Content = string(content)
+	return s.OfflineStore.SaveOffline(recipientID, &m, limit)
+}
+
+// TakeOffline 从底层存储取出并解密消息内容
+func (s *encryptedOfflineStore) TakeOffline(recipientID string) ([]*Message, error) {
+	stored, err := s.OfflineStore.TakeOffline(recipientID)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*Message, len(stored))
+	for i, msg := range stored {
+		content, err := s.enc.Decrypt([]byte(msg.Content))
+		if err != nil {
+			return nil, err
+		}
+		m := *msg
+		m.Content = string(content)
+		msgs[i] = &m
+	}
+	return msgs, nil
+}
@@ -0,0 +1,82 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_OfflineQueueSurvivesRestore(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store), WithOfflineQueue(10))
+	session := createTestSession(t, cs, "user1", "staff1")
+	for _, content := range []string{"first", "second"} {
+		msg, err := cs.SendMessage(session.ID, "staff1", content, MessageTypeText)
+		assert.NoError(t, err)
+		assert.NoError(t, cs.QueueOfflineMessage("user1", msg))
+	}
+	snapshots := cs.Snapshot()
+	cs.Shutdown()
+
+	// 新进程使用同一存储恢复，用户重新连接后取出离线消息
+	restored := NewCustomerService(WithStore(store), WithOfflineQueue(10))
+	defer restored.Shutdown()
+	assert.NoError(t, restored.RestoreFrom(snapshots))
+	restored.ConnectUser("user1", "TestUser", nil)
+
+	msgs, err := restored.TakeOfflineMessages("user1")
+	assert.NoError(t, err)
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "first", msgs[0].Content)
+		assert.Equal(t, "second", msgs[1].Content)
+		assert.Equal(t, session.ID, msgs[0].SessionID)
+	}
+
+	// 取出后不再重复投递
+	msgs, err = restored.TakeOfflineMessages("user1")
+	assert.NoError(t, err)
+	assert.Empty(t, msgs)
+}
+
+func TestCustomerService_OfflineQueueLimit(t *testing.T) {
+	cs := NewCustomerService(WithOfflineQueue(2))
+	defer cs.Shutdown()
+
+	for _, content := range []string{"a", "b", "c"} {
+		assert.NoError(t, cs.QueueOfflineMessage("user1", &Message{ID: content, Content: content}))
+	}
+	msgs, err := cs.TakeOfflineMessages("user1")
+	assert.NoError(t, err)
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "b", msgs[0].Content)
+		assert.Equal(t, "c", msgs[1].Content)
+	}
+}
+
+func TestCustomerService_OfflineQueueDisabled(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	assert.NoError(t, cs.QueueOfflineMessage("user1", &Message{Content: "hello"}))
+	msgs, err := cs.TakeOfflineMessages("user1")
+	assert.NoError(t, err)
+	assert.Empty(t, msgs)
+}
+
+func TestCustomerService_OfflineQueueEncrypted(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store), WithEncryptor(newAESEncryptor(t)), WithOfflineQueue(10))
+	defer cs.Shutdown()
+
+	assert.NoError(t, cs.QueueOfflineMessage("user1", &Message{Content: "secret"}))
+	store.mu.RLock()
+	stored := store.offline["user1"][0].Content
+	store.mu.RUnlock()
+	assert.NotEqual(t, "secret", stored)
+
+	msgs, err := cs.TakeOfflineMessages("user1")
+	assert.NoError(t, err)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "secret", msgs[0].Content)
+	}
+}
@@ -0,0 +1,22 @@
+package customer_service
+
+import "time"
+
+// Option 客服系统配置项
+type Option func(*CustomerService)
+
+// WithStore 设置消息持久化存储
+func WithStore(store SessionStore) Option {
+	return func(cs *CustomerService) {
+		cs.store = store
+	}
+}
+
+// WithStoreBatch 开启消息批量写入，缓冲达到size条或距上次写入超过interval时刷入存储
+// size小于等于1时退化为同步写入
+func WithStoreBatch(size int, interval time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.batchSize = size
+		cs.flushInterval = interval
+	}
+}
@@ -0,0 +1,47 @@
+package customer_service
+
+import (
+	"log"
+	"strings"
+)
+
+// WithPreSessionBuffer 设置用户在会话建立前最多可缓存的消息条数，会话建立后按顺序补发到会话中
+// 0表示不缓存，会话建立前发送的消息返回ErrNoActiveSession
+func WithPreSessionBuffer(n int) Option {
+	return func(cs *CustomerService) {
+		cs.preSessionBuffer = n
+	}
+}
+
+// BufferUserMessage 缓存用户在会话建立前发送的消息，未开启缓存时返回ErrNoActiveSession，缓存已满时返回ErrPreSessionBufferFull
+func (cs *CustomerService) BufferUserMessage(userID, content string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	user, exists := cs.users[userID]
+	if !exists {
+		return ErrUserNotFound
+	}
+	if cs.preSessionBuffer <= 0 {
+		return ErrNoActiveSession
+	}
+	if len(user.pending) >= cs.preSessionBuffer {
+		return ErrPreSessionBufferFull
+	}
+	if strings.TrimSpace(content) == "" {
+		return ErrEmptyContent
+	}
+	user.pending = append(user.pending, content)
+	return nil
+}
+
+// replayPendingLocked 将用户在会话建立前缓存的消息补发到会话中，调用方需持有cs.mu
+func (cs *CustomerService) replayPendingLocked(session *Session, user *User) {
+	pending := user.pending
+	user.pending = nil
+	for _, content := range pending {
+		if _, err := cs.sendLocked(session, user.ID, session.StaffID, "", content, MessageTypeText); err != nil {
+			log.Printf("Error replaying pending message of user %s: %v", user.ID, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_PreSessionBuffer(t *testing.T) {
+	cs := NewCustomerService(WithPreSessionBuffer(2))
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser1", nil)
+	_, err := cs.ConnectStaff("staff1", "TestStaff1", "group1", nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cs.BufferUserMessage("user1", "first"))
+	assert.NoError(t, cs.BufferUserMessage("user1", "second"))
+	err = cs.BufferUserMessage("user1", "third")
+	assert.ErrorIs(t, err, ErrPreSessionBufferFull)
+	assert.NotErrorIs(t, err, ErrNoActiveSession)
+	assert.ErrorIs(t, cs.BufferUserMessage("missing", "hi"), ErrUserNotFound)
+
+	// 会话建立后按顺序补发
+	session, err := cs.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	if assert.Len(t, session.Messages, 2) {
+		assert.Equal(t, "first", session.Messages[0].Content)
+		assert.Equal(t, "second", session.Messages[1].Content)
+		assert.Equal(t, "user1", session.Messages[0].FromID)
+		assert.Equal(t, "staff1", session.Messages[0].ToID)
+	}
+
+	// 缓存已清空，不会重复补发
+	assert.Empty(t, cs.GetUser("user1").pending)
+}
+
+func TestCustomerService_PreSessionBufferDisabled(t *testing.T) {
+	cs := NewCustomerService()
+	cs.ConnectUser("user1", "TestUser1", nil)
+
+	assert.ErrorIs(t, cs.BufferUserMessage("user1", "hi"), ErrNoActiveSession)
+}
@@ -0,0 +1,92 @@
+package customer_service
+
+import "time"
+
+// defaultPositionInterval 同一用户排队位置通知的默认最小间隔
+const defaultPositionInterval = time.Second
+
+// QueuePosition 排队位置通知，Position从1开始，为0表示已被接入
+type QueuePosition struct {
+	UserID   string `json:"user_id"`
+	GroupID  string `json:"group_id"`
+	Position int    `json:"position"`
+}
+
+// positionState 单个排队用户的位置通知状态
+type positionState struct {
+	lastSent time.Time // 上次通知时间
+	lastPos  int       // 上次通知的位置
+	timer    Timer     // 节流期间合并的待发通知
+}
+
+// WithQueuePositionInterval 设置同一用户排队位置通知的最小间隔，间隔内的多次变化合并为一次，小于等于0时每次变化都通知
+func WithQueuePositionInterval(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.positionInterval = d
+	}
+}
+
+// queuePositionLocked 获取用户当前的排队位置，未排队时返回0，调用方需持有cs.mu
+func (cs *CustomerService) queuePositionLocked(userID string) (string, int) {
+	entry, exists := cs.waiting[userID]
+	if !exists {
+		return "", 0
+	}
+	for i, id := range cs.queues[entry.GroupID] {
+		if id == userID {
+			return entry.GroupID, i + 1
+		}
+	}
+	return entry.GroupID, 0
+}
+
+// notifyQueuePositionsLocked 队列变化后向组内排队用户通知新位置，调用方需持有cs.mu
+func (cs *CustomerService) notifyQueuePositionsLocked(groupID string) {
+	for _, userID := range cs.queues[groupID] {
+		cs.updateQueuePositionLocked(userID)
+	}
+}
+
+// updateQueuePositionLocked 位置变化时通知用户，距上次通知不足间隔时推迟到间隔结束后发送最新位置，调用方需持有cs.mu
+func (cs *CustomerService) updateQueuePositionLocked(userID string) {
+	groupID, pos := cs.queuePositionLocked(userID)
+	if pos == 0 {
+		return
+	}
+
+	state, exists := cs.positions[userID]
+	if !exists {
+		state = &positionState{}
+		cs.positions[userID] = state
+	}
+	if state.timer != nil || pos == state.lastPos {
+		return
+	}
+
+	now := cs.now()
+	if wait := state.lastSent.Add(cs.positionInterval).Sub(now); wait > 0 {
+		state.timer = cs.afterFunc(wait, func() {
+			cs.mu.Lock()
+			defer cs.mu.Unlock()
+			if current, exists := cs.positions[userID]; exists && current == state {
+				state.timer = nil
+				cs.updateQueuePositionLocked(userID)
+			}
+		})
+		return
+	}
+
+	state.lastSent = now
+	state.lastPos = pos
+	cs.emit(EventQueuePosition, QueuePosition{UserID: userID, GroupID: groupID, Position: pos})
+}
+
+// clearQueuePositionLocked 用户离开队列后清除其通知状态，调用方需持有cs.mu
+func (cs *CustomerService) clearQueuePositionLocked(userID string) {
+	if state, exists := cs.positions[userID]; exists {
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+		delete(cs.positions, userID)
+	}
+}
@@ -0,0 +1,72 @@
+package customer_service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_QueuePositionThrottle(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithQueuePositionInterval(interval))
+	defer cs.Shutdown()
+
+	var mu sync.Mutex
+	var updates []QueuePosition
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		if p, ok := payload.(QueuePosition); ok && p.UserID == "watch" {
+			mu.Lock()
+			updates = append(updates, p)
+			mu.Unlock()
+		}
+	})
+	received := func() []QueuePosition {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]QueuePosition(nil), updates...)
+	}
+
+	cs.CreateGroup("group1", "TestGroup")
+	for i := 0; i < 400; i++ {
+		id := fmt.Sprintf("user%d", i)
+		cs.ConnectUser(id, "TestUser", nil)
+		assert.NoError(t, cs.EnqueueUser(id, "group1"))
+	}
+	cs.ConnectUser("watch", "Watcher", nil)
+	assert.NoError(t, cs.EnqueueUser("watch", "group1"))
+
+	// 队首用户每毫秒离开一个，被观察用户的位置持续变化
+	for i := 0; i < 60; i++ {
+		cs.DisconnectUser(cs.QueuedUsers("group1")[0], DisconnectClientClose)
+		clock.Advance(time.Millisecond)
+	}
+	clock.Advance(2 * interval)
+
+	// 排队时通知一次，此后每个间隔结束时合并通知一次，最后一次通知为当前位置
+	assert.Eventually(t, func() bool {
+		return len(received()) == 3
+	}, time.Second, 5*time.Millisecond)
+	got := received()
+	assert.Equal(t, 401, got[0].Position)
+	position := 0
+	for i, id := range cs.QueuedUsers("group1") {
+		if id == "watch" {
+			position = i + 1
+		}
+	}
+	assert.Equal(t, position, got[len(got)-1].Position)
+
+	// 被接入时立即通知最终位置
+	cs.ConnectStaff("staff1", "TestStaff", "group1", nil)
+	before := len(received())
+	_, err := cs.CreateSession("watch", "staff1")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		got := received()
+		return len(got) == before+1 && got[len(got)-1].Position == 0
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,97 @@
+package customer_service
+
+import (
+	"sync"
+	"time"
+)
+
+// presenceBufferSize 每个订阅者的事件缓冲大小，缓冲满时丢弃新事件，避免慢订阅者阻塞主流程
+const presenceBufferSize = 64
+
+// PresenceRole 在线状态事件的主体类型
+type PresenceRole string
+
+const (
+	PresenceRoleUser  PresenceRole = "user"
+	PresenceRoleStaff PresenceRole = "staff"
+	// PresenceRoleSupervisor 主管不产生在线状态事件，仅用于按身份统计连接数
+	PresenceRoleSupervisor PresenceRole = "supervisor"
+)
+
+// PresenceEvent 用户或客服上下线事件
+type PresenceEvent struct {
+	ID     string           `json:"id"`
+	Role   PresenceRole     `json:"role"`
+	Online bool             `json:"online"`
+	State  PresenceState    `json:"state"`            // 上线为online，下线为offline
+	Reason DisconnectReason `json:"reason,omitempty"` // 下线原因，仅下线事件携带
+	At     time.Time        `json:"at"`
+}
+
+// presenceHub 在线状态订阅管理
+type presenceHub struct {
+	subs   map[int]chan PresenceEvent
+	nextID int
+	mu     sync.Mutex
+}
+
+func newPresenceHub() *presenceHub {
+	return &presenceHub{subs: make(map[int]chan PresenceEvent)}
+}
+
+// subscribe 注册订阅者，返回事件通道和取消订阅函数
+func (h *presenceHub) subscribe() (<-chan PresenceEvent, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := h.nextID
+	h.nextID++
+	ch := make(chan PresenceEvent, presenceBufferSize)
+	h.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subs, id)
+			close(ch)
+		})
+	}
+}
+
+// publish 非阻塞地向所有订阅者投递事件
+func (h *presenceHub) publish(event PresenceEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+			// 订阅者处理过慢，丢弃该事件
+		}
+	}
+}
+
+// SubscribePresence 订阅用户和客服的上下线事件
+// 返回的函数用于取消订阅，取消后通道会被关闭
+func (cs *CustomerService) SubscribePresence() (<-chan PresenceEvent, func()) {
+	return cs.presence.subscribe()
+}
+
+// publishPresence 发布上下线事件，reason仅下线时有效
+func (cs *CustomerService) publishPresence(id string, role PresenceRole, online bool, reason DisconnectReason) {
+	state := PresenceOffline
+	if online {
+		state = PresenceOnline
+	}
+	cs.presence.publish(PresenceEvent{
+		ID:     id,
+		Role:   role,
+		Online: online,
+		State:  state,
+		Reason: reason,
+		At:     cs.now(),
+	})
+}
@@ -0,0 +1,89 @@
+package customer_service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// receivePresence 在超时时间内读取一条上下线事件
+func receivePresence(t *testing.T, ch <-chan PresenceEvent) PresenceEvent {
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for presence event")
+	}
+	return PresenceEvent{}
+}
+
+func TestCustomerService_SubscribePresence(t *testing.T) {
+	cs, server := setupTestServer(t)
+	defer server.Close()
+
+	userConn := createWebSocketConn(t, server)
+	defer userConn.Close()
+
+	staffConn := createWebSocketConn(t, server)
+	defer staffConn.Close()
+
+	events, unsubscribe := cs.SubscribePresence()
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", userConn)
+	cs.ConnectStaff("staff1", "TestStaff", "group1", staffConn)
+	cs.DisconnectUser("user1", DisconnectClientClose)
+
+	event := receivePresence(t, events)
+	assert.Equal(t, "user1", event.ID)
+	assert.Equal(t, PresenceRoleUser, event.Role)
+	assert.True(t, event.Online)
+
+	event = receivePresence(t, events)
+	assert.Equal(t, "staff1", event.ID)
+	assert.Equal(t, PresenceRoleStaff, event.Role)
+	assert.True(t, event.Online)
+
+	event = receivePresence(t, events)
+	assert.Equal(t, "user1", event.ID)
+	assert.False(t, event.Online)
+
+	// 断开不存在的用户不产生事件
+	cs.DisconnectUser("nonexistent", DisconnectClientClose)
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event: %+v", event)
+	default:
+	}
+
+	// 取消订阅后通道关闭，重复取消不应panic
+	unsubscribe()
+	unsubscribe()
+	_, ok := <-events
+	assert.False(t, ok)
+	cs.DisconnectStaff("staff1", DisconnectClientClose)
+}
+
+func TestCustomerService_SubscribePresence_SlowSubscriber(t *testing.T) {
+	cs := NewCustomerService()
+	events, unsubscribe := cs.SubscribePresence()
+	defer unsubscribe()
+
+	// 订阅者不读取时，超出缓冲的事件被丢弃而不阻塞连接流程
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < presenceBufferSize*2; i++ {
+			cs.ConnectUser(fmt.Sprintf("user%d", i), "TestUser", nil)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("slow subscriber blocked ConnectUser")
+	}
+	assert.Len(t, events, presenceBufferSize)
+}
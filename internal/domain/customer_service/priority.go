@@ -0,0 +1,39 @@
+package customer_service
+
+import (
+	"sort"
+	"time"
+)
+
+// WithQueueAging 设置排队老化步长，用户每等待一个步长有效优先级加1，避免低优先级用户长期得不到接入，0表示不老化
+func WithQueueAging(step time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.queueAging = step
+	}
+}
+
+// effectivePriorityLocked 计算排队用户当前的有效优先级，调用方需持有cs.mu
+func (cs *CustomerService) effectivePriorityLocked(entry *queueEntry, now time.Time) int {
+	if cs.queueAging <= 0 {
+		return entry.Priority
+	}
+	return entry.Priority + int(now.Sub(entry.EnqueueAt)/cs.queueAging)
+}
+
+// dispatchOrderLocked 按有效优先级从高到低返回组内排队用户，优先级相同时保持排队顺序，调用方需持有cs.mu
+// 排队位置通知仍按排队先后计算
+func (cs *CustomerService) dispatchOrderLocked(groupID string) []*queueEntry {
+	now := cs.now()
+	entries := make([]*queueEntry, 0, len(cs.queues[groupID]))
+	priorities := make(map[string]int, len(cs.queues[groupID]))
+	for _, userID := range cs.queues[groupID] {
+		if entry, exists := cs.waiting[userID]; exists {
+			entries = append(entries, entry)
+			priorities[userID] = cs.effectivePriorityLocked(entry, now)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return priorities[entries[i].UserID] > priorities[entries[j].UserID]
+	})
+	return entries
+}
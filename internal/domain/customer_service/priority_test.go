@@ -0,0 +1,53 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newBusyAutoAcceptStaff 创建只能同时处理一个会话且已被占用的自动接入客服
+func newBusyAutoAcceptStaff(t *testing.T, cs *CustomerService) *Session {
+	session := createTestSession(t, cs, "busy", "staff1")
+	cs.SetAutoAccept("staff1", true)
+	assert.NoError(t, cs.SetStaffCapacity("staff1", 1))
+	return session
+}
+
+func TestCustomerService_EnqueuePriority(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	busy := newBusyAutoAcceptStaff(t, cs)
+	low, _ := cs.ConnectUser("low", "LowUser", nil)
+	high, _ := cs.ConnectUser("high", "HighUser", nil)
+	assert.NoError(t, cs.EnqueueUser("low", "group1"))
+	assert.NoError(t, cs.EnqueueUserWithPriority("high", "group1", 5))
+
+	// 不老化时后到的高优先级用户先被分配
+	assert.NoError(t, cs.CloseSession(busy.ID, "staff1"))
+	assert.NotEmpty(t, high.SessionID)
+	assert.Empty(t, low.SessionID)
+	assert.Equal(t, []string{"low"}, cs.QueuedUsers("group1"))
+}
+
+func TestCustomerService_QueueAging(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithQueueAging(10*time.Second))
+	defer cs.Shutdown()
+
+	busy := newBusyAutoAcceptStaff(t, cs)
+	low, _ := cs.ConnectUser("low", "LowUser", nil)
+	high, _ := cs.ConnectUser("high", "HighUser", nil)
+	assert.NoError(t, cs.EnqueueUser("low", "group1"))
+
+	// 等待足够久的低优先级用户优先于新到的高优先级用户
+	clock.Advance(100 * time.Second)
+	assert.NoError(t, cs.EnqueueUserWithPriority("high", "group1", 3))
+
+	assert.NoError(t, cs.CloseSession(busy.ID, "staff1"))
+	assert.NotEmpty(t, low.SessionID)
+	assert.Empty(t, high.SessionID)
+	assert.Equal(t, []string{"high"}, cs.QueuedUsers("group1"))
+}
@@ -0,0 +1,253 @@
+package customer_service
+
+import (
+	"time"
+)
+
+// queueEntry 排队中的用户
+type queueEntry struct {
+	UserID    string
+	GroupID   string
+	SessionID string // 重新排队的会话，新用户为空
+	Priority  int    // 排队优先级，数值越大越先分配
+	Subject   string // 用户声明的咨询主题，优先分配给具备同名技能的客服
+	EnqueueAt time.Time
+}
+
+// EnqueueUser 将用户加入客服组的等待队列，并尝试向组内客服发起会话邀请
+// 非营业时间返回ErrOutOfHours，错误描述为该组的自动回复，排队请求激增时返回ErrSystemBusy
+func (cs *CustomerService) EnqueueUser(userID, groupID string) error {
+	return cs.EnqueueUserWithPriority(userID, groupID, 0)
+}
+
+// EnqueueUserWithPriority 以指定优先级将用户加入客服组的等待队列，数值越大越先分配，
+// 优先级相同时按排队先后分配
+func (cs *CustomerService) EnqueueUserWithPriority(userID, groupID string, priority int) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.enqueueLocked(userID, groupID, priority, "")
+}
+
+// enqueueLocked 校验后将用户加入等待队列并尝试分配，调用方需持有cs.mu
+func (cs *CustomerService) enqueueLocked(userID, groupID string, priority int, subject string) error {
+	user, exists := cs.users[userID]
+	if !exists {
+		return ErrUserNotFound
+	}
+	if cs.requireReady && !user.ready {
+		return ErrUserNotReady
+	}
+	group, exists := cs.resolveGroupLocked(groupID)
+	if !exists {
+		return ErrGroupNotFound
+	}
+	groupID = group.ID
+	user.GroupID = groupID
+	if err := cs.checkOpenLocked(group, cs.now()); err != nil {
+		return err
+	}
+	if group.draining {
+		return ErrGroupDraining
+	}
+	if user.SessionID != "" {
+		return ErrInvalidOperation
+	}
+	if _, queued := cs.waiting[userID]; queued {
+		return ErrUserAlreadyQueued
+	}
+	if cs.shedLocked(cs.now()) {
+		return ErrSystemBusy
+	}
+	if cs.atSystemCapacityLocked() {
+		return ErrSystemAtCapacity
+	}
+	if err := cs.admitLocked(group); err != nil {
+		return err
+	}
+
+	entry := &queueEntry{
+		UserID:    userID,
+		GroupID:   groupID,
+		Priority:  priority,
+		Subject:   subject,
+		EnqueueAt: cs.now(),
+	}
+	cs.waiting[userID] = entry
+	cs.queues[groupID] = append(cs.queues[groupID], userID)
+	cs.notifyQueuePositionsLocked(groupID)
+
+	cs.dispatchLocked(entry, nil)
+	return nil
+}
+
+// QueuedUsers 按排队顺序返回客服组中等待的用户ID
+func (cs *CustomerService) QueuedUsers(groupID string) []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	users := make([]string, len(cs.queues[groupID]))
+	copy(users, cs.queues[groupID])
+	return users
+}
+
+// requeueSessionLocked 将进行中的会话从客服处移回组内等待队列，保留会话及其消息，调用方需持有cs.mu
+func (cs *CustomerService) requeueSessionLocked(session *Session, reason string) error {
+	// 关闭的会话只能经ReopenSession回到等待状态
+	if session.Status != SessionStatusActive {
+		return ErrInvalidTransition
+	}
+	if err := cs.transitionLocked(session, SessionStatusWaiting, reason); err != nil {
+		return err
+	}
+	prevStaffID := session.StaffID
+	if staff, exists := cs.staffs[session.StaffID]; exists {
+		delete(staff.Sessions, session.ID)
+	}
+	session.StaffID = ""
+	session.UpdateAt = cs.now()
+
+	if _, exists := cs.users[session.UserID]; !exists {
+		return nil
+	}
+	cs.queueSessionLocked(session, map[string]bool{prevStaffID: true})
+	return nil
+}
+
+// queueSessionLocked 将等待中的会话排入所属组的队列并尝试分配给exclude以外的客服，调用方需持有cs.mu
+func (cs *CustomerService) queueSessionLocked(session *Session, exclude map[string]bool) {
+	cs.dequeueLocked(session.UserID)
+
+	entry := &queueEntry{
+		UserID:    session.UserID,
+		GroupID:   session.GroupID,
+		SessionID: session.ID,
+		Subject:   session.Subject,
+		EnqueueAt: cs.now(),
+	}
+	cs.waiting[entry.UserID] = entry
+	cs.queues[entry.GroupID] = append(cs.queues[entry.GroupID], entry.UserID)
+	cs.notifyQueuePositionsLocked(entry.GroupID)
+	cs.dispatchLocked(entry, exclude)
+}
+
+// dequeueLocked 将用户移出等待队列，返回用户此前是否在排队，调用方需持有cs.mu
+func (cs *CustomerService) dequeueLocked(userID string) bool {
+	entry, exists := cs.waiting[userID]
+	if !exists {
+		return false
+	}
+	delete(cs.waiting, userID)
+
+	queue := cs.queues[entry.GroupID]
+	for i, id := range queue {
+		if id == userID {
+			cs.queues[entry.GroupID] = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	cs.clearQueuePositionLocked(userID)
+	cs.notifyQueuePositionsLocked(entry.GroupID)
+	return true
+}
+
+// dispatchLocked 为排队用户选择客服，自动接入的客服直接分配，其余发起邀请，
+// exclude中的客服不参与，调用方需持有cs.mu
+func (cs *CustomerService) dispatchLocked(entry *queueEntry, exclude map[string]bool) {
+	if _, offered := cs.userOffers[entry.UserID]; offered {
+		return
+	}
+	// 重新排队的会话已计入上限，只有新会话受系统上限限制
+	if entry.SessionID == "" && cs.atSystemCapacityLocked() {
+		return
+	}
+	staff := cs.pickStaffLocked(entry.GroupID, entry.Subject, exclude)
+	if staff == nil {
+		return
+	}
+	if staff.AutoAccept {
+		if user, exists := cs.users[entry.UserID]; exists {
+			cs.autoAssignLocked(user, staff)
+		}
+		return
+	}
+	offer := cs.offerLocked(staff, entry.UserID, entry.GroupID)
+	for id := range exclude {
+		offer.declined[id] = true
+	}
+}
+
+// dispatchGroupLocked 按有效优先级为组内尚未收到邀请的排队用户依次发起邀请，调用方需持有cs.mu
+func (cs *CustomerService) dispatchGroupLocked(groupID string) {
+	for _, entry := range cs.dispatchOrderLocked(groupID) {
+		// 前面的分配可能已使该用户离开队列
+		if cs.waiting[entry.UserID] == entry {
+			cs.dispatchLocked(entry, nil)
+		}
+	}
+}
+
+// pickStaffLocked 在组内未满额且连接正常的在线客服中按分配策略选择一位，跳过exclude中的客服，
+// 组正在排空或已达会话总数上限时不选择，调用方需持有cs.mu
+// subject不为空且有客服具备同名技能时只在这些客服中选择。默认选择会话数最少的客服，会话数相同时按ID排序
+func (cs *CustomerService) pickStaffLocked(groupID, subject string, exclude map[string]bool) *CSStaff {
+	group, exists := cs.groups[groupID]
+	if !exists || cs.checkGroupLocked(group) != nil {
+		return nil
+	}
+
+	candidates := make([]*CSStaff, 0, len(group.Members))
+	for id, staff := range group.Members {
+		if exclude[id] || staff.Status != UserStatusOnline || staff.unresponsive || cs.atCapacityLocked(staff) {
+			continue
+		}
+		candidates = append(candidates, staff)
+	}
+	candidates = preferSkilledLocked(candidates, subject)
+	if cs.routing == RoutingWeighted {
+		return cs.pickWeightedLocked(candidates)
+	}
+
+	var picked *CSStaff
+	for _, staff := range candidates {
+		if picked == nil ||
+			len(staff.Sessions) < len(picked.Sessions) ||
+			(len(staff.Sessions) == len(picked.Sessions) && staff.ID < picked.ID) {
+			picked = staff
+		}
+	}
+	return picked
+}
+
+// atCapacityLocked 判断客服的会话数与待处理邀请数之和是否已达上限，调用方需持有cs.mu
+func (cs *CustomerService) atCapacityLocked(staff *CSStaff) bool {
+	if staff.MaxSessions <= 0 {
+		return false
+	}
+	return len(staff.Sessions)+cs.pendingOffersLocked(staff.ID) >= staff.MaxSessions
+}
+
+// assignQueuedLocked 将排队用户分配给客服，重新排队的会话沿用原会话，调用方需持有cs.mu
+func (cs *CustomerService) assignQueuedLocked(user *User, staff *CSStaff) *Session {
+	entry, queued := cs.waiting[user.ID]
+	if queued && entry.SessionID != "" {
+		if session, exists := cs.sessions[entry.SessionID]; exists && cs.attachSessionLocked(session, user, staff, ReasonAssigned) == nil {
+			return session
+		}
+	}
+	session := cs.createSessionLocked(user, staff)
+	if queued {
+		session.Subject = entry.Subject
+	}
+	return session
+}
+
+// autoAssignLocked 将排队用户直接分配给自动接入的客服并发出分配事件，调用方需持有cs.mu
+func (cs *CustomerService) autoAssignLocked(user *User, staff *CSStaff) {
+	session := cs.assignQueuedLocked(user, staff)
+	cs.emit(EventSessionAssigned, SessionEvent{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		StaffID:   session.StaffID,
+	})
+}
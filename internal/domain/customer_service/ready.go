@@ -0,0 +1,38 @@
+package customer_service
+
+// WithRequireUserReady 开启后用户需先提交会话前表单（MarkUserReady）才能排队，
+// 未就绪的用户排队时返回ErrUserNotReady，不会被分配给客服
+func WithRequireUserReady(on bool) Option {
+	return func(cs *CustomerService) {
+		cs.requireReady = on
+	}
+}
+
+// MarkUserReady 记录用户提交的会话前表单并标记为就绪，fields在新建会话时写入会话自定义字段，
+// 重复调用时合并字段，同名字段以最后一次为准
+func (cs *CustomerService) MarkUserReady(userID string, fields map[string]string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	user, exists := cs.users[userID]
+	if !exists {
+		return ErrUserNotFound
+	}
+	for key, value := range fields {
+		if user.preChat == nil {
+			user.preChat = make(map[string]string, len(fields))
+		}
+		user.preChat[key] = value
+	}
+	user.ready = true
+	return nil
+}
+
+// IsUserReady 判断用户是否已提交会话前表单
+func (cs *CustomerService) IsUserReady(userID string) bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	user, exists := cs.users[userID]
+	return exists && user.ready
+}
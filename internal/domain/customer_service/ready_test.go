@@ -0,0 +1,43 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_MarkUserReady(t *testing.T) {
+	cs := NewCustomerService(WithRequireUserReady(true))
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	staff, _ := cs.ConnectStaff("staff1", "TestStaff", "group1", nil)
+	cs.SetAutoAccept("staff1", true)
+	user, _ := cs.ConnectUser("user1", "TestUser", nil)
+
+	// 未提交会话前表单的用户不能排队，也不会被分配
+	assert.Equal(t, ErrUserNotReady, cs.EnqueueUser("user1", "group1"))
+	assert.False(t, cs.IsUserReady("user1"))
+	assert.Empty(t, cs.QueuedUsers("group1"))
+	assert.Empty(t, user.SessionID)
+	assert.Empty(t, staff.Sessions)
+
+	// 就绪后排队并分配，表单字段写入会话自定义字段
+	assert.NoError(t, cs.MarkUserReady("user1", map[string]string{"name": "Alice", "issue": "refund"}))
+	assert.True(t, cs.IsUserReady("user1"))
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+	assert.NotEmpty(t, user.SessionID)
+	session := cs.GetSession(user.SessionID)
+	assert.Equal(t, map[string]string{"name": "Alice", "issue": "refund"}, session.GetVariables())
+
+	assert.Equal(t, ErrUserNotFound, cs.MarkUserReady("nonexistent", nil))
+}
+
+func TestCustomerService_ReadyNotRequiredByDefault(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+}
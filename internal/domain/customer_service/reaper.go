@@ -0,0 +1,104 @@
+package customer_service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultReapInterval 后台巡检的默认间隔
+const defaultReapInterval = time.Second
+
+// SilenceAction 用户长时间未发言时对会话的处理方式
+type SilenceAction int
+
+const (
+	SilenceActionRequeue SilenceAction = iota // 释放客服，会话重新排队
+	SilenceActionClose                        // 关闭会话
+)
+
+// WithUserSilencePolicy 用户在会话中超过timeout未发言时，按action释放客服
+// 只观察用户一侧，客服持续发言不会重置计时
+func WithUserSilencePolicy(timeout time.Duration, action SilenceAction) Option {
+	return func(cs *CustomerService) {
+		cs.userSilenceTimeout = timeout
+		cs.userSilenceAction = action
+	}
+}
+
+// WithReapInterval 设置后台巡检间隔
+func WithReapInterval(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.reapInterval = d
+	}
+}
+
+// reaper 后台巡检协程，定期处理超时的会话
+type reaper struct {
+	done chan struct{}
+	wg   sync.WaitGroup
+	beat atomic.Int64 // 最近一次完成巡检的系统时间，用于健康检查判断协程是否卡住，不受服务时钟影响
+}
+
+// startReaper 启动后台巡检
+func (cs *CustomerService) startReaper() {
+	r := &reaper{done: make(chan struct{})}
+	r.beat.Store(time.Now().UnixNano())
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(cs.reapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				cs.reap(cs.now())
+				r.beat.Store(time.Now().UnixNano())
+			case <-r.done:
+				return
+			}
+		}
+	}()
+	cs.reaper = r
+}
+
+// stop 停止后台巡检并等待协程退出
+func (r *reaper) stop() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+// reap 执行一次巡检
+func (cs *CustomerService) reap(now time.Time) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.reapSilentSessionsLocked(now)
+}
+
+// reapSilentSessionsLocked 处理用户长时间未发言的会话，调用方需持有cs.mu
+func (cs *CustomerService) reapSilentSessionsLocked(now time.Time) {
+	if cs.userSilenceTimeout <= 0 {
+		return
+	}
+
+	for _, session := range cs.sessions {
+		if session.Status != SessionStatusActive || now.Sub(session.userActiveAt) < cs.userSilenceTimeout {
+			continue
+		}
+
+		event := SessionEvent{
+			SessionID: session.ID,
+			UserID:    session.UserID,
+			StaffID:   session.StaffID,
+			Reason:    ReasonUserSilence,
+		}
+		if cs.userSilenceAction == SilenceActionClose {
+			if cs.closeSessionLocked(session, event.Reason) == nil {
+				cs.emit(EventSessionClosed, event)
+			}
+		} else if cs.requeueSessionLocked(session, event.Reason) == nil {
+			cs.emit(EventSessionRequeued, event)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordSessionEvents 通过事件回调收集会话事件和邀请
+func recordSessionEvents(cs *CustomerService) (<-chan string, <-chan SessionEvent, <-chan Offer) {
+	types := make(chan string, 16)
+	events := make(chan SessionEvent, 16)
+	offers := make(chan Offer, 16)
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		switch p := payload.(type) {
+		case SessionEvent:
+			types <- eventType
+			events <- p
+		case Offer:
+			offers <- p
+		}
+	})
+	return types, events, offers
+}
+
+func TestCustomerService_UserSilenceRequeue(t *testing.T) {
+	cs := NewCustomerService(
+		WithUserSilencePolicy(100*time.Millisecond, SilenceActionRequeue),
+		WithReapInterval(10*time.Millisecond),
+	)
+	defer cs.Shutdown()
+	types, events, offers := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	staff1 := cs.GetStaff("staff1")
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+
+	// 客服持续发言，用户沉默
+	stop := time.After(300 * time.Millisecond)
+	var eventType string
+	var event SessionEvent
+loop:
+	for {
+		select {
+		case eventType = <-types:
+			event = <-events
+			break loop
+		case <-stop:
+			t.Fatal("session was not requeued")
+		case <-time.After(20 * time.Millisecond):
+			cs.SendMessage(session.ID, "staff1", "Are you there?", MessageTypeText)
+		}
+	}
+
+	assert.Equal(t, EventSessionRequeued, eventType)
+	assert.Equal(t, session.ID, event.SessionID)
+	assert.Equal(t, "staff1", event.StaffID)
+	assert.Equal(t, ReasonUserSilence, event.Reason)
+
+	// 客服被释放，会话保留在队列中
+	cs.mu.RLock()
+	assert.Equal(t, SessionStatusWaiting, session.Status)
+	assert.NotContains(t, staff1.Sessions, session.ID)
+	assert.Equal(t, session.ID, cs.GetUser("user1").SessionID)
+	cs.mu.RUnlock()
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+
+	// 邀请转给其他客服，接受后沿用原会话和历史消息
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "staff2", offer.StaffID)
+	resumed, err := cs.AcceptOffer("staff2", offer.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, session, resumed)
+	assert.Equal(t, SessionStatusActive, resumed.Status)
+	assert.Equal(t, "staff2", resumed.StaffID)
+	assert.NotEmpty(t, resumed.Messages)
+}
+
+func TestCustomerService_UserSilenceClose(t *testing.T) {
+	cs := NewCustomerService(
+		WithUserSilencePolicy(time.Minute, SilenceActionClose),
+		WithReapInterval(time.Hour),
+	)
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	active := createTestSession(t, cs, "user2", "staff1")
+
+	// 模拟用户2在30秒后发言，计时被重置
+	active.userActiveAt = time.Now().Add(30 * time.Second)
+	cs.reap(time.Now().Add(time.Minute))
+
+	assert.Equal(t, EventSessionClosed, <-types)
+	event := <-events
+	assert.Equal(t, session.ID, event.SessionID)
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.Empty(t, cs.GetUser("user1").SessionID)
+	assert.Equal(t, UserStatusOnline, cs.GetUser("user1").Status)
+	assert.NotContains(t, cs.GetStaff("staff1").Sessions, session.ID)
+
+	assert.Equal(t, SessionStatusActive, active.Status)
+}
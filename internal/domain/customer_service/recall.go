@@ -0,0 +1,68 @@
+package customer_service
+
+// recalledContent 撤回后消息显示的内容
+const recalledContent = "消息已撤回"
+
+// LastMessage 获取会话的最后一条消息，会话没有消息时返回nil
+func (cs *CustomerService) LastMessage(sessionID string) (*Message, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	return session.LastMessage, nil
+}
+
+// RecallMessage 撤回消息，仅发送者本人可以撤回。消息保留在会话中，内容替换为撤回提示
+func (cs *CustomerService) RecallMessage(sessionID, messageID, byID string) (*Message, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+
+	msg := findMessage(session, messageID)
+	if msg == nil {
+		return nil, ErrMessageNotFound
+	}
+	if msg.FromID != byID || msg.Type == MessageTypeSystem || msg.Recalled {
+		return nil, ErrInvalidOperation
+	}
+
+	msg.Recalled = true
+	msg.Content = recalledContent
+	session.UpdateAt = cs.now()
+	cs.emit(EventMessageRecalled, *msg)
+	return msg, nil
+}
+
+// MarkUndelivered 标记消息未送达，由网关在多次重发仍未收到接收方确认后调用
+func (cs *CustomerService) MarkUndelivered(sessionID, messageID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	msg := findMessage(session, messageID)
+	if msg == nil {
+		return ErrMessageNotFound
+	}
+	msg.Undelivered = true
+	return nil
+}
+
+// findMessage 在会话内存中的消息里查找指定ID的消息，从最新一条开始查找，调用方需持有cs.mu
+func findMessage(session *Session, messageID string) *Message {
+	for i := len(session.Messages) - 1; i >= 0; i-- {
+		if session.Messages[i].ID == messageID {
+			return session.Messages[i]
+		}
+	}
+	return nil
+}
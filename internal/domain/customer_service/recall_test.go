@@ -0,0 +1,64 @@
+package customer_service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_LastMessage(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	last, err := cs.LastMessage(session.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, last)
+
+	// 多次发送后缓存始终指向最后一条
+	for i := 0; i < 50; i++ {
+		from := "user1"
+		if i%2 == 1 {
+			from = "staff1"
+		}
+		msg, err := cs.SendMessage(session.ID, from, fmt.Sprintf("message %d", i), MessageTypeText)
+		assert.NoError(t, err)
+		last, _ = cs.LastMessage(session.ID)
+		assert.Same(t, msg, last)
+	}
+	_, errs := cs.SendMessages(session.ID, "user1", []string{"a", "b", ""})
+	last, _ = cs.LastMessage(session.ID)
+	assert.Same(t, session.Messages[len(session.Messages)-1], last)
+	assert.Equal(t, "b", last.Content)
+	assert.Equal(t, ErrEmptyContent, errs[2])
+
+	// 撤回只修改内容，缓存仍指向同一条消息
+	_, err = cs.RecallMessage(session.ID, last.ID, "staff1")
+	assert.Equal(t, ErrInvalidOperation, err)
+	recalled, err := cs.RecallMessage(session.ID, last.ID, "user1")
+	assert.NoError(t, err)
+	assert.True(t, recalled.Recalled)
+	last, _ = cs.LastMessage(session.ID)
+	assert.Same(t, recalled, last)
+	assert.Equal(t, recalledContent, last.Content)
+
+	_, err = cs.RecallMessage(session.ID, "nonexistent", "user1")
+	assert.Equal(t, ErrMessageNotFound, err)
+	_, err = cs.LastMessage("nonexistent")
+	assert.Equal(t, ErrSessionNotFound, err)
+}
+
+func TestCustomerService_MarkUndelivered(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	msg, _ := cs.SendMessage(session.ID, "staff1", "Hello", MessageTypeText)
+	assert.False(t, msg.Undelivered)
+	assert.NoError(t, cs.MarkUndelivered(session.ID, msg.ID))
+	assert.True(t, msg.Undelivered)
+
+	assert.Equal(t, ErrMessageNotFound, cs.MarkUndelivered(session.ID, "nonexistent"))
+	assert.Equal(t, ErrSessionNotFound, cs.MarkUndelivered("nonexistent", msg.ID))
+}
@@ -0,0 +1,68 @@
+package customer_service
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WithReconnectGrace 设置用户断线后的重连宽限期。宽限期内用户标记为离线但会话保持，
+// 重新连接后继续原会话；超过宽限期仍未重连才移除用户并关闭会话。0表示断线立即移除
+func WithReconnectGrace(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.reconnectGrace = d
+	}
+}
+
+// startReconnectGraceLocked 标记用户离线并开始宽限期计时，调用方需持有cs.mu
+func (cs *CustomerService) startReconnectGraceLocked(user *User) {
+	user.Status = UserStatusOffline
+	user.Conn = nil
+	if user.graceTimer != nil {
+		user.graceTimer.Stop()
+	}
+
+	userID := user.ID
+	user.graceTimer = cs.afterFunc(cs.reconnectGrace, func() {
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		// 用户可能已重新连接，只处理仍在等待重连的同一用户
+		if current, exists := cs.users[userID]; exists && current == user && user.graceTimer != nil {
+			user.graceTimer = nil
+			cs.expireUserLocked(user)
+		}
+	})
+}
+
+// resumeUserLocked 宽限期内重新连接的用户沿用原有状态和会话，调用方需持有cs.mu
+func (cs *CustomerService) resumeUserLocked(user *User, name, channel string, conn *websocket.Conn) {
+	user.graceTimer.Stop()
+	user.graceTimer = nil
+	user.Conn = conn
+	user.Name = name
+	user.Channel = channel
+	user.Status = UserStatusOnline
+	user.lastSeenAt = cs.now()
+	if session, exists := cs.sessions[user.SessionID]; exists && session.Status != SessionStatusClosed {
+		user.Status = UserStatusInSession
+	}
+}
+
+// expireUserLocked 宽限期结束仍未重连，移除用户并关闭其会话，调用方需持有cs.mu
+func (cs *CustomerService) expireUserLocked(user *User) {
+	cs.removeUserLocked(user)
+
+	session, exists := cs.sessions[user.SessionID]
+	if !exists || session.Status == SessionStatusClosed {
+		return
+	}
+	event := SessionEvent{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		StaffID:   session.StaffID,
+		Reason:    ReasonUserOffline,
+	}
+	if cs.closeSessionLocked(session, ReasonUserOffline) == nil {
+		cs.emit(EventSessionClosed, event)
+	}
+}
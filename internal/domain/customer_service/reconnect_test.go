@@ -0,0 +1,92 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ReconnectWithinGrace(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithReconnectGrace(time.Minute))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	token, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+
+	// 宽限期内用户标记为离线，会话保持
+	cs.DisconnectUser("user1", DisconnectClientClose)
+	user := cs.GetUser("user1")
+	if assert.NotNil(t, user) {
+		assert.Equal(t, UserStatusOffline, user.Status)
+		assert.Equal(t, session.ID, user.SessionID)
+	}
+	assert.Equal(t, SessionStatusActive, session.Status)
+
+	resumed, err := cs.ResumeUser(token, "user1", "TestUser", nil)
+	assert.NoError(t, err)
+	assert.Same(t, user, resumed)
+	assert.Equal(t, UserStatusInSession, resumed.Status)
+	assert.Equal(t, session.ID, resumed.SessionID)
+
+	// 超过原宽限期后会话仍然有效
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, SessionStatusActive, session.Status)
+	_, err = cs.SendMessage(session.ID, "user1", "hello again", MessageTypeText)
+	assert.NoError(t, err)
+}
+
+func TestCustomerService_ReconnectGraceExpired(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithReconnectGrace(time.Minute))
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.DisconnectUser("user1", DisconnectClientClose)
+	clock.Advance(time.Minute)
+
+	// 宽限期结束仍未重连，移除用户并关闭会话
+	select {
+	case eventType := <-types:
+		assert.Equal(t, EventSessionClosed, eventType)
+	case <-time.After(time.Second):
+		t.Fatal("session was not closed")
+	}
+	event := <-events
+	assert.Equal(t, session.ID, event.SessionID)
+	assert.Equal(t, ReasonUserOffline, event.Reason)
+	assert.Nil(t, cs.GetUser("user1"))
+	assert.Equal(t, SessionStatusClosed, session.Status)
+}
+
+func TestCustomerService_ReconnectWithoutToken(t *testing.T) {
+	cs := NewCustomerService(WithReconnectGrace(time.Minute))
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.DisconnectUser("user1", DisconnectClientClose)
+	old := cs.GetUser("user1")
+
+	// 宽限期内未出示令牌的连接按新用户处理，原会话随宽限期结束而关闭
+	user, err := cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, err)
+	assert.NotSame(t, old, user)
+	assert.Empty(t, user.SessionID)
+	assert.Equal(t, UserStatusOnline, user.Status)
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.Equal(t, EventSessionClosed, <-types)
+	assert.Equal(t, ReasonUserOffline, (<-events).Reason)
+}
+
+func TestCustomerService_DisconnectWithoutGrace(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	createTestSession(t, cs, "user1", "staff1")
+	cs.DisconnectUser("user1", DisconnectClientClose)
+	assert.Nil(t, cs.GetUser("user1"))
+}
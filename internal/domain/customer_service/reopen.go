@@ -0,0 +1,72 @@
+package customer_service
+
+import "time"
+
+// defaultReopenWindow 会话关闭后默认允许重新打开的时长
+const defaultReopenWindow = 10 * time.Minute
+
+// WithReopenWindow 设置会话关闭后允许重新打开的时长，小于等于0时不允许重新打开
+func WithReopenWindow(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.reopenWindow = d
+	}
+}
+
+// ReopenSession 重新打开最近关闭的会话，保留原有消息和自定义字段，服务等级考核重新计时。
+// 原客服在线或在整理中且未满额时直接接回并结束整理，否则会话重新排队。超过允许的时长返回ErrReopenWindowExpired，
+// 用户已离线返回ErrUserNotFound，用户已在其他会话或排队中返回ErrInvalidOperation
+func (cs *CustomerService) ReopenSession(sessionID string) (*Session, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	if session.Status != SessionStatusClosed {
+		return nil, ErrInvalidOperation
+	}
+	if cs.reopenWindow <= 0 || cs.now().Sub(session.UpdateAt) > cs.reopenWindow {
+		return nil, ErrReopenWindowExpired
+	}
+	user, exists := cs.users[session.UserID]
+	if !exists || user.graceTimer != nil {
+		return nil, ErrUserNotFound
+	}
+	// 会话关闭时都会解除用户关联，仍指向会话的用户有未关闭的会话
+	if user.SessionID != "" {
+		return nil, ErrInvalidOperation
+	}
+	if _, queued := cs.waiting[user.ID]; queued {
+		return nil, ErrInvalidOperation
+	}
+	if cs.atSystemCapacityLocked() {
+		return nil, ErrSystemAtCapacity
+	}
+
+	if err := cs.transitionLocked(session, SessionStatusWaiting, ReasonReopened); err != nil {
+		return nil, err
+	}
+	session.UpdateAt = cs.now()
+	cs.startSLALocked(session)
+
+	staff, exists := cs.staffs[session.StaffID]
+	if exists && (staff.Status == UserStatusOnline || staff.Status == UserStatusWrapUp) &&
+		!cs.atCapacityLocked(staff) && cs.checkGroupLocked(cs.groups[staff.GroupID]) == nil {
+		if staff.Status == UserStatusWrapUp {
+			cs.cancelWrapUpLocked(staff)
+		}
+		cs.attachSessionLocked(session, user, staff, ReasonReopened)
+		cs.emit(EventSessionAssigned, SessionEvent{
+			SessionID: session.ID,
+			UserID:    session.UserID,
+			StaffID:   session.StaffID,
+			Reason:    ReasonReopened,
+		})
+		return session, nil
+	}
+
+	session.StaffID = ""
+	cs.queueSessionLocked(session, nil)
+	return session, nil
+}
@@ -0,0 +1,119 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ReopenSession(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(session.ID, "user1", "hello", MessageTypeText)
+	session.SetVariable("order", "42")
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+
+	// 窗口内重新打开，原客服接回并保留消息和自定义字段
+	reopened, err := cs.ReopenSession(session.ID)
+	assert.NoError(t, err)
+	assert.Same(t, session, reopened)
+	assert.Equal(t, SessionStatusActive, session.Status)
+	assert.Equal(t, "staff1", session.StaffID)
+	assert.Equal(t, session.ID, cs.GetUser("user1").SessionID)
+	assert.Contains(t, cs.GetStaff("staff1").Sessions, session.ID)
+	assert.Len(t, session.Messages, 1)
+	assert.Equal(t, "42", session.GetVariables()["order"])
+
+	// 进行中的会话不能重新打开
+	_, err = cs.ReopenSession(session.ID)
+	assert.Equal(t, ErrInvalidOperation, err)
+	_, err = cs.ReopenSession("nonexistent")
+	assert.Equal(t, ErrSessionNotFound, err)
+}
+
+func TestCustomerService_ReopenSessionExpired(t *testing.T) {
+	cs := NewCustomerService(WithReopenWindow(time.Minute))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+	cs.mu.Lock()
+	session.UpdateAt = time.Now().Add(-2 * time.Minute)
+	cs.mu.Unlock()
+
+	_, err := cs.ReopenSession(session.ID)
+	assert.Equal(t, ErrReopenWindowExpired, err)
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.Empty(t, cs.GetUser("user1").SessionID)
+}
+
+func TestCustomerService_ReopenSessionRequeues(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.DisconnectStaff("staff1", DisconnectClientClose)
+	assert.Equal(t, SessionStatusClosed, session.Status)
+
+	// 原客服已离线，会话重新排队，组内其他客服接入后沿用原会话
+	reopened, err := cs.ReopenSession(session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStatusWaiting, reopened.Status)
+	assert.Empty(t, reopened.StaffID)
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+
+	cs.ConnectStaff("staff2", "TestStaff", "group1", nil)
+	claimed, err := cs.ClaimNext("staff2")
+	assert.NoError(t, err)
+	assert.Same(t, session, claimed)
+	assert.Equal(t, SessionStatusActive, session.Status)
+	assert.Equal(t, "staff2", session.StaffID)
+
+	// 用户已有会话时不能重新打开其他会话
+	other := createTestSession(t, cs, "user2", "staff2")
+	cs.CloseSession(other.ID, "user2")
+	createTestSession(t, cs, "user2", "staff2")
+	_, err = cs.ReopenSession(other.ID)
+	assert.Equal(t, ErrInvalidOperation, err)
+}
+
+func TestCustomerService_ReopenSessionDuringWrapUp(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+	types, _ := recordSLAEvents(cs)
+	cs.SetWrapUpDuration(10 * time.Minute)
+
+	cs.CreateGroup("group1", "TestGroup")
+	assert.NoError(t, cs.SetSLA("group1", &SLAConfig{FirstResponse: 2 * time.Minute}))
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(session.ID, "staff1", "hello", MessageTypeText)
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+	assert.Equal(t, UserStatusWrapUp, cs.GetStaff("staff1").Status)
+
+	// 客服整理中时接回会话并结束整理，整理计时不再把客服恢复为在线后重新分配
+	clock.Advance(5 * time.Minute)
+	reopened, err := cs.ReopenSession(session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStatusActive, reopened.Status)
+	assert.Equal(t, "staff1", reopened.StaffID)
+	assert.Equal(t, UserStatusOnline, cs.GetStaff("staff1").Status)
+	cs.mu.RLock()
+	assert.Nil(t, cs.staffs["staff1"].wrapUpTimer)
+	cs.mu.RUnlock()
+
+	// 服务等级考核从重新打开时重新计时
+	status := cs.SLAStatus(session.ID)
+	assert.Equal(t, clock.Now().Add(2*time.Minute), status.FirstResponseDue)
+	assert.True(t, status.FirstResponseAt.IsZero())
+	clock.Advance(2 * time.Minute)
+	select {
+	case eventType := <-types:
+		assert.Equal(t, EventSLABreach, eventType)
+	case <-time.After(time.Second):
+		t.Fatal("SLA breach was not emitted after reopening")
+	}
+}
@@ -0,0 +1,85 @@
+package customer_service
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// reportHeader 会话报表的CSV表头
+var reportHeader = []string{
+	"session_id", "user_id", "staff_id", "group_id", "channel",
+	"status", "created_at", "closed_at", "message_count", "first_response_seconds",
+}
+
+// reportRow 报表中的一行，加锁期间只复制这些字段
+type reportRow struct {
+	id, userID, staffID, groupID, channel string
+	status                                SessionStatus
+	createAt, closedAt                    time.Time
+	messageCount                          int64
+	firstResponse                         time.Duration
+}
+
+// ExportReport 以CSV格式导出创建时间在[since, until)内的会话，按创建时间排序，until为零值时不限上界。
+// 加锁期间只复制每个会话的报表字段，写出在锁外逐行进行，不会因写入方缓慢而阻塞服务
+func (cs *CustomerService) ExportReport(since, until time.Time, w io.Writer) error {
+	cs.mu.RLock()
+	rows := make([]reportRow, 0)
+	for _, session := range cs.sessions {
+		if session.CreateAt.Before(since) || (!until.IsZero() && !session.CreateAt.Before(until)) {
+			continue
+		}
+		row := reportRow{
+			id:           session.ID,
+			userID:       session.UserID,
+			staffID:      session.StaffID,
+			groupID:      session.GroupID,
+			channel:      session.Channel,
+			status:       session.Status,
+			createAt:     session.CreateAt,
+			messageCount: session.msgSeq,
+
+			firstResponse: session.FirstResponseDuration,
+		}
+		if session.Status == SessionStatusClosed {
+			row.closedAt = session.UpdateAt
+		}
+		rows = append(rows, row)
+	}
+	cs.mu.RUnlock()
+
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].createAt.Equal(rows[j].createAt) {
+			return rows[i].createAt.Before(rows[j].createAt)
+		}
+		return rows[i].id < rows[j].id
+	})
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(reportHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		closedAt := ""
+		if !row.closedAt.IsZero() {
+			closedAt = row.closedAt.Format(time.RFC3339)
+		}
+		firstResponse := ""
+		if row.firstResponse > 0 {
+			firstResponse = strconv.FormatFloat(row.firstResponse.Seconds(), 'f', 3, 64)
+		}
+		record := []string{
+			row.id, row.userID, row.staffID, row.groupID, row.channel,
+			row.status.String(), row.createAt.Format(time.RFC3339), closedAt,
+			strconv.FormatInt(row.messageCount, 10), firstResponse,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
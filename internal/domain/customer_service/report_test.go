@@ -0,0 +1,75 @@
+package customer_service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ExportReport(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	start := time.Now()
+	first := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(first.ID, "user1", "hello", MessageTypeText)
+	cs.SendMessage(first.ID, "staff1", "hi", MessageTypeText)
+	assert.NoError(t, cs.CloseSession(first.ID, "staff1"))
+	second := createTestSession(t, cs, "user2", "staff1")
+	cs.SendMessage(second.ID, "user2", "hello", MessageTypeText)
+
+	// 时间范围外的会话不导出
+	old := createTestSession(t, cs, "user3", "staff1")
+	old.CreateAt = start.Add(-time.Hour)
+
+	var buf bytes.Buffer
+	assert.NoError(t, cs.ExportReport(start, time.Time{}, &buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 3) {
+		return
+	}
+	assert.Equal(t, reportHeader, records[0])
+
+	byID := map[string][]string{records[1][0]: records[1], records[2][0]: records[2]}
+	row := byID[first.ID]
+	if assert.NotNil(t, row) {
+		assert.Equal(t, []string{first.ID, "user1", "staff1", "group1", ChannelWeb, "closed"}, row[:6])
+		assert.NotEmpty(t, row[7])
+		assert.Equal(t, "2", row[8])
+	}
+	row = byID[second.ID]
+	if assert.NotNil(t, row) {
+		assert.Equal(t, "active", row[5])
+		assert.Empty(t, row[7])
+		assert.Equal(t, "1", row[8])
+	}
+
+	// 上界之后创建的会话同样不导出
+	buf.Reset()
+	assert.NoError(t, cs.ExportReport(start.Add(-2*time.Hour), start, &buf))
+	records, err = csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, old.ID, records[1][0])
+	}
+}
+
+// failingWriter 写入总是失败
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestCustomerService_ExportReportWriteError(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	createTestSession(t, cs, "user1", "staff1")
+
+	assert.EqualError(t, cs.ExportReport(time.Time{}, time.Time{}, failingWriter{}), "disk full")
+}
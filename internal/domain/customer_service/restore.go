@@ -0,0 +1,213 @@
+package customer_service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OrphanPolicy 客服已不在线的孤儿会话的处理方式
+type OrphanPolicy int
+
+const (
+	OrphanPolicyRequeue OrphanPolicy = iota // 会话重新排队，由组内其他客服接入
+	OrphanPolicyClose                       // 关闭会话
+	OrphanPolicyMark                        // 保留会话并标记为待重新分配，由主管处理
+)
+
+// SessionSnapshot 会话状态快照
+type SessionSnapshot struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	StaffID   string            `json:"staff_id"`
+	GroupID   string            `json:"group_id"`
+	Channel   string            `json:"channel"`
+	Subject   string            `json:"subject,omitempty"`
+	Status    SessionStatus     `json:"status"`
+	CreateAt  time.Time         `json:"create_at"`
+	UpdateAt  time.Time         `json:"update_at"`
+	Messages  []*Message        `json:"messages,omitempty"` // 为空且配置了存储时从存储加载
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// ReconcileReport 会话核对结果，各列表为会话ID
+type ReconcileReport struct {
+	Requeued []string `json:"requeued"`
+	Closed   []string `json:"closed"`
+	Marked   []string `json:"marked"`
+}
+
+// WithOrphanPolicy 设置ReconcileSessions对客服已不在线的会话的处理方式
+func WithOrphanPolicy(policy OrphanPolicy) Option {
+	return func(cs *CustomerService) {
+		cs.orphanPolicy = policy
+	}
+}
+
+// Snapshot 导出全部未关闭会话的快照，按会话ID排序
+func (cs *CustomerService) Snapshot() []SessionSnapshot {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	snapshots := make([]SessionSnapshot, 0, len(cs.sessions))
+	for _, session := range cs.sessions {
+		if session.Status == SessionStatusClosed {
+			continue
+		}
+		snapshots = append(snapshots, sessionSnapshotLocked(session))
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	return snapshots
+}
+
+// GetSessionSnapshot 获取单个会话的快照，包括已关闭的会话，内容在服务锁内复制，可以在锁外安全读取
+func (cs *CustomerService) GetSessionSnapshot(sessionID string) (SessionSnapshot, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return SessionSnapshot{}, ErrSessionNotFound
+	}
+	return sessionSnapshotLocked(session), nil
+}
+
+// sessionSnapshotLocked 生成会话快照，消息为副本，调用方需持有cs.mu
+func sessionSnapshotLocked(session *Session) SessionSnapshot {
+	messages := make([]*Message, len(session.Messages))
+	for i, msg := range session.Messages {
+		messages[i] = snapshotMessage(msg)
+	}
+	return SessionSnapshot{
+		ID:        session.ID,
+		UserID:    session.UserID,
+		StaffID:   session.StaffID,
+		GroupID:   session.GroupID,
+		Channel:   session.Channel,
+		Subject:   session.Subject,
+		Status:    session.Status,
+		CreateAt:  session.CreateAt,
+		UpdateAt:  session.UpdateAt,
+		Messages:  messages,
+		Variables: session.GetVariables(),
+	}
+}
+
+// RestoreFrom 从快照恢复会话，已存在的会话保持不变。会话的用户和客服已连接时重新建立关联，
+// 恢复后应调用ReconcileSessions处理参与者已不在线的会话
+func (cs *CustomerService) RestoreFrom(snapshots []SessionSnapshot) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, snap := range snapshots {
+		if _, exists := cs.sessions[snap.ID]; exists || snap.Status == SessionStatusClosed {
+			continue
+		}
+
+		messages := snap.Messages
+		if len(messages) == 0 && cs.store != nil {
+			loaded, err := cs.store.LoadMessages(snap.ID)
+			if err != nil {
+				return fmt.Errorf("load messages of session %s: %w", snap.ID, err)
+			}
+			messages = loaded
+		}
+
+		session := &Session{
+			ID:       snap.ID,
+			UserID:   snap.UserID,
+			StaffID:  snap.StaffID,
+			GroupID:  snap.GroupID,
+			Channel:  snap.Channel,
+			Subject:  snap.Subject,
+			Status:   snap.Status,
+			CreateAt: snap.CreateAt,
+			UpdateAt: snap.UpdateAt,
+			Messages: make([]*Message, 0, len(messages)),
+		}
+		for key, value := range snap.Variables {
+			session.SetVariable(key, value)
+		}
+		for _, msg := range messages {
+			copied := *msg
+			cs.appendMessageLocked(session, &copied)
+			if copied.Seq > session.msgSeq {
+				session.msgSeq = copied.Seq
+			}
+		}
+		cs.evictMessagesLocked(session)
+		session.userActiveAt = cs.now()
+		cs.sessions[session.ID] = session
+
+		// 参与者已重新连接时恢复关联
+		if session.Status != SessionStatusActive {
+			continue
+		}
+		if staff, exists := cs.staffs[session.StaffID]; exists && staff.Status != UserStatusOffline {
+			staff.Sessions[session.ID] = session
+		}
+		if user, exists := cs.users[session.UserID]; exists && user.SessionID == "" {
+			user.SessionID = session.ID
+			user.Status = UserStatusInSession
+		}
+	}
+	return nil
+}
+
+// ReconcileSessions 核对未关闭的会话：用户已不在线的会话直接关闭，
+// 客服已不在线的进行中会话按孤儿会话策略重新排队、关闭或标记为待重新分配
+func (cs *CustomerService) ReconcileSessions() ReconcileReport {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	ids := make([]string, 0, len(cs.sessions))
+	for id, session := range cs.sessions {
+		if session.Status != SessionStatusClosed {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var report ReconcileReport
+	for _, id := range ids {
+		session := cs.sessions[id]
+		event := SessionEvent{
+			SessionID: session.ID,
+			UserID:    session.UserID,
+			StaffID:   session.StaffID,
+			Reason:    ReasonOrphaned,
+		}
+
+		if _, exists := cs.users[session.UserID]; !exists {
+			if cs.closeSessionLocked(session, event.Reason) == nil {
+				report.Closed = append(report.Closed, id)
+				cs.emit(EventSessionClosed, event)
+			}
+			continue
+		}
+
+		staff, exists := cs.staffs[session.StaffID]
+		if session.Status != SessionStatusActive || (exists && staff.Status != UserStatusOffline && staff.Sessions[id] == session) {
+			continue
+		}
+
+		switch cs.orphanPolicy {
+		case OrphanPolicyClose:
+			if cs.closeSessionLocked(session, event.Reason) == nil {
+				report.Closed = append(report.Closed, id)
+				cs.emit(EventSessionClosed, event)
+			}
+		case OrphanPolicyMark:
+			session.Orphaned = true
+			report.Marked = append(report.Marked, id)
+		default:
+			if cs.requeueSessionLocked(session, event.Reason) == nil {
+				report.Requeued = append(report.Requeued, id)
+				cs.emit(EventSessionRequeued, event)
+			}
+		}
+	}
+	return report
+}
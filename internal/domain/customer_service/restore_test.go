@@ -0,0 +1,118 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// restoreOrphan 在原服务中创建会话并导出快照，再在只有用户重新连接的新服务中恢复
+func restoreOrphan(t *testing.T, opts ...Option) (*CustomerService, *Session) {
+	store := NewMemoryStore()
+	old := NewCustomerService(WithStore(store))
+	session := createTestSession(t, old, "user1", "staff1")
+	old.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	old.SendMessage(session.ID, "staff1", "Hi", MessageTypeText)
+	snapshots := old.Snapshot()
+	old.Shutdown()
+
+	// 快照不含消息时从存储加载
+	for i := range snapshots {
+		snapshots[i].Messages = nil
+	}
+
+	cs := NewCustomerService(append(opts, WithStore(store))...)
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, cs.RestoreFrom(snapshots))
+
+	restored := cs.GetSession(session.ID)
+	assert.NotNil(t, restored)
+	assert.Len(t, restored.Messages, 2)
+	assert.Equal(t, "Hi", restored.LastMessage.Content)
+	assert.Equal(t, session.ID, cs.GetUser("user1").SessionID)
+	return cs, restored
+}
+
+func TestCustomerService_ReconcileRequeue(t *testing.T) {
+	cs, session := restoreOrphan(t)
+	defer cs.Shutdown()
+	offers := recordOffers(cs)
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+
+	report := cs.ReconcileSessions()
+	assert.Equal(t, []string{session.ID}, report.Requeued)
+	assert.Equal(t, SessionStatusWaiting, session.Status)
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+
+	// 组内其他客服接入，新消息序号接在恢复的消息之后
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "staff2", offer.StaffID)
+	_, err := cs.AcceptOffer("staff2", offer.ID)
+	assert.NoError(t, err)
+	msg, err := cs.SendMessage(session.ID, "staff2", "Back", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), msg.Seq)
+
+	// 核对后状态一致，再次核对不做处理
+	assert.Equal(t, ReconcileReport{}, cs.ReconcileSessions())
+}
+
+func TestCustomerService_ReconcileCloseAndMark(t *testing.T) {
+	cs, session := restoreOrphan(t, WithOrphanPolicy(OrphanPolicyClose))
+	assert.Equal(t, []string{session.ID}, cs.ReconcileSessions().Closed)
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.Empty(t, cs.GetUser("user1").SessionID)
+	cs.Shutdown()
+
+	cs, session = restoreOrphan(t, WithOrphanPolicy(OrphanPolicyMark))
+	defer cs.Shutdown()
+	assert.Equal(t, []string{session.ID}, cs.ReconcileSessions().Marked)
+	assert.True(t, session.Orphaned)
+	assert.Equal(t, SessionStatusActive, session.Status)
+
+	// 标记的会话可以直接转给其他客服
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	assert.NoError(t, cs.TransferSession(session.ID, "staff2"))
+	assert.False(t, session.Orphaned)
+	assert.Empty(t, cs.ReconcileSessions().Marked)
+}
+
+func TestCustomerService_ReconcileMissingUser(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.DisconnectUser("user1", DisconnectClientClose)
+	report := cs.ReconcileSessions()
+	assert.Equal(t, []string{session.ID}, report.Closed)
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.NotContains(t, cs.GetStaff("staff1").Sessions, session.ID)
+}
+
+func TestCustomerService_GetSessionSnapshot(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	session.SetVariable("order", "42")
+
+	snap, err := cs.GetSessionSnapshot(session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, session.ID, snap.ID)
+	assert.Equal(t, "staff1", snap.StaffID)
+	assert.Equal(t, map[string]string{"order": "42"}, snap.Variables)
+
+	// 快照中的消息是副本，之后的变化不影响快照
+	if assert.Len(t, snap.Messages, 1) {
+		assert.NotSame(t, session.Messages[0], snap.Messages[0])
+	}
+	cs.SendMessage(session.ID, "staff1", "Hi", MessageTypeText)
+	session.SetVariable("order", "43")
+	assert.Len(t, snap.Messages, 1)
+	assert.Equal(t, "42", snap.Variables["order"])
+
+	_, err = cs.GetSessionSnapshot("nonexistent")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
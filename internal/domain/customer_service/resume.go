@@ -0,0 +1,91 @@
+package customer_service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const defaultResumeTokenTTL = time.Minute
+
+// resumeToken 重连令牌，只能由签发时的用户使用一次
+type resumeToken struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// WithResumeTokenTTL 设置重连令牌的有效期，过期后令牌作废，小于等于0时使用默认值
+func WithResumeTokenTTL(d time.Duration) Option {
+	return func(cs *CustomerService) {
+		if d > 0 {
+			cs.resumeTokenTTL = d
+		}
+	}
+}
+
+// IssueResumeToken 为用户签发重连令牌，断线后在宽限期内凭令牌恢复原会话。
+// 每个用户同时只有一个有效令牌，重新签发时旧令牌作废
+func (cs *CustomerService) IssueResumeToken(userID string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b[:])
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, exists := cs.users[userID]; !exists {
+		return "", ErrUserNotFound
+	}
+	if cs.resumeTokens == nil {
+		cs.resumeTokens = make(map[string]*resumeToken)
+	}
+	now := cs.now()
+	for key, t := range cs.resumeTokens {
+		if t.userID == userID || !now.Before(t.expiresAt) {
+			delete(cs.resumeTokens, key)
+		}
+	}
+	cs.resumeTokens[token] = &resumeToken{userID: userID, expiresAt: now.Add(cs.resumeTokenTTL)}
+	return token, nil
+}
+
+// ResumeUser 凭重连令牌恢复宽限期内断线的用户，令牌在成功恢复后立即作废。
+// 令牌不存在、已使用、已过期、不属于该用户或用户已不在宽限期内时返回ErrInvalidResumeToken
+func (cs *CustomerService) ResumeUser(token, userID, name string, conn *websocket.Conn) (*User, error) {
+	if err := cs.ValidateIdentity(userID, name); err != nil {
+		return nil, err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if err := cs.consumeResumeTokenLocked(token, userID); err != nil {
+		return nil, err
+	}
+	user, exists := cs.users[userID]
+	if !exists || user.graceTimer == nil {
+		return nil, ErrInvalidResumeToken
+	}
+	cs.resumeUserLocked(user, name, user.Channel, conn)
+	cs.publishPresence(userID, PresenceRoleUser, true, "")
+	return user, nil
+}
+
+// consumeResumeTokenLocked 校验并作废用户的重连令牌，令牌不存在、已使用、已过期或不属于该用户时返回ErrInvalidResumeToken，
+// 调用方需持有cs.mu
+func (cs *CustomerService) consumeResumeTokenLocked(token, userID string) error {
+	t, exists := cs.resumeTokens[token]
+	if !exists || t.userID != userID {
+		// 不属于该用户的令牌不作废，避免他人猜中令牌后使原用户无法恢复
+		return ErrInvalidResumeToken
+	}
+	delete(cs.resumeTokens, token)
+	if !cs.now().Before(t.expiresAt) {
+		return ErrInvalidResumeToken
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ResumeTokenSingleUse(t *testing.T) {
+	cs := NewCustomerService(WithReconnectGrace(time.Second))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	token, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+
+	cs.DisconnectUser("user1", DisconnectError)
+	user, err := cs.ResumeUser(token, "user1", "TestUser", nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, user) {
+		assert.Equal(t, UserStatusInSession, user.Status)
+		assert.Equal(t, session.ID, user.SessionID)
+	}
+
+	// 再次断线后重放同一令牌
+	cs.DisconnectUser("user1", DisconnectError)
+	_, err = cs.ResumeUser(token, "user1", "TestUser", nil)
+	assert.Equal(t, ErrInvalidResumeToken, err)
+}
+
+func TestCustomerService_ResumeTokenBoundToUser(t *testing.T) {
+	cs := NewCustomerService(WithReconnectGrace(time.Second))
+	defer cs.Shutdown()
+
+	createTestSession(t, cs, "user1", "staff1")
+	token, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+	cs.DisconnectUser("user1", DisconnectError)
+
+	// 他人使用令牌失败，且不影响原用户恢复
+	_, err = cs.ResumeUser(token, "user2", "Other", nil)
+	assert.Equal(t, ErrInvalidResumeToken, err)
+	_, err = cs.ResumeUser(token, "user1", "TestUser", nil)
+	assert.NoError(t, err)
+}
+
+func TestCustomerService_ResumeTokenExpiredOrReplaced(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock), WithReconnectGrace(time.Hour), WithResumeTokenTTL(time.Minute))
+	defer cs.Shutdown()
+
+	createTestSession(t, cs, "user1", "staff1")
+	expired, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+	clock.Advance(2 * time.Minute)
+	cs.DisconnectUser("user1", DisconnectError)
+	_, err = cs.ResumeUser(expired, "user1", "TestUser", nil)
+	assert.Equal(t, ErrInvalidResumeToken, err)
+
+	// 重新签发后旧令牌作废
+	old, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+	current, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+	_, err = cs.ResumeUser(old, "user1", "TestUser", nil)
+	assert.Equal(t, ErrInvalidResumeToken, err)
+	_, err = cs.ResumeUser(current, "user1", "TestUser", nil)
+	assert.NoError(t, err)
+
+	_, err = cs.IssueResumeToken("missing")
+	assert.Equal(t, ErrUserNotFound, err)
+}
@@ -0,0 +1,113 @@
+package customer_service
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// SessionPurger 可选的存储清理能力，存储实现该接口时清理过期会话会同时删除存储中的消息
+type SessionPurger interface {
+	DeleteSessions(sessionIDs ...string) error
+}
+
+// DeleteSessions 删除会话的全部消息
+func (s *MemoryStore) DeleteSessions(sessionIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range sessionIDs {
+		delete(s.messages, id)
+	}
+	return nil
+}
+
+// DeleteSessions 底层存储支持清理时透传，否则忽略
+func (s *encryptedStore) DeleteSessions(sessionIDs ...string) error {
+	if purger, ok := s.SessionStore.(SessionPurger); ok {
+		return purger.DeleteSessions(sessionIDs...)
+	}
+	return nil
+}
+
+// WithRetention 开启后台清理，每隔interval清理关闭时间早于maxAge之前的会话，任一参数小于等于0时不开启
+func WithRetention(maxAge, interval time.Duration) Option {
+	return func(cs *CustomerService) {
+		cs.retentionAge = maxAge
+		cs.retentionInterval = interval
+	}
+}
+
+// PurgeOldSessions 从内存和存储中删除关闭时间早于before的会话及其消息，返回删除的会话数。
+// 未关闭的会话不会被删除。缓冲中的消息先写入存储再删除，存储的删除在锁外进行，
+// 失败时内存中的会话已删除，返回的错误来自存储
+func (cs *CustomerService) PurgeOldSessions(before time.Time) (int, error) {
+	cs.mu.Lock()
+	var ids []string
+	for id, session := range cs.sessions {
+		if session.Status != SessionStatusClosed || !session.UpdateAt.Before(before) {
+			continue
+		}
+		cs.removeSessionLocked(session)
+		ids = append(ids, id)
+	}
+	store, writer := cs.store, cs.writer
+	cs.mu.Unlock()
+
+	purger, ok := store.(SessionPurger)
+	if len(ids) == 0 || !ok {
+		return len(ids), nil
+	}
+	if writer != nil {
+		if err := writer.flush(); err != nil {
+			return len(ids), err
+		}
+	}
+	return len(ids), purger.DeleteSessions(ids...)
+}
+
+// removeSessionLocked 将已关闭的会话移出内存并解除残留的关联，调用方需持有cs.mu
+func (cs *CustomerService) removeSessionLocked(session *Session) {
+	delete(cs.sessions, session.ID)
+	cs.totalMessages.Add(-int64(session.msgCount))
+	if user, exists := cs.users[session.UserID]; exists && user.SessionID == session.ID {
+		user.SessionID = ""
+	}
+	if staff, exists := cs.staffs[session.StaffID]; exists {
+		delete(staff.Sessions, session.ID)
+	}
+}
+
+// retentionJob 定期清理过期会话的后台任务
+type retentionJob struct {
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startRetention 启动后台清理
+func (cs *CustomerService) startRetention() {
+	job := &retentionJob{done: make(chan struct{})}
+	job.wg.Add(1)
+	go func() {
+		defer job.wg.Done()
+		ticker := time.NewTicker(cs.retentionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if _, err := cs.PurgeOldSessions(now.Add(-cs.retentionAge)); err != nil {
+					log.Printf("Error purging old sessions: %v", err)
+				}
+			case <-job.done:
+				return
+			}
+		}
+	}()
+	cs.retention = job
+}
+
+// stop 停止后台清理并等待协程退出
+func (job *retentionJob) stop() {
+	close(job.done)
+	job.wg.Wait()
+}
@@ -0,0 +1,65 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_PurgeOldSessions(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store))
+	defer cs.Shutdown()
+
+	old := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(old.ID, "user1", "hello", MessageTypeText)
+	cs.CloseSession(old.ID, "user1")
+	recent := createTestSession(t, cs, "user2", "staff1")
+	cs.SendMessage(recent.ID, "user2", "hi", MessageTypeText)
+	cs.CloseSession(recent.ID, "user2")
+	active := createTestSession(t, cs, "user3", "staff1")
+	cs.SendMessage(active.ID, "user3", "still here", MessageTypeText)
+	assert.Equal(t, 3, cs.Stats().TotalMessages)
+
+	// 只有关闭时间早于截止时间的会话被清理，进行中的会话即使更早创建也保留
+	cutoff := time.Now().Add(-time.Hour)
+	cs.mu.Lock()
+	old.UpdateAt = cutoff.Add(-time.Minute)
+	active.CreateAt = cutoff.Add(-time.Hour)
+	active.UpdateAt = cutoff.Add(-time.Hour)
+	cs.mu.Unlock()
+
+	n, err := cs.PurgeOldSessions(cutoff)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Nil(t, cs.GetSession(old.ID))
+	assert.NotNil(t, cs.GetSession(recent.ID))
+	assert.NotNil(t, cs.GetSession(active.ID))
+	assert.Equal(t, 2, cs.Stats().TotalMessages)
+
+	msgs, _ := store.LoadMessages(old.ID)
+	assert.Empty(t, msgs)
+	msgs, _ = store.LoadMessages(recent.ID)
+	assert.Len(t, msgs, 1)
+
+	// 再次清理没有可删除的会话
+	n, err = cs.PurgeOldSessions(cutoff)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestCustomerService_RetentionJob(t *testing.T) {
+	cs := NewCustomerService(WithRetention(time.Hour, 10*time.Millisecond))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.CloseSession(session.ID, "user1")
+	cs.mu.Lock()
+	session.UpdateAt = time.Now().Add(-2 * time.Hour)
+	cs.mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		return cs.GetSession(session.ID) == nil
+	}, time.Second, 10*time.Millisecond)
+}
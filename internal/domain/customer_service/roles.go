@@ -0,0 +1,112 @@
+package customer_service
+
+// Role 参与者在会话中的角色，决定可以执行的操作
+type Role string
+
+const (
+	RoleUser       Role = "user"       // 发起会话的用户
+	RoleAgent      Role = "agent"      // 负责会话的客服
+	RoleSupervisor Role = "supervisor" // 加入会话的主管
+	RoleBot        Role = "bot"        // 自动应答，只能发送消息
+	RoleObserver   Role = "observer"   // 旁听者，只能查看消息
+)
+
+// Permission 会话内的操作权限
+type Permission string
+
+const (
+	PermissionSend     Permission = "send"     // 发送消息
+	PermissionClose    Permission = "close"    // 关闭会话
+	PermissionTransfer Permission = "transfer" // 转接会话
+)
+
+// defaultRolePermissions 各角色的默认权限，观察者没有任何操作权限
+var defaultRolePermissions = map[Role][]Permission{
+	RoleUser:       {PermissionSend, PermissionClose},
+	RoleAgent:      {PermissionSend, PermissionClose, PermissionTransfer},
+	RoleSupervisor: {PermissionSend, PermissionClose},
+	RoleBot:        {PermissionSend},
+	RoleObserver:   nil,
+}
+
+// WithRolePermissions 覆盖角色的默认权限，不传权限表示该角色不能执行任何操作
+func WithRolePermissions(role Role, perms ...Permission) Option {
+	return func(cs *CustomerService) {
+		if cs.permissions == nil {
+			cs.permissions = make(map[Role][]Permission)
+		}
+		cs.permissions[role] = append([]Permission{}, perms...)
+	}
+}
+
+// SetParticipantRole 为会话参与者指定角色，例如让主管以观察者身份旁听，或将客服标记为自动应答
+func (cs *CustomerService) SetParticipantRole(sessionID, participantID string, role Role) error {
+	if _, known := defaultRolePermissions[role]; !known {
+		return ErrInvalidOperation
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if !session.isParticipant(participantID) {
+		return ErrNotParticipant
+	}
+	if session.roles == nil {
+		session.roles = make(map[string]Role)
+	}
+	session.roles[participantID] = role
+	return nil
+}
+
+// ParticipantRole 获取参与者在会话中的角色
+func (cs *CustomerService) ParticipantRole(sessionID, participantID string) (Role, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return "", ErrSessionNotFound
+	}
+	if !session.isParticipant(participantID) {
+		return "", ErrNotParticipant
+	}
+	return session.roleOf(participantID), nil
+}
+
+// roleOf 获取参与者的角色，未单独指定时按身份取默认角色，非参与者返回空
+func (s *Session) roleOf(id string) Role {
+	if role, exists := s.roles[id]; exists {
+		return role
+	}
+	switch {
+	case id == "":
+		return ""
+	case id == s.UserID:
+		return RoleUser
+	case id == s.StaffID:
+		return RoleAgent
+	case s.Supervisors[id]:
+		return RoleSupervisor
+	default:
+		return ""
+	}
+}
+
+// checkPermissionLocked 检查参与者的角色是否允许执行操作，调用方需持有cs.mu
+func (cs *CustomerService) checkPermissionLocked(session *Session, id string, perm Permission) error {
+	role := session.roleOf(id)
+	perms, configured := cs.permissions[role]
+	if !configured {
+		perms = defaultRolePermissions[role]
+	}
+	for _, p := range perms {
+		if p == perm {
+			return nil
+		}
+	}
+	return ErrPermissionDenied
+}
@@ -0,0 +1,107 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ObserverCannotSend(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectSupervisor("sup1", nil)
+	cs.ConnectSupervisor("sup2", nil)
+	assert.NoError(t, cs.JoinSession(session.ID, "sup1"))
+	assert.NoError(t, cs.JoinSession(session.ID, "sup2"))
+
+	// sup2以观察者身份旁听
+	assert.NoError(t, cs.SetParticipantRole(session.ID, "sup2", RoleObserver))
+	role, err := cs.ParticipantRole(session.ID, "sup2")
+	assert.NoError(t, err)
+	assert.Equal(t, RoleObserver, role)
+	role, err = cs.ParticipantRole(session.ID, "sup1")
+	assert.NoError(t, err)
+	assert.Equal(t, RoleSupervisor, role)
+
+	_, err = cs.SendMessage(session.ID, "sup2", "hello", MessageTypeText)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	_, err = cs.SendMessage(session.ID, "sup1", "hello", MessageTypeText)
+	assert.NoError(t, err)
+
+	// 观察者仍然收到会话中的消息
+	msg, err := cs.SendMessage(session.ID, "user1", "hi", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Contains(t, cs.MessageRecipients(msg), "sup2")
+	assert.ErrorIs(t, cs.CloseSession(session.ID, "sup2"), ErrPermissionDenied)
+
+	// 退出会话后角色随之清除
+	assert.NoError(t, cs.LeaveSession(session.ID, "sup2"))
+	_, err = cs.ParticipantRole(session.ID, "sup2")
+	assert.ErrorIs(t, err, ErrNotParticipant)
+}
+
+func TestCustomerService_SetParticipantRoleErrors(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	assert.ErrorIs(t, cs.SetParticipantRole(session.ID, "user1", Role("admin")), ErrInvalidOperation)
+	assert.ErrorIs(t, cs.SetParticipantRole(session.ID, "stranger", RoleObserver), ErrNotParticipant)
+	assert.ErrorIs(t, cs.SetParticipantRole("missing", "user1", RoleObserver), ErrSessionNotFound)
+}
+
+func TestCustomerService_RolePermissions(t *testing.T) {
+	cs := NewCustomerService(WithRolePermissions(RoleAgent, PermissionSend))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	if _, err := cs.ConnectStaff("staff2", "TestStaff", "group1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 客服的权限被收窄为只能发送消息
+	_, err := cs.SendMessage(session.ID, "staff1", "hello", MessageTypeText)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, cs.TransferSession(session.ID, "staff2"), ErrPermissionDenied)
+	assert.ErrorIs(t, cs.CloseSession(session.ID, "staff1"), ErrPermissionDenied)
+	assert.Equal(t, "staff1", session.StaffID)
+
+	// 用户的默认权限不受影响
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+	assert.Equal(t, SessionStatusClosed, session.Status)
+}
+
+func TestCustomerService_SupervisorCloseSession(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectSupervisor("sup1", nil)
+	assert.NoError(t, cs.JoinSession(session.ID, "sup1"))
+
+	assert.NoError(t, cs.CloseSession(session.ID, "sup1"))
+	assert.Equal(t, EventSessionClosed, <-types)
+	assert.Equal(t, ReasonClosedByAdmin, (<-events).Reason)
+}
+
+func TestCustomerService_TransferClearsStaffRole(t *testing.T) {
+	cs := NewCustomerService(WithRolePermissions(RoleBot, PermissionSend, PermissionTransfer))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	if _, err := cs.ConnectStaff("staff2", "TestStaff", "group1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 自动应答转给人工客服后再转回，原先指定的角色不再保留
+	assert.NoError(t, cs.SetParticipantRole(session.ID, "staff1", RoleBot))
+	assert.NoError(t, cs.TransferSession(session.ID, "staff2"))
+	assert.NoError(t, cs.TransferSession(session.ID, "staff1"))
+	role, err := cs.ParticipantRole(session.ID, "staff1")
+	assert.NoError(t, err)
+	assert.Equal(t, RoleAgent, role)
+}
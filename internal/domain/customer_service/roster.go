@@ -0,0 +1,109 @@
+package customer_service
+
+import "sort"
+
+// 会话评分范围
+const (
+	minRating = 1
+	maxRating = 5
+)
+
+// StaffRosterEntry 客服看板中的一行，是加锁期间复制的快照，可以安全地对外发布
+type StaffRosterEntry struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	GroupID        string     `json:"group_id"`
+	Status         UserStatus `json:"status"`
+	ActiveSessions int        `json:"active_sessions"`
+	MaxSessions    int        `json:"max_sessions"` // 0表示不限
+	AverageRating  float64    `json:"average_rating"`
+	RatedSessions  int        `json:"rated_sessions"` // 参与平均分计算的已评价会话数
+}
+
+// RateSession 用户为自己的会话评分，score取值1到5，重复评分时覆盖之前的评分
+func (cs *CustomerService) RateSession(sessionID, userID string, score int) error {
+	if score < minRating || score > maxRating {
+		return ErrInvalidOperation
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if session.UserID != userID {
+		return ErrNotParticipant
+	}
+	session.Rating = score
+	return nil
+}
+
+// StaffRoster 返回客服组的看板数据，按当前会话数从多到少排序，组不存在时返回空
+func (cs *CustomerService) StaffRoster(groupID string) []StaffRosterEntry {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return nil
+	}
+	staffs := make([]*CSStaff, 0, len(group.Members))
+	for _, staff := range group.Members {
+		staffs = append(staffs, staff)
+	}
+	return cs.rosterLocked(staffs)
+}
+
+// StaffRosterAll 返回所有在线客服的看板数据，排序同StaffRoster
+func (cs *CustomerService) StaffRosterAll() []StaffRosterEntry {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	staffs := make([]*CSStaff, 0, len(cs.staffs))
+	for _, staff := range cs.staffs {
+		staffs = append(staffs, staff)
+	}
+	return cs.rosterLocked(staffs)
+}
+
+// rosterLocked 生成客服看板快照，评分按会话当前所属客服汇总，调用方需持有cs.mu
+func (cs *CustomerService) rosterLocked(staffs []*CSStaff) []StaffRosterEntry {
+	type ratingSum struct{ total, count int }
+	ratings := make(map[string]ratingSum)
+	for _, session := range cs.sessions {
+		if session.Rating == 0 || session.StaffID == "" {
+			continue
+		}
+		sum := ratings[session.StaffID]
+		sum.total += session.Rating
+		sum.count++
+		ratings[session.StaffID] = sum
+	}
+
+	entries := make([]StaffRosterEntry, 0, len(staffs))
+	for _, staff := range staffs {
+		entry := StaffRosterEntry{
+			ID:             staff.ID,
+			Name:           staff.Name,
+			GroupID:        staff.GroupID,
+			Status:         staff.Status,
+			ActiveSessions: len(staff.Sessions),
+			MaxSessions:    staff.MaxSessions,
+		}
+		if sum := ratings[staff.ID]; sum.count > 0 {
+			entry.AverageRating = float64(sum.total) / float64(sum.count)
+			entry.RatedSessions = sum.count
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].ActiveSessions != entries[j].ActiveSessions {
+			return entries[i].ActiveSessions > entries[j].ActiveSessions
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
@@ -0,0 +1,58 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_StaffRoster(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	s1 := createTestSession(t, cs, "user1", "staff1")
+	createTestSession(t, cs, "user2", "staff1")
+	s3 := createTestSession(t, cs, "user3", "staff2")
+	cs.ConnectStaff("staff3", "客服3", "group1", nil)
+	assert.NoError(t, cs.SetStaffCapacity("staff1", 3))
+	assert.NoError(t, cs.SetStaffStatus("staff3", UserStatusAway))
+
+	assert.NoError(t, cs.RateSession(s1.ID, "user1", 5))
+	assert.NoError(t, cs.RateSession(s3.ID, "user3", 2))
+	assert.NoError(t, cs.RateSession(s3.ID, "user3", 4))
+	assert.Equal(t, ErrInvalidOperation, cs.RateSession(s1.ID, "user1", 6))
+	assert.Equal(t, ErrNotParticipant, cs.RateSession(s1.ID, "user2", 3))
+
+	roster := cs.StaffRoster("group1")
+	assert.Equal(t, []StaffRosterEntry{
+		{ID: "staff1", Name: "TestStaff", GroupID: "group1", Status: UserStatusOnline, ActiveSessions: 2, MaxSessions: 3, AverageRating: 5, RatedSessions: 1},
+		{ID: "staff2", Name: "TestStaff", GroupID: "group1", Status: UserStatusOnline, ActiveSessions: 1, AverageRating: 4, RatedSessions: 1},
+		{ID: "staff3", Name: "客服3", GroupID: "group1", Status: UserStatusAway},
+	}, roster)
+
+	// 返回的是快照，结束会话后再次获取才会反映变化
+	assert.NoError(t, cs.CloseSession(s1.ID, "staff1"))
+	assert.Equal(t, 2, roster[0].ActiveSessions)
+	roster = cs.StaffRoster("group1")
+	assert.Equal(t, "staff1", roster[0].ID)
+	assert.Equal(t, 1, roster[0].ActiveSessions)
+
+	assert.Nil(t, cs.StaffRoster("missing"))
+}
+
+func TestCustomerService_StaffRosterAll(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	createTestSession(t, cs, "user1", "staff1")
+	cs.CreateGroup("group2", "Group2")
+	cs.ConnectStaff("staff2", "客服2", "group2", nil)
+
+	roster := cs.StaffRosterAll()
+	if assert.Len(t, roster, 2) {
+		assert.Equal(t, "staff1", roster[0].ID)
+		assert.Equal(t, 1, roster[0].ActiveSessions)
+		assert.Equal(t, "staff2", roster[1].ID)
+		assert.Equal(t, "group2", roster[1].GroupID)
+	}
+}
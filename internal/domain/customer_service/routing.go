@@ -0,0 +1,58 @@
+package customer_service
+
+// RoutingStrategy 排队用户分配客服的策略
+type RoutingStrategy int
+
+const (
+	RoutingLeastLoaded RoutingStrategy = iota // 选择会话数最少的客服
+	RoutingWeighted                           // 按客服权重随机选择，权重越高分到的会话越多
+)
+
+// WithRoutingStrategy 设置分配策略，默认RoutingLeastLoaded。任何策略都只在未满额的在线客服中选择
+func WithRoutingStrategy(strategy RoutingStrategy) Option {
+	return func(cs *CustomerService) {
+		cs.routing = strategy
+	}
+}
+
+// SetStaffWeight 设置客服在加权分配中的权重，可以由评分或响应时间等绩效指标换算得到，小于等于0时按1计算
+func (cs *CustomerService) SetStaffWeight(staffID string, w float64) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return ErrStaffNotFound
+	}
+	staff.Weight = w
+	cs.emitStaffUpdatedLocked(staff)
+	return nil
+}
+
+// staffWeight 返回客服的有效权重
+func staffWeight(staff *CSStaff) float64 {
+	if staff.Weight <= 0 {
+		return 1
+	}
+	return staff.Weight
+}
+
+// pickWeightedLocked 按权重比例从候选客服中随机选择一位，没有候选时返回nil，调用方需持有cs.mu
+func (cs *CustomerService) pickWeightedLocked(candidates []*CSStaff) *CSStaff {
+	total := 0.0
+	for _, staff := range candidates {
+		total += staffWeight(staff)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	r := cs.rand.Float64() * total
+	for _, staff := range candidates {
+		r -= staffWeight(staff)
+		if r < 0 {
+			return staff
+		}
+	}
+	return candidates[len(candidates)-1]
+}
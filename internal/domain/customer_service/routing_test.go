@@ -0,0 +1,68 @@
+package customer_service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_WeightedRouting(t *testing.T) {
+	cs := NewCustomerService(WithRoutingStrategy(RoutingWeighted))
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	for _, id := range []string{"staff1", "staff2", "staff3"} {
+		cs.ConnectStaff(id, id, "group1", nil)
+		cs.SetAutoAccept(id, true)
+	}
+	assert.NoError(t, cs.SetStaffWeight("staff1", 1))
+	assert.NoError(t, cs.SetStaffWeight("staff2", 3))
+	assert.NoError(t, cs.SetStaffWeight("staff3", 6))
+	assert.Equal(t, ErrStaffNotFound, cs.SetStaffWeight("nonexistent", 1))
+
+	// 每次分配后关闭会话，使各客服的负载保持一致，只比较权重的影响
+	const rounds = 3000
+	counts := map[string]int{}
+	for i := 0; i < rounds; i++ {
+		userID := fmt.Sprintf("user%d", i)
+		user, _ := cs.ConnectUser(userID, userID, nil)
+		assert.NoError(t, cs.EnqueueUser(userID, "group1"))
+		session := cs.GetSession(user.SessionID)
+		assert.NotNil(t, session)
+		counts[session.StaffID]++
+		assert.NoError(t, cs.CloseSession(session.ID, userID))
+		cs.DisconnectUser(userID, DisconnectClientClose)
+	}
+
+	// 权重1:3:6，期望占比10%、30%、60%，允许5个百分点的误差
+	assert.InDelta(t, 0.1, float64(counts["staff1"])/rounds, 0.05)
+	assert.InDelta(t, 0.3, float64(counts["staff2"])/rounds, 0.05)
+	assert.InDelta(t, 0.6, float64(counts["staff3"])/rounds, 0.05)
+}
+
+func TestCustomerService_WeightedRoutingRespectsCapacity(t *testing.T) {
+	cs := NewCustomerService(WithRoutingStrategy(RoutingWeighted))
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	heavy, _ := cs.ConnectStaff("staff1", "Heavy", "group1", nil)
+	cs.ConnectStaff("staff2", "Light", "group1", nil)
+	cs.SetAutoAccept("staff1", true)
+	cs.SetAutoAccept("staff2", true)
+	cs.SetStaffWeight("staff1", 100)
+	heavy.MaxSessions = 1
+	assert.NoError(t, cs.SetStaffStatus("staff2", UserStatusAway))
+
+	// 高权重客服满额、另一位离开时，用户留在队列中
+	user1, _ := cs.ConnectUser("user1", "TestUser1", nil)
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
+	assert.Equal(t, "staff1", cs.GetSession(user1.SessionID).StaffID)
+	cs.ConnectUser("user2", "TestUser2", nil)
+	assert.NoError(t, cs.EnqueueUser("user2", "group1"))
+	assert.Equal(t, []string{"user2"}, cs.QueuedUsers("group1"))
+
+	// 低权重客服恢复在线后接入
+	assert.NoError(t, cs.SetStaffStatus("staff2", UserStatusOnline))
+	assert.Equal(t, "staff2", cs.GetSession(cs.GetUser("user2").SessionID).StaffID)
+}
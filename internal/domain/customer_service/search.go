@@ -0,0 +1,116 @@
+package customer_service
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Range 消息内容中的一段匹配，Start和End为字符（rune）下标，End不包含在内
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SearchResult 搜索命中的消息及内容中全部匹配的位置，客户端可以直接据此高亮，无需重新搜索
+type SearchResult struct {
+	Message *Message `json:"message"`
+	Matches []Range  `json:"matches"`
+}
+
+// SearchMessages 在单个会话中搜索包含query的消息（忽略大小写），按时间从新到旧返回，
+// limit大于0时最多返回limit条。已撤回的消息不参与搜索
+func (cs *CustomerService) SearchMessages(sessionID, query string, limit int) ([]SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrInvalidOperation
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	return limitResults(matchMessages(session.Messages, foldCase(query), nil), limit), nil
+}
+
+// SearchStaffMessages 在客服当前负责的全部会话中搜索消息，结果中的SessionID标明所属会话，
+// 排序与数量限制同SearchMessages
+func (cs *CustomerService) SearchStaffMessages(staffID, query string, limit int) ([]SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrInvalidOperation
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return nil, ErrStaffNotFound
+	}
+
+	needle := foldCase(query)
+	var results []SearchResult
+	for _, session := range staff.Sessions {
+		results = matchMessages(session.Messages, needle, results)
+	}
+	return limitResults(results, limit), nil
+}
+
+// matchMessages 将内容包含needle的消息及匹配位置追加到results，needle需经foldCase处理
+func matchMessages(messages []*Message, needle string, results []SearchResult) []SearchResult {
+	for _, msg := range messages {
+		if msg.Recalled {
+			continue
+		}
+		if matches := matchRanges(msg.Content, needle); len(matches) > 0 {
+			results = append(results, SearchResult{Message: msg, Matches: matches})
+		}
+	}
+	return results
+}
+
+// foldCase 逐字符转为小写，与原文字符一一对应，转换后的字符下标可以直接用于原文
+func foldCase(s string) string {
+	return strings.Map(unicode.ToLower, s)
+}
+
+// matchRanges 查找content中needle的全部不重叠出现位置（忽略大小写），needle需经foldCase处理。
+// 只遍历一遍内容，字符下标随查找位置增量累计，长内容也不会重复计数
+func matchRanges(content, needle string) []Range {
+	haystack := foldCase(content)
+	width := utf8.RuneCountInString(needle)
+
+	var matches []Range
+	pos, runes := 0, 0
+	for {
+		i := strings.Index(haystack[pos:], needle)
+		if i < 0 {
+			return matches
+		}
+		start := runes + utf8.RuneCountInString(haystack[pos:pos+i])
+		matches = append(matches, Range{Start: start, End: start + width})
+		pos += i + len(needle)
+		runes = start + width
+	}
+}
+
+// limitResults 按时间从新到旧排序并截取前limit条，时间相同时按会话ID和序号排序保证结果稳定
+func limitResults(results []SearchResult, limit int) []SearchResult {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i].Message, results[j].Message
+		if !a.CreateAt.Equal(b.CreateAt) {
+			return a.CreateAt.After(b.CreateAt)
+		}
+		if a.SessionID != b.SessionID {
+			return a.SessionID < b.SessionID
+		}
+		return a.Seq > b.Seq
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
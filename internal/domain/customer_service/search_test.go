@@ -0,0 +1,92 @@
+package customer_service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_SearchStaffMessages(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+
+	session1 := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectUser("user2", "TestUser2", nil)
+	session2, err := cs.CreateSession("user2", "staff1")
+	assert.NoError(t, err)
+
+	// 匹配的消息分布在两个会话中
+	cs.SendMessage(session1.ID, "user1", "My refund is late", MessageTypeText)
+	clock.Advance(time.Second)
+	cs.SendMessage(session2.ID, "user2", "Hello", MessageTypeText)
+	clock.Advance(time.Second)
+	cs.SendMessage(session2.ID, "staff1", "The REFUND was issued", MessageTypeText)
+	clock.Advance(time.Second)
+	latest, _ := cs.SendMessage(session1.ID, "staff1", "Checking your refund now", MessageTypeText)
+
+	results, err := cs.SearchStaffMessages("staff1", "refund", 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Same(t, latest, results[0].Message)
+	assert.Equal(t, session2.ID, results[1].Message.SessionID)
+	assert.Equal(t, session1.ID, results[2].Message.SessionID)
+
+	// 按limit截取最新的结果
+	results, err = cs.SearchStaffMessages("staff1", "refund", 2)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Same(t, latest, results[0].Message)
+
+	// 已撤回的消息不再出现在结果中
+	cs.RecallMessage(session1.ID, latest.ID, "staff1")
+	results, _ = cs.SearchStaffMessages("staff1", "refund", 0)
+	assert.Len(t, results, 2)
+
+	results, err = cs.SearchMessages(session2.ID, "refund", 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	_, err = cs.SearchStaffMessages("nonexistent", "refund", 0)
+	assert.Equal(t, ErrStaffNotFound, err)
+	_, err = cs.SearchStaffMessages("staff1", " ", 0)
+	assert.Equal(t, ErrInvalidOperation, err)
+}
+
+func TestCustomerService_SearchMatchRanges(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	msg, _ := cs.SendMessage(session.ID, "user1", "Refund? 我要refund，REFUND!", MessageTypeText)
+	cs.SendMessage(session.ID, "staff1", "Hello", MessageTypeText)
+
+	results, err := cs.SearchMessages(session.ID, "rEfUnd", 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Same(t, msg, results[0].Message)
+
+	// 下标按字符计算，中文字符各占一位
+	assert.Equal(t, []Range{{0, 6}, {10, 16}, {17, 23}}, results[0].Matches)
+	content := []rune(msg.Content)
+	for _, r := range results[0].Matches {
+		assert.Equal(t, "refund", strings.ToLower(string(content[r.Start:r.End])))
+	}
+
+	// 匹配不重叠
+	msg, _ = cs.SendMessage(session.ID, "user1", "aaaaa", MessageTypeText)
+	results, err = cs.SearchMessages(session.ID, "aa", 0)
+	assert.NoError(t, err)
+	assert.Same(t, msg, results[0].Message)
+	assert.Equal(t, []Range{{0, 2}, {2, 4}}, results[0].Matches)
+}
+
+func TestMatchRanges_LongContent(t *testing.T) {
+	content := strings.Repeat("退款x", 10000)
+	matches := matchRanges(content, foldCase("X"))
+	assert.Len(t, matches, 10000)
+	assert.Equal(t, Range{Start: 2, End: 3}, matches[0])
+	assert.Equal(t, Range{Start: 29999, End: 30000}, matches[9999])
+}
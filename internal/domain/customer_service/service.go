@@ -1,65 +1,237 @@
 package customer_service
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-var (
-	ErrUserNotFound     = errors.New("user not found")
-	ErrStaffNotFound    = errors.New("staff not found")
-	ErrSessionNotFound  = errors.New("session not found")
-	ErrGroupNotFound    = errors.New("group not found")
-	ErrInvalidOperation = errors.New("invalid operation")
-)
-
 // CustomerService 客服系统服务
 type CustomerService struct {
 	users    map[string]*User    // 在线用户列表
 	staffs   map[string]*CSStaff // 在线客服列表
 	groups   map[string]*CSGroup // 客服组列表
 	sessions map[string]*Session // 活动会话列表
+	presence *presenceHub        // 上下线事件订阅
 	mu       sync.RWMutex
+
+	supervisors map[string]*Supervisor // 在线主管列表
+
+	waiting    map[string]*queueEntry // 排队中的用户
+	queues     map[string][]string    // 各客服组的等待队列
+	offers     map[string]*Offer      // 待客服接受的会话邀请
+	userOffers map[string]string      // 用户ID到邀请ID的映射
+	tickets    []*Ticket              // 用户留言
+	audit      []AuditEntry           // 管理员操作审计日志
+	events     *eventDispatcher       // 事件分发，未设置回调时为空
+	seq        int64                  // 内部ID序号
+
+	totalMessages atomic.Int64 // 各会话内存中保留的消息总数，统计时无需遍历会话
+
+	store          SessionStore  // 消息持久化存储，可为空
+	writer         *storeWriter  // 批量写入器，未开启批量时为空
+	batchSize      int           // 批量写入条数
+	flushInterval  time.Duration // 批量写入时间间隔
+	offerTimeout   time.Duration // 会话邀请时限
+	wrapUpDuration time.Duration // 会话结束后客服的整理时长
+	dedupeSize     int           // 每个会话记录的客户端消息ID数量
+	maxSessions    int           // 系统未关闭会话数上限，0表示不限
+
+	positions           map[string]*positionState // 各排队用户的位置通知状态
+	positionInterval    time.Duration             // 同一用户排队位置通知的最小间隔
+	orphanPolicy        OrphanPolicy              // 孤儿会话的处理方式
+	idGen               IDGenerator               // 会话ID生成器
+	maxInMemoryMessages int                       // 每个会话内存中保留的消息条数上限，0表示不限
+	maxContentLength    int                       // 文本消息内容的最大字符数，0表示不限
+	preSessionBuffer    int                       // 用户在会话建立前可缓存的消息条数
+	autoTransferOnAway  time.Duration             // 客服离开后自动转接会话的宽限期，0表示不转接
+	validation          ValidationRules           // 连接时ID与名称的校验规则
+	reconnectGrace      time.Duration             // 用户断线后的重连宽限期，0表示立即移除
+	queueAging          time.Duration             // 排队老化步长，0表示不老化
+	scanner             AttachmentScanner         // 附件检查
+	thumbnails          ThumbnailGenerator        // 图片附件的缩略图生成，可为空
+	maxConnsPerIdentity int                       // 同一身份同时保持的连接数上限，0表示不限
+	connCounts          map[string]int            // 各身份当前的连接数
+	autoCreateGroups    bool                      // 客服连接到不存在的组时自动创建该组
+	defaultGroup        string                    // 用户请求的组不存在时改为排入的默认组，为空表示不回退
+	requireReady        bool                      // 为true时用户需先调用MarkUserReady才能排队
+	encryptor           Encryptor                 // 消息内容写入存储前的加密器
+	routing             RoutingStrategy           // 排队用户分配客服的策略
+	rand                *rand.Rand                // 加权分配使用的随机源，由cs.mu保护
+	clientMaxAge        time.Duration             // 客户端发送时间最多早于服务端时间多久
+	clientMaxSkew       time.Duration             // 客户端发送时间最多晚于服务端时间多久
+	awayAfter           time.Duration             // 连接超过该时长没有入站消息或pong时视为离开
+	reopenWindow        time.Duration             // 会话关闭后允许重新打开的时长
+	surge               *surgeDetector            // 排队请求激增检测，未开启时为空
+	maxQueueWait        time.Duration             // 可接受的预计排队时长，超出时拒绝排队，0表示不限
+	filters             []MessageFilter           // 消息写入会话前依次调用的过滤器
+
+	reaper             *reaper       // 后台巡检，未开启超时策略时为空
+	reapInterval       time.Duration // 后台巡检间隔
+	userSilenceTimeout time.Duration // 用户未发言超时
+	userSilenceAction  SilenceAction // 用户未发言超时后的处理方式
+
+	retention         *retentionJob // 过期会话后台清理，未开启时为空
+	retentionAge      time.Duration // 会话关闭后保留的时长
+	retentionInterval time.Duration // 后台清理间隔
+
+	offline      OfflineStore // 离线消息队列，未开启时为空
+	offlineLimit int          // 每个接收方最多保留的离线消息条数
+
+	stream       chan Event // 生命周期事件流，首次调用Events时创建
+	streamBuffer int        // 事件流缓冲大小
+	streamClosed bool       // Shutdown后事件流已关闭
+
+	resumeTokens   map[string]*resumeToken // 未使用的重连令牌，按令牌索引，首次签发时创建
+	resumeTokenTTL time.Duration           // 重连令牌有效期
+
+	shutdownTimeout time.Duration // Shutdown等待缓冲消息写入存储的时限
+	unreadSystem    bool          // 系统消息是否计入未读数
+	clock           Clock         // 时间来源
+
+	permissions map[Role][]Permission // 自定义的角色权限，未配置的角色使用默认权限
 }
 
 // NewCustomerService 创建新的客服系统服务实例
-func NewCustomerService() *CustomerService {
-	return &CustomerService{
+func NewCustomerService(opts ...Option) *CustomerService {
+	cs := &CustomerService{
 		users:    make(map[string]*User),
 		staffs:   make(map[string]*CSStaff),
 		groups:   make(map[string]*CSGroup),
 		sessions: make(map[string]*Session),
+		presence: newPresenceHub(),
+
+		supervisors:  make(map[string]*Supervisor),
+		waiting:      make(map[string]*queueEntry),
+		queues:       make(map[string][]string),
+		offers:       make(map[string]*Offer),
+		userOffers:   make(map[string]string),
+		offerTimeout: defaultOfferTimeout,
+		dedupeSize:   defaultDedupeSize,
+
+		positions:        make(map[string]*positionState),
+		positionInterval: defaultPositionInterval,
+		idGen:            RandomIDGenerator{},
+		validation:       DefaultValidationRules,
+		scanner:          passThroughScanner{},
+		encryptor:        identityEncryptor{},
+		rand:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		connCounts:       make(map[string]int),
+		clientMaxAge:     defaultClientMaxAge,
+		clientMaxSkew:    defaultClientMaxSkew,
+		reapInterval:     defaultReapInterval,
+		awayAfter:        defaultAwayAfter,
+		reopenWindow:     defaultReopenWindow,
+		resumeTokenTTL:   defaultResumeTokenTTL,
+		shutdownTimeout:  defaultShutdownTimeout,
+		clock:            realClock{},
+	}
+	for _, opt := range opts {
+		opt(cs)
+	}
+	if cs.offlineLimit > 0 {
+		cs.offline = newOfflineStore(cs.store)
+	}
+	if _, identity := cs.encryptor.(identityEncryptor); cs.store != nil && cs.encryptor != nil && !identity {
+		cs.store = &encryptedStore{SessionStore: cs.store, enc: cs.encryptor}
+		if cs.offline != nil {
+			cs.offline = &encryptedOfflineStore{OfflineStore: cs.offline, enc: cs.encryptor}
+		}
+	}
+	if cs.store != nil && cs.batchSize > 1 {
+		cs.writer = newStoreWriter(cs.store, cs.batchSize, cs.flushInterval)
+	}
+	if cs.userSilenceTimeout > 0 {
+		cs.startReaper()
 	}
+	if cs.retentionAge > 0 && cs.retentionInterval > 0 {
+		cs.startRetention()
+	}
+	return cs
+}
+
+// ConnectUser 处理用户WebSocket连接，ID或名称不符合校验规则时返回ErrInvalidIdentity
+func (cs *CustomerService) ConnectUser(userID, name string, conn *websocket.Conn) (*User, error) {
+	return cs.ConnectUserWithChannel(userID, name, ChannelWeb, conn)
 }
 
-// ConnectUser 处理用户WebSocket连接
-func (cs *CustomerService) ConnectUser(userID, name string, conn *websocket.Conn) *User {
+// ConnectUserWithChannel 处理来自指定渠道的用户WebSocket连接，渠道为空时视为web。
+// 不会恢复宽限期内断线的用户，恢复需凭重连令牌调用ResumeUser；用户已在线时返回ErrTooManyConnections，
+// 新设备需凭令牌调用TakeoverUserConnection接管
+func (cs *CustomerService) ConnectUserWithChannel(userID, name, channel string, conn *websocket.Conn) (*User, error) {
+	if err := cs.ValidateIdentity(userID, name); err != nil {
+		return nil, err
+	}
+
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if channel == "" {
+		channel = ChannelWeb
+	}
+
+	// 宽限期内只有凭重连令牌才能继续原会话（见ResumeUser），未出示令牌的连接按新用户处理，
+	// 原用户的宽限期立即结束并关闭其会话。用户仍在线时只能凭令牌接管，否则按重复连接拒绝
+	if user, exists := cs.users[userID]; exists {
+		if user.graceTimer == nil {
+			return nil, ErrTooManyConnections
+		}
+		user.graceTimer.Stop()
+		user.graceTimer = nil
+		cs.expireUserLocked(user)
+	}
+
 	user := &User{
 		ID:       userID,
 		Name:     name,
 		Status:   UserStatusOnline,
 		Conn:     conn,
-		CreateAt: time.Now(),
+		CreateAt: cs.now(),
+		Channel:  channel,
 	}
+	user.lastSeenAt = user.CreateAt
 	cs.users[userID] = user
-	return user
+	cs.publishPresence(userID, PresenceRoleUser, true, "")
+	return user, nil
 }
 
-// ConnectStaff 处理客服WebSocket连接
+// StaffSettings 客服上线时的接待设置
+type StaffSettings struct {
+	MaxSessions int  // 同时处理的会话上限，0表示不限
+	AutoAccept  bool // 为true时排队用户直接分配，否则发起邀请
+}
+
+// ConnectStaff 处理客服WebSocket连接，ID或名称不符合校验规则时返回ErrInvalidIdentity，
+// 组不存在时返回ErrGroupNotFound，开启自动建组时创建该组。客服以默认设置上线，即不限会话数、手动接受邀请
 func (cs *CustomerService) ConnectStaff(staffID, name, groupID string, conn *websocket.Conn) (*CSStaff, error) {
+	return cs.ConnectStaffWithSettings(staffID, name, groupID, conn, StaffSettings{})
+}
+
+// ConnectStaffWithSettings 按指定的接待设置处理客服连接。组内已有排队用户时立即在上限内分配：
+// 自动接入的客服直接建立会话，其余发起邀请，无需等待客服主动领取或下一次分配
+func (cs *CustomerService) ConnectStaffWithSettings(staffID, name, groupID string, conn *websocket.Conn, settings StaffSettings) (*CSStaff, error) {
+	if err := cs.ValidateIdentity(staffID, name); err != nil {
+		return nil, err
+	}
+
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
 	group, exists := cs.groups[groupID]
-	if !exists {
+	if !exists && !cs.autoCreateGroups {
 		return nil, ErrGroupNotFound
 	}
+	if !exists {
+		group = cs.createGroupLocked(groupID, groupID)
+	}
 
 	staff := &CSStaff{
 		ID:       staffID,
@@ -68,10 +240,21 @@ func (cs *CustomerService) ConnectStaff(staffID, name, groupID string, conn *web
 		Status:   UserStatusOnline,
 		Conn:     conn,
 		Sessions: make(map[string]*Session),
+
+		MaxSessions: settings.MaxSessions,
+		AutoAccept:  settings.AutoAccept,
+		lastSeenAt:  cs.now(),
+	}
+	if staff.MaxSessions < 0 {
+		staff.MaxSessions = 0
 	}
 
 	cs.staffs[staffID] = staff
 	group.Members[staffID] = staff
+	cs.publishLocked(StaffStatusChanged{StaffID: staffID, Status: UserStatusOnline, PrevStatus: UserStatusOffline, At: staff.lastSeenAt})
+	cs.publishPresence(staffID, PresenceRoleStaff, true, "")
+	// 新上线的客服立即接待组内排队的用户
+	cs.dispatchGroupLocked(groupID)
 	return staff, nil
 }
 
@@ -79,7 +262,11 @@ func (cs *CustomerService) ConnectStaff(staffID, name, groupID string, conn *web
 func (cs *CustomerService) CreateGroup(groupID, name string) *CSGroup {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
+	return cs.createGroupLocked(groupID, name)
+}
 
+// createGroupLocked 创建客服组，调用方需持有cs.mu
+func (cs *CustomerService) createGroupLocked(groupID, name string) *CSGroup {
 	group := &CSGroup{
 		ID:      groupID,
 		Name:    name,
@@ -89,7 +276,8 @@ func (cs *CustomerService) CreateGroup(groupID, name string) *CSGroup {
 	return group
 }
 
-// CreateSession 创建会话
+// CreateSession 创建会话，客服已达会话上限时返回ErrStaffAtCapacity，客服所在组已达上限或正在排空时返回ErrGroupAtCapacity或ErrGroupDraining。
+// 上限检查与分配在同一次加锁内完成，并发创建不会超出上限
 func (cs *CustomerService) CreateSession(userID, staffID string) (*Session, error) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -103,26 +291,163 @@ func (cs *CustomerService) CreateSession(userID, staffID string) (*Session, erro
 	if !exists {
 		return nil, ErrStaffNotFound
 	}
+	if cs.atCapacityLocked(staff) {
+		return nil, ErrStaffAtCapacity
+	}
+	if err := cs.checkGroupLocked(cs.groups[staff.GroupID]); err != nil {
+		return nil, err
+	}
+	if cs.atSystemCapacityLocked() {
+		return nil, ErrSystemAtCapacity
+	}
 
+	return cs.createSessionLocked(user, staff), nil
+}
+
+// createSessionLocked 创建用户与客服之间的会话，调用方需持有cs.mu
+func (cs *CustomerService) createSessionLocked(user *User, staff *CSStaff) *Session {
 	session := &Session{
-		ID:       userID + "_" + staffID + "_" + time.Now().Format("20060102150405"),
-		UserID:   userID,
-		StaffID:  staffID,
-		Status:   SessionStatusActive,
-		CreateAt: time.Now(),
-		UpdateAt: time.Now(),
+		ID:       cs.idGen.NewSessionID(),
+		UserID:   user.ID,
+		CreateAt: cs.now(),
+		Channel:  user.Channel,
 		Messages: make([]*Message, 0),
 	}
-
+	for key, value := range user.preChat {
+		if session.Variables == nil {
+			session.Variables = make(map[string]string, len(user.preChat))
+		}
+		session.Variables[key] = value
+	}
 	cs.sessions[session.ID] = session
+	// 新会话处于等待状态，激活不会失败
+	cs.attachSessionLocked(session, user, staff, "")
+	cs.publishLocked(SessionCreated{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		StaffID:   session.StaffID,
+		GroupID:   session.GroupID,
+		At:        session.CreateAt,
+	})
+	cs.startSLALocked(session)
+	return session
+}
+
+// attachSessionLocked 将等待中的会话分配给客服并激活，reason不为空时通知会话双方状态变更，调用方需持有cs.mu
+func (cs *CustomerService) attachSessionLocked(session *Session, user *User, staff *CSStaff, reason string) error {
+	prev := session.Status
+	if err := session.transitionTo(SessionStatusActive); err != nil {
+		return err
+	}
+	session.StaffID = staff.ID
+	session.GroupID = staff.GroupID
+	session.UpdateAt = cs.now()
+	session.userActiveAt = session.UpdateAt
+
 	staff.Sessions[session.ID] = session
 	user.SessionID = session.ID
 	user.Status = UserStatusInSession
+	if reason != "" {
+		cs.emitStatusLocked(session, prev, reason)
+	}
 
-	return session, nil
+	// 用户不再需要排队等待，立即告知最终位置
+	if cs.dequeueLocked(user.ID) {
+		cs.emit(EventQueuePosition, QueuePosition{UserID: user.ID, GroupID: session.GroupID})
+	}
+	if offerID, offered := cs.userOffers[user.ID]; offered {
+		cs.removeOfferLocked(cs.offers[offerID])
+	}
+	cs.replayPendingLocked(session, user)
+	return nil
 }
 
-// TransferSession 转移会话给其他客服
+// CloseSession 由会话参与者关闭会话，参与者的角色需要有关闭权限
+func (cs *CustomerService) CloseSession(sessionID, byID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if session.Status == SessionStatusClosed {
+		return ErrSessionClosed
+	}
+
+	if !session.isParticipant(byID) {
+		return ErrInvalidOperation
+	}
+	if err := cs.checkPermissionLocked(session, byID, PermissionClose); err != nil {
+		return err
+	}
+
+	var reason string
+	switch byID {
+	case session.UserID:
+		reason = ReasonClosedByUser
+	case session.StaffID:
+		reason = ReasonClosedByStaff
+	default:
+		reason = ReasonClosedByAdmin
+	}
+
+	event := SessionEvent{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		StaffID:   session.StaffID,
+		Reason:    reason,
+	}
+	if err := cs.closeSessionLocked(session, reason); err != nil {
+		return err
+	}
+	cs.emit(EventSessionClosed, event)
+	return nil
+}
+
+// closeSessionLocked 关闭会话并解除与用户和客服的关联，客服随后进入整理状态。
+// 用户关闭、客服关闭或断开、管理员关闭和超时巡检等结束会话的路径都经过这里，调用方需持有cs.mu
+func (cs *CustomerService) closeSessionLocked(session *Session, reason string) error {
+	if err := cs.transitionLocked(session, SessionStatusClosed, reason); err != nil {
+		return err
+	}
+	session.UpdateAt = cs.now()
+	cs.recordHandleTimeLocked(session)
+
+	staff := cs.releaseSessionLocked(session)
+	if staff != nil {
+		cs.startWrapUpLocked(staff)
+	}
+	// 设置了系统上限时释放的名额可供任意组使用，否则只有未进入整理状态的客服释放了名额
+	if cs.maxSessions > 0 {
+		cs.dispatchAllLocked()
+	} else if staff != nil && staff.Status == UserStatusOnline {
+		cs.dispatchGroupLocked(staff.GroupID)
+	}
+	return nil
+}
+
+// releaseSessionLocked 结束会话后的清理：停止服务等级计时，从客服的会话列表中移除，
+// 用户不再指向该会话并退出排队，在线的用户恢复为空闲，已断线的用户保持离线。
+// 返回会话所属的客服，客服已不在系统中时为nil，调用方需持有cs.mu
+func (cs *CustomerService) releaseSessionLocked(session *Session) *CSStaff {
+	stopSLALocked(session)
+
+	staff, exists := cs.staffs[session.StaffID]
+	if exists {
+		delete(staff.Sessions, session.ID)
+	}
+	if user, exists := cs.users[session.UserID]; exists && user.SessionID == session.ID {
+		user.SessionID = ""
+		if user.Status != UserStatusOffline {
+			user.Status = UserStatusOnline
+		}
+		cs.dequeueLocked(user.ID)
+	}
+	return staff
+}
+
+// TransferSession 转移会话给其他客服，当前客服的角色需要有转接权限
 func (cs *CustomerService) TransferSession(sessionID, newStaffID string) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -131,31 +456,61 @@ func (cs *CustomerService) TransferSession(sessionID, newStaffID string) error {
 	if !exists {
 		return ErrSessionNotFound
 	}
+	if err := cs.checkPermissionLocked(session, session.StaffID, PermissionTransfer); err != nil {
+		return err
+	}
+
+	return cs.transferLocked(session, newStaffID, "", "")
+}
 
+// transferLocked 将会话转给新客服并记录转接历史，byID和reason为空表示由原客服发起，调用方需持有cs.mu
+func (cs *CustomerService) transferLocked(session *Session, newStaffID, byID, reason string) error {
 	newStaff, exists := cs.staffs[newStaffID]
 	if !exists {
 		return ErrStaffNotFound
 	}
 
-	oldStaff, exists := cs.staffs[session.StaffID]
-	if !exists {
+	// 待重新分配的孤儿会话原客服已不在线
+	oldStaffID := session.StaffID
+	oldStaff, exists := cs.staffs[oldStaffID]
+	if !exists && !session.Orphaned {
 		return ErrStaffNotFound
 	}
 
 	// 从原客服的会话列表中移除
-	delete(oldStaff.Sessions, sessionID)
+	if exists {
+		delete(oldStaff.Sessions, session.ID)
+	}
+	delete(session.roles, oldStaffID)
+	session.Orphaned = false
 
 	// 更新会话信息
 	session.StaffID = newStaffID
-	session.UpdateAt = time.Now()
+	session.GroupID = newStaff.GroupID
+	session.UpdateAt = cs.now()
+	session.Transfers = append(session.Transfers, TransferRecord{
+		FromStaffID: oldStaffID,
+		ToStaffID:   newStaffID,
+		ByID:        byID,
+		Reason:      reason,
+		CreateAt:    session.UpdateAt,
+	})
 
 	// 添加到新客服的会话列表
-	newStaff.Sessions[sessionID] = session
-
+	newStaff.Sessions[session.ID] = session
+
+	cs.publishLocked(SessionTransferred{
+		SessionID:   session.ID,
+		FromStaffID: oldStaffID,
+		ToStaffID:   newStaffID,
+		Reason:      reason,
+		At:          session.UpdateAt,
+	})
+	cs.appendSystemMessageLocked(session, fmt.Sprintf("会话已转接给客服%s", newStaff.Name))
 	return nil
 }
 
-// SendMessage 发送消息
+// SendMessage 发送消息，用户与客服的消息发给对方，主管的消息发给会话全部参与者
 func (cs *CustomerService) SendMessage(sessionID, fromID, content string, msgType MessageType) (*Message, error) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -164,72 +519,272 @@ func (cs *CustomerService) SendMessage(sessionID, fromID, content string, msgTyp
 	if !exists {
 		return nil, ErrSessionNotFound
 	}
+	return cs.sendLocked(session, fromID, defaultRecipient(session, fromID), "", content, msgType)
+}
+
+// SendMessageTo 向会话中的指定参与者发送消息，toID为空时发给会话全部参与者
+func (cs *CustomerService) SendMessageTo(sessionID, fromID, toID, content string, msgType MessageType) (*Message, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	return cs.sendLocked(session, fromID, toID, "", content, msgType)
+}
+
+// sendLocked 构造消息并追加到会话，调用方需持有cs.mu
+func (cs *CustomerService) sendLocked(session *Session, fromID, toID, clientMsgID, content string, msgType MessageType) (*Message, error) {
+	return cs.sendAtLocked(session, fromID, toID, clientMsgID, content, msgType, time.Time{})
+}
+
+// sendAtLocked 同sendLocked，并记录客户端声明的发送时间，超出允许偏差的时间被丢弃，调用方需持有cs.mu
+func (cs *CustomerService) sendAtLocked(session *Session, fromID, toID, clientMsgID, content string, msgType MessageType, clientSentAt time.Time) (*Message, error) {
+	msg, err := cs.newMessageTo(session, fromID, toID, content, msgType)
+	if err != nil {
+		return nil, err
+	}
+	msg.ClientMsgID = clientMsgID
+	msg.ClientSentAt = cs.trustedClientTime(clientSentAt, msg.CreateAt)
+
+	cs.appendMessageLocked(session, msg)
+	session.UpdateAt = cs.now()
+	cs.persistMessages(msg)
+	cs.evictMessagesLocked(session)
+	cs.publishMessageLocked(msg)
+
+	return msg, nil
+}
+
+// SendMessages 在同一会话中批量发送消息
+// 整批在一次加锁内处理，返回的两个切片与contents一一对应，失败的条目消息为nil、错误非nil
+func (cs *CustomerService) SendMessages(sessionID, fromID string, contents []string) ([]*Message, []error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	msgs := make([]*Message, len(contents))
+	errs := make([]error, len(contents))
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		for i := range errs {
+			errs[i] = ErrSessionNotFound
+		}
+		return msgs, errs
+	}
+
+	for i, content := range contents {
+		msg, err := cs.newMessage(session, fromID, content, MessageTypeText)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		cs.appendMessageLocked(session, msg)
+		msgs[i] = msg
+		cs.persistMessages(msg)
+		cs.publishMessageLocked(msg)
+	}
+	session.UpdateAt = cs.now()
+	cs.evictMessagesLocked(session)
+
+	return msgs, errs
+}
+
+// defaultRecipient 未指定接收者时的默认接收方：用户与客服互发，其余发给全部参与者
+func defaultRecipient(session *Session, fromID string) string {
+	switch fromID {
+	case session.UserID:
+		return session.StaffID
+	case session.StaffID:
+		return session.UserID
+	default:
+		return ""
+	}
+}
+
+// newMessage 校验发送者并构造一条发给默认接收方的消息，调用方需持有cs.mu
+func (cs *CustomerService) newMessage(session *Session, fromID, content string, msgType MessageType) (*Message, error) {
+	return cs.newMessageTo(session, fromID, defaultRecipient(session, fromID), content, msgType)
+}
+
+// newMessageTo 校验发送者与接收者并构造一条消息，toID为空表示发给会话全部参与者。
+// 已关闭的会话只接受系统消息，需先重新打开才能继续发送，调用方需持有cs.mu
+func (cs *CustomerService) newMessageTo(session *Session, fromID, toID, content string, msgType MessageType) (*Message, error) {
+	if session.Status == SessionStatusClosed && msgType != MessageTypeSystem {
+		return nil, ErrSessionClosed
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrEmptyContent
+	}
+	if msgType == MessageTypeText {
+		if err := cs.checkContentLength(content); err != nil {
+			return nil, err
+		}
+	}
 
 	msg := &Message{
-		ID:        sessionID + "_" + time.Now().Format("20060102150405"),
-		SessionID: sessionID,
+		SessionID: session.ID,
 		FromID:    fromID,
-		ToID:      "", // 根据fromID是用户还是客服来设置
+		ToID:      toID,
 		Content:   content,
 		Type:      msgType,
-		CreateAt:  time.Now(),
+		CreateAt:  cs.now(),
+	}
+
+	// 系统消息对会话全部参与者可见
+	if msgType == MessageTypeSystem {
+		msg.FromID = SystemSenderID
+		msg.ToID = ""
+	} else if !session.isParticipant(fromID) {
+		return nil, ErrInvalidOperation
+	} else if err := cs.checkPermissionLocked(session, fromID, PermissionSend); err != nil {
+		return nil, err
+	} else if toID == fromID || (toID != "" && !session.isParticipant(toID)) {
+		return nil, ErrNotParticipant
 	}
 
-	// 设置接收者ID
 	if fromID == session.UserID {
-		msg.ToID = session.StaffID
+		session.userActiveAt = msg.CreateAt
 	} else if fromID == session.StaffID {
-		msg.ToID = session.UserID
-	} else {
-		return nil, ErrInvalidOperation
+		session.sla.recordReply(msg.CreateAt)
 	}
+	recordFirstResponse(session, msg)
 
-	session.Messages = append(session.Messages, msg)
-	session.UpdateAt = time.Now()
+	// 同一秒内可能产生多条消息，使用会话内递增序号保证ID唯一
+	session.msgSeq++
+	msg.Seq = session.msgSeq
+	msg.ID = messageID(session.ID, msg.Seq)
 
 	return msg, nil
 }
 
-// DisconnectUser 处理用户断开连接
-func (cs *CustomerService) DisconnectUser(userID string) {
+// messageID 由会话ID和会话内序号生成消息ID
+func messageID(sessionID string, seq int64) string {
+	return sessionID + "_" + strconv.FormatInt(seq, 10)
+}
+
+// DisconnectUser 处理用户断开连接，配置了重连宽限期时保留用户和会话等待重连，
+// 主动退出或被移出时不保留宽限期，直接移除用户并关闭会话。断开原因记入审计日志和上下线事件
+func (cs *CustomerService) DisconnectUser(userID string, reason DisconnectReason) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	if user, exists := cs.users[userID]; exists {
-		user.Status = UserStatusOffline
-		if user.Conn != nil {
-			user.Conn.Close()
+	user, exists := cs.users[userID]
+	if !exists {
+		return
+	}
+	cs.disconnectUserLocked(user, reason)
+}
+
+// disconnectUserLocked 关闭用户连接，开启重连宽限期时保留会话等待重连，否则移除用户，调用方需持有cs.mu
+func (cs *CustomerService) disconnectUserLocked(user *User, reason DisconnectReason) {
+	if user.graceTimer != nil && !reason.skipsGrace() {
+		return
+	}
+	if user.Conn != nil {
+		user.Conn.Close()
+	}
+	cs.recordAuditLocked(AuditEntry{
+		Action:    AuditUserDisconnect,
+		ActorID:   user.ID,
+		SessionID: user.SessionID,
+		Reason:    string(reason),
+	})
+	switch {
+	case reason.skipsGrace():
+		if user.graceTimer != nil {
+			user.graceTimer.Stop()
+			user.graceTimer = nil
 		}
-		delete(cs.users, userID)
+		cs.expireUserLocked(user)
+	case cs.reconnectGrace > 0:
+		cs.startReconnectGraceLocked(user)
+	default:
+		cs.removeUserLocked(user)
+	}
+	cs.publishPresence(user.ID, PresenceRoleUser, false, reason)
+}
+
+// removeUserLocked 将离线用户移出系统，不再排队，调用方需持有cs.mu
+func (cs *CustomerService) removeUserLocked(user *User) {
+	user.Status = UserStatusOffline
+	delete(cs.users, user.ID)
+
+	// 离线用户不再排队
+	cs.dequeueLocked(user.ID)
+	if offerID, offered := cs.userOffers[user.ID]; offered {
+		cs.removeOfferLocked(cs.offers[offerID])
 	}
 }
 
-// DisconnectStaff 处理客服断开连接
-func (cs *CustomerService) DisconnectStaff(staffID string) {
+// DisconnectStaff 处理客服断开连接，网络错误等意外断开时会话重新排队，主动断开时关闭会话。
+// 断开原因记入审计日志和上下线事件
+func (cs *CustomerService) DisconnectStaff(staffID string, reason DisconnectReason) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
 	if staff, exists := cs.staffs[staffID]; exists {
-		staff.Status = UserStatusOffline
-		if staff.Conn != nil {
-			staff.Conn.Close()
+		cs.disconnectStaffLocked(staff, reason)
+	}
+}
+
+// disconnectStaffLocked 将客服移出系统并按断开原因关闭或重新排队其全部会话，未处理的邀请转给其他客服，调用方需持有cs.mu
+func (cs *CustomerService) disconnectStaffLocked(staff *CSStaff, reason DisconnectReason) {
+	staffID := staff.ID
+	cs.setStaffStatusLocked(staff, UserStatusOffline)
+	if staff.wrapUpTimer != nil {
+		staff.wrapUpTimer.Stop()
+	}
+	stopAwayTimerLocked(staff)
+	if staff.Conn != nil {
+		staff.Conn.Close()
+	}
+
+	// 从所属组中移除
+	if group, exists := cs.groups[staff.GroupID]; exists {
+		delete(group.Members, staffID)
+	}
+
+	cs.recordAuditLocked(AuditEntry{
+		Action:  AuditStaffDisconnect,
+		ActorID: staffID,
+		Reason:  string(reason),
+	})
+
+	// 意外断开时会话重新排队，否则关闭该客服的所有会话
+	for sessionID := range staff.Sessions {
+		session, exists := cs.sessions[sessionID]
+		if !exists {
+			continue
 		}
-		
-		// 从所属组中移除
-		if group, exists := cs.groups[staff.GroupID]; exists {
-			delete(group.Members, staffID)
+		event := SessionEvent{
+			SessionID: session.ID,
+			UserID:    session.UserID,
+			StaffID:   staffID,
+			Reason:    ReasonStaffOffline,
 		}
-
-		// 关闭该客服的所有会话
-		for sessionID := range staff.Sessions {
-			if session, exists := cs.sessions[sessionID]; exists {
-				session.Status = SessionStatusClosed
-				session.UpdateAt = time.Now()
+		if reason.requeuesSessions() {
+			if cs.requeueSessionLocked(session, ReasonStaffOffline) == nil {
+				cs.emit(EventSessionRequeued, event)
 			}
+			continue
 		}
+		if cs.closeSessionLocked(session, ReasonStaffOffline) == nil {
+			cs.emit(EventSessionClosed, event)
+		}
+	}
+
+	delete(cs.staffs, staffID)
 
-		delete(cs.staffs, staffID)
+	// 将该客服未处理的邀请转给其他客服
+	for _, offer := range cs.offers {
+		if offer.StaffID == staffID {
+			cs.reofferLocked(offer)
+		}
 	}
+
+	cs.publishPresence(staffID, PresenceRoleStaff, false, reason)
 }
 
 // GetUser 获取用户信息
@@ -260,4 +815,62 @@ func (cs *CustomerService) GetSession(sessionID string) *Session {
 		return session
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// ListGroupSessions 按创建时间列出客服组的会话，channel非空时只返回该渠道的会话
+func (cs *CustomerService) ListGroupSessions(groupID, channel string) []*Session {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	sessions := make([]*Session, 0)
+	for _, session := range cs.sessions {
+		if session.GroupID != groupID {
+			continue
+		}
+		if channel != "" && session.Channel != channel {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].CreateAt.Equal(sessions[j].CreateAt) {
+			return sessions[i].ID < sessions[j].ID
+		}
+		return sessions[i].CreateAt.Before(sessions[j].CreateAt)
+	})
+	return sessions
+}
+
+// Shutdown 关闭客服系统，在关闭时限内确保缓冲中的消息全部写入存储，见ShutdownContext
+func (cs *CustomerService) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), cs.shutdownTimeout)
+	defer cancel()
+	return cs.ShutdownContext(ctx)
+}
+
+// ShutdownContext 关闭客服系统并将缓冲中的消息写入存储，写入失败时重试直到成功或ctx结束，
+// ctx结束时返回最后一次写入错误或ctx.Err()，仍未写入的消息会丢失
+func (cs *CustomerService) ShutdownContext(ctx context.Context) error {
+	cs.mu.Lock()
+	writer, reaper, retention := cs.writer, cs.reaper, cs.retention
+	cs.writer, cs.reaper, cs.retention = nil, nil, nil
+	if cs.events != nil {
+		cs.events.stop()
+		cs.events = nil
+	}
+	cs.closeStreamLocked()
+	cs.mu.Unlock()
+
+	if reaper != nil {
+		reaper.stop()
+	}
+	if retention != nil {
+		retention.stop()
+	}
+
+	if writer == nil {
+		return nil
+	}
+	return writer.close(ctx)
+}
@@ -127,6 +127,26 @@ func createWebSocketConn(t *testing.T, server *httptest.Server) *websocket.Conn
 	return conn
 }
 
+// createTestSession 使用空连接创建用户、客服和二者之间的会话
+func createTestSession(t testing.TB, cs *CustomerService, userID, staffID string) *Session {
+	if cs.groups["group1"] == nil {
+		cs.CreateGroup("group1", "TestGroup")
+	}
+	if cs.GetUser(userID) == nil {
+		cs.ConnectUser(userID, "TestUser", nil)
+	}
+	if cs.GetStaff(staffID) == nil {
+		if _, err := cs.ConnectStaff(staffID, "TestStaff", "group1", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session, err := cs.CreateSession(userID, staffID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
 func TestCustomerService_Basic(t *testing.T) {
 	cs := NewCustomerService()
 	assert.NotNil(t, cs)
@@ -143,7 +163,8 @@ func TestCustomerService_ConnectUser(t *testing.T) {
 	conn := createWebSocketConn(t, server)
 	defer conn.Close()
 
-	user := cs.ConnectUser("user1", "TestUser", conn)
+	user, err := cs.ConnectUser("user1", "TestUser", conn)
+	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	assert.Equal(t, "user1", user.ID)
 	assert.Equal(t, "TestUser", user.Name)
@@ -188,6 +209,40 @@ func TestCustomerService_ConnectStaff(t *testing.T) {
 	assert.Equal(t, ErrGroupNotFound, err)
 }
 
+func TestCustomerService_ConnectStaffAssignsQueue(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	offers := recordOffers(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	for _, id := range []string{"user1", "user2", "user3", "user4"} {
+		cs.ConnectUser(id, "TestUser", nil)
+		assert.NoError(t, cs.EnqueueUser(id, "group1"))
+	}
+
+	// 自动接入的客服上线后立即按排队顺序接入，直到达到上限
+	staff, err := cs.ConnectStaffWithSettings("staff1", "TestStaff", "group1", nil, StaffSettings{MaxSessions: 2, AutoAccept: true})
+	assert.NoError(t, err)
+	assert.Len(t, staff.Sessions, 2)
+	assert.Equal(t, "staff1", cs.GetSession(cs.GetUser("user1").SessionID).StaffID)
+	assert.Equal(t, "staff1", cs.GetSession(cs.GetUser("user2").SessionID).StaffID)
+	assert.Equal(t, []string{"user3", "user4"}, cs.QueuedUsers("group1"))
+
+	// 手动接受的客服上线后收到邀请，邀请数同样受上限约束
+	staff, err = cs.ConnectStaffWithSettings("staff2", "TestStaff2", "group1", nil, StaffSettings{MaxSessions: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, staff.Sessions)
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "staff2", offer.StaffID)
+	assert.Equal(t, "user3", offer.UserID)
+	assert.Equal(t, []string{"user3", "user4"}, cs.QueuedUsers("group1"))
+	select {
+	case offer := <-offers:
+		t.Fatalf("unexpected offer: %+v", offer)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 func TestCustomerService_CreateSession(t *testing.T) {
 	cs, server := setupTestServer(t)
 	defer server.Close()
@@ -200,14 +255,16 @@ func TestCustomerService_CreateSession(t *testing.T) {
 
 	// 准备测试数据
 	cs.CreateGroup("group1", "TestGroup")
-	user := cs.ConnectUser("user1", "TestUser", userConn)
+	user, _ := cs.ConnectUser("user1", "TestUser", userConn)
 	staff, _ := cs.ConnectStaff("staff1", "TestStaff", "group1", staffConn)
 
 	// 测试创建会话
 	session, err := cs.CreateSession("user1", "staff1")
 	assert.NoError(t, err)
 	assert.NotNil(t, session)
-	assert.Contains(t, session.ID, "user1_staff1")
+	assert.NotEmpty(t, session.ID)
+	assert.NotContains(t, session.ID, "user1")
+	assert.NotContains(t, session.ID, "staff1")
 	assert.Equal(t, "user1", session.UserID)
 	assert.Equal(t, "staff1", session.StaffID)
 	assert.Equal(t, SessionStatusActive, session.Status)
@@ -312,6 +369,75 @@ func TestCustomerService_SendMessage(t *testing.T) {
 	assert.Equal(t, ErrInvalidOperation, err)
 }
 
+func TestCustomerService_SendMessages(t *testing.T) {
+	cs, server := setupTestServer(t)
+	defer server.Close()
+
+	userConn := createWebSocketConn(t, server)
+	defer userConn.Close()
+
+	staffConn := createWebSocketConn(t, server)
+	defer staffConn.Close()
+
+	// 准备测试数据
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", userConn)
+	cs.ConnectStaff("staff1", "TestStaff", "group1", staffConn)
+	session, _ := cs.CreateSession("user1", "staff1")
+
+	// 测试混合有效和无效条目的批量发送
+	msgs, errs := cs.SendMessages(session.ID, "user1", []string{"first", "", "third", "   "})
+	assert.Len(t, msgs, 4)
+	assert.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, ErrEmptyContent, errs[1])
+	assert.NoError(t, errs[2])
+	assert.Equal(t, ErrEmptyContent, errs[3])
+	assert.Nil(t, msgs[1])
+	assert.Nil(t, msgs[3])
+	assert.Equal(t, "first", msgs[0].Content)
+	assert.Equal(t, "third", msgs[2].Content)
+	assert.NotEqual(t, msgs[0].ID, msgs[2].ID)
+
+	// 只有成功的消息被追加到会话中
+	assert.Len(t, session.Messages, 2)
+	assert.Equal(t, msgs[0], session.Messages[0])
+	assert.Equal(t, msgs[2], session.Messages[1])
+
+	// 测试错误情况
+	_, errs = cs.SendMessages("nonexistent", "user1", []string{"a", "b"})
+	assert.Equal(t, []error{ErrSessionNotFound, ErrSessionNotFound}, errs)
+
+	_, errs = cs.SendMessages(session.ID, "nonexistent", []string{"a"})
+	assert.Equal(t, ErrInvalidOperation, errs[0])
+	assert.Len(t, session.Messages, 2)
+}
+
+func TestCustomerService_SendToClosedSession(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	assert.NoError(t, cs.CloseSession(session.ID, "staff1"))
+
+	// 已关闭的会话双方都不能再发送
+	_, err := cs.SendMessage(session.ID, "user1", "hello?", MessageTypeText)
+	assert.Equal(t, ErrSessionClosed, err)
+	_, err = cs.SendMessage(session.ID, "staff1", "bye", MessageTypeText)
+	assert.Equal(t, ErrSessionClosed, err)
+	_, errs := cs.SendMessages(session.ID, "user1", []string{"a"})
+	assert.Equal(t, []error{ErrSessionClosed}, errs)
+	assert.Empty(t, session.Messages)
+
+	// 重新打开后可以继续发送
+	_, err = cs.ReopenSession(session.ID)
+	assert.NoError(t, err)
+	msg, err := cs.SendMessage(session.ID, "user1", "hello again", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello again", msg.Content)
+	assert.Len(t, session.Messages, 1)
+}
+
 func TestCustomerService_DisconnectUser(t *testing.T) {
 	cs, server := setupTestServer(t)
 	defer server.Close()
@@ -323,14 +449,14 @@ func TestCustomerService_DisconnectUser(t *testing.T) {
 	cs.ConnectUser("user1", "TestUser", conn)
 
 	// 测试断开连接
-	cs.DisconnectUser("user1")
+	cs.DisconnectUser("user1", DisconnectClientClose)
 	assert.Empty(t, cs.users)
 
 	// 等待一段时间确保连接已关闭
 	time.Sleep(100 * time.Millisecond)
 
 	// 测试断开不存在的用户
-	cs.DisconnectUser("nonexistent") // 不应该panic
+	cs.DisconnectUser("nonexistent", DisconnectClientClose) // 不应该panic
 }
 
 func TestCustomerService_DisconnectStaff(t *testing.T) {
@@ -350,7 +476,7 @@ func TestCustomerService_DisconnectStaff(t *testing.T) {
 	session, _ := cs.CreateSession("user1", "staff1")
 
 	// 测试断开连接
-	cs.DisconnectStaff("staff1")
+	cs.DisconnectStaff("staff1", DisconnectClientClose)
 	assert.Empty(t, cs.staffs)
 	assert.Empty(t, cs.groups["group1"].Members)
 	assert.Equal(t, SessionStatusClosed, cs.sessions[session.ID].Status)
@@ -359,7 +485,38 @@ func TestCustomerService_DisconnectStaff(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// 测试断开不存在的客服
-	cs.DisconnectStaff("nonexistent") // 不应该panic
+	cs.DisconnectStaff("nonexistent", DisconnectClientClose) // 不应该panic
+}
+
+func TestCustomerService_DisconnectStaffReleasesUsers(t *testing.T) {
+	cs := NewCustomerService(WithReconnectGrace(time.Minute))
+	defer cs.Shutdown()
+	types, events, _ := recordSessionEvents(cs)
+
+	session1 := createTestSession(t, cs, "user1", "staff1")
+	session2 := createTestSession(t, cs, "user2", "staff1")
+	// 用户2已断线，处于重连宽限期
+	cs.DisconnectUser("user2", DisconnectClientClose)
+
+	cs.DisconnectStaff("staff1", DisconnectClientClose)
+
+	// 会话关闭后用户不再指向该会话，在线用户恢复为空闲，断线用户保持离线
+	user1 := cs.GetUser("user1")
+	assert.Equal(t, SessionStatusClosed, session1.Status)
+	assert.Empty(t, user1.SessionID)
+	assert.Equal(t, UserStatusOnline, user1.Status)
+	user2 := cs.GetUser("user2")
+	assert.Equal(t, SessionStatusClosed, session2.Status)
+	assert.Empty(t, user2.SessionID)
+	assert.Equal(t, UserStatusOffline, user2.Status)
+
+	for range []*Session{session1, session2} {
+		assert.Equal(t, EventSessionClosed, <-types)
+		assert.Equal(t, ReasonStaffOffline, (<-events).Reason)
+	}
+
+	// 用户可以重新排队
+	assert.NoError(t, cs.EnqueueUser("user1", "group1"))
 }
 
 func TestCustomerService_GetMethods(t *testing.T) {
@@ -374,7 +531,7 @@ func TestCustomerService_GetMethods(t *testing.T) {
 
 	// 准备测试数据
 	cs.CreateGroup("group1", "TestGroup")
-	user := cs.ConnectUser("user1", "TestUser", userConn)
+	user, _ := cs.ConnectUser("user1", "TestUser", userConn)
 	staff, _ := cs.ConnectStaff("staff1", "TestStaff", "group1", staffConn)
 	session, _ := cs.CreateSession("user1", "staff1")
 
@@ -388,3 +545,41 @@ func TestCustomerService_GetMethods(t *testing.T) {
 	assert.Nil(t, cs.GetStaff("nonexistent"))
 	assert.Nil(t, cs.GetSession("nonexistent"))
 }
+
+func TestCustomerService_ListGroupSessions(t *testing.T) {
+	cs := NewCustomerService()
+	cs.CreateGroup("group1", "TestGroup")
+	cs.CreateGroup("group2", "OtherGroup")
+	cs.ConnectStaff("staff1", "TestStaff1", "group1", nil)
+	cs.ConnectStaff("staff2", "TestStaff2", "group2", nil)
+
+	// 来自不同渠道的用户
+	cs.ConnectUser("user1", "WebUser", nil)
+	cs.ConnectUserWithChannel("user2", "MobileUser", ChannelMobile, nil)
+	cs.ConnectUserWithChannel("user3", "EmailUser", ChannelEmail, nil)
+	cs.ConnectUserWithChannel("user4", "MobileUser2", ChannelMobile, nil)
+
+	webSession, _ := cs.CreateSession("user1", "staff1")
+	mobileSession, _ := cs.CreateSession("user2", "staff1")
+	emailSession, _ := cs.CreateSession("user3", "staff1")
+	otherSession, _ := cs.CreateSession("user4", "staff2")
+
+	assert.Equal(t, ChannelWeb, webSession.Channel)
+	assert.Equal(t, ChannelMobile, mobileSession.Channel)
+	assert.Equal(t, ChannelEmail, emailSession.Channel)
+	assert.Equal(t, "group1", webSession.GroupID)
+
+	// 不指定渠道返回组内全部会话
+	assert.Len(t, cs.ListGroupSessions("group1", ""), 3)
+
+	// 按渠道过滤
+	assert.Equal(t, []*Session{mobileSession}, cs.ListGroupSessions("group1", ChannelMobile))
+	assert.Equal(t, []*Session{emailSession}, cs.ListGroupSessions("group1", ChannelEmail))
+	assert.Equal(t, []*Session{otherSession}, cs.ListGroupSessions("group2", ChannelMobile))
+	assert.Empty(t, cs.ListGroupSessions("group2", ChannelWeb))
+	assert.Empty(t, cs.ListGroupSessions("nonexistent", ""))
+
+	// 转移到其他组后随之变更
+	cs.TransferSession(emailSession.ID, "staff2")
+	assert.Equal(t, []*Session{emailSession}, cs.ListGroupSessions("group2", ChannelEmail))
+}
@@ -0,0 +1,163 @@
+package customer_service
+
+import "time"
+
+// SLA考核指标
+const (
+	SLAMetricFirstResponse = "first_response"
+	SLAMetricResolution    = "resolution"
+)
+
+// SLAConfig 客服组的服务等级目标，时限从会话创建开始计算
+type SLAConfig struct {
+	FirstResponse time.Duration // 客服首次回复的时限，0表示不考核
+	Resolution    time.Duration // 会话关闭的时限，0表示不考核
+	WarnBefore    time.Duration // 距时限还剩多久时发出预警，0表示不预警
+}
+
+// SLAEvent 服务等级预警或超时事件
+type SLAEvent struct {
+	SessionID string    `json:"session_id"`
+	GroupID   string    `json:"group_id"`
+	StaffID   string    `json:"staff_id"`
+	Metric    string    `json:"metric"`
+	Deadline  time.Time `json:"deadline"`
+}
+
+// SLAStatus 会话的服务等级考核状态，未考核的指标截止时间为零值
+type SLAStatus struct {
+	SessionID             string    `json:"session_id"`
+	FirstResponseDue      time.Time `json:"first_response_due"`
+	FirstResponseAt       time.Time `json:"first_response_at"` // 客服首次回复时间，尚未回复时为零值
+	FirstResponseBreached bool      `json:"first_response_breached"`
+	ResolutionDue         time.Time `json:"resolution_due"`
+	ResolutionBreached    bool      `json:"resolution_breached"`
+}
+
+// slaTracker 单个会话的考核状态
+type slaTracker struct {
+	config       SLAConfig
+	startAt      time.Time
+	firstReplyAt time.Time
+	timers       []Timer
+}
+
+// SetSLA 设置客服组的服务等级目标，cfg为空时取消考核，只对之后创建的会话生效
+func (cs *CustomerService) SetSLA(groupID string, cfg *SLAConfig) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	group.SLA = cfg
+	return nil
+}
+
+// startSLALocked 按会话所属组的配置从当前时刻开始考核计时，重新打开的会话重新计时，调用方需持有cs.mu
+func (cs *CustomerService) startSLALocked(session *Session) {
+	group, exists := cs.groups[session.GroupID]
+	if !exists || group.SLA == nil {
+		return
+	}
+	tracker := &slaTracker{config: *group.SLA, startAt: cs.now()}
+	session.sla = tracker
+
+	for _, metric := range []string{SLAMetricFirstResponse, SLAMetricResolution} {
+		deadline, tracked := tracker.deadline(metric)
+		if !tracked {
+			continue
+		}
+		if warnAt := deadline.Add(-tracker.config.WarnBefore); tracker.config.WarnBefore > 0 && warnAt.After(tracker.startAt) {
+			tracker.timers = append(tracker.timers, cs.slaTimerLocked(session, EventSLAWarning, metric, warnAt, deadline))
+		}
+		tracker.timers = append(tracker.timers, cs.slaTimerLocked(session, EventSLABreach, metric, deadline, deadline))
+	}
+}
+
+// slaTimerLocked 在at时刻检查指标是否仍未达成，未达成时发出事件，调用方需持有cs.mu
+func (cs *CustomerService) slaTimerLocked(session *Session, eventType, metric string, at, deadline time.Time) Timer {
+	return cs.afterFunc(at.Sub(cs.now()), func() {
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		if !session.sla.pending(session, metric) {
+			return
+		}
+		cs.emit(eventType, SLAEvent{
+			SessionID: session.ID,
+			GroupID:   session.GroupID,
+			StaffID:   session.StaffID,
+			Metric:    metric,
+			Deadline:  deadline,
+		})
+	})
+}
+
+// stopSLALocked 会话关闭后停止考核计时，调用方需持有cs.mu
+func stopSLALocked(session *Session) {
+	if session.sla == nil {
+		return
+	}
+	for _, timer := range session.sla.timers {
+		timer.Stop()
+	}
+	session.sla.timers = nil
+}
+
+// recordReply 记录客服的首次回复，tracker为空时忽略，调用方需持有cs.mu
+func (t *slaTracker) recordReply(at time.Time) {
+	if t != nil && t.firstReplyAt.IsZero() {
+		t.firstReplyAt = at
+	}
+}
+
+// deadline 获取指标的截止时间，未考核时返回false
+func (t *slaTracker) deadline(metric string) (time.Time, bool) {
+	limit := t.config.FirstResponse
+	if metric == SLAMetricResolution {
+		limit = t.config.Resolution
+	}
+	return t.startAt.Add(limit), limit > 0
+}
+
+// pending 判断指标是否仍未达成
+func (t *slaTracker) pending(session *Session, metric string) bool {
+	if session.Status == SessionStatusClosed {
+		return false
+	}
+	return metric == SLAMetricResolution || t.firstReplyAt.IsZero()
+}
+
+// SLAStatus 获取会话的服务等级考核状态，会话不存在或未考核时返回只带会话ID的零值
+func (cs *CustomerService) SLAStatus(sessionID string) SLAStatus {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	status := SLAStatus{SessionID: sessionID}
+	session, exists := cs.sessions[sessionID]
+	if !exists || session.sla == nil {
+		return status
+	}
+	tracker := session.sla
+	now := cs.now()
+
+	if due, tracked := tracker.deadline(SLAMetricFirstResponse); tracked {
+		status.FirstResponseDue = due
+		status.FirstResponseAt = tracker.firstReplyAt
+		if tracker.firstReplyAt.IsZero() {
+			status.FirstResponseBreached = session.Status != SessionStatusClosed && now.After(due)
+		} else {
+			status.FirstResponseBreached = tracker.firstReplyAt.After(due)
+		}
+	}
+	if due, tracked := tracker.deadline(SLAMetricResolution); tracked {
+		status.ResolutionDue = due
+		end := now
+		if session.Status == SessionStatusClosed {
+			end = session.UpdateAt
+		}
+		status.ResolutionBreached = end.After(due)
+	}
+	return status
+}
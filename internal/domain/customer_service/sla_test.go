@@ -0,0 +1,85 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordSLAEvents 记录服务等级事件
+func recordSLAEvents(cs *CustomerService) (<-chan string, <-chan SLAEvent) {
+	types := make(chan string, 16)
+	events := make(chan SLAEvent, 16)
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		if event, ok := payload.(SLAEvent); ok {
+			types <- eventType
+			events <- event
+		}
+	})
+	return types, events
+}
+
+func TestCustomerService_SLABreach(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+	types, events := recordSLAEvents(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	assert.NoError(t, cs.SetSLA("group1", &SLAConfig{FirstResponse: 2 * time.Minute, WarnBefore: time.Minute}))
+	session := createTestSession(t, cs, "user1", "staff1")
+	_, err := cs.SendMessage(session.ID, "user1", "有人吗", MessageTypeText)
+	assert.NoError(t, err)
+
+	// 客服一直未回复，先预警后超时
+	for _, expected := range []string{EventSLAWarning, EventSLABreach} {
+		clock.Advance(time.Minute)
+		select {
+		case eventType := <-types:
+			assert.Equal(t, expected, eventType)
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not emitted", expected)
+		}
+		event := <-events
+		assert.Equal(t, session.ID, event.SessionID)
+		assert.Equal(t, "staff1", event.StaffID)
+		assert.Equal(t, SLAMetricFirstResponse, event.Metric)
+	}
+
+	clock.Advance(time.Second)
+	status := cs.SLAStatus(session.ID)
+	assert.True(t, status.FirstResponseBreached)
+	assert.True(t, status.FirstResponseAt.IsZero())
+	assert.True(t, status.ResolutionDue.IsZero())
+}
+
+func TestCustomerService_SLAMet(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+	types, _ := recordSLAEvents(cs)
+
+	cs.CreateGroup("group1", "TestGroup")
+	assert.NoError(t, cs.SetSLA("group1", &SLAConfig{FirstResponse: time.Minute, Resolution: 2 * time.Minute}))
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 客服及时回复并关闭会话，不再发出事件
+	_, err := cs.SendMessage(session.ID, "staff1", "您好", MessageTypeText)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.CloseSession(session.ID, "staff1"))
+	clock.Advance(3 * time.Minute)
+
+	select {
+	case eventType := <-types:
+		t.Fatalf("unexpected %s", eventType)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	status := cs.SLAStatus(session.ID)
+	assert.False(t, status.FirstResponseAt.IsZero())
+	assert.False(t, status.FirstResponseBreached)
+	assert.False(t, status.ResolutionBreached)
+	assert.Equal(t, SLAStatus{SessionID: "missing"}, cs.SLAStatus("missing"))
+	assert.ErrorIs(t, cs.SetSLA("missing", nil), ErrGroupNotFound)
+}
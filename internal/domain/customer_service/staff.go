@@ -0,0 +1,116 @@
+package customer_service
+
+import "sort"
+
+// StaffView 客服信息的只读快照，不包含连接和会话内容，可以安全地对外发布
+type StaffView struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	GroupID        string     `json:"group_id"`
+	Status         UserStatus `json:"status"`
+	Skills         []string   `json:"skills"`
+	MaxSessions    int        `json:"max_sessions"`
+	AutoAccept     bool       `json:"auto_accept"`
+	Weight         float64    `json:"weight"`
+	ActiveSessions int        `json:"active_sessions"`
+	FocusSessionID string     `json:"focus_session_id,omitempty"`
+}
+
+// SetStaffStatus 客服切换在线或离开状态，离开期间不分配新会话
+func (cs *CustomerService) SetStaffStatus(staffID string, status UserStatus) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return ErrStaffNotFound
+	}
+	if status != UserStatusOnline && status != UserStatusAway {
+		return ErrInvalidOperation
+	}
+	if staff.Status == UserStatusOffline {
+		return ErrStaffUnavailable
+	}
+
+	if staff.wrapUpTimer != nil {
+		staff.wrapUpTimer.Stop()
+		staff.wrapUpTimer = nil
+	}
+	prev := staff.Status
+	cs.setStaffStatusLocked(staff, status)
+	if status == UserStatusOnline {
+		stopAwayTimerLocked(staff)
+		cs.dispatchGroupLocked(staff.GroupID)
+	} else if prev != UserStatusAway {
+		cs.startAwayTimerLocked(staff)
+	}
+	cs.emitStaffUpdatedLocked(staff)
+	return nil
+}
+
+// UpdateSkills 更新客服的技能标签
+func (cs *CustomerService) UpdateSkills(staffID string, skills []string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return ErrStaffNotFound
+	}
+	staff.Skills = append([]string(nil), skills...)
+	sort.Strings(staff.Skills)
+	cs.emitStaffUpdatedLocked(staff)
+	return nil
+}
+
+// SetStaffCapacity 设置客服同时处理的会话上限，小于等于0表示不限
+func (cs *CustomerService) SetStaffCapacity(staffID string, n int) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return ErrStaffNotFound
+	}
+	if n < 0 {
+		n = 0
+	}
+	staff.MaxSessions = n
+	// 上限放宽后继续分配排队用户
+	cs.dispatchGroupLocked(staff.GroupID)
+	cs.emitStaffUpdatedLocked(staff)
+	return nil
+}
+
+// GetStaffView 获取客服信息的只读快照，客服不存在时返回false
+func (cs *CustomerService) GetStaffView(staffID string) (StaffView, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return StaffView{}, false
+	}
+	return staffViewLocked(staff), true
+}
+
+// staffViewLocked 生成客服快照，调用方需持有cs.mu
+func staffViewLocked(staff *CSStaff) StaffView {
+	return StaffView{
+		ID:             staff.ID,
+		Name:           staff.Name,
+		GroupID:        staff.GroupID,
+		Status:         staff.Status,
+		Skills:         append([]string(nil), staff.Skills...),
+		MaxSessions:    staff.MaxSessions,
+		AutoAccept:     staff.AutoAccept,
+		Weight:         staffWeight(staff),
+		ActiveSessions: len(staff.Sessions),
+		FocusSessionID: focusedSessionLocked(staff),
+	}
+}
+
+// emitStaffUpdatedLocked 发布客服信息变更事件，调用方需持有cs.mu
+func (cs *CustomerService) emitStaffUpdatedLocked(staff *CSStaff) {
+	cs.emit(EventStaffUpdated, staffViewLocked(staff))
+}
@@ -0,0 +1,52 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_StaffUpdated(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	updates := make(chan StaffView, 8)
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		if eventType == EventStaffUpdated {
+			updates <- payload.(StaffView)
+		}
+	})
+
+	createTestSession(t, cs, "user1", "staff1")
+	receive := func() StaffView {
+		select {
+		case view := <-updates:
+			return view
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for staff_updated")
+		}
+		return StaffView{}
+	}
+
+	// 状态切换发布完整快照
+	assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusAway))
+	view := receive()
+	assert.Equal(t, "staff1", view.ID)
+	assert.Equal(t, "group1", view.GroupID)
+	assert.Equal(t, UserStatusAway, view.Status)
+	assert.Equal(t, 1, view.ActiveSessions)
+
+	assert.NoError(t, cs.UpdateSkills("staff1", []string{"refund", "billing"}))
+	assert.Equal(t, []string{"billing", "refund"}, receive().Skills)
+
+	assert.NoError(t, cs.SetStaffCapacity("staff1", 3))
+	view = receive()
+	assert.Equal(t, 3, view.MaxSessions)
+	assert.Equal(t, UserStatusAway, view.Status)
+
+	// 无效状态和不存在的客服不发布事件
+	assert.Equal(t, ErrInvalidOperation, cs.SetStaffStatus("staff1", UserStatusInSession))
+	assert.Equal(t, ErrStaffNotFound, cs.UpdateSkills("nonexistent", nil))
+	assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusOnline))
+	assert.Equal(t, UserStatusOnline, receive().Status)
+}
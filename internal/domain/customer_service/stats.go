@@ -0,0 +1,111 @@
+package customer_service
+
+import (
+	"sort"
+	"time"
+)
+
+// rttSmoothing 往返时延滑动平均的权重分母，每个新样本占1/rttSmoothing
+const rttSmoothing = 8
+
+// ConnStats 单个在线连接的统计
+type ConnStats struct {
+	ID   string        `json:"id"`
+	Role PresenceRole  `json:"role"`
+	RTT  time.Duration `json:"rtt"`
+}
+
+// Stats 系统运行统计
+type Stats struct {
+	OnlineUsers    int         `json:"online_users"`
+	OnlineStaffs   int         `json:"online_staffs"`
+	ActiveSessions int         `json:"active_sessions"`
+	TotalMessages  int         `json:"total_messages"`
+	Connections    []ConnStats `json:"connections"` // 按角色、ID排序
+}
+
+// RecordRTT 记录一次连接往返时延并更新滑动平均，收到pong同时视为连接活跃
+func (cs *CustomerService) RecordRTT(role PresenceRole, id string, rtt time.Duration) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	now := cs.now()
+	switch role {
+	case PresenceRoleUser:
+		if user, exists := cs.users[id]; exists {
+			user.RTT = smoothRTT(user.RTT, rtt)
+			user.lastSeenAt = now
+		}
+	case PresenceRoleStaff:
+		if staff, exists := cs.staffs[id]; exists {
+			staff.RTT = smoothRTT(staff.RTT, rtt)
+			staff.lastSeenAt = now
+			cs.recoverStaffLocked(staff)
+		}
+	}
+}
+
+// smoothRTT 以首个样本为初值计算滑动平均
+func smoothRTT(avg, sample time.Duration) time.Duration {
+	if avg == 0 {
+		return sample
+	}
+	return avg + (sample-avg)/rttSmoothing
+}
+
+// Stats 获取系统运行统计
+func (cs *CustomerService) Stats() Stats {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	var stats Stats
+	for _, user := range cs.users {
+		if user.Status == UserStatusOffline {
+			continue
+		}
+		stats.OnlineUsers++
+		stats.Connections = append(stats.Connections, ConnStats{ID: user.ID, Role: PresenceRoleUser, RTT: user.RTT})
+	}
+	for _, staff := range cs.staffs {
+		if staff.Status == UserStatusOffline {
+			continue
+		}
+		stats.OnlineStaffs++
+		stats.Connections = append(stats.Connections, ConnStats{ID: staff.ID, Role: PresenceRoleStaff, RTT: staff.RTT})
+	}
+	for _, session := range cs.sessions {
+		if session.Status == SessionStatusActive {
+			stats.ActiveSessions++
+		}
+	}
+	stats.TotalMessages = int(cs.totalMessages.Load())
+
+	sort.Slice(stats.Connections, func(i, j int) bool {
+		a, b := stats.Connections[i], stats.Connections[j]
+		if a.Role != b.Role {
+			return a.Role > b.Role // user排在staff之前
+		}
+		return a.ID < b.ID
+	})
+	return stats
+}
+
+// appendMessageLocked 经过滤器处理后向会话追加消息并累加系统消息总数，调用方需持有cs.mu
+func (cs *CustomerService) appendMessageLocked(session *Session, msg *Message) {
+	cs.filterMessageLocked(msg)
+	session.appendMessage(msg)
+	cs.countUnreadLocked(session, msg)
+	cs.totalMessages.Add(1)
+}
+
+// MessageCount 获取会话在内存中保留的消息条数，已撤回的消息仍计入，已淘汰的不计入
+func (cs *CustomerService) MessageCount(sessionID string) (int, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return 0, ErrSessionNotFound
+	}
+	return session.msgCount, nil
+}
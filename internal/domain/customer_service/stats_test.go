@@ -0,0 +1,75 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_Stats(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	cs.SendMessage(session.ID, "staff1", "Hi", MessageTypeText)
+
+	// 首个样本作为初值，之后按1/8权重平滑
+	cs.RecordRTT(PresenceRoleUser, "user1", 80*time.Millisecond)
+	cs.RecordRTT(PresenceRoleUser, "user1", 160*time.Millisecond)
+	cs.RecordRTT(PresenceRoleStaff, "staff1", 20*time.Millisecond)
+	cs.RecordRTT(PresenceRoleStaff, "unknown", time.Second)
+
+	stats := cs.Stats()
+	assert.Equal(t, 1, stats.OnlineUsers)
+	assert.Equal(t, 1, stats.OnlineStaffs)
+	assert.Equal(t, 1, stats.ActiveSessions)
+	assert.Equal(t, 2, stats.TotalMessages)
+	assert.Equal(t, []ConnStats{
+		{ID: "user1", Role: PresenceRoleUser, RTT: 90 * time.Millisecond},
+		{ID: "staff1", Role: PresenceRoleStaff, RTT: 20 * time.Millisecond},
+	}, stats.Connections)
+}
+
+func TestCustomerService_MessageCounters(t *testing.T) {
+	cs := NewCustomerService(WithMaxInMemoryMessages(3))
+	defer cs.Shutdown()
+
+	first := createTestSession(t, cs, "user1", "staff1")
+	second := createTestSession(t, cs, "user2", "staff1")
+
+	// assertCounters 计数器与会话中实际保留的消息条数一致
+	assertCounters := func(firstCount, secondCount int) {
+		t.Helper()
+		count, err := cs.MessageCount(first.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, firstCount, count)
+		assert.Len(t, first.Messages, firstCount)
+		count, err = cs.MessageCount(second.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, secondCount, count)
+		assert.Len(t, second.Messages, secondCount)
+		assert.Equal(t, firstCount+secondCount, cs.Stats().TotalMessages)
+	}
+
+	msg, err := cs.SendMessage(first.ID, "user1", "one", MessageTypeText)
+	assert.NoError(t, err)
+	cs.SendMessages(second.ID, "user2", []string{"a", "b"})
+	assertCounters(1, 2)
+
+	// 撤回保留消息，计数不变
+	_, err = cs.RecallMessage(first.ID, msg.ID, "user1")
+	assert.NoError(t, err)
+	assertCounters(1, 2)
+
+	// 超出内存上限的消息被淘汰，计数随之减少
+	cs.SendMessages(first.ID, "staff1", []string{"two", "three", "four", "five"})
+	assertCounters(3, 2)
+	cs.SendMessage(second.ID, SystemSenderID, "系统通知", MessageTypeSystem)
+	cs.SendMessage(second.ID, "staff1", "c", MessageTypeText)
+	assertCounters(3, 3)
+
+	_, err = cs.MessageCount("missing")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
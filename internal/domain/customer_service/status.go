@@ -0,0 +1,66 @@
+package customer_service
+
+// ReasonAssigned 等待中的会话分配给客服
+const ReasonAssigned = "assigned"
+
+// statusMessages 状态变更原因对应的说明文字，展示给会话双方
+var statusMessages = map[string]string{
+	ReasonAssigned:      "assigned to an agent",
+	ReasonReopened:      "session reopened",
+	ReasonUserSilence:   "no reply from the user for too long",
+	ReasonClosedByUser:  "closed by the user",
+	ReasonClosedByStaff: "closed by the agent",
+	ReasonClosedByAdmin: "closed by a supervisor",
+	ReasonMerged:        "merged into another session",
+	ReasonOrphaned:      "the agent is no longer available",
+	ReasonStaffAway:     "the agent is away",
+	ReasonStaffOffline:  "the agent went offline",
+	ReasonUserOffline:   "the user went offline",
+	ReasonGroupDeleted:  "the service group was removed",
+}
+
+// SessionStatusChange 会话状态变更通知，发给变更时的用户和客服
+type SessionStatusChange struct {
+	SessionID  string `json:"session_id"`
+	UserID     string `json:"user_id"`
+	StaffID    string `json:"staff_id,omitempty"`
+	Status     string `json:"status"`
+	PrevStatus string `json:"prev_status"`
+	Reason     string `json:"reason"`
+	Message    string `json:"message"` // 面向用户的说明
+}
+
+// transitionLocked 切换会话状态并发出状态变更事件，reason为空时只切换不通知，
+// 用于新建会话的激活，由会话创建通知代替，调用方需持有cs.mu
+func (cs *CustomerService) transitionLocked(session *Session, status SessionStatus, reason string) error {
+	prev := session.Status
+	if err := session.transitionTo(status); err != nil {
+		return err
+	}
+	if status == SessionStatusClosed {
+		cs.publishLocked(SessionClosed{
+			SessionID: session.ID,
+			UserID:    session.UserID,
+			StaffID:   session.StaffID,
+			Reason:    reason,
+			At:        cs.now(),
+		})
+	}
+	if reason != "" {
+		cs.emitStatusLocked(session, prev, reason)
+	}
+	return nil
+}
+
+// emitStatusLocked 发出会话状态变更事件，调用方需持有cs.mu
+func (cs *CustomerService) emitStatusLocked(session *Session, prev SessionStatus, reason string) {
+	cs.emit(EventSessionStatus, SessionStatusChange{
+		SessionID:  session.ID,
+		UserID:     session.UserID,
+		StaffID:    session.StaffID,
+		Status:     session.Status.String(),
+		PrevStatus: prev.String(),
+		Reason:     reason,
+		Message:    statusMessages[reason],
+	})
+}
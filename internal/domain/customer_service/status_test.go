@@ -0,0 +1,92 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordStatusChanges 记录会话状态变更事件
+func recordStatusChanges(cs *CustomerService) <-chan SessionStatusChange {
+	changes := make(chan SessionStatusChange, 16)
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		if change, ok := payload.(SessionStatusChange); ok && eventType == EventSessionStatus {
+			changes <- change
+		}
+	})
+	return changes
+}
+
+// nextStatusChange 读取下一条状态变更事件，超时则测试失败
+func nextStatusChange(t *testing.T, changes <-chan SessionStatusChange) SessionStatusChange {
+	t.Helper()
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(time.Second):
+		t.Fatal("no session status change")
+		return SessionStatusChange{}
+	}
+}
+
+func TestCustomerService_SessionStatusOnClose(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	changes := recordStatusChanges(cs)
+
+	assert.NoError(t, cs.CloseSession(session.ID, "staff1"))
+	assert.Equal(t, SessionStatusChange{
+		SessionID:  session.ID,
+		UserID:     "user1",
+		StaffID:    "staff1",
+		Status:     "closed",
+		PrevStatus: "active",
+		Reason:     ReasonClosedByStaff,
+		Message:    statusMessages[ReasonClosedByStaff],
+	}, nextStatusChange(t, changes))
+}
+
+func TestCustomerService_SessionStatusOnRequeueAndAssign(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	changes := recordStatusChanges(cs)
+
+	// 客服异常断开，会话重新排队，通知中仍带原客服以便告知双方
+	cs.DisconnectStaff("staff1", DisconnectError)
+	change := nextStatusChange(t, changes)
+	assert.Equal(t, "waiting", change.Status)
+	assert.Equal(t, "active", change.PrevStatus)
+	assert.Equal(t, "staff1", change.StaffID)
+	assert.Equal(t, ReasonStaffOffline, change.Reason)
+
+	// 新客服接入排队中的会话
+	cs.ConnectStaff("staff2", "客服2", "group1", nil)
+	_, err := cs.ClaimNext("staff2")
+	assert.NoError(t, err)
+	change = nextStatusChange(t, changes)
+	assert.Equal(t, session.ID, change.SessionID)
+	assert.Equal(t, "active", change.Status)
+	assert.Equal(t, "waiting", change.PrevStatus)
+	assert.Equal(t, "staff2", change.StaffID)
+	assert.Equal(t, ReasonAssigned, change.Reason)
+}
+
+func TestCustomerService_SessionStatusSkipsNewSession(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	changes := recordStatusChanges(cs)
+	createTestSession(t, cs, "user1", "staff1")
+
+	// 新会话由会话创建通知告知，不重复发送状态变更
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected status change: %+v", change)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
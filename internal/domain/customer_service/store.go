@@ -0,0 +1,202 @@
+package customer_service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// SessionStore 会话消息持久化存储
+type SessionStore interface {
+	// AppendMessage 追加一条或多条消息
+	AppendMessage(msgs ...*Message) error
+	// LoadMessages 按写入顺序加载会话的全部消息
+	LoadMessages(sessionID string) ([]*Message, error)
+}
+
+// MemoryStore 基于内存的存储实现，主要用于测试和单机部署
+type MemoryStore struct {
+	messages map[string][]*Message
+	offline  map[string][]*Message // 各接收方的离线消息
+	mu       sync.RWMutex
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		messages: make(map[string][]*Message),
+		offline:  make(map[string][]*Message),
+	}
+}
+
+// AppendMessage 追加消息，保存副本以免与内存中的会话共享状态
+func (s *MemoryStore) AppendMessage(msgs ...*Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, msg := range msgs {
+		s.messages[msg.SessionID] = append(s.messages[msg.SessionID], snapshotMessage(msg))
+	}
+	return nil
+}
+
+// snapshotMessage 复制消息及其附加信息，副本与原消息不共享可变状态
+func snapshotMessage(msg *Message) *Message {
+	m := *msg
+	m.Headers = cloneHeaders(msg.Headers)
+	return &m
+}
+
+// LoadMessages 加载会话消息
+func (s *MemoryStore) LoadMessages(sessionID string) ([]*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msgs := make([]*Message, len(s.messages[sessionID]))
+	copy(msgs, s.messages[sessionID])
+	return msgs, nil
+}
+
+// maxBufferedMessages 批量写入缓冲的消息数上限，存储持续失败时丢弃最早的消息，避免缓冲无限增长
+const maxBufferedMessages = 10000
+
+// storeWriter 消息批量写入器，按条数或时间间隔将缓冲的消息刷入存储
+type storeWriter struct {
+	store    SessionStore
+	size     int
+	interval time.Duration
+	limit    int // 缓冲的消息数上限
+	buf      []*Message
+	mu       sync.Mutex // 保护buf
+	flushMu  sync.Mutex // 保证同一时刻只有一次写入，维持消息顺序
+	notify   chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newStoreWriter(store SessionStore, size int, interval time.Duration) *storeWriter {
+	w := &storeWriter{
+		store:    store,
+		size:     size,
+		interval: interval,
+		limit:    maxBufferedMessages,
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// add 缓冲一条消息，缓冲满时通知后台协程写入，不在调用方协程中做I/O
+func (w *storeWriter) add(msg *Message) {
+	w.mu.Lock()
+	w.buf = append(w.buf, msg)
+	w.trimLocked()
+	full := len(w.buf) >= w.size
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run 后台写入循环
+func (w *storeWriter) run() {
+	defer w.wg.Done()
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.notify:
+		case <-tick:
+		case <-w.done:
+			return
+		}
+		if err := w.flush(); err != nil {
+			log.Printf("Error flushing messages to store: %v", err)
+		}
+	}
+}
+
+// flush 将缓冲的消息写入存储，失败时放回缓冲等待下次重试
+func (w *storeWriter) flush() error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	batch := w.buf
+	w.buf = nil
+	w.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := w.store.AppendMessage(batch...); err != nil {
+		w.mu.Lock()
+		w.buf = append(batch, w.buf...)
+		w.trimLocked()
+		w.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// trimLocked 缓冲超过上限时丢弃最早的消息，调用方需持有w.mu
+func (w *storeWriter) trimLocked() {
+	dropped := len(w.buf) - w.limit
+	if dropped <= 0 {
+		return
+	}
+	for i := 0; i < dropped; i++ {
+		w.buf[i] = nil
+	}
+	w.buf = w.buf[dropped:]
+	log.Printf("Store writer buffer full, dropped %d oldest messages", dropped)
+}
+
+// close 停止后台协程并写入剩余消息，ctx结束时不再等待
+func (w *storeWriter) close(ctx context.Context) error {
+	close(w.done)
+	stopped := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return w.flushContext(ctx)
+}
+
+// persistMessages 将新消息交给存储，调用方需持有cs.mu。
+// 批量写入时缓冲消息的快照，后台写入不会读到之后撤回、合并等操作对消息的修改
+func (cs *CustomerService) persistMessages(msgs ...*Message) {
+	if cs.store == nil || len(msgs) == 0 {
+		return
+	}
+
+	if cs.writer != nil {
+		for _, msg := range msgs {
+			cs.writer.add(snapshotMessage(msg))
+		}
+		return
+	}
+
+	if err := cs.store.AppendMessage(msgs...); err != nil {
+		log.Printf("Error appending messages to store: %v", err)
+	}
+}
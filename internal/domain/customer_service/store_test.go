@@ -0,0 +1,154 @@
+package customer_service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore 记录写入次数并模拟每次写入的I/O耗时
+type countingStore struct {
+	*MemoryStore
+	latency time.Duration
+	calls   int
+	mu      sync.Mutex
+}
+
+func (s *countingStore) AppendMessage(msgs ...*Message) error {
+	time.Sleep(s.latency)
+	s.mu.Lock()
+	s.calls++
+	s.mu.Unlock()
+	return s.MemoryStore.AppendMessage(msgs...)
+}
+
+func storedCount(t *testing.T, store SessionStore, sessionID string) int {
+	msgs, err := store.LoadMessages(sessionID)
+	assert.NoError(t, err)
+	return len(msgs)
+}
+
+func TestCustomerService_SyncStore(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store))
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	msg, err := cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	assert.NoError(t, err)
+
+	stored, _ := store.LoadMessages(session.ID)
+	assert.Len(t, stored, 1)
+	assert.Equal(t, msg.ID, stored[0].ID)
+	assert.NoError(t, cs.Shutdown())
+}
+
+func TestCustomerService_StoreBatch(t *testing.T) {
+	store := &countingStore{MemoryStore: NewMemoryStore()}
+	cs := NewCustomerService(WithStore(store), WithStoreBatch(3, time.Hour))
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 未达到批量大小前不写入
+	cs.SendMessage(session.ID, "user1", "one", MessageTypeText)
+	cs.SendMessage(session.ID, "staff1", "two", MessageTypeText)
+	assert.Equal(t, 0, storedCount(t, store, session.ID))
+
+	// 达到批量大小后一次性写入
+	cs.SendMessage(session.ID, "user1", "three", MessageTypeText)
+	assert.Eventually(t, func() bool {
+		return storedCount(t, store, session.ID) == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, store.calls)
+
+	// 关闭时写入剩余消息，且保持发送顺序
+	cs.SendMessages(session.ID, "user1", []string{"four", "five"})
+	assert.NoError(t, cs.Shutdown())
+
+	stored, _ := store.LoadMessages(session.ID)
+	assert.Len(t, stored, 5)
+	for i, msg := range stored {
+		assert.Equal(t, session.Messages[i].ID, msg.ID)
+	}
+}
+
+func TestCustomerService_StoreFlushInterval(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store), WithStoreBatch(100, 20*time.Millisecond))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	assert.Eventually(t, func() bool {
+		return storedCount(t, store, session.ID) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCustomerService_StoreBatchSnapshot(t *testing.T) {
+	store := NewMemoryStore()
+	cs := NewCustomerService(WithStore(store), WithStoreBatch(100, time.Hour))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	msg, err := cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	assert.NoError(t, err)
+	msg.SetHeader("k", "v")
+
+	// 缓冲的是发送时的快照，写入前撤回不影响已缓冲的内容
+	_, err = cs.RecallMessage(session.ID, msg.ID, "user1")
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Flush(context.Background()))
+
+	stored, _ := store.LoadMessages(session.ID)
+	if assert.Len(t, stored, 1) {
+		assert.Equal(t, "Hello", stored[0].Content)
+		assert.False(t, stored[0].Recalled)
+		assert.Nil(t, stored[0].Headers)
+	}
+}
+
+func TestStoreWriter_Limit(t *testing.T) {
+	store := &failingStore{MemoryStore: NewMemoryStore(), failures: -1}
+	w := newStoreWriter(store, 100, 0)
+	w.limit = 3
+
+	// 存储持续失败时缓冲不超过上限，丢弃最早的消息
+	for _, id := range []string{"m1", "m2", "m3", "m4"} {
+		w.add(&Message{ID: id, SessionID: "s1"})
+	}
+	assert.Error(t, w.flush())
+	w.add(&Message{ID: "m5", SessionID: "s1"})
+
+	w.mu.Lock()
+	ids := make([]string, len(w.buf))
+	for i, msg := range w.buf {
+		ids[i] = msg.ID
+	}
+	w.mu.Unlock()
+	assert.Equal(t, []string{"m3", "m4", "m5"}, ids)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, w.close(ctx))
+}
+
+func benchmarkStoreWrites(b *testing.B, opts ...Option) {
+	store := &countingStore{MemoryStore: NewMemoryStore(), latency: 50 * time.Microsecond}
+	cs := NewCustomerService(append([]Option{WithStore(store)}, opts...)...)
+	session := createTestSession(b, cs, "user1", "staff1")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	}
+	cs.Shutdown()
+}
+
+func BenchmarkSendMessage_SyncStore(b *testing.B) {
+	benchmarkStoreWrites(b)
+}
+
+func BenchmarkSendMessage_BatchedStore(b *testing.B) {
+	benchmarkStoreWrites(b, WithStoreBatch(256, 10*time.Millisecond))
+}
@@ -0,0 +1,126 @@
+package customer_service
+
+import "time"
+
+// defaultEventBuffer 事件流默认缓冲的事件数
+const defaultEventBuffer = 256
+
+// Event 生命周期事件，具体类型为SessionCreated、MessageSent、SessionTransferred、SessionClosed、StaffStatusChanged之一
+type Event interface {
+	EventType() string
+}
+
+// SessionCreated 新会话建立
+type SessionCreated struct {
+	SessionID string    `json:"session_id"`
+	UserID    string    `json:"user_id"`
+	StaffID   string    `json:"staff_id"`
+	GroupID   string    `json:"group_id"`
+	At        time.Time `json:"at"`
+}
+
+// MessageSent 会话中写入一条用户或客服发送的消息，系统消息不包括在内
+type MessageSent struct {
+	MessageID string    `json:"message_id"`
+	SessionID string    `json:"session_id"`
+	FromID    string    `json:"from_id"`
+	ToID      string    `json:"to_id"`
+	Seq       int64     `json:"seq"`
+	At        time.Time `json:"at"`
+}
+
+// SessionTransferred 会话转给其他客服
+type SessionTransferred struct {
+	SessionID   string    `json:"session_id"`
+	FromStaffID string    `json:"from_staff_id"`
+	ToStaffID   string    `json:"to_staff_id"`
+	Reason      string    `json:"reason,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// SessionClosed 会话关闭
+type SessionClosed struct {
+	SessionID string    `json:"session_id"`
+	UserID    string    `json:"user_id"`
+	StaffID   string    `json:"staff_id"`
+	Reason    string    `json:"reason"`
+	At        time.Time `json:"at"`
+}
+
+// StaffStatusChanged 客服状态变化，包括上线和下线
+type StaffStatusChanged struct {
+	StaffID    string     `json:"staff_id"`
+	Status     UserStatus `json:"status"`
+	PrevStatus UserStatus `json:"prev_status"`
+	At         time.Time  `json:"at"`
+}
+
+func (SessionCreated) EventType() string     { return "session_created" }
+func (MessageSent) EventType() string        { return "message_sent" }
+func (SessionTransferred) EventType() string { return "session_transferred" }
+func (SessionClosed) EventType() string      { return "session_closed" }
+func (StaffStatusChanged) EventType() string { return "staff_status_changed" }
+
+// WithEventBuffer 设置事件流的缓冲大小，小于等于0时使用默认值
+func WithEventBuffer(n int) Option {
+	return func(cs *CustomerService) {
+		cs.streamBuffer = n
+	}
+}
+
+// Events 返回生命周期事件流，首次调用时创建，之后返回同一通道。事件按发生顺序投递，
+// 缓冲满时丢弃新事件以免阻塞主流程。Shutdown后通道关闭
+func (cs *CustomerService) Events() <-chan Event {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.stream == nil {
+		size := cs.streamBuffer
+		if size <= 0 {
+			size = defaultEventBuffer
+		}
+		cs.stream = make(chan Event, size)
+	}
+	return cs.stream
+}
+
+// publishLocked 非阻塞地向事件流投递事件，未调用Events时忽略，调用方需持有cs.mu
+func (cs *CustomerService) publishLocked(event Event) {
+	if cs.stream == nil || cs.streamClosed {
+		return
+	}
+	select {
+	case cs.stream <- event:
+	default:
+		// 消费者处理过慢，丢弃该事件
+	}
+}
+
+// publishMessageLocked 发布消息写入事件，调用方需持有cs.mu
+func (cs *CustomerService) publishMessageLocked(msg *Message) {
+	cs.publishLocked(MessageSent{
+		MessageID: msg.ID,
+		SessionID: msg.SessionID,
+		FromID:    msg.FromID,
+		ToID:      msg.ToID,
+		Seq:       msg.Seq,
+		At:        msg.CreateAt,
+	})
+}
+
+// setStaffStatusLocked 更新客服状态，状态变化时发布事件，调用方需持有cs.mu
+func (cs *CustomerService) setStaffStatusLocked(staff *CSStaff, status UserStatus) {
+	prev := staff.Status
+	staff.Status = status
+	if prev != status {
+		cs.publishLocked(StaffStatusChanged{StaffID: staff.ID, Status: status, PrevStatus: prev, At: cs.now()})
+	}
+}
+
+// closeStreamLocked 关闭事件流，调用方需持有cs.mu
+func (cs *CustomerService) closeStreamLocked() {
+	if cs.stream != nil && !cs.streamClosed {
+		close(cs.stream)
+		cs.streamClosed = true
+	}
+}
@@ -0,0 +1,83 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nextEvent 读取事件流的下一个事件，超时则测试失败
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func TestCustomerService_EventStream(t *testing.T) {
+	cs := NewCustomerService()
+	events := cs.Events()
+	assert.Equal(t, events, cs.Events())
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectStaff("staff1", "TestStaff", "group1", nil)
+	cs.ConnectStaff("staff2", "TestStaff", "group1", nil)
+	cs.ConnectUser("user1", "TestUser", nil)
+	session, err := cs.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	msg, err := cs.SendMessage(session.ID, "user1", "hello", MessageTypeText)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.TransferSession(session.ID, "staff2"))
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+
+	staff := nextEvent(t, events).(StaffStatusChanged)
+	assert.Equal(t, "staff1", staff.StaffID)
+	assert.Equal(t, UserStatusOnline, staff.Status)
+	assert.Equal(t, UserStatusOffline, staff.PrevStatus)
+	assert.Equal(t, "staff2", nextEvent(t, events).(StaffStatusChanged).StaffID)
+
+	created := nextEvent(t, events).(SessionCreated)
+	assert.Equal(t, SessionCreated{SessionID: session.ID, UserID: "user1", StaffID: "staff1", GroupID: "group1", At: created.At}, created)
+
+	sent := nextEvent(t, events).(MessageSent)
+	assert.Equal(t, MessageSent{MessageID: msg.ID, SessionID: session.ID, FromID: "user1", ToID: "staff1", Seq: 1, At: msg.CreateAt}, sent)
+
+	// 转接产生的系统消息不计入消息事件
+	transferred := nextEvent(t, events).(SessionTransferred)
+	assert.Equal(t, "staff1", transferred.FromStaffID)
+	assert.Equal(t, "staff2", transferred.ToStaffID)
+
+	closed := nextEvent(t, events).(SessionClosed)
+	assert.Equal(t, SessionClosed{SessionID: session.ID, UserID: "user1", StaffID: "staff2", Reason: ReasonClosedByUser, At: closed.At}, closed)
+
+	// 停机后事件流关闭
+	cs.Shutdown()
+	_, open := <-events
+	assert.False(t, open)
+}
+
+func TestCustomerService_EventStreamStaffStatus(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	cs.SetWrapUpDuration(time.Hour)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	events := cs.Events()
+	cs.CloseSession(session.ID, "staff1")
+	cs.DisconnectStaff("staff1", DisconnectLogout)
+
+	var statuses []UserStatus
+	for _, eventType := range []string{"session_closed", "staff_status_changed", "staff_status_changed"} {
+		event := nextEvent(t, events)
+		assert.Equal(t, eventType, event.EventType())
+		if change, ok := event.(StaffStatusChanged); ok {
+			statuses = append(statuses, change.Status)
+		}
+	}
+	assert.Equal(t, []UserStatus{UserStatusWrapUp, UserStatusOffline}, statuses)
+}
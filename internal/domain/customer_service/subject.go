@@ -0,0 +1,47 @@
+package customer_service
+
+import "strings"
+
+// RequestSession 用户主动发起会话并声明咨询主题，主题记录在会话上，分配时优先选择具备同名技能的客服。
+// 能立即分配时返回新会话，否则用户留在等待队列中并返回nil，排队失败的错误与EnqueueUser一致
+func (cs *CustomerService) RequestSession(userID, groupID, subject string) (*Session, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if err := cs.enqueueLocked(userID, groupID, 0, strings.TrimSpace(subject)); err != nil {
+		return nil, err
+	}
+	user := cs.users[userID]
+	if session, exists := cs.sessions[user.SessionID]; exists {
+		return session, nil
+	}
+	return nil, nil
+}
+
+// preferSkilledLocked 返回candidates中具备subject技能（不区分大小写）的客服，
+// subject为空或没有客服具备该技能时原样返回，调用方需持有cs.mu
+func preferSkilledLocked(candidates []*CSStaff, subject string) []*CSStaff {
+	if subject == "" {
+		return candidates
+	}
+	var skilled []*CSStaff
+	for _, staff := range candidates {
+		if hasSkill(staff, subject) {
+			skilled = append(skilled, staff)
+		}
+	}
+	if len(skilled) == 0 {
+		return candidates
+	}
+	return skilled
+}
+
+// hasSkill 判断客服是否具备指定技能，不区分大小写
+func hasSkill(staff *CSStaff, skill string) bool {
+	for _, s := range staff.Skills {
+		if strings.EqualFold(s, skill) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_RequestSessionRoutesBySubject(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectStaff("staff1", "General", "group1", nil)
+	cs.ConnectStaff("staff2", "Billing", "group1", nil)
+	cs.SetAutoAccept("staff1", true)
+	cs.SetAutoAccept("staff2", true)
+	assert.NoError(t, cs.UpdateSkills("staff2", []string{"Billing"}))
+	cs.ConnectUser("user1", "TestUser", nil)
+	cs.ConnectUser("user2", "TestUser", nil)
+
+	// 主题与技能匹配时不区分大小写，即使staff2的会话更多也优先分配
+	session, err := cs.RequestSession("user1", "group1", "billing")
+	assert.NoError(t, err)
+	if assert.NotNil(t, session) {
+		assert.Equal(t, "staff2", session.StaffID)
+		assert.Equal(t, "billing", session.Subject)
+	}
+	session, err = cs.RequestSession("user2", "group1", "billing")
+	assert.NoError(t, err)
+	if assert.NotNil(t, session) {
+		assert.Equal(t, "staff2", session.StaffID)
+	}
+
+	// 没有客服具备该技能时按默认策略分配
+	cs.ConnectUser("user3", "TestUser", nil)
+	session, err = cs.RequestSession("user3", "group1", "shipping")
+	assert.NoError(t, err)
+	if assert.NotNil(t, session) {
+		assert.Equal(t, "staff1", session.StaffID)
+	}
+
+	_, err = cs.RequestSession("user1", "group1", "billing")
+	assert.Equal(t, ErrInvalidOperation, err)
+	_, err = cs.RequestSession("nonexistent", "group1", "billing")
+	assert.Equal(t, ErrUserNotFound, err)
+}
+
+func TestCustomerService_RequestSessionKeepsSubjectWhenQueued(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+
+	// 没有在线客服时留在队列中，客服领取后会话仍记录主题
+	session, err := cs.RequestSession("user1", "group1", "billing")
+	assert.NoError(t, err)
+	assert.Nil(t, session)
+	assert.Equal(t, []string{"user1"}, cs.QueuedUsers("group1"))
+
+	cs.ConnectStaff("staff1", "General", "group1", nil)
+	session, err = cs.ClaimNext("staff1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, session) {
+		assert.Equal(t, "billing", session.Subject)
+	}
+}
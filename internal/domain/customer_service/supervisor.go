@@ -0,0 +1,192 @@
+package customer_service
+
+import (
+	"log"
+	"sort"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConnectSupervisor 处理主管连接
+func (cs *CustomerService) ConnectSupervisor(supervisorID string, conn *websocket.Conn) *Supervisor {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	supervisor := &Supervisor{ID: supervisorID, Conn: conn}
+	cs.supervisors[supervisorID] = supervisor
+	return supervisor
+}
+
+// DisconnectSupervisor 处理主管断开连接，主管随之退出已加入的会话
+func (cs *CustomerService) DisconnectSupervisor(supervisorID string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, exists := cs.supervisors[supervisorID]; !exists {
+		return
+	}
+	delete(cs.supervisors, supervisorID)
+	for _, session := range cs.sessions {
+		delete(session.Supervisors, supervisorID)
+		delete(session.joinedSeq, supervisorID)
+		delete(session.roles, supervisorID)
+	}
+}
+
+// GetSupervisor 获取主管信息
+func (cs *CustomerService) GetSupervisor(supervisorID string) *Supervisor {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.supervisors[supervisorID]
+}
+
+// JoinSession 主管加入会话，加入后成为会话参与者
+func (cs *CustomerService) JoinSession(sessionID, supervisorID string) error {
+	return cs.JoinSessionWithTranscript(sessionID, supervisorID, nil)
+}
+
+// JoinSessionWithTranscript 主管加入会话并获取此前的完整记录。加入与获取记录在同一次加锁内完成，
+// 记录中的消息不会再通过MessageRecipients实时投递给该主管，加入后的新消息不会遗漏。
+// onJoin在持有服务锁时调用，只应做入队等不阻塞的操作，使记录先于实时消息发出
+func (cs *CustomerService) JoinSessionWithTranscript(sessionID, supervisorID string, onJoin func(transcript []Message)) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if session.Status == SessionStatusClosed {
+		return ErrSessionClosed
+	}
+	if _, exists := cs.supervisors[supervisorID]; !exists {
+		return ErrInvalidOperation
+	}
+
+	if session.Supervisors == nil {
+		session.Supervisors = make(map[string]bool)
+	}
+	if session.joinedSeq == nil {
+		session.joinedSeq = make(map[string]int64)
+	}
+	session.Supervisors[supervisorID] = true
+	session.joinedSeq[supervisorID] = session.msgSeq
+
+	if onJoin != nil {
+		onJoin(cs.transcriptLocked(session))
+	}
+	return nil
+}
+
+// transcriptLocked 获取会话的完整消息记录副本，内存中已淘汰的消息从存储补齐，调用方需持有cs.mu
+func (cs *CustomerService) transcriptLocked(session *Session) []Message {
+	messages := session.Messages
+	if cs.store != nil && len(messages) > 0 && messages[0].Seq > 1 {
+		if cs.writer != nil {
+			if err := cs.writer.flush(); err != nil {
+				log.Printf("Error flushing messages to store: %v", err)
+			}
+		}
+		if stored, err := cs.store.LoadMessages(session.ID); err == nil {
+			older := pageMessages(stored, messages[0].Seq, len(stored))
+			messages = append(older, messages...)
+		} else {
+			log.Printf("Error loading transcript of session %s: %v", session.ID, err)
+		}
+	}
+
+	transcript := make([]Message, len(messages))
+	for i, msg := range messages {
+		transcript[i] = *msg
+	}
+	return transcript
+}
+
+// LeaveSession 主管退出会话
+func (cs *CustomerService) LeaveSession(sessionID, supervisorID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if !session.Supervisors[supervisorID] {
+		return ErrNotParticipant
+	}
+	delete(session.Supervisors, supervisorID)
+	delete(session.joinedSeq, supervisorID)
+	delete(session.roles, supervisorID)
+	return nil
+}
+
+// MessageRecipients 获取应实时收到消息的参与者：指定了接收者时为该参与者，否则为发送者以外的全部参与者；
+// 已加入的主管旁听会话中的全部消息，但不包括加入时已在记录中收到的消息
+func (cs *CustomerService) MessageRecipients(msg *Message) []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[msg.SessionID]
+	if !exists {
+		if msg.ToID != "" {
+			return []string{msg.ToID}
+		}
+		return nil
+	}
+
+	participants := sessionParticipantsLocked(session)
+	recipients := make([]string, 0, len(participants))
+	for _, id := range participants {
+		if id == msg.FromID {
+			continue
+		}
+		if session.Supervisors[id] {
+			if seq, joined := session.joinedSeq[id]; joined && msg.Seq <= seq {
+				continue
+			}
+		} else if msg.ToID != "" && id != msg.ToID {
+			continue
+		}
+		recipients = append(recipients, id)
+	}
+	return recipients
+}
+
+// SessionParticipants 获取会话全部参与者ID，依次为用户、客服和按ID排序的主管
+func (cs *CustomerService) SessionParticipants(sessionID string) []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return nil
+	}
+	return sessionParticipantsLocked(session)
+}
+
+// sessionParticipantsLocked 获取会话全部参与者ID，调用方需持有cs.mu
+func sessionParticipantsLocked(session *Session) []string {
+	participants := []string{session.UserID}
+	if session.StaffID != "" {
+		participants = append(participants, session.StaffID)
+	}
+	supervisors := make([]string, 0, len(session.Supervisors))
+	for id := range session.Supervisors {
+		supervisors = append(supervisors, id)
+	}
+	sort.Strings(supervisors)
+	return append(participants, supervisors...)
+}
+
+// ListSupervisors 获取在线主管ID，按ID排序
+func (cs *CustomerService) ListSupervisors() []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	ids := make([]string, 0, len(cs.supervisors))
+	for id := range cs.supervisors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
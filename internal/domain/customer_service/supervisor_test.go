@@ -0,0 +1,118 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_SupervisorAddressing(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectSupervisor("sup1", nil)
+
+	// 未加入会话的主管不能发言
+	_, err := cs.SendMessage(session.ID, "sup1", "Hello", MessageTypeText)
+	assert.Equal(t, ErrInvalidOperation, err)
+
+	assert.NoError(t, cs.JoinSession(session.ID, "sup1"))
+	assert.Equal(t, []string{"user1", "staff1", "sup1"}, cs.SessionParticipants(session.ID))
+
+	// 用户与客服之间的默认收发方式不变
+	msg, err := cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, "staff1", msg.ToID)
+
+	// 主管默认广播给全部参与者
+	msg, err = cs.SendMessage(session.ID, "sup1", "Everyone", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Empty(t, msg.ToID)
+
+	// 指定接收者时只发给该参与者
+	msg, err = cs.SendMessageTo(session.ID, "sup1", "staff1", "Whisper", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, "staff1", msg.ToID)
+	msg, err = cs.SendMessageTo(session.ID, "staff1", "sup1", "Reply", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, "sup1", msg.ToID)
+
+	// 接收者必须是会话中的其他参与者
+	_, err = cs.SendMessageTo(session.ID, "staff1", "user2", "Hi", MessageTypeText)
+	assert.Equal(t, ErrNotParticipant, err)
+	_, err = cs.SendMessageTo(session.ID, "staff1", "staff1", "Hi", MessageTypeText)
+	assert.Equal(t, ErrNotParticipant, err)
+
+	// 主管断开后退出会话
+	cs.DisconnectSupervisor("sup1")
+	assert.Equal(t, []string{"user1", "staff1"}, cs.SessionParticipants(session.ID))
+	_, err = cs.SendMessageTo(session.ID, "staff1", "sup1", "Hi", MessageTypeText)
+	assert.Equal(t, ErrNotParticipant, err)
+}
+
+func TestCustomerService_JoinSessionWithTranscript(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectSupervisor("sup1", nil)
+
+	before1, err := cs.SendMessage(session.ID, "user1", "before1", MessageTypeText)
+	assert.NoError(t, err)
+	before2, err := cs.SendMessage(session.ID, "staff1", "before2", MessageTypeText)
+	assert.NoError(t, err)
+
+	var transcript []Message
+	assert.NoError(t, cs.JoinSessionWithTranscript(session.ID, "sup1", func(messages []Message) {
+		transcript = messages
+	}))
+	if assert.Len(t, transcript, 2) {
+		assert.Equal(t, "before1", transcript[0].Content)
+		assert.Equal(t, "before2", transcript[1].Content)
+	}
+
+	// 加入前的消息已在记录中，即使在加入后才投递也不会再发给主管
+	assert.Equal(t, []string{"staff1"}, cs.MessageRecipients(before1))
+	assert.Equal(t, []string{"user1"}, cs.MessageRecipients(before2))
+
+	// 加入后用户与客服之间的消息主管也能收到
+	after, err := cs.SendMessage(session.ID, "user1", "after", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"staff1", "sup1"}, cs.MessageRecipients(after))
+	broadcast, err := cs.SendMessageTo(session.ID, "user1", "", "everyone", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"staff1", "sup1"}, cs.MessageRecipients(broadcast))
+
+	// 重新加入时记录包含全部消息
+	assert.NoError(t, cs.LeaveSession(session.ID, "sup1"))
+	assert.NoError(t, cs.JoinSessionWithTranscript(session.ID, "sup1", func(messages []Message) {
+		transcript = messages
+	}))
+	assert.Len(t, transcript, 4)
+
+	assert.ErrorIs(t, cs.JoinSessionWithTranscript("missing", "sup1", nil), ErrSessionNotFound)
+}
+
+func TestCustomerService_TranscriptFromStore(t *testing.T) {
+	cs := NewCustomerService(WithStore(NewMemoryStore()), WithMaxInMemoryMessages(2))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectSupervisor("sup1", nil)
+	for _, content := range []string{"m1", "m2", "m3", "m4"} {
+		_, err := cs.SendMessage(session.ID, "user1", content, MessageTypeText)
+		assert.NoError(t, err)
+	}
+
+	// 内存中已淘汰的消息从存储补齐
+	var transcript []Message
+	assert.NoError(t, cs.JoinSessionWithTranscript(session.ID, "sup1", func(messages []Message) {
+		transcript = messages
+	}))
+	contents := make([]string, len(transcript))
+	for i, msg := range transcript {
+		contents[i] = msg.Content
+	}
+	assert.Equal(t, []string{"m1", "m2", "m3", "m4"}, contents)
+}
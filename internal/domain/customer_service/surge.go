@@ -0,0 +1,61 @@
+package customer_service
+
+import "time"
+
+// surgeDetector 按滑动窗口统计排队请求数，超过上限时视为流量激增，由cs.mu保护
+type surgeDetector struct {
+	limit  int
+	window time.Duration
+	hits   []time.Time // 窗口内的请求时间，按时间顺序
+}
+
+// record 记录一次请求
+func (d *surgeDetector) record(now time.Time) {
+	d.prune(now)
+	d.hits = append(d.hits, now)
+}
+
+// active 判断窗口内的请求数是否超过上限
+func (d *surgeDetector) active(now time.Time) bool {
+	d.prune(now)
+	return len(d.hits) > d.limit
+}
+
+// prune 丢弃窗口之外的请求记录
+func (d *surgeDetector) prune(now time.Time) {
+	cutoff := now.Add(-d.window)
+	i := 0
+	for i < len(d.hits) && !d.hits[i].After(cutoff) {
+		i++
+	}
+	d.hits = d.hits[i:]
+}
+
+// WithSurgeProtection 开启流量激增保护：window内的排队请求超过limit时，新的排队请求返回ErrSystemBusy，
+// 引导用户留言，已有会话不受影响。请求速率回落到上限以内后自动恢复，任一参数小于等于0时不开启
+func WithSurgeProtection(limit int, window time.Duration) Option {
+	return func(cs *CustomerService) {
+		if limit <= 0 || window <= 0 {
+			cs.surge = nil
+			return
+		}
+		cs.surge = &surgeDetector{limit: limit, window: window}
+	}
+}
+
+// SurgeActive 判断当前是否处于流量激增状态，未开启保护时始终为false
+func (cs *CustomerService) SurgeActive() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.surge != nil && cs.surge.active(cs.now())
+}
+
+// shedLocked 记录一次排队请求并判断是否需要拒绝，调用方需持有cs.mu
+func (cs *CustomerService) shedLocked(now time.Time) bool {
+	if cs.surge == nil {
+		return false
+	}
+	cs.surge.record(now)
+	return cs.surge.active(now)
+}
@@ -0,0 +1,66 @@
+package customer_service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurgeDetector(t *testing.T) {
+	d := &surgeDetector{limit: 2, window: time.Second}
+	now := time.Now()
+
+	d.record(now)
+	d.record(now.Add(100 * time.Millisecond))
+	assert.False(t, d.active(now.Add(100*time.Millisecond)))
+	d.record(now.Add(200 * time.Millisecond))
+	assert.True(t, d.active(now.Add(200*time.Millisecond)))
+
+	// 最早的请求移出窗口后恢复
+	assert.False(t, d.active(now.Add(1050*time.Millisecond)))
+	assert.Len(t, d.hits, 2)
+}
+
+func TestCustomerService_SurgeProtection(t *testing.T) {
+	cs := NewCustomerService(WithSurgeProtection(3, 100*time.Millisecond))
+	defer cs.Shutdown()
+
+	active := createTestSession(t, cs, "active", "staff1")
+	for i := 0; i < 5; i++ {
+		cs.ConnectUser(fmt.Sprintf("user%d", i), "TestUser", nil)
+	}
+	assert.False(t, cs.SurgeActive())
+
+	// 窗口内超过上限的排队请求被拒绝，引导用户留言
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, cs.EnqueueUser(fmt.Sprintf("user%d", i), "group1"))
+	}
+	assert.Equal(t, ErrSystemBusy, cs.EnqueueUser("user3", "group1"))
+	assert.True(t, cs.SurgeActive())
+	assert.Len(t, cs.QueuedUsers("group1"), 3)
+
+	// 已有会话不受影响
+	_, err := cs.SendMessage(active.ID, "active", "still here", MessageTypeText)
+	assert.NoError(t, err)
+
+	// 请求速率回落后恢复
+	assert.Eventually(t, func() bool {
+		return !cs.SurgeActive()
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, cs.EnqueueUser("user4", "group1"))
+}
+
+func TestCustomerService_SurgeProtectionDisabled(t *testing.T) {
+	cs := NewCustomerService(WithSurgeProtection(0, time.Second))
+	defer cs.Shutdown()
+
+	cs.CreateGroup("group1", "TestGroup")
+	for i := 0; i < 10; i++ {
+		userID := fmt.Sprintf("user%d", i)
+		cs.ConnectUser(userID, "TestUser", nil)
+		assert.NoError(t, cs.EnqueueUser(userID, "group1"))
+	}
+	assert.False(t, cs.SurgeActive())
+}
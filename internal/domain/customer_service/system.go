@@ -0,0 +1,18 @@
+package customer_service
+
+import "log"
+
+// appendSystemMessageLocked 向会话追加一条系统消息并通知会话双方，调用方需持有cs.mu
+func (cs *CustomerService) appendSystemMessageLocked(session *Session, content string) *Message {
+	msg, err := cs.newMessage(session, SystemSenderID, content, MessageTypeSystem)
+	if err != nil {
+		log.Printf("Error creating system message for session %s: %v", session.ID, err)
+		return nil
+	}
+	cs.appendMessageLocked(session, msg)
+	session.UpdateAt = msg.CreateAt
+	cs.persistMessages(msg)
+	cs.evictMessagesLocked(session)
+	cs.emit(EventSystemMessage, *msg)
+	return msg
+}
@@ -0,0 +1,34 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_TransferSystemMessage(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	messages := make(chan Message, 4)
+	cs.SetEventHook(func(eventType string, payload interface{}) {
+		if eventType == EventSystemMessage {
+			messages <- payload.(Message)
+		}
+	})
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.ConnectStaff("staff2", "TestStaff2", "group1", nil)
+	cs.SendMessage(session.ID, "user1", "Hello", MessageTypeText)
+
+	assert.NoError(t, cs.TransferSession(session.ID, "staff2"))
+	assert.Len(t, session.Messages, 2)
+	msg := session.Messages[1]
+	assert.Equal(t, SystemSenderID, msg.FromID)
+	assert.Empty(t, msg.ToID)
+	assert.Equal(t, MessageTypeSystem, msg.Type)
+	assert.Contains(t, msg.Content, "TestStaff2")
+	assert.Equal(t, int64(2), msg.Seq)
+
+	// 系统消息通过事件通知网关转发给会话双方
+	assert.Equal(t, *msg, <-messages)
+}
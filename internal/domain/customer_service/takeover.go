@@ -0,0 +1,44 @@
+package customer_service
+
+import "github.com/gorilla/websocket"
+
+// TakeoverUserConnection 同一用户凭重连令牌在新设备上连接时，将会话改绑到新连接，客服无需任何操作即可继续对话。
+// 旧设备已断线、用户在重连宽限期内时按ResumeUser恢复，否则要求用户仍在会话中。
+// 返回被替换的旧连接，是否关闭由调用方决定，令牌在接管成功后作废。
+// 用户不存在返回ErrUserNotFound，没有进行中的会话返回ErrNoActiveSession，令牌无效返回ErrInvalidResumeToken
+func (cs *CustomerService) TakeoverUserConnection(token, userID string, conn *websocket.Conn) (*websocket.Conn, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	user, exists := cs.users[userID]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	session, exists := cs.sessions[user.SessionID]
+	if user.graceTimer == nil && (!exists || session.Status == SessionStatusClosed) {
+		return nil, ErrNoActiveSession
+	}
+	if err := cs.consumeResumeTokenLocked(token, userID); err != nil {
+		return nil, err
+	}
+
+	old := user.Conn
+	if user.graceTimer != nil {
+		cs.resumeUserLocked(user, user.Name, user.Channel, conn)
+		cs.publishPresence(userID, PresenceRoleUser, true, "")
+		return old, nil
+	}
+	user.Conn = conn
+	return old, nil
+}
+
+// DisconnectUserConn 仅当conn仍是用户当前的连接时按DisconnectUser处理，
+// 已被新设备接管的旧连接断开时不影响用户和会话
+func (cs *CustomerService) DisconnectUserConn(userID string, conn *websocket.Conn, reason DisconnectReason) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if user, exists := cs.users[userID]; exists && user.Conn == conn {
+		cs.disconnectUserLocked(user, reason)
+	}
+}
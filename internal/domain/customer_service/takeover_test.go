@@ -0,0 +1,79 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_TakeoverUserConnection(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+	user := cs.GetUser("user1")
+	token, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+
+	// 没有令牌或令牌不符时不能接管，在线用户的重复连接被拒绝
+	oldConn, newConn := &websocket.Conn{}, &websocket.Conn{}
+	user.Conn = oldConn
+	_, err = cs.TakeoverUserConnection("guessed", "user1", newConn)
+	assert.Equal(t, ErrInvalidResumeToken, err)
+	_, err = cs.ConnectUser("user1", "TestUser", newConn)
+	assert.Equal(t, ErrTooManyConnections, err)
+	assert.Same(t, oldConn, user.Conn)
+
+	// 新设备凭令牌接管会话，返回旧连接，会话和状态保持不变
+	replaced, err := cs.TakeoverUserConnection(token, "user1", newConn)
+	assert.NoError(t, err)
+	assert.Same(t, oldConn, replaced)
+	assert.Same(t, newConn, user.Conn)
+	assert.Equal(t, session.ID, user.SessionID)
+	assert.Equal(t, UserStatusInSession, user.Status)
+	_, err = cs.TakeoverUserConnection(token, "user1", oldConn)
+	assert.Equal(t, ErrInvalidResumeToken, err)
+
+	// 旧连接断开不影响新设备
+	cs.DisconnectUserConn("user1", oldConn, DisconnectClientClose)
+	assert.Same(t, user, cs.GetUser("user1"))
+	msg, err := cs.SendMessage(session.ID, "staff1", "still here", MessageTypeText)
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", msg.ToID)
+
+	// 错误情况
+	_, err = cs.TakeoverUserConnection(token, "nonexistent", newConn)
+	assert.Equal(t, ErrUserNotFound, err)
+	cs.ConnectUser("user2", "TestUser2", nil)
+	other, err := cs.IssueResumeToken("user2")
+	assert.NoError(t, err)
+	_, err = cs.TakeoverUserConnection(other, "user2", newConn)
+	assert.Equal(t, ErrNoActiveSession, err)
+}
+
+func TestCustomerService_TakeoverDuringReconnectGrace(t *testing.T) {
+	cs := NewCustomerService(WithReconnectGrace(time.Minute))
+	defer cs.Shutdown()
+	session := createTestSession(t, cs, "user1", "staff1")
+	token, err := cs.IssueResumeToken("user1")
+	assert.NoError(t, err)
+
+	// 旧设备断线进入宽限期后，没有令牌不能接管
+	cs.DisconnectUser("user1", DisconnectClientClose)
+	user := cs.GetUser("user1")
+	assert.Equal(t, UserStatusOffline, user.Status)
+	newConn := &websocket.Conn{}
+	_, err = cs.TakeoverUserConnection("guessed", "user1", newConn)
+	assert.Equal(t, ErrInvalidResumeToken, err)
+	assert.NotNil(t, user.graceTimer)
+
+	// 凭令牌接管即结束宽限期
+	replaced, err := cs.TakeoverUserConnection(token, "user1", newConn)
+	assert.NoError(t, err)
+	assert.Nil(t, replaced)
+	assert.Same(t, newConn, user.Conn)
+	assert.Nil(t, user.graceTimer)
+	assert.Equal(t, UserStatusInSession, user.Status)
+	assert.Equal(t, SessionStatusActive, cs.GetSession(session.ID).Status)
+}
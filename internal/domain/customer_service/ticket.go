@@ -0,0 +1,62 @@
+package customer_service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ticket 无客服可用或非营业时间时用户留下的离线留言
+type Ticket struct {
+	ID       string    `json:"id"`
+	UserID   string    `json:"user_id"`
+	GroupID  string    `json:"group_id"`
+	Subject  string    `json:"subject"`
+	Body     string    `json:"body"`
+	Contact  string    `json:"contact"`
+	CreateAt time.Time `json:"create_at"`
+}
+
+// CreateTicket 创建留言，归属用户最近一次请求的客服组
+func (cs *CustomerService) CreateTicket(userID, subject, body, contact string) (*Ticket, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	user, exists := cs.users[userID]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, ErrEmptyContent
+	}
+	if strings.TrimSpace(contact) == "" {
+		return nil, ErrInvalidTicket
+	}
+
+	cs.seq++
+	ticket := &Ticket{
+		ID:       "ticket_" + strconv.FormatInt(cs.seq, 10),
+		UserID:   userID,
+		GroupID:  user.GroupID,
+		Subject:  subject,
+		Body:     body,
+		Contact:  contact,
+		CreateAt: cs.now(),
+	}
+	cs.tickets = append(cs.tickets, ticket)
+	return ticket, nil
+}
+
+// ListTickets 按创建顺序列出客服组的留言，groupID为空时返回全部留言
+func (cs *CustomerService) ListTickets(groupID string) []*Ticket {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	tickets := make([]*Ticket, 0)
+	for _, ticket := range cs.tickets {
+		if groupID == "" || ticket.GroupID == groupID {
+			tickets = append(tickets, ticket)
+		}
+	}
+	return tickets
+}
@@ -0,0 +1,50 @@
+package customer_service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_CreateTicket(t *testing.T) {
+	cs := NewCustomerService()
+	cs.CreateGroup("group1", "TestGroup")
+	cs.CreateGroup("group2", "OtherGroup")
+	cs.ConnectUser("user1", "TestUser", nil)
+	cs.ConnectUser("user2", "TestUser2", nil)
+
+	// 非营业时间排队失败后留言，留言归属请求的客服组
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Weekday()
+	cs.SetBusinessHours("group1", &BusinessHours{Days: []time.Weekday{tomorrow}})
+	assert.True(t, errors.Is(cs.EnqueueUser("user1", "group1"), ErrOutOfHours))
+
+	ticket, err := cs.CreateTicket("user1", "退款", "我想申请退款", "user1@example.com")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ticket.ID)
+	assert.Equal(t, "user1", ticket.UserID)
+	assert.Equal(t, "group1", ticket.GroupID)
+	assert.Equal(t, "退款", ticket.Subject)
+	assert.Equal(t, "我想申请退款", ticket.Body)
+	assert.Equal(t, "user1@example.com", ticket.Contact)
+	assert.NotZero(t, ticket.CreateAt)
+
+	cs.EnqueueUser("user2", "group2")
+	other, err := cs.CreateTicket("user2", "", "其他问题", "13800000000")
+	assert.NoError(t, err)
+
+	// 按组取出留言
+	assert.Equal(t, []*Ticket{ticket}, cs.ListTickets("group1"))
+	assert.Equal(t, []*Ticket{other}, cs.ListTickets("group2"))
+	assert.Equal(t, []*Ticket{ticket, other}, cs.ListTickets(""))
+	assert.Empty(t, cs.ListTickets("nonexistent"))
+
+	// 错误情况
+	_, err = cs.CreateTicket("nonexistent", "s", "b", "c")
+	assert.Equal(t, ErrUserNotFound, err)
+	_, err = cs.CreateTicket("user1", "s", "  ", "c")
+	assert.Equal(t, ErrEmptyContent, err)
+	_, err = cs.CreateTicket("user1", "s", "b", "")
+	assert.Equal(t, ErrInvalidTicket, err)
+}
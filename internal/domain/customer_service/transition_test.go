@@ -0,0 +1,44 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_TransitionTo(t *testing.T) {
+	session := &Session{Status: SessionStatusWaiting}
+	assert.Equal(t, ErrInvalidTransition, session.transitionTo(SessionStatusWaiting))
+
+	// 进行中与等待之间可以来回切换，用于重新排队
+	assert.NoError(t, session.transitionTo(SessionStatusActive))
+	assert.Equal(t, ErrInvalidTransition, session.transitionTo(SessionStatusActive))
+	assert.NoError(t, session.transitionTo(SessionStatusWaiting))
+	assert.NoError(t, session.transitionTo(SessionStatusActive))
+	assert.NoError(t, session.transitionTo(SessionStatusClosed))
+
+	// 关闭后只能重新打开回到等待状态
+	for _, status := range []SessionStatus{SessionStatusActive, SessionStatusClosed} {
+		assert.Equal(t, ErrInvalidTransition, session.transitionTo(status))
+		assert.Equal(t, SessionStatusClosed, session.Status)
+	}
+	assert.NoError(t, session.transitionTo(SessionStatusWaiting))
+}
+
+func TestCustomerService_ClosedSessionTransitions(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+
+	// 已关闭的会话不能重新排队、重新激活或再次关闭
+	cs.mu.Lock()
+	assert.Equal(t, ErrInvalidTransition, cs.requeueSessionLocked(session, ReasonStaffAway))
+	assert.Equal(t, ErrInvalidTransition, cs.attachSessionLocked(session, cs.users["user1"], cs.staffs["staff1"], ReasonAssigned))
+	assert.Equal(t, ErrInvalidTransition, cs.closeSessionLocked(session, ReasonClosedByUser))
+	cs.mu.Unlock()
+	assert.Equal(t, SessionStatusClosed, session.Status)
+	assert.Empty(t, cs.GetUser("user1").SessionID)
+	assert.Equal(t, ErrSessionClosed, cs.CloseSession(session.ID, "user1"))
+}
@@ -0,0 +1,51 @@
+package customer_service
+
+// Typing 输入状态通知，Draft为用户尚未发送的草稿，只在会话所属客服组开启草稿共享时携带，
+// 草稿不会保存为消息
+type Typing struct {
+	SessionID string `json:"session_id"`
+	FromID    string `json:"from_id"`
+	ToID      string `json:"to_id"`
+	Draft     string `json:"draft,omitempty"`
+}
+
+// SetShareDrafts 设置客服组是否向客服共享用户正在输入的草稿
+func (cs *CustomerService) SetShareDrafts(groupID string, on bool) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	group, exists := cs.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	group.ShareDrafts = on
+	return nil
+}
+
+// NotifyTyping 生成发给会话另一方的输入状态通知，客服的草稿和未开启共享时用户的草稿都会被丢弃
+func (cs *CustomerService) NotifyTyping(sessionID, fromID, draft string) (Typing, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return Typing{}, ErrSessionNotFound
+	}
+	if session.Status == SessionStatusClosed {
+		return Typing{}, ErrSessionClosed
+	}
+
+	typing := Typing{SessionID: sessionID, FromID: fromID}
+	switch fromID {
+	case session.UserID:
+		typing.ToID = session.StaffID
+		if group, exists := cs.groups[session.GroupID]; exists && group.ShareDrafts {
+			typing.Draft = draft
+		}
+	case session.StaffID:
+		typing.ToID = session.UserID
+	default:
+		return Typing{}, ErrInvalidOperation
+	}
+	return typing, nil
+}
@@ -0,0 +1,40 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_NotifyTyping(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	// 未开启草稿共享时只通知正在输入
+	typing, err := cs.NotifyTyping(session.ID, "user1", "我的订单")
+	assert.NoError(t, err)
+	assert.Equal(t, "staff1", typing.ToID)
+	assert.Empty(t, typing.Draft)
+
+	assert.NoError(t, cs.SetShareDrafts("group1", true))
+	typing, err = cs.NotifyTyping(session.ID, "user1", "我的订单")
+	assert.NoError(t, err)
+	assert.Equal(t, "我的订单", typing.Draft)
+
+	// 客服的草稿从不共享给用户
+	typing, err = cs.NotifyTyping(session.ID, "staff1", "您好")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", typing.ToID)
+	assert.Empty(t, typing.Draft)
+
+	// 草稿不保存为消息
+	assert.Empty(t, session.Messages)
+
+	_, err = cs.NotifyTyping(session.ID, "other", "")
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+	_, err = cs.NotifyTyping("missing", "user1", "")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	assert.ErrorIs(t, cs.SetShareDrafts("missing", true), ErrGroupNotFound)
+}
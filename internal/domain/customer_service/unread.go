@@ -0,0 +1,64 @@
+package customer_service
+
+// WithUnreadSystemMessages 设置系统消息是否计入未读数，默认不计入，
+// 避免“客服已加入”等提示让未读角标失真。无论是否计入，系统消息都照常投递
+func WithUnreadSystemMessages(count bool) Option {
+	return func(cs *CustomerService) {
+		cs.unreadSystem = count
+	}
+}
+
+// UnreadCount 获取参与者在会话中的未读消息数
+func (cs *CustomerService) UnreadCount(sessionID, readerID string) (int, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return 0, ErrSessionNotFound
+	}
+	if !session.isParticipant(readerID) {
+		return 0, ErrNotParticipant
+	}
+	return session.unread[readerID], nil
+}
+
+// MarkRead 参与者已读会话中的全部消息，未读数清零
+func (cs *CustomerService) MarkRead(sessionID, readerID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	session, exists := cs.sessions[sessionID]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if !session.isParticipant(readerID) {
+		return ErrNotParticipant
+	}
+	delete(session.unread, readerID)
+	return nil
+}
+
+// countUnreadLocked 为消息的接收者累加未读数，发送者本人不计，调用方需持有cs.mu
+func (cs *CustomerService) countUnreadLocked(session *Session, msg *Message) {
+	if msg.Type == MessageTypeSystem && !cs.unreadSystem {
+		return
+	}
+	if session.unread == nil {
+		session.unread = make(map[string]int)
+	}
+	if msg.ToID != "" {
+		session.unread[msg.ToID]++
+		return
+	}
+	for _, id := range []string{session.UserID, session.StaffID} {
+		if id != "" && id != msg.FromID {
+			session.unread[id]++
+		}
+	}
+	for id := range session.Supervisors {
+		if id != msg.FromID {
+			session.unread[id]++
+		}
+	}
+}
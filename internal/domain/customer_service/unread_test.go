@@ -0,0 +1,58 @@
+package customer_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// appendTestSystemMessage 向会话追加一条系统消息
+func appendTestSystemMessage(cs *CustomerService, session *Session, content string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.appendSystemMessageLocked(session, content)
+}
+
+func TestCustomerService_UnreadIgnoresSystemMessages(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(session.ID, "staff1", "hello", MessageTypeText)
+	appendTestSystemMessage(cs, session, "agent joined")
+	cs.SendMessage(session.ID, "staff1", "how can I help", MessageTypeText)
+	appendTestSystemMessage(cs, session, "agent is typing")
+	cs.SendMessage(session.ID, "user1", "hi", MessageTypeText)
+
+	// 系统消息照常写入会话，但不计入未读数
+	assert.Len(t, session.Messages, 5)
+	count, err := cs.UnreadCount(session.ID, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = cs.UnreadCount(session.ID, "staff1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, cs.MarkRead(session.ID, "user1"))
+	count, _ = cs.UnreadCount(session.ID, "user1")
+	assert.Equal(t, 0, count)
+
+	_, err = cs.UnreadCount(session.ID, "user2")
+	assert.Equal(t, ErrNotParticipant, err)
+	assert.Equal(t, ErrSessionNotFound, cs.MarkRead("missing", "user1"))
+}
+
+func TestCustomerService_UnreadCountsSystemMessages(t *testing.T) {
+	cs := NewCustomerService(WithUnreadSystemMessages(true))
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.SendMessage(session.ID, "staff1", "hello", MessageTypeText)
+	appendTestSystemMessage(cs, session, "agent joined")
+
+	count, err := cs.UnreadCount(session.ID, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, _ = cs.UnreadCount(session.ID, "staff1")
+	assert.Equal(t, 1, count)
+}
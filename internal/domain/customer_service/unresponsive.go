@@ -0,0 +1,26 @@
+package customer_service
+
+// MarkStaffUnresponsive 客服连接漏回pong时标记为推断离开：不再分配新会话，已有会话保持不变。
+// 收到该客服的pong或入站消息后自动恢复，返回false表示客服不存在或已标记
+func (cs *CustomerService) MarkStaffUnresponsive(staffID string) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists || staff.unresponsive {
+		return false
+	}
+	staff.unresponsive = true
+	return true
+}
+
+// recoverStaffLocked 清除客服的推断离开标记，并为组内排队用户重新分配，调用方需持有cs.mu
+func (cs *CustomerService) recoverStaffLocked(staff *CSStaff) {
+	if !staff.unresponsive {
+		return
+	}
+	staff.unresponsive = false
+	if staff.Status == UserStatusOnline {
+		cs.dispatchGroupLocked(staff.GroupID)
+	}
+}
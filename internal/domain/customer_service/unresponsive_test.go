@@ -0,0 +1,33 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_MarkStaffUnresponsive(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	cs.SetAutoAccept("staff1", true)
+	assert.True(t, cs.MarkStaffUnresponsive("staff1"))
+	assert.False(t, cs.MarkStaffUnresponsive("staff1"))
+	assert.False(t, cs.MarkStaffUnresponsive("nonexistent"))
+
+	// 推断离开的客服保留已有会话，但不再分配新会话
+	assert.Equal(t, PresenceAway, cs.Presence("staff1"))
+	assert.Equal(t, SessionStatusActive, session.Status)
+	assert.Equal(t, "staff1", session.StaffID)
+	cs.ConnectUser("user2", "TestUser", nil)
+	assert.NoError(t, cs.EnqueueUser("user2", "group1"))
+	assert.Equal(t, []string{"user2"}, cs.QueuedUsers("group1"))
+
+	// 收到pong后恢复，排队用户随即分配
+	cs.RecordRTT(PresenceRoleStaff, "staff1", 10*time.Millisecond)
+	assert.Equal(t, PresenceOnline, cs.Presence("staff1"))
+	assert.Empty(t, cs.QueuedUsers("group1"))
+	assert.Len(t, cs.GetStaff("staff1").Sessions, 2)
+}
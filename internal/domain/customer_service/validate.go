@@ -0,0 +1,59 @@
+package customer_service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidationRules 用户和客服连接时ID与名称的校验规则
+type ValidationRules struct {
+	MaxIDLength   int            // ID最大字符数，小于等于0时不限制
+	MaxNameLength int            // 名称最大字符数，小于等于0时不限制
+	IDPattern     *regexp.Regexp // ID允许的字符集，为空时只拒绝控制字符
+}
+
+// DefaultValidationRules 默认校验规则：ID由字母、数字和._:@-组成且不超过64个字符，名称不超过64个字符
+var DefaultValidationRules = ValidationRules{
+	MaxIDLength:   64,
+	MaxNameLength: 64,
+	IDPattern:     regexp.MustCompile(`^[A-Za-z0-9_.:@-]+$`),
+}
+
+// WithValidationRules 设置连接时ID与名称的校验规则
+func WithValidationRules(rules ValidationRules) Option {
+	return func(cs *CustomerService) {
+		cs.validation = rules
+	}
+}
+
+// ValidateIdentity 按校验规则检查ID和名称：去除首尾空白后不能为空，不能超长，不能包含控制字符，ID须符合字符集
+// 网关可以在升级WebSocket连接前调用，以便用HTTP状态码拒绝非法输入
+func (cs *CustomerService) ValidateIdentity(id, name string) error {
+	if err := validateField("id", id, cs.validation.MaxIDLength); err != nil {
+		return err
+	}
+	if cs.validation.IDPattern != nil && !cs.validation.IDPattern.MatchString(id) {
+		return NewServiceError(CodeInvalidIdentity, fmt.Sprintf("id %q contains characters outside %s", id, cs.validation.IDPattern))
+	}
+	return validateField("name", name, cs.validation.MaxNameLength)
+}
+
+// validateField 检查单个字段非空、不超长且不含控制字符
+func validateField(field, value string, maxLength int) error {
+	if strings.TrimSpace(value) == "" {
+		return NewServiceError(CodeInvalidIdentity, field+" must not be empty")
+	}
+	if maxLength > 0 && utf8.RuneCountInString(value) > maxLength {
+		return NewServiceError(CodeInvalidIdentity, fmt.Sprintf("%s exceeds %d characters", field, maxLength))
+	}
+	if !utf8.ValidString(value) {
+		return NewServiceError(CodeInvalidIdentity, field+" is not valid UTF-8")
+	}
+	if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		return NewServiceError(CodeInvalidIdentity, field+" must not contain control characters")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package customer_service
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_ValidateIdentity(t *testing.T) {
+	cs := NewCustomerService()
+	cs.CreateGroup("group1", "TestGroup")
+
+	tests := []struct {
+		name     string
+		id       string
+		userName string
+		message  string
+	}{
+		{"whitespace name", "user1", "   ", "name must not be empty"},
+		{"empty id", "", "TestUser", "id must not be empty"},
+		{"long id", strings.Repeat("u", 65), "TestUser", "id exceeds 64 characters"},
+		{"long name", "user1", strings.Repeat("名", 65), "name exceeds 64 characters"},
+		{"control in name", "user1", "Test\x07User", "name must not contain control characters"},
+		{"control in id", "user\n1", "TestUser", "id must not contain control characters"},
+		{"id charset", "user 1", "TestUser", "outside"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cs.ConnectUser(tt.id, tt.userName, nil)
+			assert.ErrorIs(t, err, ErrInvalidIdentity)
+			assert.Contains(t, err.Error(), tt.message)
+
+			_, err = cs.ConnectStaff(tt.id, tt.userName, "group1", nil)
+			assert.ErrorIs(t, err, ErrInvalidIdentity)
+		})
+	}
+	assert.Empty(t, cs.users)
+	assert.Empty(t, cs.staffs)
+
+	// 名称可以包含中文和空格
+	_, err := cs.ConnectUser("user-1@web", "测试 用户", nil)
+	assert.NoError(t, err)
+}
+
+func TestCustomerService_CustomValidationRules(t *testing.T) {
+	cs := NewCustomerService(WithValidationRules(ValidationRules{
+		MaxIDLength: 8,
+		IDPattern:   regexp.MustCompile(`^[0-9]+$`),
+	}))
+
+	_, err := cs.ConnectUser("123456789", "TestUser", nil)
+	assert.ErrorIs(t, err, ErrInvalidIdentity)
+	_, err = cs.ConnectUser("user1", "TestUser", nil)
+	assert.ErrorIs(t, err, ErrInvalidIdentity)
+	_, err = cs.ConnectUser("1001", strings.Repeat("n", 200), nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,36 @@
+package customer_service
+
+// SetVariable 设置会话自定义字段，供CRM等集成附加订单号、会员等级等上下文
+func (s *Session) SetVariable(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Variables == nil {
+		s.Variables = make(map[string]string)
+	}
+	s.Variables[key] = value
+}
+
+// GetVariable 获取会话自定义字段
+func (s *Session) GetVariable(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, exists := s.Variables[key]
+	return value, exists
+}
+
+// GetVariables 获取会话全部自定义字段的副本，没有字段时返回nil
+func (s *Session) GetVariables() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.Variables) == 0 {
+		return nil
+	}
+	variables := make(map[string]string, len(s.Variables))
+	for key, value := range s.Variables {
+		variables[key] = value
+	}
+	return variables
+}
@@ -0,0 +1,65 @@
+package customer_service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_VariablesConcurrent(t *testing.T) {
+	cs := NewCustomerService()
+	session := createTestSession(t, cs, "user1", "staff1")
+
+	_, exists := session.GetVariable("order_id")
+	assert.False(t, exists)
+	assert.Nil(t, session.GetVariables())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				session.SetVariable(fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", j))
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				session.GetVariable(fmt.Sprintf("key%d", i))
+				session.GetVariables()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	variables := session.GetVariables()
+	assert.Len(t, variables, 8)
+	value, exists := session.GetVariable("key3")
+	assert.True(t, exists)
+	assert.Equal(t, "value99", value)
+
+	// 返回的是副本，修改不影响会话
+	variables["key3"] = "changed"
+	value, _ = session.GetVariable("key3")
+	assert.Equal(t, "value99", value)
+}
+
+func TestCustomerService_SnapshotVariables(t *testing.T) {
+	cs := NewCustomerService()
+	session := createTestSession(t, cs, "user1", "staff1")
+	session.SetVariable("tier", "gold")
+
+	snapshots := cs.Snapshot()
+	if assert.Len(t, snapshots, 1) {
+		assert.Equal(t, map[string]string{"tier": "gold"}, snapshots[0].Variables)
+	}
+
+	restored := NewCustomerService()
+	assert.NoError(t, restored.RestoreFrom(snapshots))
+	value, exists := restored.GetSession(session.ID).GetVariable("tier")
+	assert.True(t, exists)
+	assert.Equal(t, "gold", value)
+}
@@ -0,0 +1,63 @@
+package customer_service
+
+import "time"
+
+// SetWrapUpDuration 设置会话结束后客服的整理时长，为0时不进入整理状态
+func (cs *CustomerService) SetWrapUpDuration(d time.Duration) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.wrapUpDuration = d
+}
+
+// EndWrapUp 客服提前结束整理状态
+func (cs *CustomerService) EndWrapUp(staffID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	staff, exists := cs.staffs[staffID]
+	if !exists {
+		return ErrStaffNotFound
+	}
+	if staff.Status != UserStatusWrapUp {
+		return ErrInvalidOperation
+	}
+	cs.endWrapUpLocked(staff)
+	return nil
+}
+
+// startWrapUpLocked 使在线客服进入整理状态并在整理时长后自动恢复在线，整理中再结束会话时重新计时。
+// 离开或离线的客服保持原状态，调用方需持有cs.mu
+func (cs *CustomerService) startWrapUpLocked(staff *CSStaff) {
+	if cs.wrapUpDuration <= 0 || (staff.Status != UserStatusOnline && staff.Status != UserStatusWrapUp) {
+		return
+	}
+
+	cs.setStaffStatusLocked(staff, UserStatusWrapUp)
+	if staff.wrapUpTimer != nil {
+		staff.wrapUpTimer.Stop()
+	}
+	staffID := staff.ID
+	staff.wrapUpTimer = cs.afterFunc(cs.wrapUpDuration, func() {
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		// 客服可能已断开并重新连接，只处理仍处于整理状态的同一客服
+		if current, exists := cs.staffs[staffID]; exists && current == staff && staff.Status == UserStatusWrapUp {
+			cs.endWrapUpLocked(staff)
+		}
+	})
+}
+
+// endWrapUpLocked 结束整理状态，并为组内排队用户重新分配，调用方需持有cs.mu
+func (cs *CustomerService) endWrapUpLocked(staff *CSStaff) {
+	cs.cancelWrapUpLocked(staff)
+	cs.dispatchGroupLocked(staff.GroupID)
+}
+
+// cancelWrapUpLocked 停止整理计时并恢复在线，不为排队用户分配，调用方需持有cs.mu
+func (cs *CustomerService) cancelWrapUpLocked(staff *CSStaff) {
+	if staff.wrapUpTimer != nil {
+		staff.wrapUpTimer.Stop()
+		staff.wrapUpTimer = nil
+	}
+	cs.setStaffStatusLocked(staff, UserStatusOnline)
+}
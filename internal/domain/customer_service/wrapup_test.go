@@ -0,0 +1,90 @@
+package customer_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomerService_WrapUp(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+	_, _, offers := recordSessionEvents(cs)
+	cs.SetWrapUpDuration(time.Minute)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	staff := cs.GetStaff("staff1")
+
+	// 会话关闭后客服进入整理状态
+	assert.Equal(t, ErrInvalidOperation, cs.CloseSession(session.ID, "nonexistent"))
+	assert.NoError(t, cs.CloseSession(session.ID, "staff1"))
+	assert.Equal(t, ErrSessionClosed, cs.CloseSession(session.ID, "staff1"))
+	assert.Equal(t, UserStatusWrapUp, cs.GetStaff("staff1").Status)
+
+	// 整理期间不分配新会话
+	cs.ConnectUser("user2", "TestUser2", nil)
+	assert.NoError(t, cs.EnqueueUser("user2", "group1"))
+	_, err := cs.OfferSession("staff1", "user2")
+	assert.Equal(t, ErrStaffUnavailable, err)
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, UserStatusWrapUp, cs.GetStaff("staff1").Status)
+	cs.mu.RLock()
+	assert.Equal(t, 0, cs.pendingOffersLocked("staff1"))
+	cs.mu.RUnlock()
+	assert.Empty(t, staff.Sessions)
+
+	// 整理时长结束后自动恢复在线，并收到排队用户的邀请
+	clock.Advance(time.Second)
+	offer := receiveOffer(t, offers)
+	assert.Equal(t, "staff1", offer.StaffID)
+	assert.Equal(t, "user2", offer.UserID)
+	cs.mu.RLock()
+	assert.Equal(t, UserStatusOnline, staff.Status)
+	cs.mu.RUnlock()
+}
+
+func TestCustomerService_EndWrapUp(t *testing.T) {
+	cs := NewCustomerService()
+	defer cs.Shutdown()
+	cs.SetWrapUpDuration(time.Hour)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	assert.Equal(t, ErrInvalidOperation, cs.EndWrapUp("staff1"))
+
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+	assert.Equal(t, UserStatusWrapUp, cs.GetStaff("staff1").Status)
+
+	// 客服提前结束整理
+	assert.NoError(t, cs.EndWrapUp("staff1"))
+	assert.Equal(t, UserStatusOnline, cs.GetStaff("staff1").Status)
+	assert.Equal(t, ErrStaffNotFound, cs.EndWrapUp("nonexistent"))
+
+	// 未设置整理时长时不进入整理状态
+	cs.SetWrapUpDuration(0)
+	session = createTestSession(t, cs, "user2", "staff1")
+	assert.NoError(t, cs.CloseSession(session.ID, "staff1"))
+	assert.Equal(t, UserStatusOnline, cs.GetStaff("staff1").Status)
+}
+
+func TestCustomerService_WrapUpWhileAway(t *testing.T) {
+	clock := newFakeClock()
+	cs := NewCustomerService(WithClock(clock))
+	defer cs.Shutdown()
+	cs.SetWrapUpDuration(time.Minute)
+
+	session := createTestSession(t, cs, "user1", "staff1")
+	assert.NoError(t, cs.SetStaffStatus("staff1", UserStatusAway))
+	cs.ConnectUser("user2", "TestUser2", nil)
+	assert.NoError(t, cs.EnqueueUser("user2", "group1"))
+
+	// 离开状态下结束会话不进入整理，整理时长过后也不会被置为在线并分配新会话
+	assert.NoError(t, cs.CloseSession(session.ID, "user1"))
+	assert.Equal(t, UserStatusAway, cs.GetStaff("staff1").Status)
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, UserStatusAway, cs.GetStaff("staff1").Status)
+	cs.mu.RLock()
+	assert.Equal(t, 0, cs.pendingOffersLocked("staff1"))
+	cs.mu.RUnlock()
+}
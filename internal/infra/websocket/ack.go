@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"clash/internal/domain/customer_service"
+)
+
+// pendingAck 等待接收方确认的一次投递
+type pendingAck struct {
+	message     *customer_service.Message
+	dto         MessageDTO // 首次投递时的下发内容，重发时原样使用
+	recipientID string
+	resends     int
+	timer       *time.Timer
+}
+
+// ackTracker 跟踪已转发但尚未收到客户端ack的消息，超时后重发，重发次数用尽后放弃
+type ackTracker struct {
+	timeout    time.Duration
+	maxResends int
+	pending    map[string]*pendingAck // 键为接收者ID和消息ID
+	mu         sync.Mutex
+}
+
+func newAckTracker(timeout time.Duration, maxResends int) *ackTracker {
+	return &ackTracker{
+		timeout:    timeout,
+		maxResends: maxResends,
+		pending:    make(map[string]*pendingAck),
+	}
+}
+
+func ackKey(recipientID, messageID string) string {
+	return recipientID + "/" + messageID
+}
+
+// track 开始等待接收方对消息的确认，超时时调用onTimeout
+func (t *ackTracker) track(recipientID string, message *customer_service.Message, dto MessageDTO, onTimeout func(*pendingAck)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := ackKey(recipientID, message.ID)
+	if p, exists := t.pending[key]; exists {
+		p.timer.Stop()
+	}
+	p := &pendingAck{message: message, dto: dto, recipientID: recipientID}
+	p.timer = time.AfterFunc(t.timeout, func() { onTimeout(p) })
+	t.pending[key] = p
+}
+
+// ack 接收方确认收到消息，返回是否有对应的等待记录
+func (t *ackTracker) ack(recipientID, messageID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := ackKey(recipientID, messageID)
+	p, exists := t.pending[key]
+	if !exists {
+		return false
+	}
+	p.timer.Stop()
+	delete(t.pending, key)
+	return true
+}
+
+// retry 超时后决定如何处理：仍可重发时重新计时并返回resend，重发次数用尽时移除记录并返回exhausted，
+// 期间已确认时两者都为false
+func (t *ackTracker) retry(p *pendingAck) (resend, exhausted bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := ackKey(p.recipientID, p.message.ID)
+	if t.pending[key] != p {
+		return false, false
+	}
+	if p.resends >= t.maxResends {
+		delete(t.pending, key)
+		return false, true
+	}
+	p.resends++
+	p.timer.Reset(t.timeout)
+	return true, false
+}
+
+// stop 停止全部计时，不再重发
+func (t *ackTracker) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, p := range t.pending {
+		p.timer.Stop()
+		delete(t.pending, key)
+	}
+}
+
+// trackDelivery 开启确认机制时记录一次投递，未开启时不做处理
+func (g *MessageGateway) trackDelivery(recipientID string, message *customer_service.Message, dto MessageDTO) {
+	if g.acks == nil {
+		return
+	}
+	g.acks.track(recipientID, message, dto, g.handleAckTimeout)
+}
+
+// handleAckTimeout 确认超时后重发消息；重发次数用尽后标记消息未送达并通知发送者
+func (g *MessageGateway) handleAckTimeout(p *pendingAck) {
+	resend, exhausted := g.acks.retry(p)
+	if resend {
+		g.writeJSON(g.participantConn(p.recipientID), "message", p.dto)
+		return
+	}
+	if !exhausted {
+		return
+	}
+
+	if err := g.service.MarkUndelivered(p.message.SessionID, p.message.ID); err != nil {
+		log.Printf("Error marking message %s undelivered: %v", p.message.ID, err)
+	}
+	g.writeJSON(g.participantConn(p.message.FromID), "message_undelivered", map[string]string{
+		"session_id": p.message.SessionID,
+		"message_id": p.message.ID,
+		"to_id":      p.recipientID,
+	})
+}
+
+// handleAck 处理客户端对消息的确认
+func (g *MessageGateway) handleAck(recipientID string, payload json.RawMessage) {
+	if g.acks == nil {
+		return
+	}
+	var ack struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := g.decodePayload(payload, &ack); err != nil {
+		log.Printf("Error parsing ack payload: %v", err)
+		return
+	}
+	g.acks.ack(recipientID, ack.MessageID)
+}
@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// pendingAcks 返回网关中等待确认的投递数
+func pendingAcks(gateway *MessageGateway) int {
+	gateway.acks.mu.Lock()
+	defer gateway.acks.mu.Unlock()
+	return len(gateway.acks.pending)
+}
+
+func TestMessageGateway_AckResendThenUndelivered(t *testing.T) {
+	gateway, server := newTestGateway(t, WithAckTimeout(50*time.Millisecond, 2))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 用户不回复ack：首次投递后重发2次，内容和消息ID相同
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","content":"hello"}`)
+	var messageID string
+	for i := 0; i < 3; i++ {
+		received := readTestMessage(t, userConn)
+		assert.Equal(t, "message", received["type"])
+		payload := received["payload"].(map[string]interface{})
+		assert.Equal(t, "hello", payload["content"])
+		if messageID == "" {
+			messageID = payload["id"].(string)
+		}
+		assert.Equal(t, messageID, payload["id"])
+	}
+
+	// 重发次数用尽后通知发送者，消息标记为未送达
+	undelivered := readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "message_undelivered", undelivered["type"])
+	assert.Equal(t, map[string]interface{}{
+		"session_id": session.ID,
+		"message_id": messageID,
+		"to_id":      "user1",
+	}, undelivered["payload"])
+	msg, err := gateway.service.LastMessage(session.ID)
+	assert.NoError(t, err)
+	assert.True(t, msg.Undelivered)
+	assert.Equal(t, MessageStatusUndelivered, newMessageDTO(msg).Status)
+}
+
+func TestMessageGateway_AckStopsResend(t *testing.T) {
+	gateway, server := newTestGateway(t, WithAckTimeout(time.Minute, 2))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","content":"hello"}`)
+	received := readTestMessage(t, userConn)
+	messageID := received["payload"].(map[string]interface{})["id"].(string)
+	writeTestMessage(t, userConn, "ack", `{"message_id":"`+messageID+`"}`)
+
+	// 确认后不再等待重发，消息保持已发送状态
+	assert.Eventually(t, func() bool {
+		return pendingAcks(gateway) == 0
+	}, time.Second, 5*time.Millisecond)
+	msg, err := gateway.service.LastMessage(session.ID)
+	assert.NoError(t, err)
+	assert.False(t, msg.Undelivered)
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","content":"next"}`)
+	assert.Equal(t, "next", readTestMessage(t, userConn)["payload"].(map[string]interface{})["content"])
+}
+
+func TestMessageGateway_SupervisorAckStrictFields(t *testing.T) {
+	gateway, server := newTestGateway(t, WithAckTimeout(time.Minute, 1), WithStrictFields(true))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	supervisorConn := dialTestGateway(t, server, "/supervisor?supervisor_id=sup1")
+	defer supervisorConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil &&
+			gateway.service.GetSupervisor("sup1") != nil
+	}, time.Second, 10*time.Millisecond)
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	assert.NoError(t, gateway.service.JoinSession(session.ID, "sup1"))
+
+	// 严格模式下主管的ack同样被接受，不再重发
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","content":"hello"}`)
+	for _, conn := range []*websocket.Conn{userConn, supervisorConn} {
+		received := readTestMessageExcept(t, conn, "presence")
+		assert.Equal(t, "message", received["type"])
+		messageID := received["payload"].(map[string]interface{})["id"].(string)
+		writeTestMessage(t, conn, "ack", `{"message_id":"`+messageID+`"}`)
+	}
+
+	assert.Eventually(t, func() bool {
+		return pendingAcks(gateway) == 0
+	}, time.Second, 5*time.Millisecond)
+	msg, err := gateway.service.LastMessage(session.ID)
+	assert.NoError(t, err)
+	assert.False(t, msg.Undelivered)
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","content":"next"}`)
+	next := readTestMessageExcept(t, supervisorConn, "presence")
+	assert.Equal(t, "message", next["type"])
+	assert.Equal(t, "next", next["payload"].(map[string]interface{})["content"])
+}
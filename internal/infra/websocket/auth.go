@@ -0,0 +1,12 @@
+package websocket
+
+import "net/http"
+
+// SupervisorAuthorizer 校验主管连接请求，返回false时以403拒绝连接。
+// 主管可以订阅全部上下线事件并加入任意会话，生产环境应据请求中的认证信息确认其身份
+type SupervisorAuthorizer func(r *http.Request, supervisorID string) bool
+
+// authorizeSupervisor 校验主管连接请求，未配置校验函数时全部放行
+func (g *MessageGateway) authorizeSupervisor(r *http.Request, supervisorID string) bool {
+	return g.supervisorAuth == nil || g.supervisorAuth(r, supervisorID)
+}
@@ -0,0 +1,57 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageGateway_SupervisorAuthorizer(t *testing.T) {
+	gateway, server := newTestGateway(t,
+		WithSupervisorAuthorizer(func(r *http.Request, supervisorID string) bool {
+			return r.Header.Get("Authorization") == "Bearer "+supervisorID
+		}),
+		WithServiceOptions(customer_service.WithMaxConnectionsPerIdentity(1)),
+	)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/supervisor?supervisor_id=sup1"
+
+	// 未通过校验的请求以403拒绝，不登记主管
+	for _, header := range []http.Header{nil, {"Authorization": {"Bearer sup2"}}} {
+		_, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if assert.Error(t, err) && assert.NotNil(t, resp) {
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		}
+	}
+	assert.Nil(t, gateway.service.GetSupervisor("sup1"))
+
+	// 通过校验后正常连接
+	header := http.Header{"Authorization": {"Bearer sup1"}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetSupervisor("sup1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 与客服相同的按身份连接数上限
+	_, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if assert.Error(t, err) && assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	}
+	assert.Equal(t, 1, gateway.service.ConnectionCount(customer_service.PresenceRoleSupervisor, "sup1"))
+
+	// 连接断开后释放名额
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.ConnectionCount(customer_service.PresenceRoleSupervisor, "sup1") == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,206 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// BusMessage 网关实例之间转发的消息，ToID为接收者的用户、客服或主管ID
+type BusMessage struct {
+	Origin  string          `json:"origin"` // 发布消息的网关实例ID，实例不处理自己发布的消息
+	ToID    string          `json:"to_id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// MessageBus 网关实例之间的发布订阅通道。各实例经总线通告本实例上连接的参与者，
+// 接收者在其他实例上连接时，消息经总线发给该实例投递。
+// 进程内可以使用MemoryBus，跨进程部署可以基于Redis等实现该接口
+type MessageBus interface {
+	// Publish 向全部订阅者广播消息
+	Publish(msg BusMessage) error
+	// Subscribe 注册消息处理函数，返回取消订阅的函数
+	Subscribe(handler func(BusMessage)) (unsubscribe func())
+}
+
+// MemoryBus 进程内的消息总线，同一进程中的多个网关实例共享
+type MemoryBus struct {
+	handlers map[int]func(BusMessage)
+	nextID   int
+	mu       sync.RWMutex
+}
+
+// NewMemoryBus 创建进程内消息总线
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{handlers: make(map[int]func(BusMessage))}
+}
+
+// Publish 在调用方协程中依次调用订阅者，处理函数中可以再次发布消息
+func (b *MemoryBus) Publish(msg BusMessage) error {
+	b.mu.RLock()
+	handlers := make([]func(BusMessage), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+// Subscribe 注册消息处理函数
+func (b *MemoryBus) Subscribe(handler func(BusMessage)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// 网关实例之间的内部消息类型，不会写给客户端
+const (
+	busTypePresence = "bus.presence" // 参与者在发布消息的实例上连接或断开，ToID为参与者ID
+	busTypeSync     = "bus.sync"     // 新加入的实例请求其他实例重新通告各自的在线参与者
+)
+
+// busPresence 参与者在实例上的在线状态
+type busPresence struct {
+	Online bool `json:"online"`
+}
+
+// newInstanceID 生成网关实例ID
+func newInstanceID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("websocket: read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// isLocal 判断连接是否由本实例持有
+func (g *MessageGateway) isLocal(conn *websocket.Conn) bool {
+	if conn == nil {
+		return false
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, exists := g.senders[conn]
+	return exists
+}
+
+// deliverTo 向参与者发送一条网关消息。参与者在本实例上连接时直接写出，在其他实例上连接时经总线交给该实例，
+// 都不是时（如重连宽限期内）不发送，由调用方决定是否转入离线队列。返回是否在本实例写出
+func (g *MessageGateway) deliverTo(id, msgType string, payload interface{}) bool {
+	conn := g.participantConn(id)
+	if g.isLocal(conn) {
+		g.writeJSON(conn, msgType, payload)
+		return true
+	}
+	if g.remoteInstance(id) == "" {
+		return false
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding %s for bus: %v", msgType, err)
+		return false
+	}
+	// 经接收者的发布队列异步发布，不让总线的延迟或故障阻塞调用方
+	g.busRetry.publish(g.bus, BusMessage{Origin: g.instanceID, ToID: id, Type: msgType, Payload: data})
+	return false
+}
+
+// handleBusMessage 处理其他实例经总线发来的消息：记录参与者的在线通告，响应新实例的同步请求，
+// 其余消息写给本实例上连接的接收者，接收者不在本实例上连接时忽略
+func (g *MessageGateway) handleBusMessage(msg BusMessage) {
+	if msg.Origin == g.instanceID {
+		return
+	}
+	switch msg.Type {
+	case busTypePresence:
+		var presence busPresence
+		if err := json.Unmarshal(msg.Payload, &presence); err != nil {
+			log.Printf("Error decoding presence from bus: %v", err)
+			return
+		}
+		g.presenceMu.Lock()
+		if presence.Online {
+			g.remoteIDs[msg.ToID] = msg.Origin
+		} else if g.remoteIDs[msg.ToID] == msg.Origin {
+			delete(g.remoteIDs, msg.ToID)
+		}
+		g.presenceMu.Unlock()
+
+	case busTypeSync:
+		g.presenceMu.Lock()
+		ids := make([]string, 0, len(g.localIDs))
+		for id := range g.localIDs {
+			ids = append(ids, id)
+		}
+		g.presenceMu.Unlock()
+		for _, id := range ids {
+			g.publishPresence(id, true)
+		}
+
+	default:
+		if conn := g.participantConn(msg.ToID); g.isLocal(conn) {
+			g.writeJSON(conn, msg.Type, msg.Payload)
+		}
+	}
+}
+
+// trackLocal 登记参与者在本实例上的一个连接，参与者在本实例上的首个连接建立和最后一个连接断开时经总线通告其他实例。
+// 返回连接断开时调用的注销函数，未配置总线时不做任何事
+func (g *MessageGateway) trackLocal(id string) (untrack func()) {
+	if g.bus == nil {
+		return func() {}
+	}
+	g.presenceMu.Lock()
+	g.localIDs[id]++
+	first := g.localIDs[id] == 1
+	g.presenceMu.Unlock()
+	if first {
+		g.publishPresence(id, true)
+	}
+
+	return func() {
+		g.presenceMu.Lock()
+		g.localIDs[id]--
+		last := g.localIDs[id] <= 0
+		if last {
+			delete(g.localIDs, id)
+		}
+		g.presenceMu.Unlock()
+		if last {
+			g.publishPresence(id, false)
+		}
+	}
+}
+
+// publishPresence 经总线通告参与者在本实例上的在线状态，不持有锁发布，避免与其他实例的处理函数互相等待
+func (g *MessageGateway) publishPresence(id string, online bool) {
+	data, _ := json.Marshal(busPresence{Online: online})
+	if err := g.bus.Publish(BusMessage{Origin: g.instanceID, ToID: id, Type: busTypePresence, Payload: data}); err != nil {
+		log.Printf("Error publishing presence of %s to bus: %v", id, err)
+	}
+}
+
+// remoteInstance 返回参与者所在的其他实例，参与者不在其他实例上连接或未配置总线时返回空串
+func (g *MessageGateway) remoteInstance(id string) string {
+	g.presenceMu.Lock()
+	defer g.presenceMu.Unlock()
+	return g.remoteIDs[id]
+}
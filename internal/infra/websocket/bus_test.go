@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newBusTestServer 为网关创建只处理用户和客服连接的测试服务器
+func newBusTestServer(gateway *MessageGateway) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/user") {
+			gateway.HandleUserConnection(w, r)
+		} else if strings.Contains(r.URL.Path, "/staff") {
+			gateway.HandleStaffConnection(w, r)
+		}
+	}))
+}
+
+func TestMessageGateway_BusRouting(t *testing.T) {
+	bus := NewMemoryBus()
+	published := recordBus(bus)
+	gatewayA := NewMessageGateway(WithMessageBus(bus))
+	defer gatewayA.Shutdown()
+	gatewayB := NewMessageGateway(WithMessageBus(bus))
+	defer gatewayB.Shutdown()
+	assert.NotSame(t, gatewayA.service, gatewayB.service)
+	serverA, serverB := newBusTestServer(gatewayA), newBusTestServer(gatewayB)
+	defer serverA.Close()
+	defer serverB.Close()
+
+	// 客服连接实例A，用户连接实例B，两个实例经总线得知对方的在线参与者
+	gatewayA.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, serverA, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, serverB, "/user?user_id=user1&name=用户1")
+	assert.Eventually(t, func() bool {
+		return gatewayA.remoteInstance("user1") == gatewayB.instanceID && gatewayB.remoteInstance("staff1") == gatewayA.instanceID
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, gatewayA.service.GetUser("user1"))
+
+	// 双向消息按在线通告跨实例投递
+	assert.False(t, gatewayA.deliverTo("user1", "notice", map[string]string{"text": "hello from A"}))
+	received := readTestMessage(t, userConn)
+	assert.Equal(t, "notice", received["type"])
+	assert.Equal(t, "hello from A", received["payload"].(map[string]interface{})["text"])
+	assert.False(t, gatewayB.deliverTo("staff1", "notice", map[string]string{"text": "hello from B"}))
+	received = readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "notice", received["type"])
+	assert.Equal(t, "hello from B", received["payload"].(map[string]interface{})["text"])
+
+	// 后加入的实例同步已有实例的在线参与者
+	gatewayC := NewMessageGateway(WithMessageBus(bus))
+	defer gatewayC.Shutdown()
+	assert.Equal(t, gatewayB.instanceID, gatewayC.remoteInstance("user1"))
+	assert.Equal(t, gatewayA.instanceID, gatewayC.remoteInstance("staff1"))
+
+	// 每个实例只处理自己服务的事件，其他实例创建后实例A仍收到本服务的邀请
+	gatewayA.service.ConnectUser("user2", "用户2", nil)
+	assert.NoError(t, gatewayA.service.EnqueueUser("user2", "group1"))
+	assert.Equal(t, customer_service.EventSessionOffer, readTestMessageExcept(t, staffConn, "presence")["type"])
+
+	// 用户断开后不再经总线发送
+	userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gatewayA.remoteInstance("user1") == ""
+	}, time.Second, 10*time.Millisecond)
+	before := len(published())
+	assert.False(t, gatewayA.deliverTo("user1", "notice", nil))
+	assert.Len(t, published(), before)
+}
+
+func TestMessageGateway_BusQueuesOffline(t *testing.T) {
+	bus := NewMemoryBus()
+	gateway := NewMessageGateway(
+		WithMessageBus(bus),
+		WithServiceOptions(customer_service.WithReconnectGrace(time.Minute), customer_service.WithOfflineQueue(10)),
+	)
+	defer gateway.Shutdown()
+	other := NewMessageGateway(WithMessageBus(bus))
+	defer other.Shutdown()
+	server := newBusTestServer(gateway)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 用户断线进入重连宽限期，没有任何实例通告其在线
+	userConn.Close()
+	assert.Eventually(t, func() bool {
+		user := gateway.service.GetUser("user1")
+		return user != nil && user.Status == customer_service.UserStatusOffline
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, other.remoteInstance("user1"))
+
+	// 客服发出的消息存入离线队列，不因配置了总线而丢失
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","content":"are you there?"}`)
+	var queued []*customer_service.Message
+	assert.Eventually(t, func() bool {
+		msgs, err := gateway.service.TakeOfflineMessages("user1")
+		assert.NoError(t, err)
+		queued = append(queued, msgs...)
+		return len(queued) > 0
+	}, time.Second, 10*time.Millisecond)
+	if assert.Len(t, queued, 1) {
+		assert.Equal(t, "are you there?", queued[0].Content)
+	}
+}
+
+func TestMemoryBus_Unsubscribe(t *testing.T) {
+	bus := NewMemoryBus()
+	var received []string
+	unsubscribe := bus.Subscribe(func(msg BusMessage) {
+		received = append(received, msg.Type)
+	})
+	assert.NoError(t, bus.Publish(BusMessage{Type: "first"}))
+	unsubscribe()
+	assert.NoError(t, bus.Publish(BusMessage{Type: "second"}))
+	assert.Equal(t, []string{"first"}, received)
+}
@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+)
+
+// 服务端主动关闭连接的原因
+const (
+	CloseReasonShutdown     = "shutdown"      // 服务停机
+	CloseReasonOverload     = "overload"      // 连接数已满
+	CloseReasonRateLimited  = "rate_limited"  // 请求过于频繁
+	CloseReasonKicked       = "kicked"        // 被管理员或同一身份的新连接踢下线
+	CloseReasonGroupDeleted = "group_deleted" // 客服所属组被删除且没有可移入的默认组
+)
+
+// closeWriteWait 发送关闭帧的超时时间
+const closeWriteWait = time.Second
+
+// closeCodes 各关闭原因对应的WebSocket关闭码
+var closeCodes = map[string]int{
+	CloseReasonShutdown:     websocket.CloseGoingAway,
+	CloseReasonOverload:     websocket.CloseTryAgainLater,
+	CloseReasonRateLimited:  websocket.ClosePolicyViolation,
+	CloseReasonKicked:       websocket.ClosePolicyViolation,
+	CloseReasonGroupDeleted: websocket.CloseNormalClosure,
+}
+
+// defaultRetryAfter 各关闭原因默认建议客户端重连前等待的时间，未列出的原因不建议自动重连
+var defaultRetryAfter = map[string]time.Duration{
+	CloseReasonShutdown:    5 * time.Second,
+	CloseReasonOverload:    capacityRetryAfter * time.Second,
+	CloseReasonRateLimited: 10 * time.Second,
+}
+
+// CloseReason 关闭帧中以JSON携带的结构化原因
+type CloseReason struct {
+	Reason     string `json:"reason"`
+	RetryAfter int    `json:"retry_after,omitempty"` // 建议重连前等待的秒数，为0表示不应自动重连
+}
+
+// disconnectReason 根据读循环的错误推断断开原因：正常关闭码视为主动退出，立即关闭会话；
+// going away表示页面关闭或跳转，视为客户端关闭；其余关闭码和网络错误视为异常断开，保留宽限期并重新排队
+func disconnectReason(err error) customer_service.DisconnectReason {
+	switch {
+	case websocket.IsCloseError(err, websocket.CloseNormalClosure):
+		return customer_service.DisconnectLogout
+	case websocket.IsCloseError(err, websocket.CloseGoingAway):
+		return customer_service.DisconnectClientClose
+	}
+	return customer_service.DisconnectError
+}
+
+// logReadError 记录读循环退出的原因，客户端正常关闭只记录关闭，异常断开和网络错误按错误记录
+func logReadError(role customer_service.PresenceRole, id string, err error) {
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		log.Printf("Connection closed by %s %s: %v", role, id, err)
+		return
+	}
+	log.Printf("Error reading message from %s %s: %v", role, id, err)
+}
+
+// CloseConnection 写完已入队的消息后发送携带原因和重连提示的关闭帧，然后关闭连接
+func (g *MessageGateway) CloseConnection(conn *websocket.Conn, reason string) {
+	if conn == nil {
+		return
+	}
+	g.closeSender(conn)
+
+	code, exists := closeCodes[reason]
+	if !exists {
+		code = websocket.CloseNormalClosure
+	}
+	text, _ := json.Marshal(CloseReason{
+		Reason:     reason,
+		RetryAfter: int(g.retryAfter[reason] / time.Second),
+	})
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, string(text)), time.Now().Add(closeWriteWait))
+	conn.Close()
+}
+
+// admitConn 检查连接数上限，超出时以overload关闭新连接并返回false
+func (g *MessageGateway) admitConn(conn *websocket.Conn) bool {
+	if g.maxConnections <= 0 {
+		return true
+	}
+	g.mu.RLock()
+	count := len(g.senders)
+	g.mu.RUnlock()
+	if count <= g.maxConnections {
+		return true
+	}
+	g.CloseConnection(conn, CloseReasonOverload)
+	return false
+}
+
+// Shutdown 以shutdown原因关闭全部连接并停止客服系统服务
+func (g *MessageGateway) Shutdown() error {
+	g.mu.RLock()
+	conns := make([]*websocket.Conn, 0, len(g.senders))
+	for conn := range g.senders {
+		conns = append(conns, conn)
+	}
+	g.mu.RUnlock()
+
+	if g.acks != nil {
+		g.acks.stop()
+	}
+	if g.unsubscribe != nil {
+		g.unsubscribe()
+	}
+	if g.busRetry != nil {
+		g.busRetry.shutdown()
+	}
+	for _, conn := range conns {
+		g.CloseConnection(conn, CloseReasonShutdown)
+	}
+	return g.service.Shutdown()
+}
@@ -0,0 +1,135 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// readCloseReason 读取到关闭帧为止，返回关闭码和结构化原因
+func readCloseReason(t *testing.T, conn *websocket.Conn) (int, CloseReason) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatalf("expected close frame, got %v", err)
+		}
+		var reason CloseReason
+		assert.NoError(t, json.Unmarshal([]byte(closeErr.Text), &reason))
+		return closeErr.Code, reason
+	}
+}
+
+func TestMessageGateway_OverloadClose(t *testing.T) {
+	gateway, server := newTestGateway(t, WithMaxConnections(1), WithRetryAfter(CloseReasonOverload, 30*time.Second))
+	defer server.Close()
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 超出连接数上限的新连接收到overload关闭帧和重连提示
+	extraConn := dialTestGateway(t, server, "/user?user_id=user2&name=用户2")
+	defer extraConn.Close()
+	code, reason := readCloseReason(t, extraConn)
+	assert.Equal(t, websocket.CloseTryAgainLater, code)
+	assert.Equal(t, CloseReasonOverload, reason.Reason)
+	assert.Equal(t, 30, reason.RetryAfter)
+	assert.Nil(t, gateway.service.GetUser("user2"))
+}
+
+func TestMessageGateway_ShutdownClose(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, gateway.Shutdown())
+	code, reason := readCloseReason(t, staffConn)
+	assert.Equal(t, websocket.CloseGoingAway, code)
+	assert.Equal(t, CloseReason{Reason: CloseReasonShutdown, RetryAfter: 5}, reason)
+}
+
+func TestMessageGateway_GroupDeletedClose(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 没有默认组可移入时，客服先收到变更通知，再收到group_deleted关闭帧
+	assert.NoError(t, gateway.service.ForceDeleteGroup("group1"))
+	changed := readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "staff_group_changed", changed["type"])
+	assert.Equal(t, "group1", changed["payload"].(map[string]interface{})["old_group_id"])
+	code, reason := readCloseReason(t, staffConn)
+	assert.Equal(t, websocket.CloseNormalClosure, code)
+	assert.Equal(t, CloseReason{Reason: CloseReasonGroupDeleted}, reason)
+}
+
+func TestDisconnectReason(t *testing.T) {
+	assert.Equal(t, customer_service.DisconnectLogout, disconnectReason(&websocket.CloseError{Code: websocket.CloseNormalClosure}))
+	assert.Equal(t, customer_service.DisconnectClientClose, disconnectReason(&websocket.CloseError{Code: websocket.CloseGoingAway}))
+	assert.Equal(t, customer_service.DisconnectError, disconnectReason(&websocket.CloseError{Code: websocket.CloseAbnormalClosure}))
+	assert.Equal(t, customer_service.DisconnectError, disconnectReason(errors.New("connection reset by peer")))
+}
+
+func TestMessageGateway_CloseCodeHandling(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithReconnectGrace(time.Minute)))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectStaff("staff1", "客服1", "group1", nil)
+	cleanConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer cleanConn.Close()
+	droppedConn := dialTestGateway(t, server, "/user?user_id=user2&name=用户2")
+	defer droppedConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil && gateway.service.GetUser("user2") != nil
+	}, time.Second, 10*time.Millisecond)
+	clean, _ := gateway.service.CreateSession("user1", "staff1")
+	dropped, _ := gateway.service.CreateSession("user2", "staff1")
+
+	// 正常关闭视为主动退出，会话立即关闭
+	cleanConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetSession(clean.ID).Status == customer_service.SessionStatusClosed
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, gateway.service.GetUser("user1"))
+
+	// 异常断开保留宽限期，会话等待用户重连
+	droppedConn.UnderlyingConn().Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.Presence("user2") == customer_service.PresenceOffline
+	}, time.Second, 10*time.Millisecond)
+	assert.NotNil(t, gateway.service.GetUser("user2"))
+	assert.Equal(t, customer_service.SessionStatusActive, gateway.service.GetSession(dropped.ID).Status)
+
+	audit := gateway.service.AuditLog()
+	if assert.Len(t, audit, 2) {
+		assert.Equal(t, string(customer_service.DisconnectLogout), audit[0].Reason)
+		assert.Equal(t, string(customer_service.DisconnectError), audit[1].Reason)
+	}
+}
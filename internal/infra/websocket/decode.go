@@ -0,0 +1,203 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultMaxMessageSize  = 64 * 1024
+	defaultMaxNestingDepth = 32
+	capacityRetryAfter     = 5 // 系统满载时建议客户端等待的秒数
+)
+
+// 入站消息错误码
+const (
+	ErrCodeMessageTooLarge = "message_too_large"
+	ErrCodeInvalidUTF8     = "invalid_utf8"
+	ErrCodeNestingTooDeep  = "nesting_too_deep"
+	ErrCodeInvalidJSON     = "invalid_json"
+	ErrCodeUnknownField    = "unknown_field"
+	ErrCodeValidation      = "validation_error"
+	ErrCodeInternal        = "internal_error"
+)
+
+// DecodeError 入站消息解析错误
+type DecodeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"` // 校验失败的字段，嵌套字段以点号连接
+}
+
+func (e *DecodeError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// decodeMessage 校验并解析入站帧。连接已按maxMessageSize设置读取上限，超出的帧在读取时即断开连接，
+// 这里的大小检查为直接解析的数据给出结构化错误
+func (g *MessageGateway) decodeMessage(data []byte) (*WSMessage, error) {
+	if len(data) > g.maxMessageSize {
+		return nil, &DecodeError{
+			Code:    ErrCodeMessageTooLarge,
+			Message: fmt.Sprintf("message exceeds %d bytes", g.maxMessageSize),
+		}
+	}
+	if !utf8.Valid(data) {
+		return nil, &DecodeError{Code: ErrCodeInvalidUTF8, Message: "message is not valid UTF-8"}
+	}
+	if depth := nestingDepth(data); depth > g.maxNestingDepth {
+		return nil, &DecodeError{
+			Code:    ErrCodeNestingTooDeep,
+			Message: fmt.Sprintf("nesting depth exceeds %d", g.maxNestingDepth),
+		}
+	}
+
+	var msg WSMessage
+	if err := g.unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	if err := validatePayload(msg.Type, msg.Payload); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// decodePayload 解析消息负载，负载已随整帧完成大小、编码和嵌套校验
+func (g *MessageGateway) decodePayload(payload json.RawMessage, v interface{}) error {
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	return g.unmarshal(payload, v)
+}
+
+// unmarshal 按网关配置解析JSON
+func (g *MessageGateway) unmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if g.strictFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			return &DecodeError{Code: ErrCodeUnknownField, Message: err.Error()}
+		}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return newValidationError(typeErr.Field, goKind(typeErr.Type))
+		}
+		return &DecodeError{Code: ErrCodeInvalidJSON, Message: err.Error()}
+	}
+	return nil
+}
+
+// nestingDepth 计算JSON中对象和数组的最大嵌套层数，忽略字符串内的括号
+func nestingDepth(data []byte) int {
+	depth, max := 0, 0
+	inString, escaped := false, false
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > max {
+				max = depth
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return max
+}
+
+// writeError 向客户端回复结构化错误，系统满载时附带retry_after提示客户端退避
+func (g *MessageGateway) writeError(conn *websocket.Conn, err error) {
+	var decodeErr *DecodeError
+	var serviceErr *customer_service.ServiceError
+	switch {
+	case errors.As(err, &decodeErr):
+		g.writeJSON(conn, "error", decodeErr)
+	case errors.Is(err, customer_service.ErrSystemAtCapacity):
+		errors.As(err, &serviceErr)
+		g.writeJSON(conn, "error", struct {
+			*customer_service.ServiceError
+			RetryAfter int `json:"retry_after"`
+		}{serviceErr, capacityRetryAfter})
+	case errors.As(err, &serviceErr):
+		g.writeJSON(conn, "error", serviceErr)
+	default:
+		g.writeJSON(conn, "error", &DecodeError{Code: ErrCodeInternal, Message: err.Error()})
+	}
+}
+
+// HTTPStatus 将错误映射为HTTP状态码
+func HTTPStatus(err error) int {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		if decodeErr.Code == ErrCodeMessageTooLarge {
+			return http.StatusRequestEntityTooLarge
+		}
+		return http.StatusBadRequest
+	}
+
+	var serviceErr *customer_service.ServiceError
+	if !errors.As(err, &serviceErr) {
+		return http.StatusInternalServerError
+	}
+	switch serviceErr.Code {
+	case customer_service.CodeUserNotFound,
+		customer_service.CodeStaffNotFound,
+		customer_service.CodeSessionNotFound,
+		customer_service.CodeGroupNotFound,
+		customer_service.CodeOfferNotFound,
+		customer_service.CodeMessageNotFound,
+		customer_service.CodeNoWaitingUsers,
+		customer_service.CodeCannedNotFound:
+		return http.StatusNotFound
+	case customer_service.CodeUserAlreadyQueued,
+		customer_service.CodeNoActiveSession,
+		customer_service.CodeGroupNotEmpty,
+		customer_service.CodeUserNotReady,
+		customer_service.CodeReopenExpired:
+		return http.StatusConflict
+	case customer_service.CodeInvalidResumeToken:
+		return http.StatusUnauthorized
+	case customer_service.CodePermissionDenied:
+		return http.StatusForbidden
+	case customer_service.CodeTooManyConnections,
+		customer_service.CodePreSessionBufferFull:
+		return http.StatusTooManyRequests
+	case customer_service.CodeContentTooLong:
+		return http.StatusRequestEntityTooLarge
+	case customer_service.CodeOutOfHours,
+		customer_service.CodeStaffUnavailable,
+		customer_service.CodeStaffAtCapacity,
+		customer_service.CodeGroupAtCapacity,
+		customer_service.CodeGroupDraining,
+		customer_service.CodeWaitTooLong,
+		customer_service.CodeSystemAtCapacity,
+		customer_service.CodeSystemBusy:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
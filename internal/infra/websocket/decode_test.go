@@ -0,0 +1,178 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertErrorResponse 断言收到指定错误码的错误回复
+func assertErrorResponse(t *testing.T, conn *websocket.Conn, code string) {
+	response := readTestMessage(t, conn)
+	assert.Equal(t, "error", response["type"])
+	assert.Equal(t, code, response["payload"].(map[string]interface{})["code"])
+	assert.NotEmpty(t, response["payload"].(map[string]interface{})["message"])
+}
+
+func TestMessageGateway_InboundValidation(t *testing.T) {
+	_, server := newTestGateway(t, WithStrictFields(true), WithMaxMessageSize(256), WithMaxNestingDepth(4))
+	defer server.Close()
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	// 非法UTF-8
+	assert.NoError(t, userConn.WriteMessage(websocket.TextMessage, []byte("{\"type\":\"message\",\"payload\":{\"content\":\"\xff\xfe\"}}")))
+	assertErrorResponse(t, userConn, ErrCodeInvalidUTF8)
+
+	// 顶层未知字段
+	assert.NoError(t, userConn.WriteMessage(websocket.TextMessage, []byte(`{"type":"message","payload":{},"extra":1}`)))
+	assertErrorResponse(t, userConn, ErrCodeUnknownField)
+
+	// 负载中的未知字段
+	writeTestMessage(t, userConn, "message", `{"content":"hi","bogus":true}`)
+	assertErrorResponse(t, userConn, ErrCodeUnknownField)
+
+	// 嵌套过深
+	assert.NoError(t, userConn.WriteMessage(websocket.TextMessage, []byte(`{"type":"message","payload":{"a":[[[{}]]]}}`)))
+	assertErrorResponse(t, userConn, ErrCodeNestingTooDeep)
+
+	// 非法JSON
+	assert.NoError(t, userConn.WriteMessage(websocket.TextMessage, []byte(`{"type":`)))
+	assertErrorResponse(t, userConn, ErrCodeInvalidJSON)
+
+	// 超出大小的帧在读取时即被拒绝，连接以1009关闭
+	writeTestMessage(t, userConn, "message", `{"content":"`+strings.Repeat("x", 300)+`"}`)
+	userConn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, _, err := userConn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), err)
+}
+
+func TestMessageGateway_DecodeTooLarge(t *testing.T) {
+	gateway := NewMessageGateway(WithMaxMessageSize(16))
+
+	_, err := gateway.decodeMessage([]byte(`{"type":"message","payload":{"content":"hi"}}`))
+	var decodeErr *DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, ErrCodeMessageTooLarge, decodeErr.Code)
+	}
+}
+
+func TestMessageGateway_UnknownFieldError(t *testing.T) {
+	gateway := NewMessageGateway(WithStrictFields(true))
+	var payload struct {
+		Content string `json:"content"`
+	}
+
+	// 未知字段依赖标准库的错误文本识别，文本变化时这里会失败
+	decoder := json.NewDecoder(strings.NewReader(`{"content":"hi","bogus":1}`))
+	decoder.DisallowUnknownFields()
+	assert.True(t, strings.HasPrefix(decoder.Decode(&payload).Error(), "json: unknown field"))
+
+	err := gateway.unmarshal([]byte(`{"content":"hi","bogus":1}`), &payload)
+	var decodeErr *DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, ErrCodeUnknownField, decodeErr.Code)
+		assert.Contains(t, decodeErr.Message, `"bogus"`)
+	}
+}
+
+func TestMessageGateway_LenientFields(t *testing.T) {
+	gateway := NewMessageGateway()
+
+	// 默认不拒绝未知字段
+	msg, err := gateway.decodeMessage([]byte(`{"type":"message","payload":{"content":"hi"},"extra":1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "message", msg.Type)
+}
+
+func TestNestingDepth(t *testing.T) {
+	assert.Equal(t, 0, nestingDepth([]byte(`"plain"`)))
+	assert.Equal(t, 1, nestingDepth([]byte(`{"a":1}`)))
+	assert.Equal(t, 3, nestingDepth([]byte(`{"a":[{"b":2}],"c":{}}`)))
+	// 字符串内的括号和转义引号不计入
+	assert.Equal(t, 1, nestingDepth([]byte(`{"a":"[[{{\"}}"}`)))
+}
+
+func TestMessageGateway_ServiceErrorResponse(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+
+	// 业务错误以错误码回复给客户端
+	writeTestMessage(t, staffConn, "message", `{"session_id":"nonexistent","content":"hi"}`)
+	assertErrorResponse(t, staffConn, customer_service.CodeSessionNotFound)
+
+	writeTestMessage(t, staffConn, "connect_user", `{"user_id":"nonexistent"}`)
+	assertErrorResponse(t, staffConn, customer_service.CodeUserNotFound)
+
+	// 连接不存在的组时先回复错误再关闭连接
+	badConn := dialTestGateway(t, server, "/staff?staff_id=staff2&name=客服2&group_id=nonexistent")
+	defer badConn.Close()
+	assertErrorResponse(t, badConn, customer_service.CodeGroupNotFound)
+}
+
+func TestMessageGateway_CapacityBackoff(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.SetMaxSessions(1)
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	user1Conn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer user1Conn.Close()
+	user2Conn := dialTestGateway(t, server, "/user?user_id=user2&name=用户2")
+	defer user2Conn.Close()
+
+	_, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 系统满载时回复错误码并提示客户端退避
+	writeTestMessage(t, user2Conn, "enqueue", `{"group_id":"group1"}`)
+	response := readTestMessage(t, user2Conn)
+	assert.Equal(t, "error", response["type"])
+	payload := response["payload"].(map[string]interface{})
+	assert.Equal(t, customer_service.CodeSystemAtCapacity, payload["code"])
+	assert.Equal(t, float64(capacityRetryAfter), payload["retry_after"])
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(customer_service.ErrUserNotFound))
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(fmt.Errorf("wrapped: %w", customer_service.ErrSessionNotFound)))
+	assert.Equal(t, http.StatusConflict, HTTPStatus(customer_service.ErrUserAlreadyQueued))
+	assert.Equal(t, http.StatusConflict, HTTPStatus(customer_service.ErrNoActiveSession))
+	assert.Equal(t, http.StatusConflict, HTTPStatus(customer_service.ErrGroupNotEmpty))
+	assert.Equal(t, http.StatusConflict, HTTPStatus(customer_service.ErrUserNotReady))
+	assert.Equal(t, http.StatusConflict, HTTPStatus(customer_service.ErrReopenWindowExpired))
+	assert.Equal(t, http.StatusTooManyRequests, HTTPStatus(customer_service.ErrTooManyConnections))
+	assert.Equal(t, http.StatusTooManyRequests, HTTPStatus(customer_service.ErrPreSessionBufferFull))
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(customer_service.ErrNoWaitingUsers))
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(customer_service.ErrCannedNotFound))
+	assert.Equal(t, http.StatusServiceUnavailable, HTTPStatus(customer_service.ErrSystemAtCapacity))
+	assert.Equal(t, http.StatusServiceUnavailable, HTTPStatus(customer_service.ErrSystemBusy))
+	assert.Equal(t, http.StatusServiceUnavailable, HTTPStatus(customer_service.ErrStaffAtCapacity))
+	assert.Equal(t, http.StatusServiceUnavailable, HTTPStatus(customer_service.ErrGroupAtCapacity))
+	assert.Equal(t, http.StatusServiceUnavailable, HTTPStatus(customer_service.ErrGroupDraining))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, HTTPStatus(customer_service.ErrContentTooLong))
+	assert.Equal(t, http.StatusUnauthorized, HTTPStatus(customer_service.ErrInvalidResumeToken))
+	assert.Equal(t, http.StatusForbidden, HTTPStatus(customer_service.ErrPermissionDenied))
+	assert.Equal(t, http.StatusServiceUnavailable, HTTPStatus(customer_service.ErrWaitTooLong))
+	assert.Equal(t, http.StatusBadRequest, HTTPStatus(customer_service.ErrInvalidOperation))
+	assert.Equal(t, http.StatusBadRequest, HTTPStatus(customer_service.ErrInvalidIdentity))
+	assert.Equal(t, http.StatusBadRequest, HTTPStatus(&DecodeError{Code: ErrCodeInvalidJSON}))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, HTTPStatus(&DecodeError{Code: ErrCodeMessageTooLarge}))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(errors.New("boom")))
+}
@@ -1,10 +1,16 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
+	"time"
 
 	"clash/internal/domain/customer_service"
 
@@ -16,12 +22,55 @@ type MessageGateway struct {
 	service  *customer_service.CustomerService
 	upgrader websocket.Upgrader
 	mu       sync.RWMutex
+	senders  map[*websocket.Conn]*connSender // 各连接的发送队列
+
+	maxMessageSize  int                  // 入站消息最大字节数
+	maxNestingDepth int                  // 入站JSON最大嵌套层数
+	strictFields    bool                 // 是否拒绝未知字段
+	pingInterval    time.Duration        // 心跳间隔
+	writeTimeout    time.Duration        // 单次写出的超时时间
+	maxConnections  int                  // 同时保持的连接数上限，0表示不限
+	keepReplaced    bool                 // 为true时新设备接管会话后保留旧设备的连接，否则以kicked关闭
+	supervisorAuth  SupervisorAuthorizer // 主管连接的校验函数，为空时不校验
+	acks            *ackTracker          // 消息确认跟踪，未开启确认机制时为空
+
+	sendBuffer int            // 每个连接的发送队列长度
+	overflow   OverflowPolicy // 发送队列已满时的处理方式
+
+	awayAfterMissed       int // 客服连续漏回多少次pong后推断为离开，0表示不推断
+	disconnectAfterMissed int // 连续漏回多少次pong后断开连接，0表示不断开
+
+	inboundBuffer int    // 每个连接预读入站帧的缓冲条数，0表示在读循环中直接读取
+	routePrefix   string // RegisterRoutes注册连接入口时使用的路径前缀
+
+	retryAfter map[string]time.Duration // 各关闭原因建议客户端重连前等待的时间
+
+	serviceOpts []customer_service.Option // 创建客服系统服务时使用的配置项
+
+	bus         MessageBus        // 网关实例之间的消息总线，为空表示单实例部署
+	instanceID  string            // 本实例在总线上的标识
+	unsubscribe func()            // 取消总线订阅
+	localIDs    map[string]int    // 本实例上连接的参与者及其连接数
+	remoteIDs   map[string]string // 经总线通告在其他实例上连接的参与者及其所在实例
+	presenceMu  sync.Mutex        // 保护localIDs和remoteIDs
+
+	busRetry *busRetrier // 总线消息的发布队列，未开启重试时只尝试一次，失败只记录日志
 }
 
 // NewMessageGateway 创建新的消息网关实例
-func NewMessageGateway() *MessageGateway {
-	return &MessageGateway{
-		service: customer_service.NewCustomerService(),
+func NewMessageGateway(opts ...GatewayOption) *MessageGateway {
+	g := &MessageGateway{
+		senders:         make(map[*websocket.Conn]*connSender),
+		maxMessageSize:  defaultMaxMessageSize,
+		maxNestingDepth: defaultMaxNestingDepth,
+		pingInterval:    defaultPingInterval,
+		writeTimeout:    defaultWriteTimeout,
+		sendBuffer:      sendQueueSize,
+		overflow:        defaultOverflowPolicy,
+		routePrefix:     defaultRoutePrefix,
+		retryAfter:      make(map[string]time.Duration, len(defaultRetryAfter)),
+		localIDs:        make(map[string]int),
+		remoteIDs:       make(map[string]string),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -30,6 +79,26 @@ func NewMessageGateway() *MessageGateway {
 			},
 		},
 	}
+	for reason, d := range defaultRetryAfter {
+		g.retryAfter[reason] = d
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.service = customer_service.NewCustomerService(g.serviceOpts...)
+	g.service.SetEventHook(g.handleServiceEvent)
+	if g.bus != nil {
+		g.instanceID = newInstanceID()
+		g.unsubscribe = g.bus.Subscribe(g.handleBusMessage)
+		if g.busRetry == nil {
+			g.busRetry = newBusRetrier(RetryPolicy{MaxAttempts: 1}, nil)
+		}
+		// 请求已有实例通告各自的在线参与者
+		if err := g.bus.Publish(BusMessage{Origin: g.instanceID, Type: busTypeSync}); err != nil {
+			log.Printf("Error requesting presence sync from bus: %v", err)
+		}
+	}
+	return g
 }
 
 // WSMessage WebSocket消息结构
@@ -47,6 +116,15 @@ func (g *MessageGateway) HandleUserConnection(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Missing user information", http.StatusBadRequest)
 		return
 	}
+	if err := g.service.ValidateIdentity(userID, name); err != nil {
+		http.Error(w, err.Error(), HTTPStatus(err))
+		return
+	}
+	if err := g.service.AcquireConnection(customer_service.PresenceRoleUser, userID); err != nil {
+		http.Error(w, err.Error(), HTTPStatus(err))
+		return
+	}
+	defer g.service.ReleaseConnection(customer_service.PresenceRoleUser, userID)
 
 	// 升级HTTP连接为WebSocket连接
 	conn, err := g.upgrader.Upgrade(w, r, nil)
@@ -54,51 +132,276 @@ func (g *MessageGateway) HandleUserConnection(w http.ResponseWriter, r *http.Req
 		log.Printf("Failed to upgrade connection: %v", err)
 		return
 	}
+	conn.SetReadLimit(int64(g.maxMessageSize))
+	g.openSender(conn)
+	defer g.closeSender(conn)
+	if !g.admitConn(conn) {
+		return
+	}
 
-	// 注册用户连接
-	user := g.service.ConnectUser(userID, name, conn)
-	defer g.service.DisconnectUser(userID)
+	// 连接级上下文，读循环退出时取消，使该连接的后台协程随之退出
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// 注册用户连接，渠道缺省为web，断线重连时凭上次下发的resume_token恢复原会话
+	user, err := g.connectUser(conn, userID, name, r.URL.Query().Get("channel"), r.URL.Query().Get("resume_token"))
+	if err != nil {
+		log.Printf("Failed to connect user: %v", err)
+		g.writeError(conn, err)
+		g.closeSender(conn) // 确保错误回复在关闭连接前写出
+		conn.Close()
+		return
+	}
+	// 被新设备接管后旧连接断开不影响会话
+	reason := customer_service.DisconnectError
+	defer func() { g.service.DisconnectUserConn(userID, conn, reason) }()
+	defer g.trackLocal(userID)()
+	g.startHeartbeat(ctx, conn, customer_service.PresenceRoleUser, userID)
+	g.flushOffline(conn, userID)
+	inbound := g.newInboundReader(ctx, conn)
 
 	// 处理用户消息
 	for {
-		_, data, err := conn.ReadMessage()
+		data, err := inbound.next()
 		if err != nil {
-			log.Printf("Error reading message from user %s: %v", userID, err)
+			logReadError(customer_service.PresenceRoleUser, userID, err)
+			reason = disconnectReason(err)
 			break
 		}
+		g.service.Touch(customer_service.PresenceRoleUser, userID)
 
-		var msg WSMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
+		msg, err := g.decodeMessage(data)
+		if err != nil {
 			log.Printf("Error parsing message from user %s: %v", userID, err)
+			g.writeError(conn, err)
 			continue
 		}
 
 		// 处理不同类型的消息
 		switch msg.Type {
+		case "ack":
+			g.handleAck(userID, msg.Payload)
+
+		case "logout":
+			// 主动退出不保留重连宽限期，会话随之关闭
+			reason = customer_service.DisconnectLogout
+			return
+
 		case "message":
 			var payload struct {
-				Content string `json:"content"`
+				ClientMsgID  string    `json:"client_msg_id"`  // 可选，客户端重试时用于去重
+				ClientSentAt time.Time `json:"client_sent_at"` // 可选，客户端发送时间，仅用于展示
+				Content      string    `json:"content"`
 			}
-			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
 				log.Printf("Error parsing message payload: %v", err)
+				g.writeError(conn, err)
 				continue
 			}
 
-			// 发送消息
-			if user.SessionID != "" {
-				message, err := g.service.SendMessage(user.SessionID, userID, payload.Content, customer_service.MessageTypeText)
-				if err != nil {
-					log.Printf("Error sending message: %v", err)
-					continue
+			// 会话建立前的消息先缓存，会话建立后补发；未开启缓存时回复no_active_session
+			if user.SessionID == "" {
+				if err := g.service.BufferUserMessage(userID, payload.Content); err != nil {
+					g.writeError(conn, err)
 				}
+				continue
+			}
+
+			// 发送消息
+			message, duplicate, err := g.service.SendMessageOnceAt(user.SessionID, userID, payload.ClientMsgID, payload.Content, customer_service.MessageTypeText, payload.ClientSentAt)
+			if err != nil {
+				log.Printf("Error sending message: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			// 转发消息给客服，重复提交的消息已转发过
+			if !duplicate {
+				g.deliverMessage(message)
+			}
+
+		case "messages":
+			var payload struct {
+				Contents []string `json:"contents"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing messages payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			if user.SessionID == "" {
+				g.writeError(conn, customer_service.ErrNoActiveSession)
+				continue
+			}
+			g.handleBatchMessages(conn, user.SessionID, userID, payload.Contents, g.deliverMessage)
+
+		case "enqueue":
+			var payload struct {
+				GroupID string `json:"group_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing enqueue payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
 
-				// 转发消息给客服
-				g.forwardMessageToStaff(message)
+			g.enqueueUser(conn, userID, payload.GroupID)
+
+		case "user_ready":
+			var payload struct {
+				GroupID string            `json:"group_id"`
+				Fields  map[string]string `json:"fields"` // 会话前表单字段，如姓名、问题描述
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing user_ready payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			// 提交表单后才开始排队
+			if err := g.service.MarkUserReady(userID, payload.Fields); err != nil {
+				g.writeError(conn, err)
+				continue
+			}
+			g.enqueueUser(conn, userID, payload.GroupID)
+
+		case "reopen_session":
+			var payload struct {
+				SessionID string `json:"session_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing reopen_session payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			// 只能重新打开自己的会话，接回原客服时由会话分配事件通知双方
+			if session := g.service.GetSession(payload.SessionID); session == nil || session.UserID != userID {
+				g.writeError(conn, customer_service.ErrSessionNotFound)
+				continue
+			}
+			if _, err := g.service.ReopenSession(payload.SessionID); err != nil {
+				g.writeError(conn, err)
+			}
+
+		case "request_session":
+			var payload struct {
+				GroupID string `json:"group_id"`
+				Subject string `json:"subject"` // 咨询主题，优先分配给具备同名技能的客服
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing request_session payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			// 分配成功时由会话分配事件通知双方
+			_, err := g.service.RequestSession(userID, payload.GroupID, payload.Subject)
+			g.replyEnqueueError(conn, payload.GroupID, err)
+
+		case "leave_message":
+			var payload struct {
+				Subject string `json:"subject"`
+				Body    string `json:"body"`
+				Contact string `json:"contact"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing leave_message payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			ticket, err := g.service.CreateTicket(userID, payload.Subject, payload.Body, payload.Contact)
+			if err != nil {
+				log.Printf("Error creating ticket: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			g.writeJSON(conn, "ticket_created", ticket)
+
+		case "close_session":
+			if user.SessionID == "" {
+				continue
+			}
+			if err := g.service.CloseSession(user.SessionID, userID); err != nil {
+				log.Printf("Error closing session: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "recall_message":
+			var payload struct {
+				MessageID string `json:"message_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing recall_message payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			if _, err := g.service.RecallMessage(user.SessionID, payload.MessageID, userID); err != nil {
+				log.Printf("Error recalling message: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "attachment":
+			var payload customer_service.Attachment
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing attachment payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			if user.SessionID == "" {
+				g.writeError(conn, customer_service.ErrNoActiveSession)
+				continue
+			}
+			g.sendAttachment(conn, user.SessionID, userID, payload)
+
+		case "typing":
+			var payload struct {
+				Draft string `json:"draft"` // 可选，客服组开启草稿共享时转发给客服
 			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing typing payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			if user.SessionID == "" {
+				continue
+			}
+			g.forwardTyping(conn, user.SessionID, userID, payload.Draft)
 		}
 	}
 }
 
+// enqueueUser 将用户排入客服组等待客服接受邀请，非营业时间回复自动消息引导用户留言
+func (g *MessageGateway) enqueueUser(conn *websocket.Conn, userID, groupID string) {
+	g.replyEnqueueError(conn, groupID, g.service.EnqueueUser(userID, groupID))
+}
+
+// replyEnqueueError 向用户回复排队失败的原因，非营业时间回复自动消息，
+// 排队请求激增时回复system_busy引导用户留言，err为空时不回复
+func (g *MessageGateway) replyEnqueueError(conn *websocket.Conn, groupID string, err error) {
+	if err == nil {
+		return
+	}
+	log.Printf("Error enqueueing user: %v", err)
+	if errors.Is(err, customer_service.ErrOutOfHours) {
+		g.writeJSON(conn, "auto_reply", map[string]string{
+			"group_id": groupID,
+			"content":  err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, customer_service.ErrSystemBusy) {
+		g.writeJSON(conn, "system_busy", map[string]string{
+			"group_id": groupID,
+			"content":  err.Error(),
+		})
+		return
+	}
+	g.writeError(conn, err)
+}
+
 // HandleStaffConnection 处理客服WebSocket连接
 func (g *MessageGateway) HandleStaffConnection(w http.ResponseWriter, r *http.Request) {
 	// 从请求中获取客服信息（实际应用中应该从认证token中获取）
@@ -109,6 +412,20 @@ func (g *MessageGateway) HandleStaffConnection(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Missing staff information", http.StatusBadRequest)
 		return
 	}
+	settings, err := staffSettingsFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := g.service.ValidateIdentity(staffID, name); err != nil {
+		http.Error(w, err.Error(), HTTPStatus(err))
+		return
+	}
+	if err := g.service.AcquireConnection(customer_service.PresenceRoleStaff, staffID); err != nil {
+		http.Error(w, err.Error(), HTTPStatus(err))
+		return
+	}
+	defer g.service.ReleaseConnection(customer_service.PresenceRoleStaff, staffID)
 
 	// 升级HTTP连接为WebSocket连接
 	conn, err := g.upgrader.Upgrade(w, r, nil)
@@ -116,38 +433,67 @@ func (g *MessageGateway) HandleStaffConnection(w http.ResponseWriter, r *http.Re
 		log.Printf("Failed to upgrade connection: %v", err)
 		return
 	}
+	conn.SetReadLimit(int64(g.maxMessageSize))
+	g.openSender(conn)
+	defer g.closeSender(conn)
+	if !g.admitConn(conn) {
+		return
+	}
+
+	// 连接级上下文，读循环退出时取消，使该连接的后台协程随之退出
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
 
 	// 注册客服连接
-	_, err = g.service.ConnectStaff(staffID, name, groupID, conn)
+	_, err = g.service.ConnectStaffWithSettings(staffID, name, groupID, conn, settings)
 	if err != nil {
 		log.Printf("Failed to connect staff: %v", err)
+		g.writeError(conn, err)
+		g.closeSender(conn) // 确保错误回复在关闭连接前写出
 		conn.Close()
 		return
 	}
-	defer g.service.DisconnectStaff(staffID)
+	reason := customer_service.DisconnectError
+	defer func() { g.service.DisconnectStaff(staffID, reason) }()
+	defer g.trackLocal(staffID)()
+	g.startHeartbeat(ctx, conn, customer_service.PresenceRoleStaff, staffID)
+	g.flushOffline(conn, staffID)
+	inbound := g.newInboundReader(ctx, conn)
 
 	// 处理客服消息
 	for {
-		_, data, err := conn.ReadMessage()
+		data, err := inbound.next()
 		if err != nil {
-			log.Printf("Error reading message from staff %s: %v", staffID, err)
+			logReadError(customer_service.PresenceRoleStaff, staffID, err)
+			reason = disconnectReason(err)
 			break
 		}
+		g.service.Touch(customer_service.PresenceRoleStaff, staffID)
 
-		var msg WSMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
+		msg, err := g.decodeMessage(data)
+		if err != nil {
 			log.Printf("Error parsing message from staff %s: %v", staffID, err)
+			g.writeError(conn, err)
 			continue
 		}
 
 		// 处理不同类型的消息
 		switch msg.Type {
+		case "ack":
+			g.handleAck(staffID, msg.Payload)
+
+		case "logout":
+			// 主动下线时关闭全部会话，不再重新排队
+			reason = customer_service.DisconnectLogout
+			return
+
 		case "connect_user":
 			var payload struct {
 				UserID string `json:"user_id"`
 			}
-			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
 				log.Printf("Error parsing connect_user payload: %v", err)
+				g.writeError(conn, err)
 				continue
 			}
 
@@ -155,6 +501,7 @@ func (g *MessageGateway) HandleStaffConnection(w http.ResponseWriter, r *http.Re
 			session, err := g.service.CreateSession(payload.UserID, staffID)
 			if err != nil {
 				log.Printf("Error creating session: %v", err)
+				g.writeError(conn, err)
 				continue
 			}
 
@@ -166,14 +513,16 @@ func (g *MessageGateway) HandleStaffConnection(w http.ResponseWriter, r *http.Re
 				SessionID  string `json:"session_id"`
 				NewStaffID string `json:"new_staff_id"`
 			}
-			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
 				log.Printf("Error parsing transfer_session payload: %v", err)
+				g.writeError(conn, err)
 				continue
 			}
 
 			// 转移会话
 			if err := g.service.TransferSession(payload.SessionID, payload.NewStaffID); err != nil {
 				log.Printf("Error transferring session: %v", err)
+				g.writeError(conn, err)
 				continue
 			}
 
@@ -181,74 +530,743 @@ func (g *MessageGateway) HandleStaffConnection(w http.ResponseWriter, r *http.Re
 			g.notifySessionTransferred(payload.SessionID, staffID, payload.NewStaffID)
 
 		case "message":
+			var payload struct {
+				SessionID    string    `json:"session_id"`
+				ToID         string    `json:"to_id"`          // 可选，指定接收的参与者
+				ClientMsgID  string    `json:"client_msg_id"`  // 可选，客户端重试时用于去重
+				ClientSentAt time.Time `json:"client_sent_at"` // 可选，客户端发送时间，仅用于展示
+				Content      string    `json:"content"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing message payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			// 发送消息，未指定接收者时发给用户
+			var message *customer_service.Message
+			var duplicate bool
+			var err error
+			if payload.ToID == "" {
+				message, duplicate, err = g.service.SendMessageOnceAt(payload.SessionID, staffID, payload.ClientMsgID, payload.Content, customer_service.MessageTypeText, payload.ClientSentAt)
+			} else {
+				message, err = g.service.SendMessageTo(payload.SessionID, staffID, payload.ToID, payload.Content, customer_service.MessageTypeText)
+			}
+			if err != nil {
+				log.Printf("Error sending message: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			// 转发消息给接收者，重复提交的消息已转发过
+			if !duplicate {
+				g.deliverMessage(message)
+			}
+
+		case "messages":
+			var payload struct {
+				SessionID string   `json:"session_id"`
+				Contents  []string `json:"contents"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing messages payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			g.handleBatchMessages(conn, payload.SessionID, staffID, payload.Contents, g.deliverMessage)
+
+		case "accept_offer", "decline_offer":
+			var payload struct {
+				OfferID string `json:"offer_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing %s payload: %v", msg.Type, err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			if msg.Type == "decline_offer" {
+				if err := g.service.DeclineOffer(staffID, payload.OfferID); err != nil {
+					log.Printf("Error declining offer: %v", err)
+					g.writeError(conn, err)
+				}
+				continue
+			}
+
+			session, err := g.service.AcceptOffer(staffID, payload.OfferID)
+			if err != nil {
+				log.Printf("Error accepting offer: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			g.notifySessionCreated(session)
+
+		case "close_session":
+			var payload struct {
+				SessionID string `json:"session_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing close_session payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			if err := g.service.CloseSession(payload.SessionID, staffID); err != nil {
+				log.Printf("Error closing session: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "recall_message":
+			var payload struct {
+				SessionID string `json:"session_id"`
+				MessageID string `json:"message_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing recall_message payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			if _, err := g.service.RecallMessage(payload.SessionID, payload.MessageID, staffID); err != nil {
+				log.Printf("Error recalling message: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "forward_message":
+			var payload struct {
+				SourceSessionID string `json:"source_session_id"`
+				MessageID       string `json:"message_id"`
+				TargetSessionID string `json:"target_session_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing forward_message payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			message, err := g.service.ForwardMessage(payload.SourceSessionID, payload.MessageID, payload.TargetSessionID, staffID)
+			if err != nil {
+				log.Printf("Error forwarding message: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			g.deliverMessage(message)
+
+		case "attachment":
+			var payload struct {
+				SessionID string `json:"session_id"`
+				customer_service.Attachment
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing attachment payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			g.sendAttachment(conn, payload.SessionID, staffID, payload.Attachment)
+
+		case "typing":
+			var payload struct {
+				SessionID string `json:"session_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing typing payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			g.forwardTyping(conn, payload.SessionID, staffID, "")
+
+		case "add_note":
 			var payload struct {
 				SessionID string `json:"session_id"`
 				Content   string `json:"content"`
 			}
-			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-				log.Printf("Error parsing message payload: %v", err)
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing add_note payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			// 备注只回复给添加的客服，不转发给用户
+			note, err := g.service.AddNote(payload.SessionID, staffID, payload.Content)
+			if err != nil {
+				g.writeError(conn, err)
 				continue
 			}
+			g.writeJSON(conn, "note_added", note)
 
-			// 发送消息
-			message, err := g.service.SendMessage(payload.SessionID, staffID, payload.Content, customer_service.MessageTypeText)
+		case "merge_sessions":
+			var payload struct {
+				PrimaryID   string `json:"primary_id"`
+				SecondaryID string `json:"secondary_id"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing merge_sessions payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+
+			// 只能将其他会话合并到自己负责的会话中
+			primary := g.service.GetSession(payload.PrimaryID)
+			if primary == nil || primary.StaffID != staffID {
+				g.writeError(conn, customer_service.ErrInvalidOperation)
+				continue
+			}
+			if err := g.service.MergeSessions(payload.PrimaryID, payload.SecondaryID); err != nil {
+				log.Printf("Error merging sessions: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			g.writeJSON(conn, "sessions_merged", payload)
+
+		case "end_wrapup":
+			if err := g.service.EndWrapUp(staffID); err != nil {
+				log.Printf("Error ending wrap-up: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "claim_next":
+			// 拉取模式：客服主动领取排队中的下一位用户
+			session, err := g.service.ClaimNext(staffID)
+			if err != nil {
+				g.writeError(conn, err)
+				continue
+			}
+			g.notifySessionCreated(session)
+
+		case "search_canned":
+			var payload struct {
+				Query string `json:"query"` // 为空时返回客服组的全部快捷回复
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing search_canned payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			// 客服所属组可能因组删除而变更，按当前所属组查找
+			view, _ := g.service.GetStaffView(staffID)
+			g.writeJSON(conn, "canned_results", map[string]interface{}{
+				"query":   payload.Query,
+				"results": g.service.SearchCanned(view.GroupID, payload.Query),
+			})
+
+		case "set_focus":
+			var payload struct {
+				SessionID string `json:"session_id"` // 为空时取消聚焦
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing set_focus payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			if err := g.service.SetFocus(staffID, payload.SessionID); err != nil {
+				g.writeError(conn, err)
+				continue
+			}
+			g.writeSessionList(conn, staffID)
+
+		case "list_sessions":
+			g.writeSessionList(conn, staffID)
+
+		case "notification_pref":
+			var payload struct {
+				EventType string `json:"event_type"`
+				On        bool   `json:"on"`
+			}
+			if err := g.decodePayload(msg.Payload, &payload); err != nil {
+				log.Printf("Error parsing notification_pref payload: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			if err := g.service.SetNotificationPref(staffID, payload.EventType, payload.On); err != nil {
+				g.writeError(conn, err)
+				continue
+			}
+			g.writeJSON(conn, "notification_pref_updated", payload)
+		}
+	}
+}
+
+// staffSettingsFromQuery 从连接参数中读取客服的接待设置，max_sessions为会话上限，auto_accept为是否自动接入，均可省略
+func staffSettingsFromQuery(query url.Values) (customer_service.StaffSettings, error) {
+	var settings customer_service.StaffSettings
+	if value := query.Get("max_sessions"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return settings, fmt.Errorf("invalid max_sessions: %q", value)
+		}
+		settings.MaxSessions = n
+	}
+	if value := query.Get("auto_accept"); value != "" {
+		on, err := strconv.ParseBool(value)
+		if err != nil {
+			return settings, fmt.Errorf("invalid auto_accept: %q", value)
+		}
+		settings.AutoAccept = on
+	}
+	return settings, nil
+}
+
+// writeSessionList 向客服回复其负责的会话摘要，聚焦的会话排在最前
+func (g *MessageGateway) writeSessionList(conn *websocket.Conn, staffID string) {
+	summaries, err := g.service.StaffSessions(staffID)
+	if err != nil {
+		g.writeError(conn, err)
+		return
+	}
+	g.writeJSON(conn, "session_list", summaries)
+}
+
+// HandleSupervisorConnection 处理主管WebSocket连接，向其推送用户和客服的上下线事件
+func (g *MessageGateway) HandleSupervisorConnection(w http.ResponseWriter, r *http.Request) {
+	supervisorID := r.URL.Query().Get("supervisor_id")
+	if supervisorID == "" {
+		http.Error(w, "Missing supervisor information", http.StatusBadRequest)
+		return
+	}
+	if !g.authorizeSupervisor(r, supervisorID) {
+		http.Error(w, customer_service.ErrPermissionDenied.Error(), http.StatusForbidden)
+		return
+	}
+	if err := g.service.AcquireConnection(customer_service.PresenceRoleSupervisor, supervisorID); err != nil {
+		http.Error(w, err.Error(), HTTPStatus(err))
+		return
+	}
+	defer g.service.ReleaseConnection(customer_service.PresenceRoleSupervisor, supervisorID)
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade connection: %v", err)
+		return
+	}
+	conn.SetReadLimit(int64(g.maxMessageSize))
+	defer conn.Close()
+	g.openSender(conn)
+	defer g.closeSender(conn)
+	if !g.admitConn(conn) {
+		return
+	}
+
+	// 连接级上下文，读循环退出时取消，使该连接的后台协程随之退出
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	g.service.ConnectSupervisor(supervisorID, conn)
+	defer g.service.DisconnectSupervisor(supervisorID)
+	defer g.trackLocal(supervisorID)()
+
+	events, unsubscribe := g.service.SubscribePresence()
+	defer unsubscribe()
+
+	// 转发上下线事件，连接关闭或取消订阅后协程退出
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				g.writeJSON(conn, "presence", event)
+			}
+		}
+	}()
+
+	// 处理主管消息
+	inbound := g.newInboundReader(ctx, conn)
+	for {
+		data, err := inbound.next()
+		if err != nil {
+			log.Printf("Error reading message from supervisor %s: %v", supervisorID, err)
+			break
+		}
+
+		msg, err := g.decodeMessage(data)
+		if err != nil {
+			log.Printf("Error parsing message from supervisor %s: %v", supervisorID, err)
+			g.writeError(conn, err)
+			continue
+		}
+		// ack由handleAck按自身的结构解析，严格模式下不能用下面的共用结构解析
+		if msg.Type == "ack" {
+			g.handleAck(supervisorID, msg.Payload)
+			continue
+		}
+
+		var payload struct {
+			SessionID string `json:"session_id"`
+			ToID      string `json:"to_id"` // 可选，为空时发给会话全部参与者
+			Content   string `json:"content"`
+			ToStaffID string `json:"to_staff_id"`
+			UserID    string `json:"user_id"`
+			Reason    string `json:"reason"`
+		}
+		if err := g.decodePayload(msg.Payload, &payload); err != nil {
+			log.Printf("Error parsing %s payload: %v", msg.Type, err)
+			g.writeError(conn, err)
+			continue
+		}
+
+		switch msg.Type {
+		case "join_session":
+			// 加入时先收到此前的完整记录，之后的消息实时推送，两者不重不漏
+			err := g.service.JoinSessionWithTranscript(payload.SessionID, supervisorID, func(transcript []customer_service.Message) {
+				g.writeJSON(conn, "transcript", TranscriptPayload{
+					SessionID: payload.SessionID,
+					Messages:  newTranscriptDTOs(transcript),
+				})
+			})
+			if err != nil {
+				log.Printf("Error joining session: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "leave_session":
+			if err := g.service.LeaveSession(payload.SessionID, supervisorID); err != nil {
+				log.Printf("Error leaving session: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "message":
+			message, err := g.service.SendMessageTo(payload.SessionID, supervisorID, payload.ToID, payload.Content, customer_service.MessageTypeText)
 			if err != nil {
 				log.Printf("Error sending message: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			g.deliverMessage(message)
+
+		case "admin_close":
+			if err := g.service.AdminCloseSession(payload.SessionID, supervisorID, payload.Reason); err != nil {
+				log.Printf("Error closing session by admin: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "admin_transfer":
+			if err := g.service.AdminTransfer(payload.SessionID, payload.ToStaffID, supervisorID, payload.Reason); err != nil {
+				log.Printf("Error transferring session by admin: %v", err)
+				g.writeError(conn, err)
+				continue
+			}
+			history, err := g.service.TransferHistory(payload.SessionID)
+			if err != nil || len(history) == 0 {
 				continue
 			}
+			last := history[len(history)-1]
+			g.notifySessionTransferred(payload.SessionID, last.FromStaffID, last.ToStaffID)
 
-			// 转发消息给用户
-			g.forwardMessageToUser(message)
+		case "invite_staff":
+			// 提醒客服接待指定的排队用户，客服通过accept_offer或decline_offer处理
+			if err := g.service.InviteStaffToUser(payload.ToStaffID, payload.UserID); err != nil {
+				log.Printf("Error inviting staff: %v", err)
+				g.writeError(conn, err)
+			}
+
+		case "list_presence":
+			// 按连接活动推断的在线状态，区别于客服手动设置的状态
+			g.writeJSON(conn, "presence_list", g.service.ListPresence())
 		}
 	}
 }
 
-// forwardMessageToStaff 转发消息给客服
-func (g *MessageGateway) forwardMessageToStaff(message *customer_service.Message) {
+// BatchResult 批量发送中单条消息的处理结果
+type BatchResult struct {
+	Index     int    `json:"index"`
+	MessageID string `json:"message_id,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleBatchMessages 处理批量消息，向发送方回复逐条结果并转发成功的消息
+func (g *MessageGateway) handleBatchMessages(conn *websocket.Conn, sessionID, fromID string, contents []string, forward func(*customer_service.Message)) {
+	messages, errs := g.service.SendMessages(sessionID, fromID, contents)
+
+	results := make([]BatchResult, len(contents))
+	for i := range contents {
+		results[i].Index = i
+		if errs[i] != nil {
+			var serviceErr *customer_service.ServiceError
+			if errors.As(errs[i], &serviceErr) {
+				results[i].Code = serviceErr.Code
+			}
+			results[i].Error = errs[i].Error()
+			continue
+		}
+		results[i].MessageID = messages[i].ID
+	}
+
 	response := map[string]interface{}{
-		"type":    "message",
-		"payload": message,
+		"type": "messages_result",
+		"payload": map[string]interface{}{
+			"session_id": sessionID,
+			"results":    results,
+		},
 	}
 	data, _ := json.Marshal(response)
+	g.send(conn, data)
 
-	staff := g.service.GetStaff(message.ToID)
-	if staff != nil {
-		staff.Conn.WriteMessage(websocket.TextMessage, data)
+	for _, message := range messages {
+		if message != nil {
+			forward(message)
+		}
 	}
 }
 
-// forwardMessageToUser 转发消息给用户
-func (g *MessageGateway) forwardMessageToUser(message *customer_service.Message) {
+// handleServiceEvent 处理客服系统事件
+func (g *MessageGateway) handleServiceEvent(eventType string, payload interface{}) {
+	switch eventType {
+	case customer_service.EventSessionOffer, customer_service.EventAssignmentInvite:
+		offer := payload.(customer_service.Offer)
+		if staff := g.service.GetStaff(offer.StaffID); staff != nil {
+			g.writeJSON(staff.Conn, eventType, offer)
+		}
+
+	case customer_service.EventSessionAssigned:
+		event := payload.(customer_service.SessionEvent)
+		if session := g.service.GetSession(event.SessionID); session != nil {
+			g.notifySessionCreated(session)
+		}
+
+	case customer_service.EventSessionTransferred:
+		event := payload.(customer_service.SessionEvent)
+		g.notifySessionTransferred(event.SessionID, event.PrevStaffID, event.StaffID)
+
+	case customer_service.EventSystemMessage:
+		message := payload.(customer_service.Message)
+		g.deliverMessage(&message)
+
+	case customer_service.EventQueuePosition:
+		position := payload.(customer_service.QueuePosition)
+		if user := g.service.GetUser(position.UserID); user != nil {
+			g.writeJSON(user.Conn, eventType, position)
+		}
+
+	case customer_service.EventQueueCancelled:
+		event := payload.(customer_service.SessionEvent)
+		if user := g.service.GetUser(event.UserID); user != nil {
+			g.writeJSON(user.Conn, eventType, event)
+		}
+
+	case customer_service.EventSLAWarning, customer_service.EventSLABreach:
+		event := payload.(customer_service.SLAEvent)
+		g.notifyStaff(event.StaffID, eventType, event)
+		for _, id := range g.service.ListSupervisors() {
+			if supervisor := g.service.GetSupervisor(id); supervisor != nil {
+				g.writeJSON(supervisor.Conn, eventType, event)
+			}
+		}
+
+	case customer_service.EventStaffGroupChanged:
+		// 移入其他组时通知客服，被断开时先告知原因再关闭连接
+		change := payload.(customer_service.StaffGroupChange)
+		if change.NewGroupID != "" {
+			if staff := g.service.GetStaff(change.StaffID); staff != nil {
+				g.writeJSON(staff.Conn, eventType, change)
+			}
+			break
+		}
+		g.writeJSON(change.Conn, eventType, change)
+		g.CloseConnection(change.Conn, CloseReasonGroupDeleted)
+
+	case customer_service.EventStaffUpdated:
+		for _, id := range g.service.ListSupervisors() {
+			if supervisor := g.service.GetSupervisor(id); supervisor != nil {
+				g.writeJSON(supervisor.Conn, eventType, payload)
+			}
+		}
+
+	case customer_service.EventMessageRecalled:
+		message := payload.(customer_service.Message)
+		g.notifyParticipants(message.SessionID, eventType, newMessageDTO(&message))
+
+	case customer_service.EventSessionStatus:
+		// 状态变更同时告知变更时的用户和客服，排队中的会话没有客服
+		change := payload.(customer_service.SessionStatusChange)
+		g.deliverTo(change.UserID, eventType, change)
+		if change.StaffID != "" && g.service.NotificationEnabled(change.StaffID, eventType) {
+			g.deliverTo(change.StaffID, eventType, change)
+		}
+
+	case customer_service.EventSessionRequeued, customer_service.EventSessionClosed:
+		event := payload.(customer_service.SessionEvent)
+		g.notifyStaff(event.StaffID, eventType, event)
+		if eventType == customer_service.EventSessionClosed {
+			if user := g.service.GetUser(event.UserID); user != nil {
+				g.writeJSON(user.Conn, eventType, event)
+			}
+		}
+	}
+}
+
+// notifyStaff 向客服发送一条辅助通知，客服关闭了该类通知时不发送。消息投递不经过这里，不受通知设置影响
+func (g *MessageGateway) notifyStaff(staffID, eventType string, payload interface{}) {
+	if !g.service.NotificationEnabled(staffID, eventType) {
+		return
+	}
+	if staff := g.service.GetStaff(staffID); staff != nil {
+		g.writeJSON(staff.Conn, eventType, payload)
+	}
+}
+
+// writeJSON 向连接写入一条网关消息
+func (g *MessageGateway) writeJSON(conn *websocket.Conn, msgType string, payload interface{}) {
+	if conn == nil {
+		return
+	}
 	response := map[string]interface{}{
-		"type":    "message",
-		"payload": message,
+		"type":    msgType,
+		"payload": payload,
 	}
 	data, _ := json.Marshal(response)
+	g.sendLane(conn, sendLaneOf(payload), data)
+}
 
-	user := g.service.GetUser(message.ToID)
-	if user != nil {
-		user.Conn.WriteMessage(websocket.TextMessage, data)
+// deliverMessage 按消息接收方投递：指定接收者时只发给该参与者，否则发给发送者以外的全部参与者
+func (g *MessageGateway) deliverMessage(message *customer_service.Message) {
+	dto := newMessageDTO(message)
+	for _, id := range g.service.MessageRecipients(message) {
+		// 接收者在其他实例上连接时由该实例投递，确认也由该实例跟踪
+		if g.deliverTo(id, "message", dto) {
+			g.trackDelivery(id, message, dto)
+			continue
+		}
+		// 接收者在任何实例上都没有连接时（未连接或在重连宽限期内）存入离线队列等待重新连接，
+		// 在其他实例上连接时已经总线交给该实例投递
+		if g.remoteInstance(id) == "" {
+			if err := g.service.QueueOfflineMessage(id, message); err != nil {
+				log.Printf("Error queueing offline message for %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// flushOffline 向刚连接的用户或客服补发离线期间的消息
+func (g *MessageGateway) flushOffline(conn *websocket.Conn, id string) {
+	messages, err := g.service.TakeOfflineMessages(id)
+	if err != nil {
+		log.Printf("Error loading offline messages for %s: %v", id, err)
+		return
+	}
+	for _, message := range messages {
+		dto := newMessageDTO(message)
+		g.writeJSON(conn, "message", dto)
+		g.trackDelivery(id, message, dto)
+	}
+}
+
+// participantConn 查找会话参与者的连接，依次匹配用户、客服和主管
+func (g *MessageGateway) participantConn(id string) *websocket.Conn {
+	if user := g.service.GetUser(id); user != nil {
+		return user.Conn
+	}
+	if staff := g.service.GetStaff(id); staff != nil {
+		return staff.Conn
+	}
+	if supervisor := g.service.GetSupervisor(id); supervisor != nil {
+		return supervisor.Conn
+	}
+	return nil
+}
+
+// sendAttachment 发送附件消息并转发给接收方，附件未通过检查时回复attachment_rejected
+func (g *MessageGateway) sendAttachment(conn *websocket.Conn, sessionID, fromID string, att customer_service.Attachment) {
+	message, err := g.service.SendAttachment(sessionID, fromID, att)
+	if err != nil {
+		log.Printf("Error sending attachment: %v", err)
+		g.writeError(conn, err)
+		return
+	}
+	g.deliverMessage(message)
+}
+
+// forwardTyping 将输入状态转发给会话另一方，草稿是否携带由客服组配置决定
+func (g *MessageGateway) forwardTyping(conn *websocket.Conn, sessionID, fromID, draft string) {
+	typing, err := g.service.NotifyTyping(sessionID, fromID, draft)
+	if err != nil {
+		g.writeError(conn, err)
+		return
 	}
+	g.writeJSON(g.participantConn(typing.ToID), "typing", typing)
 }
 
-// notifySessionCreated 通知会话创建
+// notifyParticipants 向会话全部参与者发送同一条网关消息
+func (g *MessageGateway) notifyParticipants(sessionID, msgType string, payload interface{}) {
+	for _, id := range g.service.SessionParticipants(sessionID) {
+		g.writeJSON(g.participantConn(id), msgType, payload)
+	}
+}
+
+// SessionDTO 会话创建通知中用户和客服都可见的会话字段，字段名沿用原有的下发格式。
+// 转接记录、加入的主管等内部状态不下发
+type SessionDTO struct {
+	ID          string
+	UserID      string
+	StaffID     string
+	GroupID     string
+	Channel     string
+	Subject     string
+	Status      customer_service.SessionStatus
+	CreateAt    time.Time
+	UpdateAt    time.Time
+	Messages    []MessageDTO
+	LastMessage *MessageDTO
+}
+
+// UserSessionDTO 下发给用户的会话创建通知，附带重新连接时使用的重连令牌
+type UserSessionDTO struct {
+	SessionDTO
+	ResumeToken string `json:"resume_token,omitempty"`
+}
+
+// StaffSessionDTO 下发给客服的会话创建通知，附带集成方设置的会话自定义字段
+type StaffSessionDTO struct {
+	SessionDTO
+	Variables map[string]string `json:",omitempty"`
+}
+
+// newSessionDTO 由会话快照构造下发结构
+func newSessionDTO(snap customer_service.SessionSnapshot) SessionDTO {
+	dto := SessionDTO{
+		ID:       snap.ID,
+		UserID:   snap.UserID,
+		StaffID:  snap.StaffID,
+		GroupID:  snap.GroupID,
+		Channel:  snap.Channel,
+		Subject:  snap.Subject,
+		Status:   snap.Status,
+		CreateAt: snap.CreateAt,
+		UpdateAt: snap.UpdateAt,
+		Messages: newMessageDTOs(snap.Messages),
+	}
+	if n := len(dto.Messages); n > 0 {
+		dto.LastMessage = &dto.Messages[n-1]
+	}
+	return dto
+}
+
+// notifySessionCreated 通知会话创建，负载由服务锁内复制的会话快照构造
 func (g *MessageGateway) notifySessionCreated(session *customer_service.Session) {
-	response := map[string]interface{}{
-		"type":    "session_created",
-		"payload": session,
+	snap, err := g.service.GetSessionSnapshot(session.ID)
+	if err != nil {
+		log.Printf("Error loading session %s for notification: %v", session.ID, err)
+		return
 	}
-	data, _ := json.Marshal(response)
+	dto := newSessionDTO(snap)
 
 	// 通知用户
-	user := g.service.GetUser(session.UserID)
-	if user != nil {
-		user.Conn.WriteMessage(websocket.TextMessage, data)
+	if g.service.GetUser(snap.UserID) != nil {
+		g.deliverTo(snap.UserID, "session_created", UserSessionDTO{
+			SessionDTO:  dto,
+			ResumeToken: g.issueResumeToken(snap.UserID),
+		})
 	}
 
 	// 通知客服
-	staff := g.service.GetStaff(session.StaffID)
-	if staff != nil {
-		staff.Conn.WriteMessage(websocket.TextMessage, data)
+	if g.service.GetStaff(snap.StaffID) != nil {
+		g.deliverTo(snap.StaffID, "session_created", StaffSessionDTO{
+			SessionDTO: dto,
+			Variables:  snap.Variables,
+		})
 	}
 }
 
@@ -273,18 +1291,24 @@ func (g *MessageGateway) notifySessionTransferred(sessionID, oldStaffID, newStaf
 	// 通知用户
 	user := g.service.GetUser(session.UserID)
 	if user != nil {
-		user.Conn.WriteMessage(websocket.TextMessage, data)
+		g.send(user.Conn, data)
 	}
 
-	// 通知原客服
+	// 通知原客服，原客服可以关闭该通知；新客服的通知带有接手的会话，始终发送
 	oldStaff := g.service.GetStaff(oldStaffID)
-	if oldStaff != nil {
-		oldStaff.Conn.WriteMessage(websocket.TextMessage, data)
+	if oldStaff != nil && g.service.NotificationEnabled(oldStaffID, "session_transferred") {
+		g.send(oldStaff.Conn, data)
 	}
 
-	// 通知新客服
+	// 通知新客服，附带内部备注作为接手的上下文，备注只发给新客服
 	newStaff := g.service.GetStaff(newStaffID)
 	if newStaff != nil {
-		newStaff.Conn.WriteMessage(websocket.TextMessage, data)
+		notes, _ := g.service.Notes(sessionID, newStaffID)
+		g.writeJSON(newStaff.Conn, "session_transferred", map[string]interface{}{
+			"session_id":   sessionID,
+			"old_staff_id": oldStaffID,
+			"new_staff_id": newStaffID,
+			"notes":        notes,
+		})
 	}
 }
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
+	"clash/internal/domain/customer_service"
+
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
@@ -84,9 +87,10 @@ func TestMessageGateway(t *testing.T) {
 	assert.Equal(t, "message", receivedMsg["type"])
 
 	// 客服回复消息
+	sessionID := sessionCreatedMsg["payload"].(map[string]interface{})["ID"].(string)
 	staffMsg := WSMessage{
 		Type: "message",
-		Payload: json.RawMessage(`{"session_id":"user1_staff1_" + time.Now().Format("20060102150405"), "content":"你好，我是客服1"}`),
+		Payload: json.RawMessage(`{"session_id":"` + sessionID + `", "content":"你好，我是客服1"}`),
 	}
 	data, _ = json.Marshal(staffMsg)
 	err = staffConn.WriteMessage(websocket.TextMessage, data)
@@ -102,4 +106,1032 @@ func TestMessageGateway(t *testing.T) {
 
 	// 等待一段时间确保消息都已处理
 	time.Sleep(time.Second)
-}
\ No newline at end of file
+}
+
+// newTestGateway 创建网关及测试服务器，连接入口不带路径前缀
+func newTestGateway(t *testing.T, opts ...GatewayOption) (*MessageGateway, *httptest.Server) {
+	gateway := NewMessageGateway(append([]GatewayOption{WithRoutePrefix("")}, opts...)...)
+	mux := http.NewServeMux()
+	gateway.RegisterRoutes(mux)
+	return gateway, httptest.NewServer(mux)
+}
+
+// dialTestGateway 连接测试服务器的指定路径
+func dialTestGateway(t *testing.T, server *httptest.Server, path string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + path
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// readTestMessage 读取一条消息并解析为map
+func readTestMessage(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// readTestMessageExcept 读取下一条类型不在skip中的网关消息
+func readTestMessageExcept(t *testing.T, conn *websocket.Conn, skip ...string) map[string]interface{} {
+	for {
+		msg := readTestMessage(t, conn)
+		skipped := false
+		for _, msgType := range skip {
+			if msg["type"] == msgType {
+				skipped = true
+			}
+		}
+		if !skipped {
+			return msg
+		}
+	}
+}
+
+// writeTestMessage 发送一条网关消息
+func writeTestMessage(t *testing.T, conn *websocket.Conn, msgType, payload string) {
+	data, err := json.Marshal(WSMessage{Type: msgType, Payload: json.RawMessage(payload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMessageGateway_BatchMessages(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	writeTestMessage(t, staffConn, "connect_user", `{"user_id":"user1"}`)
+	assert.Equal(t, "session_created", readTestMessage(t, staffConn)["type"])
+	assert.Equal(t, "session_created", readTestMessage(t, userConn)["type"])
+
+	// 用户批量发送，其中第二条为空内容
+	writeTestMessage(t, userConn, "messages", `{"contents":["one","","three"]}`)
+
+	result := readTestMessage(t, userConn)
+	assert.Equal(t, "messages_result", result["type"])
+	results := result["payload"].(map[string]interface{})["results"].([]interface{})
+	assert.Len(t, results, 3)
+	assert.NotEmpty(t, results[0].(map[string]interface{})["message_id"])
+	assert.Equal(t, "empty message content", results[1].(map[string]interface{})["error"])
+	assert.Equal(t, "empty_content", results[1].(map[string]interface{})["code"])
+	assert.Nil(t, results[1].(map[string]interface{})["message_id"])
+	assert.NotEmpty(t, results[2].(map[string]interface{})["message_id"])
+
+	// 客服按顺序收到两条成功的消息
+	first := readTestMessage(t, staffConn)["payload"].(map[string]interface{})
+	second := readTestMessage(t, staffConn)["payload"].(map[string]interface{})
+	assert.Equal(t, "one", first["content"])
+	assert.Equal(t, "three", second["content"])
+}
+
+func TestMessageGateway_SupervisorPresence(t *testing.T) {
+	_, server := newTestGateway(t)
+	defer server.Close()
+
+	supervisorConn := dialTestGateway(t, server, "/supervisor?supervisor_id=sup1")
+	defer supervisorConn.Close()
+	// 读循环在订阅建立后才开始，收到查询回复说明订阅已建立
+	writeTestMessage(t, supervisorConn, "list_presence", `{}`)
+	assert.Equal(t, "presence_list", readTestMessage(t, supervisorConn)["type"])
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	event := readTestMessage(t, supervisorConn)
+	assert.Equal(t, "presence", event["type"])
+	payload := event["payload"].(map[string]interface{})
+	assert.Equal(t, "user1", payload["id"])
+	assert.Equal(t, "user", payload["role"])
+	assert.Equal(t, true, payload["online"])
+	assert.Equal(t, "online", payload["state"])
+
+	// 主管可以查询推断的在线状态
+	writeTestMessage(t, supervisorConn, "list_presence", `{}`)
+	list := readTestMessage(t, supervisorConn)
+	assert.Equal(t, "presence_list", list["type"])
+	views := list["payload"].([]interface{})
+	if assert.Len(t, views, 1) {
+		view := views[0].(map[string]interface{})
+		assert.Equal(t, "user1", view["id"])
+		assert.Equal(t, "online", view["state"])
+		assert.NotEmpty(t, view["last_seen_at"])
+	}
+
+	userConn.Close()
+	event = readTestMessage(t, supervisorConn)
+	payload = event["payload"].(map[string]interface{})
+	assert.Equal(t, "user1", payload["id"])
+	assert.Equal(t, false, payload["online"])
+}
+
+func TestMessageGateway_SessionOffer(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staff1Conn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staff1Conn.Close()
+	staff2Conn := dialTestGateway(t, server, "/staff?staff_id=staff2&name=客服2&group_id=group1")
+	defer staff2Conn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	// 用户排队后第一位客服收到邀请
+	writeTestMessage(t, userConn, "enqueue", `{"group_id":"group1"}`)
+	position := readTestMessage(t, userConn)
+	assert.Equal(t, "queue_position", position["type"])
+	assert.Equal(t, float64(1), position["payload"].(map[string]interface{})["position"])
+	offer := readTestMessage(t, staff1Conn)
+	assert.Equal(t, "session_offer", offer["type"])
+	offerID := offer["payload"].(map[string]interface{})["id"].(string)
+
+	// 第一位客服拒绝，第二位客服收到邀请并接受
+	writeTestMessage(t, staff1Conn, "decline_offer", `{"offer_id":"`+offerID+`"}`)
+	offer = readTestMessage(t, staff2Conn)
+	assert.Equal(t, "session_offer", offer["type"])
+	assert.Equal(t, offerID, offer["payload"].(map[string]interface{})["id"])
+
+	writeTestMessage(t, staff2Conn, "accept_offer", `{"offer_id":"`+offerID+`"}`)
+	created := readTestMessage(t, staff2Conn)
+	assert.Equal(t, "session_created", created["type"])
+	assert.Equal(t, "staff2", created["payload"].(map[string]interface{})["StaffID"])
+	assert.Equal(t, "session_created", readTestMessageExcept(t, userConn, "queue_position")["type"])
+}
+
+func TestMessageGateway_OutOfHoursAutoReply(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Weekday()
+	gateway.service.SetBusinessHours("group1", &customer_service.BusinessHours{
+		Days:      []time.Weekday{tomorrow},
+		AutoReply: "现在是休息时间",
+	})
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	writeTestMessage(t, userConn, "enqueue", `{"group_id":"group1"}`)
+	reply := readTestMessage(t, userConn)
+	assert.Equal(t, "auto_reply", reply["type"])
+	assert.Equal(t, "现在是休息时间", reply["payload"].(map[string]interface{})["content"])
+
+	// 用户转为留言
+	writeTestMessage(t, userConn, "leave_message", `{"subject":"咨询","body":"请回电","contact":"13800000000"}`)
+	created := readTestMessage(t, userConn)
+	assert.Equal(t, "ticket_created", created["type"])
+	assert.Equal(t, "group1", created["payload"].(map[string]interface{})["group_id"])
+	assert.Len(t, gateway.service.ListTickets("group1"), 1)
+}
+
+func TestMessageGateway_TransferSystemMessage(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staff1Conn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staff1Conn.Close()
+	staff2Conn := dialTestGateway(t, server, "/staff?staff_id=staff2&name=客服2&group_id=group1")
+	defer staff2Conn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 转接通知与系统消息到达顺序不固定，按类型归类
+	writeTestMessage(t, staff1Conn, "transfer_session", `{"session_id":"`+session.ID+`","new_staff_id":"staff2"}`)
+	received := map[string]map[string]interface{}{}
+	for i := 0; i < 2; i++ {
+		msg := readTestMessage(t, userConn)
+		received[msg["type"].(string)] = msg["payload"].(map[string]interface{})
+	}
+	assert.Contains(t, received, "session_transferred")
+	system := received["message"]
+	assert.Equal(t, customer_service.SystemSenderID, system["from_id"])
+	assert.Equal(t, "", system["to_id"])
+	assert.Equal(t, "system", system["type"])
+}
+
+func TestMessageGateway_SupervisorTargetedMessage(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	supervisorConn := dialTestGateway(t, server, "/supervisor?supervisor_id=sup1")
+	defer supervisorConn.Close()
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	writeTestMessage(t, supervisorConn, "join_session", `{"session_id":"`+session.ID+`"}`)
+	assert.Eventually(t, func() bool {
+		return len(gateway.service.SessionParticipants(session.ID)) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// 指定接收者的消息只发给客服，广播消息发给用户和客服
+	writeTestMessage(t, supervisorConn, "message", `{"session_id":"`+session.ID+`","to_id":"staff1","content":"whisper"}`)
+	writeTestMessage(t, supervisorConn, "message", `{"session_id":"`+session.ID+`","content":"everyone"}`)
+
+	whisper := readTestMessage(t, staffConn)["payload"].(map[string]interface{})
+	assert.Equal(t, "whisper", whisper["content"])
+	assert.Equal(t, "staff1", whisper["to_id"])
+	broadcast := readTestMessage(t, staffConn)["payload"].(map[string]interface{})
+	assert.Equal(t, "everyone", broadcast["content"])
+	assert.Equal(t, "", broadcast["to_id"])
+	received := readTestMessage(t, userConn)["payload"].(map[string]interface{})
+	assert.Equal(t, "everyone", received["content"])
+
+	// 客服回复主管，跳过主管收到的上下线事件
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","to_id":"sup1","content":"reply"}`)
+	msg := readTestMessageExcept(t, supervisorConn, "presence", "transcript")
+	assert.Equal(t, "message", msg["type"])
+	assert.Equal(t, "reply", msg["payload"].(map[string]interface{})["content"])
+}
+
+func TestMessageGateway_StaffUpdated(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	supervisorConn := dialTestGateway(t, server, "/supervisor?supervisor_id=sup1")
+	defer supervisorConn.Close()
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && len(gateway.service.ListSupervisors()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// 客服状态变化推送给主管，跳过上下线事件
+	assert.NoError(t, gateway.service.SetStaffStatus("staff1", customer_service.UserStatusAway))
+	msg := readTestMessageExcept(t, supervisorConn, "presence")
+	assert.Equal(t, "staff_updated", msg["type"])
+	payload := msg["payload"].(map[string]interface{})
+	assert.Equal(t, "staff1", payload["id"])
+	assert.Equal(t, float64(customer_service.UserStatusAway), payload["status"])
+}
+
+func TestMessageGateway_SupervisorAdminOverrides(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	supervisorConn := dialTestGateway(t, server, "/supervisor?supervisor_id=sup1")
+	defer supervisorConn.Close()
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	staff2Conn := dialTestGateway(t, server, "/staff?staff_id=staff2&name=客服2&group_id=group1")
+	defer staff2Conn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff2") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 主管未加入会话也可以强制转接
+	writeTestMessage(t, supervisorConn, "admin_transfer", `{"session_id":"`+session.ID+`","to_staff_id":"staff2","reason":"rebalance"}`)
+	msg := readTestMessageExcept(t, staff2Conn, "message")
+	assert.Equal(t, "session_transferred", msg["type"])
+	payload := msg["payload"].(map[string]interface{})
+	assert.Equal(t, "staff1", payload["old_staff_id"])
+	assert.Equal(t, "staff2", payload["new_staff_id"])
+
+	// 强制关闭后通知用户和当前客服
+	writeTestMessage(t, supervisorConn, "admin_close", `{"session_id":"`+session.ID+`","reason":"abuse"}`)
+	msg = readTestMessageExcept(t, staff2Conn, "message", "session_status")
+	assert.Equal(t, "session_closed", msg["type"])
+	assert.Equal(t, customer_service.ReasonClosedByAdmin, msg["payload"].(map[string]interface{})["reason"])
+
+	audit := gateway.service.AuditLog()
+	if assert.Len(t, audit, 2) {
+		assert.Equal(t, "sup1", audit[0].ActorID)
+		assert.Equal(t, customer_service.AuditAdminClose, audit[1].Action)
+	}
+
+	// 已关闭的会话返回错误
+	writeTestMessage(t, supervisorConn, "admin_close", `{"session_id":"`+session.ID+`"}`)
+	msg = readTestMessageExcept(t, supervisorConn, "presence")
+	assert.Equal(t, "error", msg["type"])
+	assert.Equal(t, customer_service.CodeSessionClosed, msg["payload"].(map[string]interface{})["code"])
+}
+
+func TestMessageGateway_SessionVariables(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	session.SetVariable("order_id", "A1001")
+	gateway.notifySessionCreated(session)
+
+	// 自定义字段只出现在客服收到的通知中
+	msg := readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "session_created", msg["type"])
+	payload := msg["payload"].(map[string]interface{})
+	assert.Equal(t, "user1", payload["UserID"])
+	assert.Equal(t, map[string]interface{}{"order_id": "A1001"}, payload["Variables"])
+
+	assert.NotContains(t, payload, "resume_token")
+
+	// 用户收到重连令牌，转接记录和主管等内部状态不下发给任何一方
+	msg = readTestMessage(t, userConn)
+	assert.Equal(t, "session_created", msg["type"])
+	userPayload := msg["payload"].(map[string]interface{})
+	assert.NotContains(t, userPayload, "Variables")
+	assert.NotEmpty(t, userPayload["resume_token"])
+	for _, field := range []string{"Transfers", "Supervisors", "Rating", "Orphaned"} {
+		assert.NotContains(t, payload, field)
+		assert.NotContains(t, userPayload, field)
+	}
+}
+
+func TestMessageGateway_PreSessionMessages(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithPreSessionBuffer(4)))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 会话建立前发送的两条消息在会话建立后出现在会话中
+	writeTestMessage(t, userConn, "message", `{"content":"first"}`)
+	writeTestMessage(t, userConn, "message", `{"content":"second"}`)
+	// 批量消息不缓存，收到其错误回复说明前两条消息已处理
+	writeTestMessage(t, userConn, "messages", `{"contents":["third"]}`)
+	assertErrorResponse(t, userConn, customer_service.CodeNoActiveSession)
+
+	writeTestMessage(t, staffConn, "connect_user", `{"user_id":"user1"}`)
+	msg := readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "session_created", msg["type"])
+	messages := msg["payload"].(map[string]interface{})["Messages"].([]interface{})
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "first", messages[0].(map[string]interface{})["content"])
+		assert.Equal(t, "second", messages[1].(map[string]interface{})["content"])
+	}
+}
+
+func TestMessageGateway_NoActiveSession(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	writeTestMessage(t, userConn, "message", `{"content":"hi"}`)
+	assertErrorResponse(t, userConn, customer_service.CodeNoActiveSession)
+}
+
+func TestMessageGateway_NoGoroutineLeak(t *testing.T) {
+	gateway, server := newTestGateway(t, WithPingInterval(5*time.Millisecond))
+	defer server.Close()
+	gateway.service.CreateGroup("group1", "测试客服组")
+
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+		staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+		supervisorConn := dialTestGateway(t, server, "/supervisor?supervisor_id=sup1")
+		assert.Eventually(t, func() bool {
+			return gateway.service.GetUser("user1") != nil &&
+				gateway.service.GetStaff("staff1") != nil &&
+				len(gateway.service.ListSupervisors()) == 1
+		}, time.Second, time.Millisecond)
+
+		userConn.Close()
+		staffConn.Close()
+		supervisorConn.Close()
+		assert.Eventually(t, func() bool {
+			return gateway.service.GetUser("user1") == nil &&
+				gateway.service.GetStaff("staff1") == nil &&
+				len(gateway.service.ListSupervisors()) == 0
+		}, time.Second, time.Millisecond)
+	}
+
+	// 连接关闭后发送队列、心跳和事件转发协程全部退出。
+	// assert.Eventually会在额外的协程中执行检查，这里手动轮询
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline)
+}
+
+func TestMessageGateway_AutoTransferOnAway(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithAutoTransferOnAway(20*time.Millisecond)))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	staff2Conn := dialTestGateway(t, server, "/staff?staff_id=staff2&name=客服2&group_id=group1")
+	defer staff2Conn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff2") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	assert.NoError(t, gateway.service.SetStaffStatus("staff1", customer_service.UserStatusAway))
+
+	// 宽限期结束后通知用户和新客服
+	msg := readTestMessageExcept(t, staff2Conn, "message")
+	assert.Equal(t, "session_transferred", msg["type"])
+	payload := msg["payload"].(map[string]interface{})
+	assert.Equal(t, session.ID, payload["session_id"])
+	assert.Equal(t, "staff1", payload["old_staff_id"])
+	assert.Equal(t, "staff2", payload["new_staff_id"])
+
+	msg = readTestMessageExcept(t, userConn, "message")
+	assert.Equal(t, "session_transferred", msg["type"])
+}
+
+func TestMessageGateway_SupervisorTranscript(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	supervisorConn := dialTestGateway(t, server, "/supervisor?supervisor_id=sup1")
+	defer supervisorConn.Close()
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil &&
+			len(gateway.service.ListSupervisors()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+	writeTestMessage(t, userConn, "message", `{"content":"before1"}`)
+	assert.Equal(t, "before1", readTestMessage(t, staffConn)["payload"].(map[string]interface{})["content"])
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","content":"before2"}`)
+	assert.Equal(t, "before2", readTestMessage(t, userConn)["payload"].(map[string]interface{})["content"])
+
+	// 中途加入的主管先收到此前的完整记录
+	writeTestMessage(t, supervisorConn, "join_session", `{"session_id":"`+session.ID+`"}`)
+	msg := readTestMessageExcept(t, supervisorConn, "presence")
+	assert.Equal(t, "transcript", msg["type"])
+	transcript := msg["payload"].(map[string]interface{})
+	assert.Equal(t, session.ID, transcript["session_id"])
+	history := transcript["messages"].([]interface{})
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "before1", history[0].(map[string]interface{})["content"])
+		assert.Equal(t, "before2", history[1].(map[string]interface{})["content"])
+	}
+
+	// 之后的消息实时推送，且只收到一次
+	writeTestMessage(t, userConn, "message", `{"content":"after1"}`)
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+session.ID+`","content":"after2"}`)
+	var live []string
+	for len(live) < 2 {
+		msg = readTestMessageExcept(t, supervisorConn, "presence")
+		assert.Equal(t, "message", msg["type"])
+		live = append(live, msg["payload"].(map[string]interface{})["content"].(string))
+	}
+	assert.ElementsMatch(t, []string{"after1", "after2"}, live)
+
+	supervisorConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	for {
+		_, data, err := supervisorConn.ReadMessage()
+		if err != nil {
+			break
+		}
+		var extra map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &extra))
+		assert.Equal(t, "presence", extra["type"], "unexpected frame %s", data)
+	}
+}
+
+func TestMessageGateway_RejectInvalidIdentity(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+	gateway.service.CreateGroup("group1", "测试客服组")
+
+	paths := []string{
+		"/user?user_id=user1&name=%20%20%20",
+		"/user?user_id=" + strings.Repeat("u", 65) + "&name=用户1",
+		"/user?user_id=user1&name=%07bell",
+		"/staff?staff_id=staff%0A1&name=客服1&group_id=group1",
+	}
+	for _, path := range paths {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + path
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		assert.Error(t, err, path)
+		if assert.NotNil(t, resp, path) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+			resp.Body.Close()
+		}
+	}
+	assert.Nil(t, gateway.service.GetUser("user1"))
+}
+
+func TestMessageGateway_TypingDrafts(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 未开启草稿共享时客服只收到正在输入
+	writeTestMessage(t, userConn, "typing", `{"draft":"我的订单"}`)
+	msg := readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "typing", msg["type"])
+	payload := msg["payload"].(map[string]interface{})
+	assert.Equal(t, session.ID, payload["session_id"])
+	assert.Equal(t, "user1", payload["from_id"])
+	assert.NotContains(t, payload, "draft")
+
+	assert.NoError(t, gateway.service.SetShareDrafts("group1", true))
+	writeTestMessage(t, userConn, "typing", `{"draft":"我的订单"}`)
+	msg = readTestMessage(t, staffConn)
+	assert.Equal(t, "typing", msg["type"])
+	assert.Equal(t, "我的订单", msg["payload"].(map[string]interface{})["draft"])
+
+	// 客服输入状态转给用户
+	writeTestMessage(t, staffConn, "typing", `{"session_id":"`+session.ID+`"}`)
+	msg = readTestMessage(t, userConn)
+	assert.Equal(t, "typing", msg["type"])
+	assert.Equal(t, "staff1", msg["payload"].(map[string]interface{})["from_id"])
+	assert.Empty(t, session.Messages)
+}
+
+func TestMessageGateway_MaxConnectionsPerIdentity(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithMaxConnectionsPerIdentity(2)))
+	defer server.Close()
+
+	// 同一用户未凭令牌的第二个连接会被拒绝，这里用客服身份占满连接数
+	gateway.service.CreateGroup("group1", "测试客服组")
+	for i := 0; i < 2; i++ {
+		conn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+		defer conn.Close()
+	}
+	assert.Eventually(t, func() bool {
+		return gateway.service.ConnectionCount(customer_service.PresenceRoleStaff, "staff1") == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// 超出上限的连接在升级前被拒绝
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/staff?staff_id=staff1&name=客服1&group_id=group1"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Error(t, err)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	// 其他身份不受影响
+	other := dialTestGateway(t, server, "/user?user_id=user2&name=用户2")
+	other.Close()
+}
+
+func TestMessageGateway_ClaimNext(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	writeTestMessage(t, staffConn, "claim_next", `{}`)
+	assertErrorResponse(t, staffConn, customer_service.CodeNoWaitingUsers)
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	writeTestMessage(t, userConn, "enqueue", `{"group_id":"group1"}`)
+	assert.Equal(t, "session_offer", readTestMessageExcept(t, staffConn, "presence")["type"])
+
+	// 客服直接领取，无需等待邀请
+	writeTestMessage(t, staffConn, "claim_next", `{}`)
+	created := readTestMessage(t, staffConn)
+	assert.Equal(t, "session_created", created["type"])
+	assert.Equal(t, "user1", created["payload"].(map[string]interface{})["UserID"])
+	assert.Equal(t, "session_created", readTestMessageExcept(t, userConn, "queue_position")["type"])
+}
+
+func TestMessageGateway_SearchCanned(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.AddCanned("group1", customer_service.CannedResponse{Key: "refund", Category: "billing", Text: "Your refund is on its way"})
+	gateway.service.AddCanned("group1", customer_service.CannedResponse{Key: "greet", Category: "general", Text: "Hello"})
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+
+	writeTestMessage(t, staffConn, "search_canned", `{"query":"billing"}`)
+	reply := readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "canned_results", reply["type"])
+	results := reply["payload"].(map[string]interface{})["results"].([]interface{})
+	assert.Len(t, results, 1)
+	assert.Equal(t, "refund", results[0].(map[string]interface{})["key"])
+}
+
+func TestMessageGateway_UserReady(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithRequireUserReady(true)))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 未提交会话前表单时不能排队
+	writeTestMessage(t, userConn, "enqueue", `{"group_id":"group1"}`)
+	assertErrorResponse(t, userConn, customer_service.CodeUserNotReady)
+	assert.Empty(t, gateway.service.QueuedUsers("group1"))
+
+	writeTestMessage(t, userConn, "user_ready", `{"group_id":"group1","fields":{"issue":"refund"}}`)
+	assert.Eventually(t, func() bool {
+		return len(gateway.service.QueuedUsers("group1")) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, gateway.service.IsUserReady("user1"))
+}
+
+func TestMessageGateway_RequestSession(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectStaff("staff1", "客服1", "group1", nil)
+	gateway.service.ConnectStaff("staff2", "客服2", "group1", nil)
+	gateway.service.SetAutoAccept("staff1", true)
+	gateway.service.SetAutoAccept("staff2", true)
+	gateway.service.UpdateSkills("staff2", []string{"billing"})
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	writeTestMessage(t, userConn, "request_session", `{"group_id":"group1","subject":"billing"}`)
+	resp := readTestMessageExcept(t, userConn, "presence", "queue_position")
+	assert.Equal(t, "session_created", resp["type"])
+
+	session := gateway.service.GetSession(gateway.service.GetUser("user1").SessionID)
+	if assert.NotNil(t, session) {
+		assert.Equal(t, "staff2", session.StaffID)
+		assert.Equal(t, "billing", session.Subject)
+	}
+
+	// 已在会话中时不能再次发起，排队位置通知与会话消息分通道发送，可能晚于会话创建通知到达
+	writeTestMessage(t, userConn, "request_session", `{"group_id":"group1","subject":"billing"}`)
+	resp = readTestMessageExcept(t, userConn, "queue_position")
+	assert.Equal(t, "error", resp["type"])
+	assert.Equal(t, customer_service.CodeInvalidOperation, resp["payload"].(map[string]interface{})["code"])
+}
+
+func TestMessageGateway_SetFocus(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil
+	}, time.Second, 10*time.Millisecond)
+	gateway.service.ConnectUser("user1", "用户1", nil)
+	gateway.service.ConnectUser("user2", "用户2", nil)
+	gateway.service.CreateSession("user1", "staff1")
+	session, _ := gateway.service.CreateSession("user2", "staff1")
+
+	writeTestMessage(t, staffConn, "set_focus", `{"session_id":"`+session.ID+`"}`)
+	reply := readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "session_list", reply["type"])
+	summaries := reply["payload"].([]interface{})
+	if assert.Len(t, summaries, 2) {
+		assert.Equal(t, session.ID, summaries[0].(map[string]interface{})["session_id"])
+		assert.Equal(t, true, summaries[0].(map[string]interface{})["focused"])
+		assert.Equal(t, false, summaries[1].(map[string]interface{})["focused"])
+	}
+
+	writeTestMessage(t, staffConn, "set_focus", `{"session_id":"nonexistent"}`)
+	assertErrorResponse(t, staffConn, customer_service.CodeSessionNotFound)
+}
+
+func TestMessageGateway_ReopenSession(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectStaff("staff1", "客服1", "group1", nil)
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	session, _ := gateway.service.CreateSession("user1", "staff1")
+	gateway.service.CloseSession(session.ID, "user1")
+	assert.Equal(t, "session_closed", readTestMessageExcept(t, userConn, "presence", "session_status")["type"])
+	gateway.service.ConnectUser("user2", "用户2", nil)
+	other, _ := gateway.service.CreateSession("user2", "staff1")
+
+	// 不能重新打开其他用户的会话
+	writeTestMessage(t, userConn, "reopen_session", `{"session_id":"`+other.ID+`"}`)
+	assertErrorResponse(t, userConn, customer_service.CodeSessionNotFound)
+
+	writeTestMessage(t, userConn, "reopen_session", `{"session_id":"`+session.ID+`"}`)
+	resp := readTestMessageExcept(t, userConn, "presence", "session_status")
+	assert.Equal(t, "session_created", resp["type"])
+	assert.Equal(t, session.ID, resp["payload"].(map[string]interface{})["ID"])
+	assert.Equal(t, customer_service.SessionStatusActive, gateway.service.GetSession(session.ID).Status)
+}
+
+func TestMessageGateway_DisconnectReason(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staff1Conn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staff1Conn.Close()
+	staff2Conn := dialTestGateway(t, server, "/staff?staff_id=staff2&name=客服2&group_id=group1")
+	defer staff2Conn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetStaff("staff2") != nil
+	}, time.Second, 10*time.Millisecond)
+	gateway.service.ConnectUser("user1", "用户1", nil)
+	gateway.service.ConnectUser("user2", "用户2", nil)
+	loggedOut, _ := gateway.service.CreateSession("user1", "staff1")
+	dropped, _ := gateway.service.CreateSession("user2", "staff2")
+
+	// 主动退出关闭会话
+	writeTestMessage(t, staff1Conn, "logout", `{}`)
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetSession(loggedOut.ID).Status == customer_service.SessionStatusClosed
+	}, time.Second, 10*time.Millisecond)
+
+	// 连接异常断开时会话重新排队
+	staff2Conn.UnderlyingConn().Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetSession(dropped.ID).Status == customer_service.SessionStatusWaiting
+	}, time.Second, 10*time.Millisecond)
+
+	audit := gateway.service.AuditLog()
+	if assert.Len(t, audit, 2) {
+		assert.Equal(t, "logout", audit[0].Reason)
+		assert.Equal(t, "error", audit[1].Reason)
+	}
+}
+
+func TestMessageGateway_SystemBusy(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithSurgeProtection(1, time.Minute)))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectUser("user1", "用户1", nil)
+	assert.NoError(t, gateway.service.EnqueueUser("user1", "group1"))
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user2&name=用户2")
+	defer userConn.Close()
+
+	// 激增期间排队被拒绝，用户转为留言
+	writeTestMessage(t, userConn, "enqueue", `{"group_id":"group1"}`)
+	reply := readTestMessageExcept(t, userConn, "presence")
+	assert.Equal(t, "system_busy", reply["type"])
+	assert.Equal(t, "group1", reply["payload"].(map[string]interface{})["group_id"])
+	assert.True(t, gateway.service.SurgeActive())
+
+	writeTestMessage(t, userConn, "leave_message", `{"subject":"咨询","body":"请回电","contact":"13800000000"}`)
+	created := readTestMessageExcept(t, userConn, "presence")
+	assert.Equal(t, "ticket_created", created["type"])
+}
+
+func TestMessageGateway_SessionStatus(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	session, _ := gateway.service.CreateSession("user1", "staff1")
+
+	// 用户关闭会话，双方都收到状态变更
+	writeTestMessage(t, userConn, "close_session", `{"session_id":"`+session.ID+`"}`)
+	for _, conn := range []*websocket.Conn{userConn, staffConn} {
+		msg := readTestMessageExcept(t, conn, "presence", "session_created")
+		assert.Equal(t, "session_status", msg["type"])
+		payload := msg["payload"].(map[string]interface{})
+		assert.Equal(t, session.ID, payload["session_id"])
+		assert.Equal(t, "closed", payload["status"])
+		assert.Equal(t, "active", payload["prev_status"])
+		assert.Equal(t, customer_service.ReasonClosedByUser, payload["reason"])
+		assert.NotEmpty(t, payload["message"])
+	}
+}
+
+func TestMessageGateway_InviteStaff(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staff1Conn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staff1Conn.Close()
+	staff2Conn := dialTestGateway(t, server, "/staff?staff_id=staff2&name=客服2&group_id=group1")
+	defer staff2Conn.Close()
+	supervisorConn := dialTestGateway(t, server, "/supervisor?supervisor_id=sup1")
+	defer supervisorConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	writeTestMessage(t, userConn, "enqueue", `{"group_id":"group1"}`)
+	assert.Equal(t, "session_offer", readTestMessage(t, staff1Conn)["type"])
+
+	// 主管指定第二位客服接待，原邀请撤销
+	writeTestMessage(t, supervisorConn, "invite_staff", `{"to_staff_id":"staff2","user_id":"user1"}`)
+	invite := readTestMessage(t, staff2Conn)
+	assert.Equal(t, "assignment_invite", invite["type"])
+	offerID := invite["payload"].(map[string]interface{})["id"].(string)
+
+	writeTestMessage(t, staff2Conn, "accept_offer", `{"offer_id":"`+offerID+`"}`)
+	created := readTestMessage(t, staff2Conn)
+	assert.Equal(t, "session_created", created["type"])
+	assert.Equal(t, "staff2", created["payload"].(map[string]interface{})["StaffID"])
+	assert.Empty(t, gateway.service.QueuedUsers("group1"))
+
+	// 用户已不在排队中
+	writeTestMessage(t, supervisorConn, "invite_staff", `{"to_staff_id":"staff1","user_id":"user1"}`)
+	response := readTestMessageExcept(t, supervisorConn, "presence")
+	assert.Equal(t, "error", response["type"])
+	assert.Equal(t, customer_service.CodeInvalidOperation, response["payload"].(map[string]interface{})["code"])
+}
+
+func TestMessageGateway_TransferWithNotes(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staff1Conn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staff1Conn.Close()
+	staff2Conn := dialTestGateway(t, server, "/staff?staff_id=staff2&name=客服2&group_id=group1")
+	defer staff2Conn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff2") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	writeTestMessage(t, staff1Conn, "add_note", `{"session_id":"`+session.ID+`","content":"refund already approved"}`)
+	added := readTestMessageExcept(t, staff1Conn, "session_created")
+	assert.Equal(t, "note_added", added["type"])
+
+	// 新客服收到的转接通知附带备注
+	writeTestMessage(t, staff1Conn, "transfer_session", `{"session_id":"`+session.ID+`","new_staff_id":"staff2"}`)
+	transferred := readTestMessageExcept(t, staff2Conn, "message")
+	assert.Equal(t, "session_transferred", transferred["type"])
+	notes := transferred["payload"].(map[string]interface{})["notes"].([]interface{})
+	if assert.Len(t, notes, 1) {
+		note := notes[0].(map[string]interface{})
+		assert.Equal(t, "refund already approved", note["content"])
+		assert.Equal(t, "staff1", note["author_id"])
+	}
+
+	// 用户收到的通知和系统消息都不含备注
+	for i := 0; i < 2; i++ {
+		userConn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		_, data, err := userConn.ReadMessage()
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "refund already approved")
+		assert.NotContains(t, string(data), `"notes"`)
+	}
+}
+
+func TestMessageGateway_NotificationPrefs(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	sla := &customer_service.SLAConfig{FirstResponse: 200 * time.Millisecond, WarnBefore: 150 * time.Millisecond}
+	assert.NoError(t, gateway.service.SetSLA("group1", sla))
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	// 客服关闭SLA预警通知，超时通知仍然开启
+	writeTestMessage(t, staffConn, "notification_pref", `{"event_type":"sla_warning","on":false}`)
+	updated := readTestMessage(t, staffConn)
+	assert.Equal(t, "notification_pref_updated", updated["type"])
+	assert.Equal(t, false, updated["payload"].(map[string]interface{})["on"])
+
+	writeTestMessage(t, staffConn, "notification_pref", `{"event_type":"message","on":false}`)
+	assertErrorResponse(t, staffConn, customer_service.CodeInvalidOperation)
+
+	writeTestMessage(t, userConn, "enqueue", `{"group_id":"group1"}`)
+	offer := readTestMessage(t, staffConn)
+	assert.Equal(t, "session_offer", offer["type"])
+	offerID := offer["payload"].(map[string]interface{})["id"].(string)
+	writeTestMessage(t, staffConn, "accept_offer", `{"offer_id":"`+offerID+`"}`)
+	assert.Equal(t, "session_created", readTestMessage(t, staffConn)["type"])
+
+	// 预警被抑制，消息照常送达，随后收到超时通知
+	writeTestMessage(t, userConn, "message", `{"content":"有人吗"}`)
+	var received []string
+	for {
+		msgType := readTestMessage(t, staffConn)["type"].(string)
+		received = append(received, msgType)
+		if msgType == customer_service.EventSLABreach {
+			break
+		}
+	}
+	assert.Contains(t, received, "message")
+	assert.NotContains(t, received, customer_service.EventSLAWarning)
+}
+
+func TestMessageGateway_StaffConnectAssignsQueue(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	writeTestMessage(t, userConn, "enqueue", `{"group_id":"group1"}`)
+	assert.Eventually(t, func() bool {
+		return len(gateway.service.QueuedUsers("group1")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// 连接参数不合法时拒绝连接
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/staff?staff_id=staff1&name=客服1&group_id=group1&max_sessions=-1"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Error(t, err)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	// 自动接入的客服上线后直接接入排队用户
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1&max_sessions=2&auto_accept=true")
+	defer staffConn.Close()
+	created := readTestMessage(t, staffConn)
+	assert.Equal(t, "session_created", created["type"])
+	assert.Equal(t, "user1", created["payload"].(map[string]interface{})["UserID"])
+	assert.Equal(t, "session_created", readTestMessageExcept(t, userConn, "queue_position")["type"])
+
+	view, _ := gateway.service.GetStaffView("staff1")
+	assert.Equal(t, 2, view.MaxSessions)
+	assert.True(t, view.AutoAccept)
+}
@@ -0,0 +1,18 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HandleHealthz 健康检查接口，服务健康时返回200，否则返回503，响应体为健康状态详情
+func (g *MessageGateway) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	health := g.service.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if health.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
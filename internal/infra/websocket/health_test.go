@@ -0,0 +1,40 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unreachableStore 连通性检查总是失败的存储
+type unreachableStore struct {
+	*customer_service.MemoryStore
+}
+
+func (unreachableStore) Ping() error {
+	return errors.New("dial tcp: connection refused")
+}
+
+func TestMessageGateway_Healthz(t *testing.T) {
+	gateway := NewMessageGateway()
+	recorder := httptest.NewRecorder()
+	gateway.HandleHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	gateway = NewMessageGateway(WithServiceOptions(customer_service.WithStore(unreachableStore{customer_service.NewMemoryStore()})))
+	recorder = httptest.NewRecorder()
+	gateway.HandleHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var health customer_service.HealthStatus
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&health))
+	assert.False(t, health.Healthy)
+	assert.False(t, health.StoreOK)
+	assert.Contains(t, health.StoreError, "connection refused")
+}
@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultPingInterval = 30 * time.Second // 默认心跳间隔
+	pingWriteWait       = 10 * time.Second // 发送ping的超时时间
+)
+
+// pingConn 心跳所需的连接能力，测试时可以替换为模拟连接
+type pingConn interface {
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	SetPongHandler(h func(appData string) error)
+	Close() error
+}
+
+// startHeartbeat 周期性发送携带发送时间的ping，收到pong后记录往返时延，ctx取消后停止。
+// 连续漏回pong时按WithMissedPongs的设置推断客服离开或断开连接，关闭后由读循环按网络错误处理。
+// pong处理函数在读循环中执行，因此需在连接开始读取消息前调用
+func (g *MessageGateway) startHeartbeat(ctx context.Context, conn pingConn, role customer_service.PresenceRole, id string) {
+	if g.pingInterval <= 0 {
+		return
+	}
+
+	var missed int32 // 已发出但尚未收到pong的ping数
+	conn.SetPongHandler(func(appData string) error {
+		sentAt, err := strconv.ParseInt(appData, 10, 64)
+		if err != nil {
+			return nil // 忽略非本网关发出的pong
+		}
+		atomic.StoreInt32(&missed, 0)
+		g.service.RecordRTT(role, id, time.Since(time.Unix(0, sentAt)))
+		return nil
+	})
+
+	go func() {
+		ticker := time.NewTicker(g.pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n := int(atomic.LoadInt32(&missed))
+				if g.disconnectAfterMissed > 0 && n >= g.disconnectAfterMissed {
+					conn.Close()
+					return
+				}
+				if role == customer_service.PresenceRoleStaff && g.awayAfterMissed > 0 && n >= g.awayAfterMissed {
+					g.service.MarkStaffUnresponsive(id)
+				}
+				atomic.AddInt32(&missed, 1)
+				now := time.Now()
+				data := strconv.FormatInt(now.UnixNano(), 10)
+				if err := conn.WriteControl(websocket.PingMessage, []byte(data), now.Add(pingWriteWait)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echoPongConn 模拟连接，收到ping后延迟delay回送pong
+type echoPongConn struct {
+	delay time.Duration
+	mu    sync.Mutex
+	pong  func(appData string) error
+}
+
+func (c *echoPongConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	c.mu.Lock()
+	pong := c.pong
+	c.mu.Unlock()
+	time.AfterFunc(c.delay, func() { pong(string(data)) })
+	return nil
+}
+
+func (c *echoPongConn) SetPongHandler(h func(appData string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pong = h
+}
+
+func (c *echoPongConn) Close() error { return nil }
+
+// flakyPongConn 模拟连接，drop为true时不回送pong，记录连接是否被关闭
+type flakyPongConn struct {
+	mu     sync.Mutex
+	pong   func(appData string) error
+	drop   bool
+	closed bool
+}
+
+func (c *flakyPongConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	c.mu.Lock()
+	pong, drop := c.pong, c.drop
+	c.mu.Unlock()
+	if !drop {
+		go pong(string(data))
+	}
+	return nil
+}
+
+func (c *flakyPongConn) SetPongHandler(h func(appData string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pong = h
+}
+
+func (c *flakyPongConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *flakyPongConn) setDrop(drop bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drop = drop
+}
+
+func (c *flakyPongConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func TestMessageGateway_HeartbeatRTT(t *testing.T) {
+	gateway := NewMessageGateway(WithPingInterval(10 * time.Millisecond))
+	defer gateway.service.Shutdown()
+	_, err := gateway.service.ConnectUser("user1", "TestUser", nil)
+	assert.NoError(t, err)
+
+	conn := &echoPongConn{delay: 30 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gateway.startHeartbeat(ctx, conn, customer_service.PresenceRoleUser, "user1")
+
+	var rtt time.Duration
+	assert.Eventually(t, func() bool {
+		if conns := gateway.service.Stats().Connections; len(conns) == 1 && conns[0].RTT > 0 {
+			rtt = conns[0].RTT
+			return true
+		}
+		return false
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, rtt, 30*time.Millisecond)
+	assert.Less(t, rtt, time.Second)
+}
+
+func TestMessageGateway_MissedPongsAway(t *testing.T) {
+	gateway := NewMessageGateway(WithPingInterval(10*time.Millisecond), WithMissedPongs(1, 50))
+	defer gateway.service.Shutdown()
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectStaff("staff1", "客服1", "group1", nil)
+	gateway.service.ConnectUser("user1", "用户1", nil)
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	conn := &flakyPongConn{drop: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gateway.startHeartbeat(ctx, conn, customer_service.PresenceRoleStaff, "staff1")
+
+	// 短暂漏回pong只推断为离开，会话保留
+	assert.Eventually(t, func() bool {
+		return gateway.service.Presence("staff1") == customer_service.PresenceAway
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, customer_service.SessionStatusActive, gateway.service.GetSession(session.ID).Status)
+	assert.Equal(t, "staff1", gateway.service.GetSession(session.ID).StaffID)
+
+	// 网络恢复后回到在线
+	conn.setDrop(false)
+	assert.Eventually(t, func() bool {
+		return gateway.service.Presence("staff1") == customer_service.PresenceOnline
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, conn.isClosed())
+}
+
+func TestMessageGateway_MissedPongsDisconnect(t *testing.T) {
+	gateway := NewMessageGateway(WithPingInterval(10*time.Millisecond), WithMissedPongs(1, 3))
+	defer gateway.service.Shutdown()
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectStaff("staff1", "客服1", "group1", nil)
+
+	conn := &flakyPongConn{drop: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gateway.startHeartbeat(ctx, conn, customer_service.PresenceRoleStaff, "staff1")
+
+	// 持续漏回pong超过断开阈值后关闭连接
+	assert.Eventually(t, conn.isClosed, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"context"
+
+	"github.com/gorilla/websocket"
+)
+
+// WithInboundBuffer 设置每个连接预读入站帧的缓冲条数。开启后由单独的协程持续读取连接，
+// 处理较慢时（如同步写入存储）pong等控制帧仍能及时处理；读循环仍按接收顺序逐条处理缓冲中的帧，
+// 同一连接发出的消息按发送顺序写入会话。小于等于0时在读循环中直接读取
+func WithInboundBuffer(n int) GatewayOption {
+	return func(g *MessageGateway) {
+		g.inboundBuffer = n
+	}
+}
+
+// inboundFrame 预读的一个入站帧或读取错误
+type inboundFrame struct {
+	data []byte
+	err  error
+}
+
+// inboundReader 按接收顺序向读循环提供入站帧，只能由读循环一个协程调用next
+type inboundReader struct {
+	conn   *websocket.Conn
+	frames chan inboundFrame // 预读缓冲，未开启预读时为空
+}
+
+// newInboundReader 创建连接的入站读取器，开启预读时启动读取协程，ctx取消或读取出错后协程退出
+func (g *MessageGateway) newInboundReader(ctx context.Context, conn *websocket.Conn) *inboundReader {
+	r := &inboundReader{conn: conn}
+	if g.inboundBuffer <= 0 {
+		return r
+	}
+
+	r.frames = make(chan inboundFrame, g.inboundBuffer)
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			select {
+			case r.frames <- inboundFrame{data: data, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// next 返回下一个入站帧，返回错误后不应再调用
+func (r *inboundReader) next() ([]byte, error) {
+	if r.frames == nil {
+		_, data, err := r.conn.ReadMessage()
+		return data, err
+	}
+	frame := <-r.frames
+	return frame.data, frame.err
+}
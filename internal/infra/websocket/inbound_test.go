@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageGateway_InboundOrdering(t *testing.T) {
+	for _, buffer := range []int{0, 32} {
+		t.Run(fmt.Sprintf("buffer=%d", buffer), func(t *testing.T) {
+			store := customer_service.NewMemoryStore()
+			gateway, server := newTestGateway(t,
+				WithInboundBuffer(buffer),
+				WithServiceOptions(
+					customer_service.WithStore(store),
+					customer_service.WithStoreBatch(16, 5*time.Millisecond),
+				),
+			)
+			defer server.Close()
+
+			gateway.service.CreateGroup("group1", "测试客服组")
+			staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+			defer staffConn.Close()
+			userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+			defer userConn.Close()
+			assert.Eventually(t, func() bool {
+				return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+			}, time.Second, 10*time.Millisecond)
+			session, err := gateway.service.CreateSession("user1", "staff1")
+			assert.NoError(t, err)
+
+			// 客服端持续读取，避免发送队列积压
+			go func() {
+				for {
+					if _, _, err := staffConn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+
+			const n = 500
+			for i := 0; i < n; i++ {
+				writeTestMessage(t, userConn, "message", fmt.Sprintf(`{"content":"msg-%03d"}`, i))
+			}
+
+			var stored []*customer_service.Message
+			assert.Eventually(t, func() bool {
+				stored, _ = store.LoadMessages(session.ID)
+				return len(stored) >= n
+			}, 5*time.Second, 20*time.Millisecond)
+			if assert.Len(t, stored, n) {
+				for i, msg := range stored {
+					assert.Equal(t, fmt.Sprintf("msg-%03d", i), msg.Content)
+					assert.Equal(t, int64(i+1), msg.Seq)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"time"
+
+	"clash/internal/domain/customer_service"
+)
+
+// 消息投递状态
+const (
+	MessageStatusSent        = "sent"
+	MessageStatusRecalled    = "recalled"
+	MessageStatusUndelivered = "undelivered" // 多次重发后仍有接收者未确认
+)
+
+// MessageDTO 下发给客户端的消息结构，字段名和枚举取值保持稳定，不随内部模型变化
+type MessageDTO struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	Seq         int64     `json:"seq"`
+	FromID      string    `json:"from_id"`
+	ToID        string    `json:"to_id"` // 为空表示发给会话全部参与者
+	Content     string    `json:"content"`
+	Type        string    `json:"type"`   // text、image、file或system
+	Status      string    `json:"status"` // sent、recalled或undelivered
+	ClientMsgID string    `json:"client_msg_id,omitempty"`
+	CreateAt    time.Time `json:"create_at"`
+
+	Attachment    *customer_service.Attachment    `json:"attachment,omitempty"`     // 图片和文件消息的附件
+	ForwardedFrom *customer_service.ForwardOrigin `json:"forwarded_from,omitempty"` // 转发消息的来源
+	ClientSentAt  *time.Time                      `json:"client_sent_at,omitempty"` // 客户端声明的发送时间，仅供展示，排序以create_at为准
+	Headers       map[string]string               `json:"headers,omitempty"`        // 过滤器附加的机器可读信息
+}
+
+// newMessageDTO 将内部消息转换为下发结构
+func newMessageDTO(msg *customer_service.Message) MessageDTO {
+	status := MessageStatusSent
+	if msg.Recalled {
+		status = MessageStatusRecalled
+	} else if msg.Undelivered {
+		status = MessageStatusUndelivered
+	}
+	var clientSentAt *time.Time
+	if !msg.ClientSentAt.IsZero() {
+		clientSentAt = &msg.ClientSentAt
+	}
+	return MessageDTO{
+		ID:            msg.ID,
+		SessionID:     msg.SessionID,
+		Seq:           msg.Seq,
+		FromID:        msg.FromID,
+		ToID:          msg.ToID,
+		Content:       msg.Content,
+		Type:          msg.Type.String(),
+		Status:        status,
+		ClientMsgID:   msg.ClientMsgID,
+		CreateAt:      msg.CreateAt,
+		Attachment:    msg.Attachment,
+		ForwardedFrom: msg.ForwardedFrom,
+		ClientSentAt:  clientSentAt,
+		Headers:       msg.Headers,
+	}
+}
+
+// newMessageDTOs 批量转换消息
+func newMessageDTOs(msgs []*customer_service.Message) []MessageDTO {
+	dtos := make([]MessageDTO, len(msgs))
+	for i, msg := range msgs {
+		dtos[i] = newMessageDTO(msg)
+	}
+	return dtos
+}
+
+// TranscriptPayload 主管加入会话时收到的会话记录
+type TranscriptPayload struct {
+	SessionID string       `json:"session_id"`
+	Messages  []MessageDTO `json:"messages"`
+}
+
+// newTranscriptDTOs 转换会话记录中的消息
+func newTranscriptDTOs(transcript []customer_service.Message) []MessageDTO {
+	dtos := make([]MessageDTO, len(transcript))
+	for i := range transcript {
+		dtos[i] = newMessageDTO(&transcript[i])
+	}
+	return dtos
+}
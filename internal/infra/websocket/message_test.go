@@ -0,0 +1,219 @@
+package websocket
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageGateway_MessageWireSchema(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	writeTestMessage(t, userConn, "message", `{"content":"hello","client_msg_id":"c1"}`)
+	msg := readTestMessage(t, staffConn)
+	assert.Equal(t, "message", msg["type"])
+	payload := msg["payload"].(map[string]interface{})
+
+	keys := make([]string, 0, len(payload))
+	for key := range payload {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{
+		"client_msg_id", "content", "create_at", "from_id", "id",
+		"seq", "session_id", "status", "to_id", "type",
+	}, keys)
+	assert.Equal(t, session.ID, payload["session_id"])
+	assert.Equal(t, float64(1), payload["seq"])
+	assert.Equal(t, "user1", payload["from_id"])
+	assert.Equal(t, "staff1", payload["to_id"])
+	assert.Equal(t, "hello", payload["content"])
+	assert.Equal(t, "text", payload["type"])
+	assert.Equal(t, MessageStatusSent, payload["status"])
+	assert.Equal(t, "c1", payload["client_msg_id"])
+
+	// 撤回通知使用同一结构，状态为recalled
+	writeTestMessage(t, userConn, "recall_message", `{"message_id":"`+payload["id"].(string)+`"}`)
+	msg = readTestMessage(t, staffConn)
+	assert.Equal(t, "message_recalled", msg["type"])
+	recalled := msg["payload"].(map[string]interface{})
+	assert.Equal(t, MessageStatusRecalled, recalled["status"])
+	assert.Equal(t, "text", recalled["type"])
+}
+
+// rejectAllScanner 拒绝全部附件
+type rejectAllScanner struct{}
+
+func (rejectAllScanner) Scan(customer_service.Attachment) error {
+	return errors.New("blocked")
+}
+
+func TestMessageGateway_Attachment(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	writeTestMessage(t, userConn, "attachment", `{"name":"a.png","mime_type":"image/png","size":10,"url":"https://cdn/a.png"}`)
+	msg := readTestMessageExcept(t, staffConn, "presence")
+	assert.Equal(t, "message", msg["type"])
+	payload := msg["payload"].(map[string]interface{})
+	assert.Equal(t, "image", payload["type"])
+	assert.Equal(t, "https://cdn/a.png", payload["attachment"].(map[string]interface{})["url"])
+}
+
+func TestMessageGateway_AttachmentRejected(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithAttachmentScanner(rejectAllScanner{})))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	writeTestMessage(t, staffConn, "attachment", `{"session_id":"s1","name":"a.exe","url":"https://cdn/a.exe"}`)
+	assertErrorResponse(t, staffConn, customer_service.CodeAttachmentRejected)
+}
+
+func TestMessageGateway_MessageHeaders(t *testing.T) {
+	intent := customer_service.MessageFilterFunc(func(msg *customer_service.Message) {
+		if msg.Content == "refund please" {
+			msg.SetHeader("intent", "refund")
+		}
+	})
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithMessageFilter(intent)))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetStaff("staff1") != nil && gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	gateway.service.CreateSession("user1", "staff1")
+
+	writeTestMessage(t, userConn, "message", `{"content":"refund please"}`)
+	msg := readTestMessage(t, staffConn)
+	assert.Equal(t, map[string]interface{}{"intent": "refund"}, msg["payload"].(map[string]interface{})["headers"])
+}
+
+func TestMessageGateway_OfflineMessagesAfterRestore(t *testing.T) {
+	store := customer_service.NewMemoryStore()
+	opts := WithServiceOptions(customer_service.WithStore(store), customer_service.WithOfflineQueue(10))
+	gateway, server := newTestGateway(t, opts)
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectStaff("staff1", "客服1", "group1", nil)
+	gateway.service.ConnectUser("user1", "用户1", nil)
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 用户未连接，消息进入离线队列
+	message, err := gateway.service.SendMessage(session.ID, "staff1", "are you there?", customer_service.MessageTypeText)
+	assert.NoError(t, err)
+	gateway.deliverMessage(message)
+	snapshots := gateway.service.Snapshot()
+	server.Close()
+	gateway.service.Shutdown()
+
+	// 重启后使用同一存储恢复，用户连接时补发
+	restored, server := newTestGateway(t, opts)
+	defer server.Close()
+	defer restored.service.Shutdown()
+	assert.NoError(t, restored.service.RestoreFrom(snapshots))
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	msg := readTestMessageExcept(t, userConn, "presence")
+	assert.Equal(t, "message", msg["type"])
+	payload := msg["payload"].(map[string]interface{})
+	assert.Equal(t, message.ID, payload["id"])
+	assert.Equal(t, "are you there?", payload["content"])
+	assert.Equal(t, session.ID, payload["session_id"])
+}
+
+func TestMessageGateway_ContentTooLong(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithMaxContentLength(10)))
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectStaff("staff1", "客服1", "group1", nil)
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 超长内容回复错误，消息不写入会话
+	writeTestMessage(t, userConn, "message", `{"content":"this message is too long"}`)
+	resp := readTestMessageExcept(t, userConn, "presence")
+	assert.Equal(t, "error", resp["type"])
+	assert.Equal(t, customer_service.CodeContentTooLong, resp["payload"].(map[string]interface{})["code"])
+	assert.Empty(t, gateway.service.GetSession(session.ID).Messages)
+}
+
+func TestMessageGateway_DeliverToNilConnQueuesOffline(t *testing.T) {
+	opts := WithServiceOptions(customer_service.WithReconnectGrace(time.Minute), customer_service.WithOfflineQueue(10))
+	gateway, server := newTestGateway(t, opts)
+	defer server.Close()
+	defer gateway.service.Shutdown()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	gateway.service.ConnectStaff("staff1", "客服1", "group1", nil)
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	session, err := gateway.service.CreateSession("user1", "staff1")
+	assert.NoError(t, err)
+
+	// 断线后进入重连宽限期，用户仍被跟踪但连接为空
+	userConn.Close()
+	assert.Eventually(t, func() bool {
+		user := gateway.service.GetUser("user1")
+		return user != nil && user.Status == customer_service.UserStatusOffline
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, gateway.participantConn("user1"))
+
+	message, err := gateway.service.SendMessage(session.ID, "staff1", "are you there?", customer_service.MessageTypeText)
+	assert.NoError(t, err)
+	assert.NotPanics(t, func() { gateway.deliverMessage(message) })
+
+	queued, err := gateway.service.TakeOfflineMessages("user1")
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+	assert.Equal(t, message.ID, queued[0].ID)
+}
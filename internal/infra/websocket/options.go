@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"time"
+
+	"clash/internal/domain/customer_service"
+)
+
+// GatewayOption 消息网关配置项
+type GatewayOption func(*MessageGateway)
+
+// WithMaxMessageSize 设置单条入站消息的最大字节数
+func WithMaxMessageSize(n int) GatewayOption {
+	return func(g *MessageGateway) {
+		g.maxMessageSize = n
+	}
+}
+
+// WithMaxNestingDepth 设置入站JSON的最大嵌套层数
+func WithMaxNestingDepth(n int) GatewayOption {
+	return func(g *MessageGateway) {
+		g.maxNestingDepth = n
+	}
+}
+
+// WithStrictFields 开启后入站消息包含未知字段时返回错误
+func WithStrictFields(strict bool) GatewayOption {
+	return func(g *MessageGateway) {
+		g.strictFields = strict
+	}
+}
+
+// WithPingInterval 设置心跳间隔，小于等于0时关闭心跳
+func WithPingInterval(d time.Duration) GatewayOption {
+	return func(g *MessageGateway) {
+		g.pingInterval = d
+	}
+}
+
+// WithMissedPongs 设置心跳漏回pong的处理：客服连续漏回awayAfter次后推断为离开，不再分配新会话但保留已有会话，
+// 收到pong后恢复；用户或客服连续漏回disconnectAfter次后断开连接。任一参数小于等于0时不启用对应处理
+func WithMissedPongs(awayAfter, disconnectAfter int) GatewayOption {
+	return func(g *MessageGateway) {
+		g.awayAfterMissed = awayAfter
+		g.disconnectAfterMissed = disconnectAfter
+	}
+}
+
+// WithWriteTimeout 设置单次写出的超时时间，超时视为投递失败并断开连接，小于等于0时不设置
+func WithWriteTimeout(d time.Duration) GatewayOption {
+	return func(g *MessageGateway) {
+		g.writeTimeout = d
+	}
+}
+
+// WithServiceOptions 设置创建客服系统服务时使用的配置项
+func WithServiceOptions(opts ...customer_service.Option) GatewayOption {
+	return func(g *MessageGateway) {
+		g.serviceOpts = append(g.serviceOpts, opts...)
+	}
+}
+
+// WithRetryAfter 设置因指定原因关闭连接时建议客户端重连前等待的时间，小于等于0表示不建议自动重连
+func WithRetryAfter(reason string, d time.Duration) GatewayOption {
+	return func(g *MessageGateway) {
+		g.retryAfter[reason] = d
+	}
+}
+
+// WithMaxConnections 设置网关同时保持的连接数上限，超出后新连接收到overload关闭帧，小于等于0表示不限
+func WithMaxConnections(n int) GatewayOption {
+	return func(g *MessageGateway) {
+		g.maxConnections = n
+	}
+}
+
+// WithKeepReplacedConnection 设置用户在新设备上接管会话后是否保留旧设备的连接，默认以kicked关闭旧连接
+func WithKeepReplacedConnection(keep bool) GatewayOption {
+	return func(g *MessageGateway) {
+		g.keepReplaced = keep
+	}
+}
+
+// WithSupervisorAuthorizer 设置主管连接的校验函数，校验不通过时以403拒绝连接，未设置时不校验
+func WithSupervisorAuthorizer(auth SupervisorAuthorizer) GatewayOption {
+	return func(g *MessageGateway) {
+		g.supervisorAuth = auth
+	}
+}
+
+// WithAckTimeout 开启消息确认：转发的消息需由接收方回复ack，超过timeout未确认时重发，
+// 最多重发maxResends次，仍未确认则标记消息未送达并向发送者回复message_undelivered
+func WithAckTimeout(timeout time.Duration, maxResends int) GatewayOption {
+	return func(g *MessageGateway) {
+		g.acks = newAckTracker(timeout, maxResends)
+	}
+}
+
+// WithSendBuffer 设置每个连接的发送队列长度和队列已满时的处理方式，默认256条、断开慢速连接。
+// OverflowBlock会使向该连接发送的全部调用方等待，包括分发服务事件的协程，一个卡住的客户端会拖慢其他连接
+func WithSendBuffer(size int, policy OverflowPolicy) GatewayOption {
+	return func(g *MessageGateway) {
+		g.sendBuffer = size
+		g.overflow = policy
+	}
+}
+
+// WithMessageBus 设置网关实例之间的消息总线。各实例使用自己的客服系统服务，经总线通告本实例上连接的参与者，
+// 接收者在其他实例上连接时经总线交给该实例投递
+func WithMessageBus(bus MessageBus) GatewayOption {
+	return func(g *MessageGateway) {
+		g.bus = bus
+	}
+}
+
+// WithBusRetry 开启总线发布失败后的重试：经总线发送的消息在接收者的发布队列中由后台协程发布，失败后按policy指数退避重试，
+// 同一接收者的消息保持顺序。次数用尽、队列已满或网关关闭时仍未成功的消息交给sink，sink为空时只记录日志。
+// 未开启时同样在后台发布，只尝试一次，失败只记录日志
+func WithBusRetry(policy RetryPolicy, sink DeadLetterSink) GatewayOption {
+	return func(g *MessageGateway) {
+		g.busRetry = newBusRetrier(policy, sink)
+	}
+}
@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// errRetryStopped 网关关闭时仍在等待发布或重试的消息以此原因交给死信处理
+var errRetryStopped = errors.New("websocket: gateway shut down before delivery")
+
+// RetryPolicy 消息经总线发布失败时的重试策略，重试间隔按指数退避并加入随机抖动
+type RetryPolicy struct {
+	MaxAttempts int           // 最多尝试的次数，包括首次发布，小于等于1时不重试
+	BaseDelay   time.Duration // 首次重试前的等待时间，之后每次翻倍
+	MaxDelay    time.Duration // 单次等待时间的上限，0表示不限
+	Jitter      float64       // 随机缩短等待时间的最大比例，取值0到1，避免多个实例同时重试
+}
+
+// backoff 返回第retry次重试前的等待时间，retry从1开始
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if jitter := p.Jitter; jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay -= time.Duration(float64(delay) * jitter * rand.Float64())
+	}
+	return delay
+}
+
+// DeadLetterSink 接收重试次数用尽仍未发布成功的消息，可以落库或告警后人工补发
+type DeadLetterSink interface {
+	DeadLetter(msg BusMessage, err error)
+}
+
+// DeadLetterFunc 将普通函数适配为DeadLetterSink
+type DeadLetterFunc func(msg BusMessage, err error)
+
+// DeadLetter 调用f(msg, err)
+func (f DeadLetterFunc) DeadLetter(msg BusMessage, err error) {
+	f(msg, err)
+}
+
+// maxRetryQueue 每个接收者等待发布的消息数上限，超出时新消息直接交给死信处理
+const maxRetryQueue = 100
+
+// errRetryQueueFull 接收者等待发布的消息已达上限时，新消息以此原因交给死信处理
+var errRetryQueueFull = errors.New("websocket: retry queue full")
+
+// retryItem 等待发布的消息
+type retryItem struct {
+	msg      BusMessage
+	attempts int // 已尝试的次数
+}
+
+// busRetrier 总线发布队列。消息进入接收者的队列，由该接收者的后台协程按顺序发布，失败后按策略退避重试，
+// 调用方不等待总线，同一接收者的消息保持顺序。未开启重试时按只尝试一次的策略使用
+type busRetrier struct {
+	policy  RetryPolicy
+	sink    DeadLetterSink
+	queues  map[string][]*retryItem // 各接收者等待发布的消息，存在即有后台协程在处理
+	stopped bool
+	mu      sync.Mutex // 保护queues和stopped
+	stop    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+func newBusRetrier(policy RetryPolicy, sink DeadLetterSink) *busRetrier {
+	return &busRetrier{
+		policy: policy,
+		sink:   sink,
+		queues: make(map[string][]*retryItem),
+		stop:   make(chan struct{}),
+	}
+}
+
+// publish 将消息加入接收者的发布队列后立即返回，不在调用方协程中访问总线。
+// 重试器已关闭或队列已满时直接交给死信处理
+func (r *busRetrier) publish(bus MessageBus, msg BusMessage) {
+	r.mu.Lock()
+	err := r.enqueueLocked(bus, &retryItem{msg: msg})
+	r.mu.Unlock()
+	if err != nil {
+		r.deadLetter(msg, err)
+	}
+}
+
+// enqueueLocked 将消息加入接收者的发布队列，队列新建时启动后台协程。
+// 重试器已关闭或队列已满时返回原因，由调用方交给死信处理，调用方需持有r.mu
+func (r *busRetrier) enqueueLocked(bus MessageBus, item *retryItem) error {
+	if r.stopped {
+		return errRetryStopped
+	}
+	queue, retrying := r.queues[item.msg.ToID]
+	if len(queue) >= maxRetryQueue {
+		log.Printf("Bus retry queue for %s is full, dropping %s", item.msg.ToID, item.msg.Type)
+		return errRetryQueueFull
+	}
+	r.queues[item.msg.ToID] = append(queue, item)
+	if !retrying {
+		r.wg.Add(1)
+		go r.run(bus, item.msg.ToID)
+	}
+	return nil
+}
+
+// run 按顺序发布接收者队列中的消息，失败后等待退避时间再试，次数用尽时交给死信处理。
+// 队列清空时退出，网关关闭时剩余的消息全部交给死信处理
+func (r *busRetrier) run(bus MessageBus, toID string) {
+	defer r.wg.Done()
+
+	for {
+		r.mu.Lock()
+		queue := r.queues[toID]
+		if len(queue) == 0 {
+			delete(r.queues, toID)
+			r.mu.Unlock()
+			return
+		}
+		item := queue[0]
+		r.mu.Unlock()
+
+		if item.attempts > 0 {
+			timer := time.NewTimer(r.policy.backoff(item.attempts))
+			select {
+			case <-timer.C:
+			case <-r.stop:
+				timer.Stop()
+				r.drain(toID)
+				return
+			}
+		}
+
+		err := bus.Publish(item.msg)
+		item.attempts++
+		if err != nil && item.attempts < r.policy.MaxAttempts {
+			continue
+		}
+		r.mu.Lock()
+		r.queues[toID] = r.queues[toID][1:]
+		r.mu.Unlock()
+		if err != nil {
+			log.Printf("Error publishing %s to bus after %d attempts: %v", item.msg.Type, item.attempts, err)
+			r.deadLetter(item.msg, err)
+		}
+	}
+}
+
+// drain 网关关闭时将接收者队列中剩余的消息交给死信处理
+func (r *busRetrier) drain(toID string) {
+	r.mu.Lock()
+	queue := r.queues[toID]
+	delete(r.queues, toID)
+	r.mu.Unlock()
+
+	for _, item := range queue {
+		r.deadLetter(item.msg, errRetryStopped)
+	}
+}
+
+func (r *busRetrier) deadLetter(msg BusMessage, err error) {
+	if r.sink != nil {
+		r.sink.DeadLetter(msg, err)
+	}
+}
+
+// shutdown 拒绝新的消息，取消等待中的重试并等待后台协程退出，可以重复调用
+func (r *busRetrier) shutdown() {
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.stopped = true
+		r.mu.Unlock()
+		close(r.stop)
+	})
+	r.wg.Wait()
+}
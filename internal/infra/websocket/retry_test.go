@@ -0,0 +1,226 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyBus 前failures次发布返回错误，之后交给内部的MemoryBus。实例之间的在线通告和同步请求直接发布，不计入次数
+type flakyBus struct {
+	*MemoryBus
+	failures int
+	attempts int
+	mu       sync.Mutex
+}
+
+func (b *flakyBus) Publish(msg BusMessage) error {
+	if msg.Type == busTypePresence || msg.Type == busTypeSync {
+		return b.MemoryBus.Publish(msg)
+	}
+	b.mu.Lock()
+	b.attempts++
+	fail := b.failures < 0 || b.attempts <= b.failures
+	b.mu.Unlock()
+	if fail {
+		return errors.New("bus unavailable")
+	}
+	return b.MemoryBus.Publish(msg)
+}
+
+func (b *flakyBus) Attempts() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.attempts
+}
+
+// announceRemote 模拟其他实例经总线通告参与者在线，不经过flakyBus的失败计数
+func announceRemote(bus *flakyBus, ids ...string) {
+	for _, id := range ids {
+		bus.MemoryBus.Publish(BusMessage{Origin: "remote", ToID: id, Type: busTypePresence, Payload: json.RawMessage(`{"online":true}`)})
+	}
+}
+
+// recordBus 在总线上订阅并记录收到的消息类型，忽略实例之间的在线通告和同步请求
+func recordBus(bus MessageBus) func() []string {
+	var mu sync.Mutex
+	var received []string
+	bus.Subscribe(func(msg BusMessage) {
+		if msg.Type == busTypePresence || msg.Type == busTypeSync {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, msg.Type)
+	})
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+func TestMessageGateway_BusRetry(t *testing.T) {
+	bus := &flakyBus{MemoryBus: NewMemoryBus(), failures: 2}
+	received := recordBus(bus)
+	dead := make(chan BusMessage, 1)
+	gateway := NewMessageGateway(
+		WithMessageBus(bus),
+		WithBusRetry(RetryPolicy{MaxAttempts: 5, BaseDelay: 20 * time.Millisecond, Jitter: 0.5}, DeadLetterFunc(func(msg BusMessage, err error) {
+			dead <- msg
+		})),
+	)
+	defer gateway.Shutdown()
+	announceRemote(bus, "user1")
+
+	// 前两次失败，第三次送达
+	assert.False(t, gateway.deliverTo("user1", "notice", map[string]string{"text": "hello"}))
+	assert.Eventually(t, func() bool {
+		return len(received()) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"notice"}, received())
+	assert.Equal(t, 3, bus.Attempts())
+	assert.Empty(t, dead)
+}
+
+func TestMessageGateway_BusRetryDeadLetter(t *testing.T) {
+	bus := &flakyBus{MemoryBus: NewMemoryBus(), failures: -1}
+	dead := make(chan error, 1)
+	gateway := NewMessageGateway(
+		WithMessageBus(bus),
+		WithBusRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, DeadLetterFunc(func(msg BusMessage, err error) {
+			assert.Equal(t, "user1", msg.ToID)
+			dead <- err
+		})),
+	)
+	announceRemote(bus, "user1")
+
+	gateway.deliverTo("user1", "notice", map[string]string{"text": "hello"})
+	select {
+	case err := <-dead:
+		assert.EqualError(t, err, "bus unavailable")
+	case <-time.After(time.Second):
+		t.Fatal("message was not dead-lettered")
+	}
+	assert.Equal(t, 3, bus.Attempts())
+
+	// 网关关闭时等待中的重试立即交给死信处理
+	slow := NewMessageGateway(
+		WithMessageBus(bus),
+		WithBusRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}, DeadLetterFunc(func(msg BusMessage, err error) {
+			dead <- err
+		})),
+	)
+	announceRemote(bus, "user1")
+	slow.deliverTo("user1", "notice", map[string]string{"text": "hello"})
+	assert.Eventually(t, func() bool {
+		return bus.Attempts() == 4
+	}, time.Second, 5*time.Millisecond)
+	assert.NoError(t, slow.Shutdown())
+	assert.ErrorIs(t, <-dead, errRetryStopped)
+
+	// 关闭后的消息不再发布
+	slow.deliverTo("user1", "notice", map[string]string{"text": "late"})
+	assert.ErrorIs(t, <-dead, errRetryStopped)
+	assert.Equal(t, 4, bus.Attempts())
+	gateway.Shutdown()
+}
+
+// blockingBus 发布时等待release关闭后才交给内部的MemoryBus，模拟卡住的总线
+type blockingBus struct {
+	*MemoryBus
+	release chan struct{}
+}
+
+func (b *blockingBus) Publish(msg BusMessage) error {
+	if msg.Type != busTypePresence && msg.Type != busTypeSync {
+		<-b.release
+	}
+	return b.MemoryBus.Publish(msg)
+}
+
+func TestMessageGateway_BusPublishAsync(t *testing.T) {
+	for _, opts := range [][]GatewayOption{nil, {WithBusRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}, nil)}} {
+		bus := &blockingBus{MemoryBus: NewMemoryBus(), release: make(chan struct{})}
+		received := recordBus(bus)
+		gateway := NewMessageGateway(append([]GatewayOption{WithMessageBus(bus)}, opts...)...)
+		bus.MemoryBus.Publish(BusMessage{Origin: "remote", ToID: "user1", Type: busTypePresence, Payload: json.RawMessage(`{"online":true}`)})
+
+		// 总线卡住时调用方不等待，消息在接收者的队列中发布
+		done := make(chan struct{})
+		go func() {
+			gateway.deliverTo("user1", "notice", map[string]string{"text": "hello"})
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("deliverTo blocked on the bus")
+		}
+		assert.Empty(t, received())
+
+		close(bus.release)
+		assert.Eventually(t, func() bool {
+			return len(received()) == 1
+		}, time.Second, 5*time.Millisecond)
+		assert.NoError(t, gateway.Shutdown())
+	}
+}
+
+func TestMessageGateway_BusRetryOrderAndLimit(t *testing.T) {
+	bus := &flakyBus{MemoryBus: NewMemoryBus(), failures: 1}
+	received := recordBus(bus)
+	dead := make(chan error, 1)
+	gateway := NewMessageGateway(
+		WithMessageBus(bus),
+		WithBusRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: 50 * time.Millisecond}, DeadLetterFunc(func(msg BusMessage, err error) {
+			select {
+			case dead <- err:
+			default:
+			}
+		})),
+	)
+	defer gateway.Shutdown()
+	announceRemote(bus, "user1", "user2", "user3")
+
+	// 首条消息等待重试时，同一接收者的后续消息排在其后，其他接收者不受影响
+	gateway.deliverTo("user1", "first", nil)
+	assert.Eventually(t, func() bool {
+		return bus.Attempts() == 1
+	}, time.Second, time.Millisecond)
+	gateway.deliverTo("user1", "second", nil)
+	gateway.deliverTo("user2", "other", nil)
+	assert.Eventually(t, func() bool {
+		return len(received()) == 3
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"other", "first", "second"}, received())
+
+	// 队列已满时新消息直接交给死信处理
+	bus.mu.Lock()
+	bus.failures = -1
+	bus.mu.Unlock()
+	for i := 0; i <= maxRetryQueue; i++ {
+		gateway.deliverTo("user3", "notice", nil)
+	}
+	assert.ErrorIs(t, <-dead, errRetryQueueFull)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 800*time.Millisecond, policy.backoff(4))
+	assert.Equal(t, time.Second, policy.backoff(5))
+	assert.Equal(t, time.Second, policy.backoff(60))
+
+	// 抖动只会缩短等待时间
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		delay := policy.backoff(2)
+		assert.True(t, delay > 100*time.Millisecond && delay <= 200*time.Millisecond, delay)
+	}
+}
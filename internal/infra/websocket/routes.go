@@ -0,0 +1,46 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultRoutePrefix 连接入口的默认路径前缀
+const defaultRoutePrefix = "/ws"
+
+// 连接入口相对于路径前缀的路径
+const (
+	RouteUser       = "/user"
+	RouteStaff      = "/staff"
+	RouteSupervisor = "/supervisor"
+)
+
+// WithRoutePrefix 设置RegisterRoutes注册连接入口时使用的路径前缀，默认为/ws，为空时直接注册在根路径下
+func WithRoutePrefix(prefix string) GatewayOption {
+	return func(g *MessageGateway) {
+		g.routePrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// RegisterRoutes 在mux上注册用户、客服和主管的连接入口，路径按前缀精确匹配，
+// 例如默认的/ws/user。WebSocket握手只接受GET请求，其他方法返回405，未注册的路径由mux返回404
+func (g *MessageGateway) RegisterRoutes(mux *http.ServeMux) {
+	mux.Handle(g.routePrefix+RouteUser, allowMethods(g.HandleUserConnection, http.MethodGet))
+	mux.Handle(g.routePrefix+RouteStaff, allowMethods(g.HandleStaffConnection, http.MethodGet))
+	mux.Handle(g.routePrefix+RouteSupervisor, allowMethods(g.HandleSupervisorConnection, http.MethodGet))
+}
+
+// allowMethods 只放行指定方法的请求，其他方法返回405并在Allow头中列出允许的方法
+func allowMethods(handler http.HandlerFunc, methods ...string) http.Handler {
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range methods {
+			if r.Method == method {
+				handler(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allow)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	})
+}
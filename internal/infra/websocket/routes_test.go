@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageGateway_RegisterRoutes(t *testing.T) {
+	gateway := NewMessageGateway()
+	mux := http.NewServeMux()
+	gateway.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	for _, path := range []string{
+		"/ws/user?user_id=user1&name=用户1",
+		"/ws/staff?staff_id=staff1&name=客服1&group_id=group1",
+		"/ws/supervisor?supervisor_id=sup1",
+	} {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+path, nil)
+		if assert.NoError(t, err, path) {
+			defer conn.Close()
+		}
+	}
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil && gateway.service.GetStaff("staff1") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 错误的方法
+	resp, err := http.Post(server.URL+"/ws/user?user_id=user2&name=用户2", "text/plain", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, http.MethodGet, resp.Header.Get("Allow"))
+		resp.Body.Close()
+	}
+
+	// 未注册的路径，不再按子串匹配
+	for _, path := range []string{"/user", "/ws/user/extra", "/ws/superuser"} {
+		resp, err := http.Get(server.URL + path)
+		if assert.NoError(t, err, path) {
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestMessageGateway_RoutePrefix(t *testing.T) {
+	gateway := NewMessageGateway(WithRoutePrefix("/chat/"))
+	mux := http.NewServeMux()
+	gateway.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chat/user?user_id=user1&name=用户1", nil)
+	if assert.NoError(t, err) {
+		conn.Close()
+	}
+	resp, err := http.Get(server.URL + "/ws/user")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp.Body.Close()
+	}
+}
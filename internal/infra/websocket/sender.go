@@ -0,0 +1,261 @@
+package websocket
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	sendQueueSize       = 256              // 每个连接默认的发送队列长度
+	defaultWriteTimeout = 10 * time.Second // 默认单次写出的超时时间
+)
+
+// defaultOverflowPolicy 默认断开队列已满的慢速连接，发送方不会因一个卡住的客户端而等待
+const defaultOverflowPolicy = OverflowDisconnect
+
+// OverflowPolicy 发送队列已满时的处理方式，防止慢速客户端占用无限内存或拖慢发送方
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 发送方等待队列有空位，需显式配置
+	OverflowDropOldest                       // 丢弃队列中最早的消息，放入新消息
+	OverflowDropNewest                       // 丢弃新消息
+	OverflowDisconnect                       // 断开连接，按断线处理
+)
+
+// frameWriter 发送队列所需的连接写能力
+type frameWriter interface {
+	WriteMessage(messageType int, data []byte) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+// connSender 连接的发送队列，由单个协程依次写出，保证同一连接上的写操作串行。
+// 消息按通道排队，同一通道内按入队顺序发送，各通道之间轮转写出，
+// 某个会话大量下发消息时其他会话的消息不会排在其全部消息之后
+type connSender struct {
+	w       frameWriter
+	timeout time.Duration  // 单次写出的超时时间，小于等于0时不设置
+	policy  OverflowPolicy // 队列已满时的处理方式
+	size    int            // 全部通道合计的队列长度
+	mu      sync.Mutex     // 保护以下字段，并使并发入队按获得锁的顺序排列
+	cond    *sync.Cond     // 入队、出队或关闭时通知
+	closed  bool
+	lanes   map[string][][]byte // 各通道待写出的消息
+	order   []string            // 有待写出消息的通道，按轮转顺序排列
+	queued  int                 // 全部通道待写出的消息数
+	done    chan struct{}       // 写协程退出后关闭
+}
+
+func newConnSender(w frameWriter, timeout time.Duration, size int, policy OverflowPolicy) *connSender {
+	if size <= 0 {
+		size = sendQueueSize
+	}
+	s := &connSender{
+		w:       w,
+		timeout: timeout,
+		policy:  policy,
+		size:    size,
+		lanes:   make(map[string][][]byte),
+		done:    make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	go s.run()
+	return s
+}
+
+// run 轮转写出各通道的消息。写失败或超时视为投递失败，关闭连接使读循环退出并按断线处理，
+// 之后丢弃剩余消息直到队列关闭
+func (s *connSender) run() {
+	defer close(s.done)
+	failed := false
+	for {
+		data, ok := s.next()
+		if !ok {
+			return
+		}
+		if failed {
+			continue
+		}
+		if err := s.write(data); err != nil {
+			log.Printf("Error writing message: %v", err)
+			failed = true
+			s.w.Close()
+		}
+	}
+}
+
+// next 等待并取出轮到的通道的下一条消息，队列已关闭且没有剩余消息时返回false
+func (s *connSender) next() ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.queued == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.queued == 0 {
+		return nil, false
+	}
+	lane := s.order[0]
+	s.order = s.order[1:]
+	data := s.popLocked(lane)
+	if len(s.lanes[lane]) > 0 {
+		s.order = append(s.order, lane)
+	}
+	s.cond.Broadcast()
+	return data, true
+}
+
+// popLocked 取出通道的第一条消息，通道清空时删除，调用方需持有s.mu
+func (s *connSender) popLocked(lane string) []byte {
+	queue := s.lanes[lane]
+	data := queue[0]
+	if len(queue) == 1 {
+		delete(s.lanes, lane)
+	} else {
+		s.lanes[lane] = queue[1:]
+	}
+	s.queued--
+	return data
+}
+
+// dropOldestLocked 丢弃积压最多的通道中最早的消息，积压相同时丢弃最先轮到的通道，调用方需持有s.mu
+func (s *connSender) dropOldestLocked() {
+	longest := -1
+	for i, lane := range s.order {
+		if longest < 0 || len(s.lanes[lane]) > len(s.lanes[s.order[longest]]) {
+			longest = i
+		}
+	}
+	lane := s.order[longest]
+	s.popLocked(lane)
+	if len(s.lanes[lane]) == 0 {
+		s.order = append(s.order[:longest], s.order[longest+1:]...)
+	}
+}
+
+// write 在写超时时间内写出一条消息，避免慢速或卡住的客户端无限期阻塞发送协程
+func (s *connSender) write(data []byte) error {
+	if s.timeout > 0 {
+		if err := s.w.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+			return err
+		}
+	}
+	return s.w.WriteMessage(websocket.TextMessage, data)
+}
+
+// send 将消息放入默认通道
+func (s *connSender) send(data []byte) bool {
+	return s.sendLane("", data)
+}
+
+// sendLane 将消息放入指定通道，队列已满时按policy处理。队列已关闭或消息被丢弃时返回false
+func (s *connSender) sendLane(lane string, data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	if s.queued >= s.size {
+		switch s.policy {
+		case OverflowBlock:
+			for s.queued >= s.size && !s.closed {
+				s.cond.Wait()
+			}
+			if s.closed {
+				return false
+			}
+		case OverflowDropOldest:
+			s.dropOldestLocked()
+		case OverflowDisconnect:
+			log.Printf("Send queue full, dropping slow connection")
+			s.closed = true
+			s.cond.Broadcast()
+			s.w.Close()
+			return false
+		default:
+			return false
+		}
+	}
+
+	if len(s.lanes[lane]) == 0 {
+		s.order = append(s.order, lane)
+	}
+	s.lanes[lane] = append(s.lanes[lane], data)
+	s.queued++
+	s.cond.Broadcast()
+	return true
+}
+
+// close 关闭发送队列并等待已入队的消息写完
+func (s *connSender) close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		s.cond.Broadcast()
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+// openSender 为连接创建发送队列，连接的所有写操作都应通过send进行
+func (g *MessageGateway) openSender(conn *websocket.Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.senders[conn] = newConnSender(conn, g.writeTimeout, g.sendBuffer, g.overflow)
+}
+
+// closeSender 写完已入队的消息后移除连接的发送队列
+func (g *MessageGateway) closeSender(conn *websocket.Conn) {
+	g.mu.Lock()
+	sender, exists := g.senders[conn]
+	delete(g.senders, conn)
+	g.mu.Unlock()
+
+	if exists {
+		sender.close()
+	}
+}
+
+// send 通过发送队列的默认通道向连接写入一条消息，连接已关闭时丢弃
+func (g *MessageGateway) send(conn *websocket.Conn, data []byte) {
+	g.sendLane(conn, "", data)
+}
+
+// sendLane 通过发送队列的指定通道向连接写入一条消息，连接已关闭时丢弃
+func (g *MessageGateway) sendLane(conn *websocket.Conn, lane string, data []byte) {
+	if conn == nil {
+		return
+	}
+	g.mu.RLock()
+	sender, exists := g.senders[conn]
+	g.mu.RUnlock()
+
+	if exists {
+		sender.sendLane(lane, data)
+	}
+}
+
+// sendLaneOf 下发消息所属的发送通道：会话相关的消息按会话分通道，与同一会话的其他消息保持顺序，
+// 其余消息使用默认通道
+func sendLaneOf(payload interface{}) string {
+	switch p := payload.(type) {
+	case MessageDTO:
+		return p.SessionID
+	case customer_service.SessionEvent:
+		return p.SessionID
+	case customer_service.SessionStatusChange:
+		return p.SessionID
+	case UserSessionDTO:
+		return p.ID
+	case StaffSessionDTO:
+		return p.ID
+	}
+	return ""
+}
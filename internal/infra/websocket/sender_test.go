@@ -0,0 +1,310 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingWriter 记录写出的消息，并检测是否有并发写入
+type recordingWriter struct {
+	inFlight   int32
+	concurrent int32
+	mu         sync.Mutex
+	frames     [][]byte
+}
+
+func (w *recordingWriter) WriteMessage(messageType int, data []byte) error {
+	if atomic.AddInt32(&w.inFlight, 1) > 1 {
+		atomic.AddInt32(&w.concurrent, 1)
+	}
+	time.Sleep(time.Microsecond)
+	w.mu.Lock()
+	w.frames = append(w.frames, data)
+	w.mu.Unlock()
+	atomic.AddInt32(&w.inFlight, -1)
+	return nil
+}
+
+func (w *recordingWriter) SetWriteDeadline(t time.Time) error { return nil }
+
+func (w *recordingWriter) Close() error { return nil }
+
+func TestConnSender_ConcurrentSends(t *testing.T) {
+	writer := &recordingWriter{}
+	sender := newConnSender(writer, defaultWriteTimeout, sendQueueSize, OverflowBlock)
+
+	const senders, perSender = 20, 50
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for seq := 0; seq < perSender; seq++ {
+				data, _ := json.Marshal(map[string]int{"sender": id, "seq": seq})
+				assert.True(t, sender.send(data))
+			}
+		}(i)
+	}
+	wg.Wait()
+	sender.close()
+	assert.False(t, sender.send([]byte("{}")))
+
+	// 写操作从不并发，消息完整且同一发送方的消息保持顺序
+	assert.Zero(t, atomic.LoadInt32(&writer.concurrent))
+	assert.Len(t, writer.frames, senders*perSender)
+	next := make(map[int]int)
+	for _, frame := range writer.frames {
+		var msg map[string]int
+		assert.NoError(t, json.Unmarshal(frame, &msg))
+		assert.Equal(t, next[msg["sender"]], msg["seq"])
+		next[msg["sender"]]++
+	}
+}
+
+func TestMessageGateway_ConcurrentWrites(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	conn := gateway.service.GetUser("user1").Conn
+
+	// 多个协程同时向同一连接写入，客户端收到的每一帧都是完整的消息
+	const writers, perWriter = 10, 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for seq := 0; seq < perWriter; seq++ {
+				gateway.writeJSON(conn, "ping", map[string]int{"writer": id, "seq": seq})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	next := make(map[float64]float64)
+	for i := 0; i < writers*perWriter; i++ {
+		msg := readTestMessage(t, userConn)
+		assert.Equal(t, "ping", msg["type"])
+		payload := msg["payload"].(map[string]interface{})
+		assert.Equal(t, next[payload["writer"].(float64)], payload["seq"])
+		next[payload["writer"].(float64)]++
+	}
+}
+
+// stuckWriter 模拟卡住的客户端，写操作一直阻塞到写超时
+type stuckWriter struct {
+	mu       sync.Mutex
+	deadline time.Time
+	writes   int
+	closed   chan struct{}
+}
+
+func (w *stuckWriter) WriteMessage(messageType int, data []byte) error {
+	w.mu.Lock()
+	w.writes++
+	deadline := w.deadline
+	w.mu.Unlock()
+	if deadline.IsZero() {
+		select {} // 未设置超时时永久阻塞
+	}
+	time.Sleep(time.Until(deadline))
+	return errors.New("i/o timeout")
+}
+
+func (w *stuckWriter) SetWriteDeadline(t time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.deadline = t
+	return nil
+}
+
+func (w *stuckWriter) Close() error {
+	close(w.closed)
+	return nil
+}
+
+func TestConnSender_WriteTimeout(t *testing.T) {
+	writer := &stuckWriter{closed: make(chan struct{})}
+	sender := newConnSender(writer, 50*time.Millisecond, sendQueueSize, OverflowBlock)
+
+	start := time.Now()
+	assert.True(t, sender.send([]byte("first")))
+	assert.True(t, sender.send([]byte("second")))
+
+	// 写超时后断开连接，剩余消息不再尝试写出
+	select {
+	case <-writer.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection was not dropped after write timeout")
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	sender.close()
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	assert.Equal(t, 1, writer.writes)
+}
+
+// stalledWriter 模拟消费过慢的客户端，首次写入阻塞直到release关闭
+type stalledWriter struct {
+	writing chan struct{} // 首次写入开始时关闭
+	release chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	frames  []string
+}
+
+func newStalledWriter() *stalledWriter {
+	return &stalledWriter{
+		writing: make(chan struct{}),
+		release: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (w *stalledWriter) WriteMessage(messageType int, data []byte) error {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	w.mu.Lock()
+	w.frames = append(w.frames, string(data))
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *stalledWriter) SetWriteDeadline(t time.Time) error { return nil }
+
+func (w *stalledWriter) Close() error {
+	select {
+	case <-w.closed:
+	default:
+		close(w.closed)
+	}
+	return nil
+}
+
+// fillStalledSender 写入第一条消息并等待其卡在写出中，再放入后续消息
+func fillStalledSender(t *testing.T, policy OverflowPolicy) (*stalledWriter, *connSender, []bool) {
+	writer := newStalledWriter()
+	sender := newConnSender(writer, 0, 2, policy)
+	assert.True(t, sender.send([]byte("1")))
+	<-writer.writing
+
+	// 队列长度为2，第3、4条超出容量
+	var results []bool
+	for _, data := range []string{"2", "3", "4", "5"} {
+		results = append(results, sender.send([]byte(data)))
+	}
+	return writer, sender, results
+}
+
+func TestConnSender_OverflowDropNewest(t *testing.T) {
+	writer, sender, results := fillStalledSender(t, OverflowDropNewest)
+	assert.Equal(t, []bool{true, true, false, false}, results)
+
+	close(writer.release)
+	sender.close()
+	assert.Equal(t, []string{"1", "2", "3"}, writer.frames)
+}
+
+func TestConnSender_OverflowDropOldest(t *testing.T) {
+	writer, sender, results := fillStalledSender(t, OverflowDropOldest)
+	assert.Equal(t, []bool{true, true, true, true}, results)
+
+	close(writer.release)
+	sender.close()
+	assert.Equal(t, []string{"1", "4", "5"}, writer.frames)
+}
+
+func TestConnSender_OverflowDisconnect(t *testing.T) {
+	writer, sender, results := fillStalledSender(t, OverflowDisconnect)
+	assert.Equal(t, []bool{true, true, false, false}, results)
+
+	// 队列满时断开连接，之后的消息不再入队
+	select {
+	case <-writer.closed:
+	case <-time.After(time.Second):
+		t.Fatal("slow connection was not dropped")
+	}
+	close(writer.release)
+	sender.close()
+	assert.Equal(t, []string{"1", "2", "3"}, writer.frames)
+}
+
+func TestMessageGateway_DefaultOverflowDoesNotBlock(t *testing.T) {
+	gateway := NewMessageGateway()
+	defer gateway.Shutdown()
+
+	// 默认策略下卡住的客户端被断开，发送方不会等待
+	var writer *stalledWriter
+	var sender *connSender
+	done := make(chan struct{})
+	go func() {
+		writer, sender, _ = fillStalledSender(t, gateway.overflow)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("send blocked on a stalled connection")
+	}
+	<-writer.closed
+	close(writer.release)
+	sender.close()
+}
+
+func TestConnSender_FairAcrossSessions(t *testing.T) {
+	writer := newStalledWriter()
+	sender := newConnSender(writer, 0, sendQueueSize, OverflowBlock)
+	assert.True(t, sender.sendLane("session1", []byte("flood-0")))
+	<-writer.writing
+
+	// session1大量下发时，session2的消息轮转写出，不必等session1的积压全部写完
+	for i := 1; i < 100; i++ {
+		assert.True(t, sender.sendLane("session1", []byte("flood")))
+	}
+	assert.True(t, sender.sendLane("session2", []byte("reply-1")))
+	assert.True(t, sender.sendLane("session2", []byte("reply-2")))
+	close(writer.release)
+	sender.close()
+
+	assert.Len(t, writer.frames, 102)
+	assert.Equal(t, []string{"flood-0", "flood", "reply-1", "flood", "reply-2", "flood"}, writer.frames[:6])
+}
+
+func TestConnSender_DropOldestFromBusiestLane(t *testing.T) {
+	writer := newStalledWriter()
+	sender := newConnSender(writer, 0, 3, OverflowDropOldest)
+	assert.True(t, sender.sendLane("session1", []byte("1")))
+	<-writer.writing
+
+	assert.True(t, sender.sendLane("session1", []byte("a1")))
+	assert.True(t, sender.sendLane("session1", []byte("a2")))
+	assert.True(t, sender.sendLane("session2", []byte("b1")))
+	// 队列已满，丢弃积压最多的session1中最早的消息
+	assert.True(t, sender.sendLane("session2", []byte("b2")))
+	close(writer.release)
+	sender.close()
+
+	assert.Equal(t, []string{"1", "a2", "b1", "b2"}, writer.frames)
+}
+
+func TestSendLaneOf(t *testing.T) {
+	assert.Equal(t, "s1", sendLaneOf(MessageDTO{SessionID: "s1"}))
+	assert.Equal(t, "s2", sendLaneOf(customer_service.SessionEvent{SessionID: "s2"}))
+	assert.Equal(t, "s3", sendLaneOf(customer_service.SessionStatusChange{SessionID: "s3"}))
+	assert.Empty(t, sendLaneOf(map[string]string{"session_id": "s4"}))
+}
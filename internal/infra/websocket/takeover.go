@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"log"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+)
+
+// resumeHistoryLimit 新设备接管会话时补发的最近消息条数
+const resumeHistoryLimit = 50
+
+// connectUser 注册用户连接。出示重连令牌时恢复宽限期内断线的用户，或由新连接接管仍在进行的会话并关闭旧设备的连接，
+// 之后向新连接补发会话消息。未出示令牌的连接只能作为新用户连接，用户已在线时按重复连接拒绝
+func (g *MessageGateway) connectUser(conn *websocket.Conn, userID, name, channel, token string) (*customer_service.User, error) {
+	if token == "" {
+		return g.service.ConnectUserWithChannel(userID, name, channel, conn)
+	}
+
+	old, err := g.service.TakeoverUserConnection(token, userID, conn)
+	if err != nil {
+		return nil, err
+	}
+	if old != nil && !g.keepReplaced {
+		g.CloseConnection(old, CloseReasonKicked)
+	}
+
+	user := g.service.GetUser(userID)
+	if user == nil {
+		return nil, customer_service.ErrUserNotFound
+	}
+	g.sendSessionResumed(conn, user)
+	return user, nil
+}
+
+// sendSessionResumed 向恢复或接管会话的连接补发最近的会话消息，并附带新签发的重连令牌，原令牌已作废
+func (g *MessageGateway) sendSessionResumed(conn *websocket.Conn, user *customer_service.User) {
+	messages, err := g.service.GetMessages(user.SessionID, 0, resumeHistoryLimit)
+	if err != nil {
+		log.Printf("Error loading messages for resume of user %s: %v", user.ID, err)
+	}
+	g.writeJSON(conn, "session_resumed", map[string]interface{}{
+		"session_id":   user.SessionID,
+		"messages":     newMessageDTOs(messages),
+		"resume_token": g.issueResumeToken(user.ID),
+	})
+}
+
+// issueResumeToken 为用户签发重连令牌，签发失败时返回空串，客户端断线后只能作为新用户连接
+func (g *MessageGateway) issueResumeToken(userID string) string {
+	token, err := g.service.IssueResumeToken(userID)
+	if err != nil {
+		log.Printf("Error issuing resume token for user %s: %v", userID, err)
+		return ""
+	}
+	return token
+}
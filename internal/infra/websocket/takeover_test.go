@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"clash/internal/domain/customer_service"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageGateway_DeviceTakeover(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	oldConn, sessionID, token := connectResumableUser(t, gateway, server, staffConn)
+	defer oldConn.Close()
+	readTestMessageExcept(t, staffConn, "presence")
+	_, err := gateway.service.SendMessage(sessionID, "user1", "hello", customer_service.MessageTypeText)
+	assert.NoError(t, err)
+
+	// 没有令牌的新连接按重复连接拒绝，不影响旧设备
+	intruder := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer intruder.Close()
+	rejected := readTestMessageExcept(t, intruder, "presence")
+	assert.Equal(t, "error", rejected["type"])
+	assert.Equal(t, customer_service.CodeTooManyConnections, rejected["payload"].(map[string]interface{})["code"])
+	assert.Equal(t, sessionID, gateway.service.GetUser("user1").SessionID)
+
+	// 新设备凭令牌接管会话，旧设备收到kicked关闭帧
+	newConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1&resume_token="+token)
+	defer newConn.Close()
+	code, reason := readCloseReason(t, oldConn)
+	assert.Equal(t, websocket.ClosePolicyViolation, code)
+	assert.Equal(t, CloseReasonKicked, reason.Reason)
+
+	// 新设备收到会话消息补发
+	resumed := readTestMessage(t, newConn)
+	assert.Equal(t, "session_resumed", resumed["type"])
+	payload := resumed["payload"].(map[string]interface{})
+	assert.Equal(t, sessionID, payload["session_id"])
+	assert.NotEmpty(t, payload["resume_token"])
+	messages := payload["messages"].([]interface{})
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "hello", messages[0].(map[string]interface{})["content"])
+
+	// 旧连接断开后会话保持，客服的消息发到新设备
+	assert.Eventually(t, func() bool {
+		return gateway.service.ConnectionCount(customer_service.PresenceRoleUser, "user1") == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, sessionID, gateway.service.GetUser("user1").SessionID)
+	writeTestMessage(t, staffConn, "message", `{"session_id":"`+sessionID+`","content":"welcome back"}`)
+	reply := readTestMessageExcept(t, newConn, "session_status")
+	assert.Equal(t, "message", reply["type"])
+	assert.Equal(t, "welcome back", reply["payload"].(map[string]interface{})["content"])
+}
+
+// connectResumableUser 建立用户与客服的会话，返回用户连接、会话ID和下发给用户的重连令牌
+func connectResumableUser(t *testing.T, gateway *MessageGateway, server *httptest.Server, staffConn *websocket.Conn) (*websocket.Conn, string, string) {
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	assert.Eventually(t, func() bool {
+		return gateway.service.GetUser("user1") != nil
+	}, time.Second, 10*time.Millisecond)
+	writeTestMessage(t, staffConn, "connect_user", `{"user_id":"user1"}`)
+	created := readTestMessageExcept(t, userConn, "presence")
+	assert.Equal(t, "session_created", created["type"])
+	payload := created["payload"].(map[string]interface{})
+	token, _ := payload["resume_token"].(string)
+	assert.NotEmpty(t, token)
+	return userConn, payload["ID"].(string), token
+}
+
+// waitUserOffline 等待用户断线进入重连宽限期
+func waitUserOffline(t *testing.T, gateway *MessageGateway, userID string) {
+	assert.Eventually(t, func() bool {
+		user := gateway.service.GetUser(userID)
+		return user != nil && user.Status == customer_service.UserStatusOffline
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMessageGateway_ReconnectWithoutTokenStartsFresh(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithReconnectGrace(time.Minute)))
+	defer server.Close()
+	defer gateway.service.Shutdown()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn, sessionID, _ := connectResumableUser(t, gateway, server, staffConn)
+	readTestMessageExcept(t, staffConn, "presence")
+	userConn.Close()
+	waitUserOffline(t, gateway, "user1")
+
+	// 只知道用户ID的连接拿不到原会话，原会话随之关闭
+	newConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer newConn.Close()
+	assert.Eventually(t, func() bool {
+		user := gateway.service.GetUser("user1")
+		return user != nil && user.Status == customer_service.UserStatusOnline
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, gateway.service.GetUser("user1").SessionID)
+	assert.Equal(t, customer_service.SessionStatusClosed, gateway.service.GetSession(sessionID).Status)
+	assert.Equal(t, "session_closed", readTestMessageExcept(t, staffConn, "presence", "session_status")["type"])
+}
+
+func TestMessageGateway_ReconnectWithResumeToken(t *testing.T) {
+	gateway, server := newTestGateway(t, WithServiceOptions(customer_service.WithReconnectGrace(time.Minute)))
+	defer server.Close()
+	defer gateway.service.Shutdown()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	staffConn := dialTestGateway(t, server, "/staff?staff_id=staff1&name=客服1&group_id=group1")
+	defer staffConn.Close()
+	userConn, sessionID, token := connectResumableUser(t, gateway, server, staffConn)
+	userConn.Close()
+	waitUserOffline(t, gateway, "user1")
+
+	// 凭令牌恢复原会话，并收到新的令牌
+	resumedConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1&resume_token="+token)
+	resumed := readTestMessageExcept(t, resumedConn, "presence")
+	assert.Equal(t, "session_resumed", resumed["type"])
+	payload := resumed["payload"].(map[string]interface{})
+	assert.Equal(t, sessionID, payload["session_id"])
+	next, _ := payload["resume_token"].(string)
+	assert.NotEmpty(t, next)
+	assert.NotEqual(t, token, next)
+	resumedConn.Close()
+	waitUserOffline(t, gateway, "user1")
+
+	// 用过的令牌不能再次使用
+	replayConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1&resume_token="+token)
+	defer replayConn.Close()
+	reply := readTestMessageExcept(t, replayConn, "presence")
+	assert.Equal(t, "error", reply["type"])
+	assert.Equal(t, customer_service.CodeInvalidResumeToken, reply["payload"].(map[string]interface{})["code"])
+	assert.Equal(t, customer_service.SessionStatusActive, gateway.service.GetSession(sessionID).Status)
+}
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// JSON值类型
+const (
+	kindString = "string"
+	kindNumber = "number"
+	kindBool   = "bool"
+	kindArray  = "array"
+	kindObject = "object"
+)
+
+// payloadSchemas 常用消息类型负载中各字段的JSON类型，解析前逐一校验以指出出错的字段。
+// 未列出的类型和字段由解析时的类型检查兜底，同名消息在用户、客服和主管连接上的字段类型一致
+var payloadSchemas = map[string]map[string]string{
+	"message": {
+		"session_id":     kindString,
+		"to_id":          kindString,
+		"client_msg_id":  kindString,
+		"client_sent_at": kindString,
+		"content":        kindString,
+	},
+	"messages":         {"session_id": kindString, "contents": kindArray},
+	"enqueue":          {"group_id": kindString},
+	"request_session":  {"group_id": kindString, "subject": kindString},
+	"user_ready":       {"group_id": kindString, "fields": kindObject},
+	"typing":           {"session_id": kindString, "draft": kindString},
+	"accept_offer":     {"offer_id": kindString},
+	"decline_offer":    {"offer_id": kindString},
+	"recall_message":   {"session_id": kindString, "message_id": kindString},
+	"close_session":    {"session_id": kindString},
+	"reopen_session":   {"session_id": kindString},
+	"transfer_session": {"session_id": kindString, "new_staff_id": kindString},
+	"add_note":         {"session_id": kindString, "content": kindString},
+
+	"notification_pref": {"event_type": kindString, "on": kindBool},
+}
+
+// validatePayload 按消息类型校验负载字段的JSON类型，null视为未填写
+func validatePayload(msgType string, payload json.RawMessage) error {
+	schema, exists := payloadSchemas[msgType]
+	if !exists || len(payload) == 0 || bytes.Equal(payload, []byte("null")) {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return newValidationError("payload", kindObject)
+	}
+	for name, want := range schema {
+		value, exists := fields[name]
+		if !exists {
+			continue
+		}
+		if got := jsonKind(value); got != "" && got != want {
+			return newValidationError(name, want)
+		}
+	}
+	return nil
+}
+
+// jsonKind 返回JSON值的类型，null返回空
+func jsonKind(value json.RawMessage) string {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return ""
+	}
+	switch value[0] {
+	case '"':
+		return kindString
+	case '{':
+		return kindObject
+	case '[':
+		return kindArray
+	case 't', 'f':
+		return kindBool
+	case 'n':
+		return ""
+	default:
+		return kindNumber
+	}
+}
+
+// goKind 返回Go类型对应的JSON值类型
+func goKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return kindString
+	case reflect.Bool:
+		return kindBool
+	case reflect.Slice, reflect.Array:
+		return kindArray
+	case reflect.Map, reflect.Struct, reflect.Interface:
+		return kindObject
+	default:
+		return kindNumber
+	}
+}
+
+// newValidationError 创建字段类型错误，field为空时指整个负载
+func newValidationError(field, want string) *DecodeError {
+	if field == "" {
+		field = "payload"
+	}
+	return &DecodeError{
+		Code:    ErrCodeValidation,
+		Message: fmt.Sprintf("field %s must be %s", field, want),
+		Field:   field,
+	}
+}
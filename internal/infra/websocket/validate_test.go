@@ -0,0 +1,53 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageGateway_ValidationError(t *testing.T) {
+	gateway, server := newTestGateway(t)
+	defer server.Close()
+
+	gateway.service.CreateGroup("group1", "测试客服组")
+	userConn := dialTestGateway(t, server, "/user?user_id=user1&name=用户1")
+	defer userConn.Close()
+
+	writeTestMessage(t, userConn, "message", `{"content":123}`)
+	response := readTestMessage(t, userConn)
+	assert.Equal(t, "error", response["type"])
+	payload := response["payload"].(map[string]interface{})
+	assert.Equal(t, ErrCodeValidation, payload["code"])
+	assert.Equal(t, "content", payload["field"])
+	assert.Equal(t, "field content must be string", payload["message"])
+
+	// 未列入校验表的字段由解析时的类型检查兜底
+	writeTestMessage(t, userConn, "leave_message", `{"subject":"hi","body":["x"],"contact":"a@b.c"}`)
+	response = readTestMessage(t, userConn)
+	payload = response["payload"].(map[string]interface{})
+	assert.Equal(t, ErrCodeValidation, payload["code"])
+	assert.Equal(t, "body", payload["field"])
+}
+
+func TestValidatePayload(t *testing.T) {
+	assertField := func(err error, field string) {
+		t.Helper()
+		if decodeErr, ok := err.(*DecodeError); assert.True(t, ok) {
+			assert.Equal(t, ErrCodeValidation, decodeErr.Code)
+			assert.Equal(t, field, decodeErr.Field)
+		}
+	}
+
+	assert.NoError(t, validatePayload("message", json.RawMessage(`{"content":"hi","client_msg_id":null}`)))
+	assert.NoError(t, validatePayload("message", nil))
+	assert.NoError(t, validatePayload("unknown_type", json.RawMessage(`{"content":1}`)))
+	assertField(validatePayload("messages", json.RawMessage(`{"contents":"hi"}`)), "contents")
+	assertField(validatePayload("accept_offer", json.RawMessage(`{"offer_id":true}`)), "offer_id")
+	assertField(validatePayload("enqueue", json.RawMessage(`"group1"`)), "payload")
+
+	// 顶层字段类型错误
+	_, err := NewMessageGateway().decodeMessage([]byte(`{"type":1,"payload":{}}`))
+	assertField(err, "type")
+}